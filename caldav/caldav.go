@@ -0,0 +1,174 @@
+// Package caldav serves and accesses calendars over CalDAV, the WebDAV
+// extension of RFC 4791.
+//
+// Handler is an http.Handler that keeps calendar collections and their
+// calendar object resources in a Storage, such as the one returned by
+// NewMemoryStorage.  Client finds, reads, synchronizes and writes the
+// calendars of a server.  A Scheduler adds the implicit scheduling of
+// RFC 6638 to the handlers of its users.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/mmsuo/vcalender/objects"
+	"path"
+	"strings"
+	"time"
+)
+
+//   RFC 4791 4.2.  Calendar Object Resources
+//
+//      Calendar object resources contained in calendar collections MUST
+//      NOT contain more than one type of calendar component (e.g.,
+//      VEVENT, VTODO, VJOURNAL, VFREEBUSY, etc.) with the exception of
+//      VTIMEZONE components, which MUST be specified for each unique
+//      TZID parameter value specified in the iCalendar object.  For
+//      instance, a calendar object resource can contain one VEVENT
+//      component and one VTIMEZONE component, but it cannot contain one
+//      VEVENT component and one VTODO component.  Instead, the VEVENT
+//      and VTODO components would have to be stored in separate calendar
+//      object resources in the same collection.
+//
+//      Calendar object resources contained in calendar collections MUST
+//      NOT specify the iCalendar METHOD property.
+//
+//      The UID property value of the calendar components contained in a
+//      calendar object resource MUST be unique in the scope of the
+//      calendar collection in which they are stored.
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+)
+
+// ErrNotFound is returned by a Storage for paths that have no calendar
+// collection or calendar object resource.
+var ErrNotFound = errors.New("caldav: not found")
+
+// ErrExists is returned by Storage.CreateCalendar for paths that are
+// already taken.
+var ErrExists = errors.New("caldav: already exists")
+
+// ErrModified is returned by Storage.PutObject when the resource does not
+// have the ETag it was expected to have.
+var ErrModified = errors.New("caldav: modified")
+
+// AnyETag is the ETag given to Storage.PutObject to write a resource
+// whether or not it exists.
+const AnyETag = "*"
+
+// Collection is a calendar collection.
+type Collection struct {
+	// Path is the absolute path of the collection, ending in a slash.
+	Path        string
+	DisplayName string
+	Description string
+	// Components lists the names of the components the collection takes,
+	// such as VEVENT and VTODO.  An empty list takes all.
+	Components []string
+}
+
+// Supports reports whether the collection takes components of the given
+// name.
+func (c *Collection) Supports(name string) bool {
+	if len(c.Components) == 0 {
+		return true
+	}
+	for _, n := range c.Components {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Object is a calendar object resource: the iCalendar text of one
+// component, with its overrides and time zones.
+type Object struct {
+	// Path is the absolute path of the resource in its collection.
+	Path string
+	// ETag is the entity tag of Data, without quotes.
+	ETag    string
+	ModTime time.Time
+	Data    []byte
+}
+
+// Storage keeps calendar collections and their calendar object
+// resources.  Implementations must be safe for concurrent use.
+type Storage interface {
+	// Calendars returns the calendar collections in the collection home.
+	Calendars(ctx context.Context, home string) ([]*Collection, error)
+	// Calendar returns the calendar collection at path, or ErrNotFound.
+	Calendar(ctx context.Context, path string) (*Collection, error)
+	// CreateCalendar adds c, or returns ErrExists.
+	CreateCalendar(ctx context.Context, c *Collection) error
+	// DeleteCalendar removes the calendar collection at path with its
+	// resources, or returns ErrNotFound.
+	DeleteCalendar(ctx context.Context, path string) error
+	// Objects returns the resources of the calendar collection at path.
+	Objects(ctx context.Context, path string) ([]*Object, error)
+	// Object returns the resource at path, or ErrNotFound.
+	Object(ctx context.Context, path string) (*Object, error)
+	// ObjectByUID returns the resource of the calendar collection at
+	// path whose components have the given UID, or ErrNotFound.
+	ObjectByUID(ctx context.Context, path, uid string) (*Object, error)
+	// PutObject creates or replaces the resource at path, whose
+	// collection exists, and returns it with its new ETag.  The check
+	// and the write are atomic: the resource is only written when its
+	// ETag is ifMatch, or when it is missing and ifMatch is empty, and
+	// ErrModified is returned otherwise.  AnyETag writes it in any case.
+	PutObject(ctx context.Context, path string, data []byte, ifMatch string) (*Object, error)
+	// DeleteObject removes the resource at path, or returns ErrNotFound.
+	DeleteObject(ctx context.Context, path string) error
+}
+
+// ErrInvalidSyncToken is returned by SyncStorage.Changes for tokens it
+// did not hand out or no longer keeps changes for.
+var ErrInvalidSyncToken = errors.New("caldav: invalid sync token")
+
+// SyncStorage is a Storage that tracks the changes of its calendar
+// collections, which lets Handler answer sync-collection reports of
+// RFC 6578.
+type SyncStorage interface {
+	Storage
+	// SyncToken returns the current sync token of the calendar collection
+	// at path.
+	SyncToken(ctx context.Context, path string) (string, error)
+	// Changes returns the resources of the calendar collection at path
+	// that were created or changed since the sync token was handed out,
+	// the paths of those removed since, and the current token.  The
+	// empty token asks for all resources.
+	Changes(ctx context.Context, path, token string) (changed []*Object, removed []string, newToken string, err error)
+}
+
+// collectionPath returns the clean form of the path of a collection,
+// ending in a slash.
+func collectionPath(p string) string {
+	p = path.Clean("/" + p)
+	if p != "/" {
+		p += "/"
+	}
+	return p
+}
+
+// parent returns the path of the collection holding the resource at p.
+func parent(p string) string {
+	return collectionPath(path.Dir(strings.TrimSuffix(p, "/")))
+}
+
+// objectUID returns the UID of the components of the calendar object
+// resource data, or the empty string.
+func objectUID(data []byte) string {
+	raw, err := objects.ReadRawComponents(bytes.NewReader(data))
+	if err != nil || len(raw) != 1 {
+		return ""
+	}
+	for _, c := range raw[0].Components {
+		if l := c.Property("UID"); c.Name != "VTIMEZONE" && l != nil {
+			return l.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,529 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//   RFC 6764 5.  Locating Services
+//
+//      Clients bootstrap from a host name by requesting the well-known
+//      URI "/.well-known/caldav", which servers redirect to the "context
+//      path" of their CalDAV service.  From there the client finds the
+//      URL of the principal of the user with the DAV:current-user-
+//      principal property [RFC5397], and the calendar home of the
+//      principal with the CALDAV:calendar-home-set property.
+
+// ErrPreconditionFailed is returned when a resource changed on the server
+// since it was read, or already exists when it was to be created.
+var ErrPreconditionFailed = errors.New("caldav: precondition failed")
+
+// Error is an unexpected response of a server.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Precondition is the name of the precondition named by a DAV:error
+	// body, if any.
+	Precondition string
+}
+
+func (e *Error) Error() string {
+	s := fmt.Sprintf("caldav: %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Precondition != "" {
+		s += " (" + e.Precondition + ")"
+	}
+	return s
+}
+
+// Client accesses the calendars of a CalDAV server.
+//
+// The ETags it returns and takes are opaque: they are the entity tags as
+// the server sent them, quotes and weakness included, and are sent back
+// as they are.
+type Client struct {
+	// HTTP sends the requests, http.DefaultClient when nil.
+	HTTP *http.Client
+	// Endpoint is the URL of the server, paths are resolved against it.
+	Endpoint *url.URL
+	// Username and Password are sent with basic authentication when
+	// Username is set.
+	Username string
+	Password string
+}
+
+// NewClient returns a Client for the server at endpoint.
+func NewClient(endpoint string, hc *http.Client) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return &Client{HTTP: hc, Endpoint: u}, nil
+}
+
+// CalendarObject is a calendar object resource read from a server.  Its
+// ETag is the opaque entity tag the server sent.
+type CalendarObject struct {
+	Object
+	Calendar *objects.Calendar
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) url(p string) string {
+	return c.Endpoint.ResolveReference(&url.URL{Path: p}).String()
+}
+
+// do sends a request with an XML or iCalendar body and returns the
+// response, whose body the caller closes.
+func (c *Client) do(ctx context.Context, hc *http.Client, method, p string, body []byte, header map[string]string) (*http.Response, error) {
+	return c.doURL(ctx, hc, method, c.url(p), body, header)
+}
+
+// doURL is do for the absolute URL u.
+func (c *Client) doURL(ctx context.Context, hc *http.Client, method, u string, body []byte, header map[string]string) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return hc.Do(req)
+}
+
+// responseError returns the Error of an unexpected response, and
+// ErrPreconditionFailed for the status 412.
+func responseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return ErrPreconditionFailed
+	}
+	e := &Error{Method: resp.Request.Method, Path: resp.Request.URL.Path, StatusCode: resp.StatusCode}
+	if n, err := readNode(resp.Body); err == nil && n != nil && n.XMLName == (xml.Name{Space: nsDAV, Local: "error"}) && len(n.Nodes) > 0 {
+		e.Precondition = n.Nodes[0].XMLName.Local
+	}
+	return e
+}
+
+// msResponse is a DAV:response of a multistatus body.
+type msResponse struct {
+	path   string
+	status int
+	// props found, by name
+	props map[xml.Name]*node
+}
+
+func statusCode(s string) int {
+	f := strings.Fields(s)
+	if len(f) < 2 {
+		return 0
+	}
+	code, _ := strconv.Atoi(f[1])
+	return code
+}
+
+// request sends an XML request and reads the multistatus it answers
+// with, returning its responses and DAV:sync-token.
+func (c *Client) request(ctx context.Context, method, p, depth string, body *element) ([]*msResponse, string, error) {
+	header := map[string]string{"Content-Type": "application/xml; charset=utf-8"}
+	if depth != "" {
+		header["Depth"] = depth
+	}
+	resp, err := c.do(ctx, c.httpClient(), method, p, []byte(body.String()), header)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, "", responseError(resp)
+	}
+	n, err := readNode(resp.Body)
+	if err != nil || n == nil || n.XMLName != (xml.Name{Space: nsDAV, Local: "multistatus"}) {
+		return nil, "", fmt.Errorf("caldav: %s %s: invalid multistatus body", method, p)
+	}
+	var responses []*msResponse
+	for _, rn := range n.children(nsDAV, "response") {
+		h := rn.child(nsDAV, "href")
+		if h == nil {
+			continue
+		}
+		hp, err := hrefPath(h.Text)
+		if err != nil {
+			return nil, "", err
+		}
+		r := &msResponse{path: hp, status: http.StatusOK, props: map[xml.Name]*node{}}
+		if s := rn.child(nsDAV, "status"); s != nil {
+			r.status = statusCode(s.Text)
+		}
+		for _, ps := range rn.children(nsDAV, "propstat") {
+			s, prop := ps.child(nsDAV, "status"), ps.child(nsDAV, "prop")
+			if s == nil || prop == nil || statusCode(s.Text)/100 != 2 {
+				continue
+			}
+			for i := range prop.Nodes {
+				r.props[prop.Nodes[i].XMLName] = &prop.Nodes[i]
+			}
+		}
+		responses = append(responses, r)
+	}
+	token := ""
+	if t := n.child(nsDAV, "sync-token"); t != nil {
+		token = strings.TrimSpace(t.Text)
+	}
+	return responses, token, nil
+}
+
+func propfind(names ...*element) *element {
+	return newElement(nsDAV, "propfind", newElement(nsDAV, "prop", names...))
+}
+
+// hrefProp returns the path of the href in the property name of the
+// response for p.
+func hrefProp(responses []*msResponse, p string, name xml.Name) (string, bool) {
+	for _, r := range responses {
+		if r.path != path.Clean(p) && collectionPath(r.path) != collectionPath(p) {
+			continue
+		}
+		if prop, ok := r.props[name]; ok {
+			if h := prop.child(nsDAV, "href"); h != nil {
+				hp, err := hrefPath(h.Text)
+				if err == nil {
+					return hp, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// FindPrincipal returns the path of the principal of the user.  It asks
+// the endpoint and, when that does not tell, the well-known URI of
+// RFC 6764.
+func (c *Client) FindPrincipal(ctx context.Context) (string, error) {
+	name := xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	body := propfind(newElement(nsDAV, name.Local))
+	responses, _, err := c.request(ctx, "PROPFIND", c.Endpoint.Path, "0", body)
+	if err == nil {
+		if p, ok := hrefProp(responses, c.Endpoint.Path, name); ok {
+			return p, nil
+		}
+	}
+	ctxPath, werr := c.wellKnown(ctx)
+	if werr != nil {
+		if err == nil {
+			err = werr
+		}
+		return "", err
+	}
+	responses, _, err = c.request(ctx, "PROPFIND", ctxPath, "0", body)
+	if err != nil {
+		return "", err
+	}
+	if p, ok := hrefProp(responses, ctxPath, name); ok {
+		return p, nil
+	}
+	return "", fmt.Errorf("caldav: no current-user-principal")
+}
+
+// wellKnown returns the path /.well-known/caldav redirects to, and makes
+// the URL it redirects to the Endpoint, as hosted services often redirect
+// to another host.  The redirect is followed by hand, since clients
+// would follow it with a GET.
+func (c *Client) wellKnown(ctx context.Context) (string, error) {
+	hc := *c.httpClient()
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	u, err := url.Parse(c.url("/.well-known/caldav"))
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < 10; i++ {
+		resp, err := c.doURL(ctx, &hc, "PROPFIND", u.String(), []byte(propfind(newElement(nsDAV, "current-user-principal")).String()), map[string]string{"Depth": "0"})
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			if u, err = resp.Location(); err != nil {
+				return "", err
+			}
+		case http.StatusMultiStatus:
+			c.Endpoint = u
+			return u.Path, nil
+		default:
+			return "", responseError(resp)
+		}
+	}
+	return "", fmt.Errorf("caldav: too many redirects from /.well-known/caldav")
+}
+
+// FindCalendarHome returns the path of the calendar home of a principal.
+func (c *Client) FindCalendarHome(ctx context.Context, principal string) (string, error) {
+	name := xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	responses, _, err := c.request(ctx, "PROPFIND", principal, "0", propfind(newElement(nsCalDAV, name.Local)))
+	if err != nil {
+		return "", err
+	}
+	if p, ok := hrefProp(responses, principal, name); ok {
+		return collectionPath(p), nil
+	}
+	return "", fmt.Errorf("caldav: no calendar-home-set for %s", principal)
+}
+
+// FindCalendars returns the calendar collections in home.
+func (c *Client) FindCalendars(ctx context.Context, home string) ([]*Collection, error) {
+	responses, _, err := c.request(ctx, "PROPFIND", home, "1", propfind(
+		newElement(nsDAV, "resourcetype"),
+		newElement(nsDAV, "displayname"),
+		newElement(nsCalDAV, "calendar-description"),
+		newElement(nsCalDAV, "supported-calendar-component-set")))
+	if err != nil {
+		return nil, err
+	}
+	var cs []*Collection
+	for _, r := range responses {
+		rt, ok := r.props[xml.Name{Space: nsDAV, Local: "resourcetype"}]
+		if !ok || rt.child(nsCalDAV, "calendar") == nil {
+			continue
+		}
+		coll := &Collection{Path: collectionPath(r.path)}
+		if d, ok := r.props[xml.Name{Space: nsDAV, Local: "displayname"}]; ok {
+			coll.DisplayName = d.Text
+		}
+		if d, ok := r.props[xml.Name{Space: nsCalDAV, Local: "calendar-description"}]; ok {
+			coll.Description = d.Text
+		}
+		if s, ok := r.props[xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}]; ok {
+			for _, comp := range s.children(nsCalDAV, "comp") {
+				if name, ok := comp.attr("name"); ok {
+					coll.Components = append(coll.Components, strings.ToUpper(name))
+				}
+			}
+		}
+		cs = append(cs, coll)
+	}
+	return cs, nil
+}
+
+// objectsOf returns the calendar object resources of responses that hold
+// calendar data.  Those without are returned by path.
+func objectsOf(responses []*msResponse) ([]*CalendarObject, []string, error) {
+	var objs []*CalendarObject
+	var missing []string
+	for _, r := range responses {
+		if r.status/100 != 2 {
+			continue
+		}
+		data, ok := r.props[xml.Name{Space: nsCalDAV, Local: "calendar-data"}]
+		if !ok {
+			missing = append(missing, r.path)
+			continue
+		}
+		o := &CalendarObject{Object: Object{Path: r.path, Data: []byte(data.Text)}}
+		if e, ok := r.props[xml.Name{Space: nsDAV, Local: "getetag"}]; ok {
+			o.ETag = strings.TrimSpace(e.Text)
+		}
+		cal, err := objects.Decode(bytes.NewReader(o.Data))
+		if cal == nil {
+			return nil, nil, fmt.Errorf("caldav: %s: %v", r.path, err)
+		}
+		// what could be read is kept, as servers store what clients
+		// send them
+		o.Calendar = cal
+		objs = append(objs, o)
+	}
+	return objs, missing, nil
+}
+
+func dataProps() *element {
+	return newElement(nsDAV, "prop", newElement(nsDAV, "getetag"), newElement(nsCalDAV, "calendar-data"))
+}
+
+// Query returns the calendar object resources of the calendar
+// collection at p that match f, the comp-filter of their VCALENDAR.
+func (c *Client) Query(ctx context.Context, p string, f *CompFilter) ([]*CalendarObject, error) {
+	body := newElement(nsCalDAV, "calendar-query", dataProps(), newElement(nsCalDAV, "filter", compFilterElement(f)))
+	responses, _, err := c.request(ctx, "REPORT", p, "1", body)
+	if err != nil {
+		return nil, err
+	}
+	objs, _, err := objectsOf(responses)
+	return objs, err
+}
+
+// QueryTimeRange returns the calendar object resources of the calendar
+// collection at p with components of the given name, such as VEVENT,
+// that have an instance in [start, end).
+func (c *Client) QueryTimeRange(ctx context.Context, p, comp string, start, end time.Time) ([]*CalendarObject, error) {
+	return c.Query(ctx, p, &CompFilter{Name: "VCALENDAR", Comps: []*CompFilter{
+		{Name: strings.ToUpper(comp), TimeRange: &TimeRange{Start: start, End: end}},
+	}})
+}
+
+// MultiGet returns the calendar object resources at paths in the
+// calendar collection at p.  Resources that do not exist are left out.
+func (c *Client) MultiGet(ctx context.Context, p string, paths []string) ([]*CalendarObject, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	body := newElement(nsCalDAV, "calendar-multiget", dataProps())
+	for _, hp := range paths {
+		body.children = append(body.children, href(hp))
+	}
+	responses, _, err := c.request(ctx, "REPORT", p, "1", body)
+	if err != nil {
+		return nil, err
+	}
+	objs, _, err := objectsOf(responses)
+	return objs, err
+}
+
+// SyncResult is the outcome of a sync-collection report.
+type SyncResult struct {
+	// Token is the sync token to pass to the next Sync.
+	Token string
+	// Changed holds the resources created or changed since the token
+	// passed to Sync, or all resources for the empty token.
+	Changed []*CalendarObject
+	// Removed holds the paths of the resources removed since.
+	Removed []string
+}
+
+//   RFC 6578 3.8.  Example: Initial DAV:sync-collection Report
+//
+//      If the client has no sync token, it sends an empty DAV:sync-token
+//      element and gets all members of the collection.  It keeps the
+//      DAV:sync-token of the response for the next request, which only
+//      reports the members changed or removed in between.
+
+// Sync returns the changes of the calendar collection at p since token,
+// which is empty for the first Sync.  When the server no longer accepts
+// the token, the error is an *Error with the Precondition
+// "valid-sync-token" and the client starts again with the empty token.
+func (c *Client) Sync(ctx context.Context, p, token string) (*SyncResult, error) {
+	body := newElement(nsDAV, "sync-collection",
+		textElement(nsDAV, "sync-token", token),
+		textElement(nsDAV, "sync-level", "1"),
+		dataProps())
+	responses, newToken, err := c.request(ctx, "REPORT", p, "", body)
+	if err != nil {
+		return nil, err
+	}
+	res := &SyncResult{Token: newToken}
+	for _, r := range responses {
+		if r.status == http.StatusNotFound {
+			res.Removed = append(res.Removed, r.path)
+		}
+	}
+	changed, missing, err := objectsOf(responses)
+	if err != nil {
+		return nil, err
+	}
+	// servers may leave calendar data out of sync reports
+	fetched, err := c.MultiGet(ctx, p, missing)
+	if err != nil {
+		return nil, err
+	}
+	res.Changed = append(changed, fetched...)
+	return res, nil
+}
+
+// Get returns the calendar object resource at p.
+func (c *Client) Get(ctx context.Context, p string) (*CalendarObject, error) {
+	resp, err := c.do(ctx, c.httpClient(), http.MethodGet, p, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	o := &CalendarObject{Object: Object{Path: path.Clean(p), Data: data, ETag: resp.Header.Get("ETag")}}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		o.ModTime = t
+	}
+	cal, err := objects.Decode(bytes.NewReader(data))
+	if cal == nil {
+		return nil, fmt.Errorf("caldav: %s: %v", p, err)
+	}
+	o.Calendar = cal
+	return o, nil
+}
+
+// Put stores cal at p and returns its new ETag, which is empty when the
+// server changed the data it stores.  With the empty etag the resource
+// must not exist yet; otherwise it must still have the given ETag.
+// ErrPreconditionFailed is returned when these do not hold.
+func (c *Client) Put(ctx context.Context, p string, cal *objects.Calendar, etag string) (string, error) {
+	text, err := cal.Calendar()
+	if err != nil {
+		return "", err
+	}
+	header := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	if etag == "" {
+		header["If-None-Match"] = "*"
+	} else {
+		header["If-Match"] = etag
+	}
+	resp, err := c.do(ctx, c.httpClient(), http.MethodPut, p, []byte(objects.Fold(text)), header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", responseError(resp)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// Delete removes the resource at p, when it still has the given ETag
+// unless etag is empty.
+func (c *Client) Delete(ctx context.Context, p, etag string) error {
+	var header map[string]string
+	if etag != "" {
+		header = map[string]string{"If-Match": etag}
+	}
+	resp, err := c.do(ctx, c.httpClient(), http.MethodDelete, p, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return responseError(resp)
+	}
+	return nil
+}
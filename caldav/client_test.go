@@ -0,0 +1,186 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decode(t *testing.T, s string) *objects.Calendar {
+	t.Helper()
+	cal, err := objects.Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	handler := NewHandler(storage)
+	// the server only answers on the well-known URI and below its paths
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer s.Close()
+	if err := storage.CreateCalendar(ctx, &Collection{Path: "/calendars/work/", DisplayName: "Work", Components: []string{"VEVENT"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClient(s.URL, s.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := c.FindPrincipal(ctx)
+	if err != nil || principal != "/principals/user/" {
+		t.Fatalf("FindPrincipal: %q %v", principal, err)
+	}
+	home, err := c.FindCalendarHome(ctx, principal)
+	if err != nil || home != "/calendars/" {
+		t.Fatalf("FindCalendarHome: %q %v", home, err)
+	}
+	cals, err := c.FindCalendars(ctx, home)
+	if err != nil || len(cals) != 1 || cals[0].Path != "/calendars/work/" || cals[0].DisplayName != "Work" ||
+		len(cals[0].Components) != 1 || cals[0].Components[0] != "VEVENT" {
+		t.Fatalf("FindCalendars: %+v %v", cals, err)
+	}
+
+	sync, err := c.Sync(ctx, "/calendars/work/", "")
+	if err != nil || len(sync.Changed) != 0 || sync.Token == "" {
+		t.Fatalf("first Sync: %+v %v", sync, err)
+	}
+
+	etag, err := c.Put(ctx, "/calendars/work/weekly.ics", decode(t, weekly), "")
+	if err != nil || etag == "" {
+		t.Fatalf("Put: %q %v", etag, err)
+	}
+	if _, err := c.Put(ctx, "/calendars/work/weekly.ics", decode(t, weekly), ""); err != ErrPreconditionFailed {
+		t.Errorf("Put of an existing resource: %v", err)
+	}
+	if _, err := c.Put(ctx, "/calendars/work/once.ics", decode(t, once), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	objs, err := c.QueryTimeRange(ctx, "/calendars/work/", "VEVENT",
+		time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 13, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(objs) != 1 || objs[0].Path != "/calendars/work/weekly.ics" || objs[0].ETag != etag {
+		t.Fatalf("QueryTimeRange: %+v %v", objs, err)
+	}
+	if objs[0].Calendar == nil || len(objs[0].Calendar.Components) != 1 {
+		t.Fatalf("QueryTimeRange returned no calendar")
+	}
+
+	next, err := c.Sync(ctx, "/calendars/work/", sync.Token)
+	if err != nil || len(next.Changed) != 2 || len(next.Removed) != 0 || next.Token == sync.Token {
+		t.Fatalf("second Sync: %+v %v", next, err)
+	}
+
+	// update with the ETag, then with the old one
+	cal := objs[0].Calendar
+	cal.Components[0].(*components.Event).Summary = descriptive.NewSummary("Team sync")
+	newETag, err := c.Put(ctx, "/calendars/work/weekly.ics", cal, etag)
+	if err != nil || newETag == "" || newETag == etag {
+		t.Fatalf("Put with ETag: %q %v", newETag, err)
+	}
+	if _, err := c.Put(ctx, "/calendars/work/weekly.ics", cal, etag); err != ErrPreconditionFailed {
+		t.Errorf("Put with an old ETag: %v", err)
+	}
+	if err := c.Delete(ctx, "/calendars/work/once.ics", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := c.Sync(ctx, "/calendars/work/", next.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last.Changed) != 1 || last.Changed[0].Path != "/calendars/work/weekly.ics" || last.Changed[0].ETag != newETag {
+		t.Errorf("third Sync changed %+v", last.Changed)
+	}
+	if len(last.Removed) != 1 || last.Removed[0] != "/calendars/work/once.ics" {
+		t.Errorf("third Sync removed %v", last.Removed)
+	}
+
+	_, err = c.Sync(ctx, "/calendars/work/", "urn:x-vcalender:sync:999")
+	if e, ok := err.(*Error); !ok || e.Precondition != "valid-sync-token" {
+		t.Errorf("Sync with an invalid token: %v", err)
+	}
+
+	o, err := c.Get(ctx, "/calendars/work/weekly.ics")
+	if err != nil || o.ETag != newETag || !bytes.Contains(o.Data, []byte("UID:weekly@example.com")) {
+		t.Errorf("Get: %+v %v", o, err)
+	}
+}
+
+func TestClient_WellKnownOtherHost(t *testing.T) {
+	ctx := context.Background()
+	s := httptest.NewServer(NewHandler(NewMemoryStorage()))
+	defer s.Close()
+	// the host the client starts from only redirects
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/caldav" {
+			http.Redirect(w, r, s.URL+"/principals/user/", http.StatusMovedPermanently)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer start.Close()
+
+	c, err := NewClient(start.URL, s.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := c.FindPrincipal(ctx)
+	if err != nil || principal != "/principals/user/" {
+		t.Fatalf("FindPrincipal: %q %v", principal, err)
+	}
+	if c.Endpoint.Host != strings.TrimPrefix(s.URL, "http://") {
+		t.Errorf("Endpoint: %s", c.Endpoint)
+	}
+	if home, err := c.FindCalendarHome(ctx, principal); err != nil || home != "/calendars/" {
+		t.Errorf("FindCalendarHome: %q %v", home, err)
+	}
+}
+
+func TestClient_WeakETag(t *testing.T) {
+	ctx := context.Background()
+	const etag = `W/"1"`
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Header().Set("ETag", etag)
+			w.Write([]byte(weekly))
+		case r.Header.Get("If-Match") != etag:
+			w.WriteHeader(http.StatusPreconditionFailed)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s.Close()
+
+	c, err := NewClient(s.URL, s.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := c.Get(ctx, "/weekly.ics")
+	if err != nil || o.ETag != etag {
+		t.Fatalf("Get: %+v %v", o, err)
+	}
+	if _, err := c.Put(ctx, "/weekly.ics", o.Calendar, o.ETag); err != nil {
+		t.Errorf("Put: %v", err)
+	}
+	if err := c.Delete(ctx, "/weekly.ics", o.ETag); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
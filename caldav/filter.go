@@ -0,0 +1,251 @@
+package caldav
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/filter"
+	"strings"
+	"time"
+)
+
+//   RFC 4791 9.7.  CALDAV:filter XML Element
+//
+//      The "filter" element specifies the search filter used to limit the
+//      calendar components returned by a calendar-query REPORT request.
+//
+//       <!ELEMENT filter (comp-filter)>
+//
+//       <!ELEMENT comp-filter (is-not-defined | (time-range?,
+//                              prop-filter*, comp-filter*))>
+//
+//       <!ELEMENT prop-filter (is-not-defined |
+//                              ((time-range | text-match)?,
+//                               param-filter*))>
+//
+//       <!ELEMENT param-filter (is-not-defined | text-match?)>
+//
+//       <!ELEMENT text-match (#PCDATA)>
+//       <!ATTLIST text-match collation        CDATA "i;ascii-casemap"
+//                            negate-condition (yes | no) "no">
+//
+//       <!ELEMENT time-range EMPTY>
+//       <!ATTLIST time-range start CDATA #IMPLIED
+//                            end   CDATA #IMPLIED>
+
+// The filters of calendar-query REPORTs are those of the filter
+// package, which follows these elements one to one.
+type (
+	CompFilter  = filter.CompFilter
+	PropFilter  = filter.PropFilter
+	ParamFilter = filter.ParamFilter
+	TextMatch   = filter.TextMatch
+	TimeRange   = filter.TimeRange
+)
+
+// collations of RFC 4790 that text matches support
+const (
+	CollationASCIICasemap = filter.CollationASCIICasemap
+	CollationOctet        = filter.CollationOctet
+	CollationASCIINumeric = filter.CollationASCIINumeric
+)
+
+const timeRangeFormat = "20060102T150405Z"
+
+func parseTimeRange(n *node) (*TimeRange, error) {
+	r := &TimeRange{}
+	for _, a := range []struct {
+		name string
+		t    *time.Time
+	}{{"start", &r.Start}, {"end", &r.End}} {
+		if v, ok := n.attr(a.name); ok {
+			t, err := time.Parse(timeRangeFormat, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time-range %s %q", a.name, v)
+			}
+			*a.t = t
+		}
+	}
+	if r.Start.IsZero() && r.End.IsZero() {
+		return nil, fmt.Errorf("time-range without start and end")
+	}
+	return r, nil
+}
+
+func parseTextMatch(n *node) (*TextMatch, error) {
+	t := &TextMatch{Text: n.Text, Collation: CollationASCIICasemap}
+	if c, ok := n.attr("collation"); ok {
+		t.Collation = c
+	}
+	// match-type as CardDAV has it
+	if m, ok := n.attr("match-type"); ok && m != filter.Contains {
+		t.MatchType = m
+	}
+	if v, ok := n.attr("negate-condition"); ok {
+		t.Negate = v == "yes"
+	}
+	return t, nil
+}
+
+// parseFilter reads the comp-filter element of a filter and checks that
+// it can be evaluated.
+func parseFilter(n *node) (*CompFilter, error) {
+	f, err := parseCompFilter(n)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseCompFilter reads a comp-filter element.
+func parseCompFilter(n *node) (*CompFilter, error) {
+	name, _ := n.attr("name")
+	f := &CompFilter{Name: strings.ToUpper(name)}
+	if f.Name == "" {
+		return nil, fmt.Errorf("comp-filter without name")
+	}
+	if n.child(nsCalDAV, "is-not-defined") != nil {
+		f.IsNotDefined = true
+		return f, nil
+	}
+	if tr := n.child(nsCalDAV, "time-range"); tr != nil {
+		r, err := parseTimeRange(tr)
+		if err != nil {
+			return nil, err
+		}
+		f.TimeRange = r
+	}
+	for _, p := range n.children(nsCalDAV, "prop-filter") {
+		pf, err := parsePropFilter(p)
+		if err != nil {
+			return nil, err
+		}
+		f.Props = append(f.Props, pf)
+	}
+	for _, c := range n.children(nsCalDAV, "comp-filter") {
+		cf, err := parseCompFilter(c)
+		if err != nil {
+			return nil, err
+		}
+		f.Comps = append(f.Comps, cf)
+	}
+	return f, nil
+}
+
+func parsePropFilter(n *node) (*PropFilter, error) {
+	name, _ := n.attr("name")
+	f := &PropFilter{Name: strings.ToUpper(name)}
+	if f.Name == "" {
+		return nil, fmt.Errorf("prop-filter without name")
+	}
+	if n.child(nsCalDAV, "is-not-defined") != nil {
+		f.IsNotDefined = true
+		return f, nil
+	}
+	// an extension of the filter package
+	if v, ok := n.attr("or-not-defined"); ok {
+		f.OrNotDefined = v == "yes"
+	}
+	if tr := n.child(nsCalDAV, "time-range"); tr != nil {
+		r, err := parseTimeRange(tr)
+		if err != nil {
+			return nil, err
+		}
+		f.TimeRange = r
+	} else if t := n.child(nsCalDAV, "text-match"); t != nil {
+		tm, err := parseTextMatch(t)
+		if err != nil {
+			return nil, err
+		}
+		f.TextMatch = tm
+	}
+	for _, p := range n.children(nsCalDAV, "param-filter") {
+		name, _ := p.attr("name")
+		pf := &ParamFilter{Name: strings.ToUpper(name)}
+		if pf.Name == "" {
+			return nil, fmt.Errorf("param-filter without name")
+		}
+		if p.child(nsCalDAV, "is-not-defined") != nil {
+			pf.IsNotDefined = true
+		} else if t := p.child(nsCalDAV, "text-match"); t != nil {
+			tm, err := parseTextMatch(t)
+			if err != nil {
+				return nil, err
+			}
+			pf.TextMatch = tm
+		}
+		f.Params = append(f.Params, pf)
+	}
+	return f, nil
+}
+
+// compFilterElement returns f as a comp-filter element.
+func compFilterElement(f *CompFilter) *element {
+	e := newElement(nsCalDAV, "comp-filter").attr("name", f.Name)
+	if f.IsNotDefined {
+		e.children = append(e.children, newElement(nsCalDAV, "is-not-defined"))
+		return e
+	}
+	if f.TimeRange != nil {
+		e.children = append(e.children, timeRangeElement(f.TimeRange))
+	}
+	for _, p := range f.Props {
+		e.children = append(e.children, propFilterElement(p))
+	}
+	for _, c := range f.Comps {
+		e.children = append(e.children, compFilterElement(c))
+	}
+	return e
+}
+
+func timeRangeElement(r *TimeRange) *element {
+	e := newElement(nsCalDAV, "time-range")
+	if !r.Start.IsZero() {
+		e.attr("start", r.Start.UTC().Format(timeRangeFormat))
+	}
+	if !r.End.IsZero() {
+		e.attr("end", r.End.UTC().Format(timeRangeFormat))
+	}
+	return e
+}
+
+func propFilterElement(f *PropFilter) *element {
+	e := newElement(nsCalDAV, "prop-filter").attr("name", f.Name)
+	if f.IsNotDefined {
+		e.children = append(e.children, newElement(nsCalDAV, "is-not-defined"))
+		return e
+	}
+	if f.OrNotDefined {
+		e.attr("or-not-defined", "yes")
+	}
+	if f.TimeRange != nil {
+		e.children = append(e.children, timeRangeElement(f.TimeRange))
+	} else if f.TextMatch != nil {
+		e.children = append(e.children, textMatchElement(f.TextMatch))
+	}
+	for _, p := range f.Params {
+		pe := newElement(nsCalDAV, "param-filter").attr("name", p.Name)
+		if p.IsNotDefined {
+			pe.children = append(pe.children, newElement(nsCalDAV, "is-not-defined"))
+		} else if p.TextMatch != nil {
+			pe.children = append(pe.children, textMatchElement(p.TextMatch))
+		}
+		e.children = append(e.children, pe)
+	}
+	return e
+}
+
+func textMatchElement(t *TextMatch) *element {
+	e := textElement(nsCalDAV, "text-match", t.Text)
+	if t.Collation != "" {
+		e.attr("collation", t.Collation)
+	}
+	if t.MatchType != "" && t.MatchType != filter.Contains {
+		e.attr("match-type", t.MatchType)
+	}
+	if t.Negate {
+		e.attr("negate-condition", "yes")
+	}
+	return e
+}
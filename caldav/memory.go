@@ -0,0 +1,241 @@
+package caldav
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStorage is a SyncStorage that keeps everything in memory.
+type memoryStorage struct {
+	mu          sync.RWMutex
+	collections map[string]*Collection
+	// objects by collection path and resource path
+	objects map[string]map[string]*Object
+	// revision of each collection, counting its changes
+	revisions map[string]int
+	// revision of the last change of each resource path, by collection,
+	// including removed resources
+	changes map[string]map[string]int
+	// resource path of each UID, by collection
+	uids map[string]map[string]string
+	now  func() time.Time
+}
+
+// NewMemoryStorage returns an empty SyncStorage that keeps everything in
+// memory, for tests and small servers.
+func NewMemoryStorage() SyncStorage {
+	return &memoryStorage{
+		collections: map[string]*Collection{},
+		objects:     map[string]map[string]*Object{},
+		revisions:   map[string]int{},
+		changes:     map[string]map[string]int{},
+		uids:        map[string]map[string]string{},
+		now:         time.Now,
+	}
+}
+
+const syncTokenPrefix = "urn:x-vcalender:sync:"
+
+// change records a change of the resource p, with the lock held.
+func (m *memoryStorage) change(p string) {
+	c := parent(p)
+	m.revisions[c]++
+	m.changes[c][p] = m.revisions[c]
+}
+
+func (m *memoryStorage) Calendars(ctx context.Context, home string) ([]*Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	home = collectionPath(home)
+	var cs []*Collection
+	for p, c := range m.collections {
+		if parent(p) == home {
+			cc := *c
+			cs = append(cs, &cc)
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Path < cs[j].Path })
+	return cs, nil
+}
+
+func (m *memoryStorage) Calendar(ctx context.Context, path string) (*Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.collections[collectionPath(path)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cc := *c
+	return &cc, nil
+}
+
+func (m *memoryStorage) CreateCalendar(ctx context.Context, c *Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := collectionPath(c.Path)
+	if _, ok := m.collections[p]; ok {
+		return ErrExists
+	}
+	cc := *c
+	cc.Path = p
+	m.collections[p] = &cc
+	m.objects[p] = map[string]*Object{}
+	m.changes[p] = map[string]int{}
+	m.uids[p] = map[string]string{}
+	return nil
+}
+
+func (m *memoryStorage) DeleteCalendar(ctx context.Context, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := collectionPath(path)
+	if _, ok := m.collections[p]; !ok {
+		return ErrNotFound
+	}
+	delete(m.collections, p)
+	delete(m.objects, p)
+	delete(m.changes, p)
+	delete(m.uids, p)
+	return nil
+}
+
+func (m *memoryStorage) Objects(ctx context.Context, path string) ([]*Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	objs, ok := m.objects[collectionPath(path)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	list := make([]*Object, 0, len(objs))
+	for _, o := range objs {
+		oo := *o
+		list = append(list, &oo)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+func (m *memoryStorage) Object(ctx context.Context, path string) (*Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.objects[parent(path)][path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	oo := *o
+	return &oo, nil
+}
+
+func (m *memoryStorage) ObjectByUID(ctx context.Context, path, uid string) (*Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c := collectionPath(path)
+	o, ok := m.objects[c][m.uids[c][uid]]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	oo := *o
+	return &oo, nil
+}
+
+func (m *memoryStorage) PutObject(ctx context.Context, path string, data []byte, ifMatch string) (*Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := parent(path)
+	objs, ok := m.objects[c]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	old, ok := objs[path]
+	if ifMatch != AnyETag && (ok && old.ETag != ifMatch || !ok && ifMatch != "") {
+		return nil, ErrModified
+	}
+	if ok {
+		m.unindex(old)
+	}
+	sum := sha1.Sum(data)
+	o := &Object{
+		Path:    path,
+		ETag:    hex.EncodeToString(sum[:]),
+		ModTime: m.now().UTC(),
+		Data:    append([]byte(nil), data...),
+	}
+	objs[path] = o
+	if uid := objectUID(data); uid != "" {
+		m.uids[c][uid] = path
+	}
+	m.change(path)
+	oo := *o
+	return &oo, nil
+}
+
+func (m *memoryStorage) DeleteObject(ctx context.Context, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objs := m.objects[parent(path)]
+	o, ok := objs[path]
+	if !ok {
+		return ErrNotFound
+	}
+	m.unindex(o)
+	delete(objs, path)
+	m.change(path)
+	return nil
+}
+
+// unindex removes the UID of o from the index, with the lock held.
+func (m *memoryStorage) unindex(o *Object) {
+	uids := m.uids[parent(o.Path)]
+	if uid := objectUID(o.Data); uids[uid] == o.Path {
+		delete(uids, uid)
+	}
+}
+
+func (m *memoryStorage) SyncToken(ctx context.Context, path string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := collectionPath(path)
+	if _, ok := m.collections[p]; !ok {
+		return "", ErrNotFound
+	}
+	return syncTokenPrefix + strconv.Itoa(m.revisions[p]), nil
+}
+
+func (m *memoryStorage) Changes(ctx context.Context, path, token string) ([]*Object, []string, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p := collectionPath(path)
+	if _, ok := m.collections[p]; !ok {
+		return nil, nil, "", ErrNotFound
+	}
+	since := 0
+	if token != "" {
+		rev, err := strconv.Atoi(strings.TrimPrefix(token, syncTokenPrefix))
+		if err != nil || !strings.HasPrefix(token, syncTokenPrefix) || rev > m.revisions[p] {
+			return nil, nil, "", ErrInvalidSyncToken
+		}
+		since = rev
+	}
+	var changed []*Object
+	var removed []string
+	for op, rev := range m.changes[p] {
+		if rev <= since {
+			continue
+		}
+		if o, ok := m.objects[p][op]; ok {
+			oo := *o
+			changed = append(changed, &oo)
+		} else if since > 0 {
+			removed = append(removed, op)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	sort.Strings(removed)
+	return changed, removed, syncTokenPrefix + strconv.Itoa(m.revisions[p]), nil
+}
@@ -0,0 +1,781 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//   RFC 6638 3.2.  Scheduling Operations
+//
+//      Scheduling operations are carried out by the server when a client
+//      creates, modifies, or removes a scheduling object resource in a
+//      calendar collection.  The server is responsible for sending the
+//      iTIP messages to the "Attendees" (when the calendar user is the
+//      "Organizer") or to the "Organizer" (when the calendar user is an
+//      "Attendee"), delivering them to the scheduling Inbox collection
+//      of local calendar users.
+//
+//   RFC 6638 3.2.1.  Scheduling Object Resources
+//
+//      A scheduling object resource is a calendar object resource
+//      containing an ORGANIZER property whose value matches one of the
+//      calendar user addresses of the owner of the calendar collection
+//      (an "Organizer" scheduling object resource), or an ATTENDEE
+//      property whose value matches one of those addresses (an
+//      "Attendee" scheduling object resource).
+//
+//   RFC 6638 5.  Scheduling Outbox Collection
+//
+//      A POST request may be issued on a scheduling Outbox collection to
+//      request free-busy information of the calendar users listed as
+//      "Attendees" of a VFREEBUSY request.
+
+// User is a calendar user of a Scheduler.
+type User struct {
+	// Address is the calendar user address, such as
+	// "mailto:jane@example.com".
+	Address string
+	// Principal is the path of the principal resource of the user.
+	Principal string
+	// Home is the path of the collection holding the calendar
+	// collections of the user.
+	Home string
+}
+
+// Inbox returns the path of the scheduling Inbox collection of u.
+func (u *User) Inbox() string {
+	return collectionPath(u.Home) + "inbox/"
+}
+
+// Outbox returns the path of the scheduling Outbox collection of u.
+func (u *User) Outbox() string {
+	return collectionPath(u.Home) + "outbox/"
+}
+
+// Scheduler delivers scheduling messages between the calendar users of
+// a Storage, as RFC 6638 describes.  Changes of scheduling object
+// resources made through the handlers of its users are sent as iTIP
+// REQUEST, REPLY and CANCEL messages to the Inbox collections of the
+// other users.  Users that are not known to the scheduler cannot be
+// reached.
+type Scheduler struct {
+	Storage Storage
+	// Now returns the current time, for the DTSTAMP of messages.
+	Now func() time.Time
+
+	mu    sync.RWMutex
+	users []*User
+}
+
+// NewScheduler returns a Scheduler for the given users of s, whose Inbox
+// collections it creates.
+func NewScheduler(ctx context.Context, s Storage, users ...*User) (*Scheduler, error) {
+	sched := &Scheduler{Storage: s, Now: time.Now}
+	for _, u := range users {
+		if err := sched.AddUser(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+	return sched, nil
+}
+
+// AddUser makes u known to the scheduler, creating its Inbox collection
+// if it is missing.
+func (s *Scheduler) AddUser(ctx context.Context, u *User) error {
+	err := s.Storage.CreateCalendar(ctx, &Collection{Path: u.Inbox(), DisplayName: "Inbox"})
+	if err != nil && err != ErrExists {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = append(s.users, u)
+	return nil
+}
+
+// Handler returns a Handler serving the calendars of u, with scheduling.
+func (s *Scheduler) Handler(u *User) *Handler {
+	return &Handler{Storage: s.Storage, Principal: u.Principal, Home: u.Home, Scheduler: s, Address: u.Address}
+}
+
+// User returns the user with the calendar user address addr, or nil.
+func (s *Scheduler) User(addr string) *User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if sameAddress(u.Address, addr) {
+			return u
+		}
+	}
+	return nil
+}
+
+// sameAddress reports whether a and b are the same calendar user
+// address, ignoring the case of the scheme and of mail addresses.
+func sameAddress(a, b string) bool {
+	trim := func(s string) string {
+		if len(s) >= 7 && strings.EqualFold(s[:7], "mailto:") {
+			return s[7:]
+		}
+		return s
+	}
+	return strings.EqualFold(trim(a), trim(b))
+}
+
+// find returns the calendar object resource with the given UID in the
+// calendars of u, or ErrNotFound.
+func (s *Scheduler) find(ctx context.Context, u *User, uid string) (*Object, error) {
+	cs, err := s.Storage.Calendars(ctx, u.Home)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cs {
+		if c.Path == u.Inbox() {
+			continue
+		}
+		o, err := s.Storage.ObjectByUID(ctx, c.Path, uid)
+		if err != ErrNotFound {
+			return o, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// delivery is a resource written by a scheduling operation: a message
+// to an Inbox or the updated copy of an organizer.
+type delivery struct {
+	// path is the path of the resource, or of the Inbox collection for
+	// messages, which are given a new name.
+	path string
+	// etag is the ETag the resource must still have when it is written.
+	etag string
+	data []byte
+	// status holds the properties whose SCHEDULE-STATUS reports the
+	// result of the delivery.
+	status []*objects.ContentLine
+}
+
+// deliver writes the deliveries.  A delivery that fails sets its
+// SCHEDULE-STATUS to 5.1 and does not keep the others from being made;
+// the first error is returned.
+func (s *Scheduler) deliver(ctx context.Context, ds []*delivery) error {
+	var first error
+	for _, d := range ds {
+		err := s.write(ctx, d)
+		if err == nil {
+			continue
+		}
+		for _, l := range d.status {
+			setParam(l, "SCHEDULE-STATUS", parameters.ScheduleStatusFailed)
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// write writes the delivery d.
+func (s *Scheduler) write(ctx context.Context, d *delivery) error {
+	if !strings.HasSuffix(d.path, "/") {
+		_, err := s.Storage.PutObject(ctx, d.path, d.data, d.etag)
+		return err
+	}
+	sum := sha1.Sum(d.data)
+	name := hex.EncodeToString(sum[:])
+	p := d.path + name + ".ics"
+	for n := 2; ; n++ {
+		// the empty ETag only creates the message when the name is free
+		_, err := s.Storage.PutObject(ctx, p, d.data, "")
+		if err != ErrModified {
+			return err
+		}
+		p = d.path + name + "-" + strconv.Itoa(n) + ".ics"
+	}
+}
+
+// message returns a delivery of msg to the Inbox of u, whose result is
+// reported by the SCHEDULE-STATUS of the given properties.
+func message(u *User, msg *objects.RawComponent, status ...*objects.ContentLine) *delivery {
+	return &delivery{path: u.Inbox(), data: []byte(objects.Fold(msg.String())), status: status}
+}
+
+// isInbox reports whether p is the Inbox collection of the user of h.
+func (h *Handler) isInbox(p string) bool {
+	if h.Scheduler == nil {
+		return false
+	}
+	u := h.Scheduler.User(h.Address)
+	return u != nil && collectionPath(p) == u.Inbox()
+}
+
+// isOutbox reports whether p is the Outbox collection of the user of h.
+func (h *Handler) isOutbox(p string) bool {
+	if h.Scheduler == nil {
+		return false
+	}
+	u := h.Scheduler.User(h.Address)
+	return u != nil && collectionPath(p) == u.Outbox()
+}
+
+// scheduleProperties returns the scheduling properties of the principal.
+func (h *Handler) scheduleProperties() []*element {
+	u := h.Scheduler.User(h.Address)
+	if u == nil {
+		return nil
+	}
+	return []*element{
+		newElement(nsCalDAV, "schedule-inbox-URL", href(u.Inbox())),
+		newElement(nsCalDAV, "schedule-outbox-URL", href(u.Outbox())),
+		newElement(nsCalDAV, "calendar-user-address-set", textElement(nsDAV, "href", u.Address)),
+	}
+}
+
+// scheduled returns the components of cal other than VTIMEZONE.
+func scheduled(cal *objects.RawComponent) []*objects.RawComponent {
+	var comps []*objects.RawComponent
+	for _, c := range cal.Components {
+		if c.Name != "VTIMEZONE" {
+			comps = append(comps, c)
+		}
+	}
+	return comps
+}
+
+// organizer returns the ORGANIZER of the components of cal, or nil.
+func organizer(cal *objects.RawComponent) *objects.ContentLine {
+	for _, c := range scheduled(cal) {
+		if l := c.Property("ORGANIZER"); l != nil {
+			return l
+		}
+	}
+	return nil
+}
+
+// attendees returns the ATTENDEE properties of the components of cal by
+// address, in the order they first appear.
+func attendees(cal *objects.RawComponent) ([]string, map[string][]*objects.ContentLine) {
+	var order []string
+	byAddr := map[string][]*objects.ContentLine{}
+	for _, c := range scheduled(cal) {
+		for _, l := range c.Properties {
+			if l.Name != "ATTENDEE" {
+				continue
+			}
+			key := strings.ToLower(l.Value)
+			if _, ok := byAddr[key]; !ok {
+				order = append(order, key)
+			}
+			byAddr[key] = append(byAddr[key], l)
+		}
+	}
+	return order, byAddr
+}
+
+// agent returns the SCHEDULE-AGENT of l, which defaults to SERVER.
+func agent(l *objects.ContentLine) string {
+	if v, ok := l.Param("SCHEDULE-AGENT"); ok {
+		return strings.ToUpper(v)
+	}
+	return parameters.ScheduleAgentServer.V
+}
+
+func partStat(l *objects.ContentLine) string {
+	if v, ok := l.Param("PARTSTAT"); ok {
+		return strings.ToUpper(v)
+	}
+	return parameters.NeedAction.V
+}
+
+// setParam sets the parameter name of l, or removes it when value is
+// empty.
+func setParam(l *objects.ContentLine, name, value string) {
+	params := l.Params[:0]
+	set := false
+	for _, p := range l.Params {
+		if !strings.EqualFold(p.Name, name) {
+			params = append(params, p)
+		} else if value != "" && !set {
+			params = append(params, &objects.Param{Name: p.Name, Values: []string{value}})
+			set = true
+		}
+	}
+	l.Params = params
+	if value != "" && !set {
+		l.Params = append(l.Params, &objects.Param{Name: name, Values: []string{value}})
+	}
+}
+
+// copyRaw returns a deep copy of c.
+func copyRaw(c *objects.RawComponent) *objects.RawComponent {
+	cc := &objects.RawComponent{Name: c.Name, Line: c.Line}
+	for _, l := range c.Properties {
+		ll := *l
+		ll.Params = nil
+		for _, p := range l.Params {
+			ll.Params = append(ll.Params, &objects.Param{Name: p.Name, Values: append([]string(nil), p.Values...)})
+		}
+		cc.Properties = append(cc.Properties, &ll)
+	}
+	for _, sub := range c.Components {
+		cc.Components = append(cc.Components, copyRaw(sub))
+	}
+	return cc
+}
+
+// significant returns the parts of cal that make a change worth sending
+// to the attendees: all but time stamps and the participation status
+// and scheduling parameters of the attendees.
+func significant(cal *objects.RawComponent) string {
+	c := copyRaw(cal)
+	for _, comp := range scheduled(c) {
+		props := comp.Properties[:0]
+		for _, l := range comp.Properties {
+			switch l.Name {
+			case "DTSTAMP", "LAST-MODIFIED":
+				continue
+			case "ATTENDEE", "ORGANIZER":
+				for _, name := range []string{"PARTSTAT", "RSVP", "SCHEDULE-STATUS", "SCHEDULE-FORCE-SEND"} {
+					setParam(l, name, "")
+				}
+			}
+			props = append(props, l)
+		}
+		comp.Properties = props
+	}
+	return c.String()
+}
+
+// itip returns an iTIP message of the given method made of cal, with the
+// attendees for which keep returns true.
+func (s *Scheduler) itip(cal *objects.RawComponent, method string, keep func(*objects.ContentLine) bool) *objects.RawComponent {
+	msg := copyRaw(cal)
+	stamp := s.Now().UTC().Format("20060102T150405Z")
+	msg.Properties = append([]*objects.ContentLine{{Name: "METHOD", Value: method}}, msg.Properties...)
+	for _, comp := range scheduled(msg) {
+		props := comp.Properties[:0]
+		for _, l := range comp.Properties {
+			switch l.Name {
+			case "ATTENDEE":
+				if keep != nil && !keep(l) {
+					continue
+				}
+				fallthrough
+			case "ORGANIZER":
+				for _, name := range []string{"SCHEDULE-AGENT", "SCHEDULE-STATUS", "SCHEDULE-FORCE-SEND"} {
+					setParam(l, name, "")
+				}
+			case "DTSTAMP":
+				l.Value = stamp
+				l.Params = nil
+			case "STATUS":
+				if method == "CANCEL" {
+					continue
+				}
+			}
+			props = append(props, l)
+		}
+		comp.Properties = props
+		if method == "CANCEL" {
+			comp.Properties = append(comp.Properties, &objects.ContentLine{Name: "STATUS", Value: "CANCELLED"})
+		}
+	}
+	return msg
+}
+
+// schedule carries out the scheduling operations of replacing the
+// calendar object resource old with data, or of deleting old when data
+// is nil.  The messages are delivered before the change is stored, and
+// the data to store is returned, which differs from data when scheduling
+// parameters were set.  A failed delivery is reported by the
+// SCHEDULE-STATUS of the stored data; only a deletion, which has no data
+// to report it in, fails with it.
+func (h *Handler) schedule(ctx context.Context, old *Object, data []byte) ([]byte, error) {
+	var before, after *objects.RawComponent
+	if old != nil {
+		raw, err := objects.ReadRawComponents(bytes.NewReader(old.Data))
+		if err == nil && len(raw) == 1 {
+			before = raw[0]
+		}
+	}
+	if data != nil {
+		raw, err := objects.ReadRawComponents(bytes.NewReader(data))
+		if err != nil || len(raw) != 1 {
+			return nil, preconditionError(nsCalDAV, "valid-calendar-data")
+		}
+		after = raw[0]
+	}
+	written := ""
+	if after != nil {
+		written = after.String()
+	}
+	cal := after
+	if cal == nil {
+		cal = before
+	}
+	if cal == nil {
+		return data, nil
+	}
+	org := organizer(cal)
+	if org == nil {
+		return data, nil
+	}
+	var ds []*delivery
+	var err error
+	if sameAddress(org.Value, h.Address) {
+		ds = h.scheduleOrganizer(before, after)
+	} else if ds, err = h.scheduleAttendee(ctx, before, after); err != nil {
+		return nil, err
+	}
+	err = h.Scheduler.deliver(ctx, ds)
+	if after == nil {
+		return nil, err
+	}
+	for _, comp := range scheduled(after) {
+		for _, l := range comp.Properties {
+			if l.Name == "ATTENDEE" || l.Name == "ORGANIZER" {
+				setParam(l, "SCHEDULE-FORCE-SEND", "")
+			}
+		}
+	}
+	if after.String() == written {
+		// keep the data as written when nothing was changed
+		return data, nil
+	}
+	return []byte(objects.Fold(after.String())), nil
+}
+
+// scheduleOrganizer sends requests to the attendees of an event of the
+// organizer that was created or changed significantly, and cancels it
+// for removed attendees and when it is deleted.  The SCHEDULE-STATUS of
+// the attendees of after is set to the result of the delivery.
+func (h *Handler) scheduleOrganizer(before, after *objects.RawComponent) []*delivery {
+	var ds []*delivery
+	var oldOrder []string
+	var oldAtts map[string][]*objects.ContentLine
+	if before != nil {
+		oldOrder, oldAtts = attendees(before)
+	}
+	var newAtts map[string][]*objects.ContentLine
+	if after != nil {
+		var order []string
+		order, newAtts = attendees(after)
+		changed := before == nil || significant(before) != significant(after)
+		for _, addr := range order {
+			lines := newAtts[addr]
+			if sameAddress(addr, h.Address) || agent(lines[0]) != parameters.ScheduleAgentServer.V {
+				continue
+			}
+			force, _ := lines[0].Param("SCHEDULE-FORCE-SEND")
+			if old, ok := oldAtts[addr]; ok && !changed && !strings.EqualFold(force, parameters.ForceSendRequest.V) {
+				// nothing is sent, the result of the last delivery stays
+				if status, ok := old[0].Param("SCHEDULE-STATUS"); ok {
+					for _, l := range lines {
+						setParam(l, "SCHEDULE-STATUS", status)
+					}
+				}
+				continue
+			}
+			status := parameters.ScheduleStatusInvalid
+			if u := h.Scheduler.User(addr); u != nil {
+				ds = append(ds, message(u, h.Scheduler.itip(after, "REQUEST", nil), lines...))
+				status = parameters.ScheduleStatusDelivered
+			}
+			for _, l := range lines {
+				setParam(l, "SCHEDULE-STATUS", status)
+			}
+		}
+	}
+	for _, addr := range oldOrder {
+		if _, ok := newAtts[addr]; ok || sameAddress(addr, h.Address) || agent(oldAtts[addr][0]) != parameters.ScheduleAgentServer.V {
+			continue
+		}
+		if u := h.Scheduler.User(addr); u != nil {
+			a := addr
+			ds = append(ds, message(u, h.Scheduler.itip(before, "CANCEL", func(l *objects.ContentLine) bool {
+				return strings.EqualFold(l.Value, a)
+			})))
+		}
+	}
+	return ds
+}
+
+// scheduleAttendee replies to the organizer when the participation
+// status of the user changes, declining when the event is deleted, and
+// updates the copy of the organizer.  The SCHEDULE-STATUS of the
+// organizer of after is set to the result of the delivery.
+func (h *Handler) scheduleAttendee(ctx context.Context, before, after *objects.RawComponent) ([]*delivery, error) {
+	me := func(l *objects.ContentLine) bool { return sameAddress(l.Value, h.Address) }
+	statuses := func(cal *objects.RawComponent) string {
+		var s []string
+		if cal != nil {
+			_, atts := attendees(cal)
+			for addr, lines := range atts {
+				if sameAddress(addr, h.Address) {
+					for _, l := range lines {
+						s = append(s, partStat(l))
+					}
+				}
+			}
+		}
+		return strings.Join(s, ",")
+	}
+	reply := after
+	if reply == nil {
+		// an attendee deleting an event declines it
+		reply = copyRaw(before)
+		for _, comp := range scheduled(reply) {
+			for _, l := range comp.Properties {
+				if l.Name == "ATTENDEE" && me(l) {
+					setParam(l, "PARTSTAT", parameters.Declined.V)
+				}
+			}
+		}
+	}
+	_, atts := attendees(reply)
+	var mine []*objects.ContentLine
+	for addr, lines := range atts {
+		if sameAddress(addr, h.Address) {
+			mine = lines
+		}
+	}
+	if len(mine) == 0 {
+		return nil, nil
+	}
+	org := organizer(reply)
+	force, _ := mine[0].Param("SCHEDULE-FORCE-SEND")
+	was := statuses(before)
+	if before == nil {
+		was = strings.TrimSuffix(strings.Repeat(parameters.NeedAction.V+",", len(mine)), ",")
+	}
+	if agent(org) != parameters.ScheduleAgentServer.V ||
+		statuses(reply) == was && !strings.EqualFold(force, parameters.ForceSendReply.V) {
+		return nil, nil
+	}
+	var orgs []*objects.ContentLine
+	if after != nil {
+		for _, comp := range scheduled(after) {
+			if l := comp.Property("ORGANIZER"); l != nil {
+				orgs = append(orgs, l)
+			}
+		}
+	}
+	u := h.Scheduler.User(org.Value)
+	status := parameters.ScheduleStatusInvalid
+	var ds []*delivery
+	if u != nil {
+		status = parameters.ScheduleStatusDelivered
+		ds = append(ds, message(u, h.Scheduler.itip(reply, "REPLY", me), orgs...))
+		d, err := h.updateOrganizer(ctx, u, reply)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			d.status = orgs
+			ds = append(ds, d)
+		}
+	}
+	for _, l := range orgs {
+		setParam(l, "SCHEDULE-STATUS", status)
+	}
+	return ds, nil
+}
+
+// updateOrganizer returns the copy of the organizer u of reply with the
+// participation status of the user of h taken from reply, or nil when
+// the organizer has no copy.
+func (h *Handler) updateOrganizer(ctx context.Context, u *User, reply *objects.RawComponent) (*delivery, error) {
+	comps := scheduled(reply)
+	if len(comps) == 0 {
+		return nil, nil
+	}
+	uid := ""
+	if l := comps[0].Property("UID"); l != nil {
+		uid = l.Value
+	}
+	o, err := h.Scheduler.find(ctx, u, uid)
+	if err == ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	raw, err := objects.ReadRawComponents(bytes.NewReader(o.Data))
+	if err != nil || len(raw) != 1 {
+		return nil, nil
+	}
+	recurrenceId := func(c *objects.RawComponent) string {
+		if l := c.Property("RECURRENCE-ID"); l != nil {
+			return l.Value
+		}
+		return ""
+	}
+	for _, comp := range scheduled(raw[0]) {
+		for _, r := range comps {
+			if recurrenceId(r) != recurrenceId(comp) {
+				continue
+			}
+			for _, rl := range r.Properties {
+				if rl.Name != "ATTENDEE" || !sameAddress(rl.Value, h.Address) {
+					continue
+				}
+				for _, l := range comp.Properties {
+					if l.Name == "ATTENDEE" && sameAddress(l.Value, h.Address) {
+						setParam(l, "PARTSTAT", partStat(rl))
+						setParam(l, "SCHEDULE-STATUS", parameters.ScheduleStatusDelivered)
+					}
+				}
+			}
+		}
+	}
+	return &delivery{path: o.Path, etag: o.ETag, data: []byte(objects.Fold(raw[0].String()))}, nil
+}
+
+//   RFC 6638 5.2.  Free-Busy Request
+//
+//      The request body of a POST request on a scheduling Outbox
+//      collection contains a VFREEBUSY component with METHOD:REQUEST,
+//      an ORGANIZER matching the calendar user, one or more ATTENDEE
+//      properties and the DTSTART and DTEND of the period to query.  The
+//      response is a CALDAV:schedule-response element with one
+//      CALDAV:response per "Attendee".
+
+// post answers a free-busy request on the Outbox of the user.
+func (h *Handler) post(w http.ResponseWriter, r *http.Request) error {
+	if !h.isOutbox(r.URL.Path) {
+		return errorf(http.StatusMethodNotAllowed, "POST is only supported on the scheduling Outbox")
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "text/calendar" {
+		return preconditionError(nsCalDAV, "supported-calendar-data")
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorf(http.StatusRequestEntityTooLarge, "%v", err)
+	}
+	raw, err := objects.ReadRawComponents(bytes.NewReader(data))
+	if err != nil || len(raw) != 1 || raw[0].Name != "VCALENDAR" {
+		return preconditionError(nsCalDAV, "valid-calendar-data")
+	}
+	cal, err := objects.DecodeRaw(raw[0])
+	if err != nil {
+		return preconditionError(nsCalDAV, "valid-calendar-data")
+	}
+	var fb *components.FreeBusy
+	for _, c := range cal.Components {
+		if v, ok := c.(*components.FreeBusy); ok {
+			fb = v
+		}
+	}
+	method := raw[0].Property("METHOD")
+	if method == nil || !strings.EqualFold(method.Value, "REQUEST") || fb == nil || fb.DtStart == nil || fb.DtEnd == nil {
+		return preconditionError(nsCalDAV, "valid-scheduling-message")
+	}
+	var comp *objects.RawComponent
+	for _, c := range raw[0].Components {
+		if c.Name == "VFREEBUSY" {
+			comp = c
+		}
+	}
+	if org := comp.Property("ORGANIZER"); org == nil || !sameAddress(org.Value, h.Address) {
+		return preconditionError(nsCalDAV, "organizer-allowed")
+	}
+	start, _ := objects.TimeOf(fb.DtStart.Parameters, fb.DtStart.Value)
+	end, _ := objects.TimeOf(fb.DtEnd.Parameters, fb.DtEnd.Value)
+	var responses []*element
+	for _, l := range comp.Properties {
+		if l.Name != "ATTENDEE" {
+			continue
+		}
+		resp := newElement(nsCalDAV, "response", newElement(nsCalDAV, "recipient", textElement(nsDAV, "href", l.Value)))
+		u := h.Scheduler.User(l.Value)
+		if u == nil {
+			resp.children = append(resp.children, textElement(nsCalDAV, "request-status", "3.7;Invalid calendar user"))
+			responses = append(responses, resp)
+			continue
+		}
+		reply, err := h.Scheduler.freeBusy(r.Context(), u, comp, l, start, end)
+		if err != nil {
+			return err
+		}
+		resp.children = append(resp.children,
+			textElement(nsCalDAV, "request-status", "2.0;Success"),
+			textElement(nsCalDAV, "calendar-data", objects.Fold(reply.String())))
+		responses = append(responses, resp)
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write([]byte(newElement(nsCalDAV, "schedule-response", responses...).String()))
+	return err
+}
+
+// freeBusy returns the VFREEBUSY reply of the attendee u to the request
+// req, with the busy time of the calendars of u within [start, end).
+func (s *Scheduler) freeBusy(ctx context.Context, u *User, req *objects.RawComponent, attendee *objects.ContentLine, start, end time.Time) (*objects.RawComponent, error) {
+	var comps []components.Component
+	cs, err := s.Storage.Calendars(ctx, u.Home)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cs {
+		if c.Path == u.Inbox() {
+			continue
+		}
+		objs, err := s.Storage.Objects(ctx, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objs {
+			cal, err := objects.Decode(bytes.NewReader(o.Data))
+			if err != nil {
+				continue
+			}
+			comps = append(comps, cal.Components...)
+		}
+	}
+	periods, err := objects.BusyPeriods(comps, start, end)
+	if err != nil {
+		return nil, err
+	}
+	utc := func(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+	fb := &objects.RawComponent{Name: "VFREEBUSY"}
+	if l := req.Property("UID"); l != nil {
+		fb.Properties = append(fb.Properties, l)
+	}
+	fb.Properties = append(fb.Properties,
+		&objects.ContentLine{Name: "DTSTAMP", Value: utc(s.Now())},
+		&objects.ContentLine{Name: "DTSTART", Value: utc(start)},
+		&objects.ContentLine{Name: "DTEND", Value: utc(end)},
+		req.Property("ORGANIZER"),
+		&objects.ContentLine{Name: "ATTENDEE", Value: attendee.Value})
+	byType := map[string]*objects.ContentLine{}
+	for _, p := range periods {
+		l, ok := byType[p.Type]
+		if !ok {
+			l = &objects.ContentLine{Name: "FREEBUSY", Params: []*objects.Param{{Name: "FBTYPE", Values: []string{p.Type}}}}
+			byType[p.Type] = l
+			fb.Properties = append(fb.Properties, l)
+		} else {
+			l.Value += ","
+		}
+		l.Value += utc(p.Start) + "/" + utc(p.End)
+	}
+	return &objects.RawComponent{
+		Name: "VCALENDAR",
+		Properties: []*objects.ContentLine{
+			{Name: "VERSION", Value: "2.0"},
+			{Name: "PRODID", Value: "-//vcalender//caldav//EN"},
+			{Name: "METHOD", Value: "REPLY"},
+		},
+		Components: []*objects.RawComponent{fb},
+	}, nil
+}
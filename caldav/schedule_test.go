@@ -0,0 +1,240 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const meeting = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:meeting@example.com
+DTSTAMP:20240601T000000Z
+DTSTART:20240610T090000Z
+DTEND:20240610T100000Z
+SUMMARY:Planning
+ORGANIZER:mailto:alice@example.com
+ATTENDEE;PARTSTAT=ACCEPTED:mailto:alice@example.com
+ATTENDEE;RSVP=TRUE:mailto:bob@example.com
+ATTENDEE;RSVP=TRUE:mailto:carol@example.com
+END:VEVENT
+END:VCALENDAR
+`
+
+// unfold returns the unfolded data of the object at p.
+func unfold(t *testing.T, s Storage, p string) string {
+	t.Helper()
+	o, err := s.Object(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Replace(string(o.Data), "\r\n ", "", -1)
+}
+
+// inbox returns the unfolded messages in the Inbox of u.
+func inbox(t *testing.T, s Storage, u *User) []string {
+	t.Helper()
+	objs, err := s.Objects(context.Background(), u.Inbox())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, o := range objs {
+		msgs = append(msgs, strings.Replace(string(o.Data), "\r\n ", "", -1))
+	}
+	return msgs
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	alice := &User{Address: "mailto:alice@example.com", Principal: "/principals/alice/", Home: "/calendars/alice/"}
+	bob := &User{Address: "mailto:Bob@Example.com", Principal: "/principals/bob/", Home: "/calendars/bob/"}
+	sched, err := NewScheduler(ctx, storage, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched.Now = func() time.Time { return time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC) }
+	for _, p := range []string{"/calendars/alice/work/", "/calendars/bob/work/"} {
+		if err := storage.CreateCalendar(ctx, &Collection{Path: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	as := httptest.NewServer(sched.Handler(alice))
+	defer as.Close()
+	bs := httptest.NewServer(sched.Handler(bob))
+	defer bs.Close()
+
+	resp, body := do(t, as, "PROPFIND", "/principals/alice/", "", "Depth", "0")
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "schedule-inbox-URL><d:href>/calendars/alice/inbox/</d:href>") ||
+		!strings.Contains(body, "<d:href>mailto:alice@example.com</d:href>") {
+		t.Errorf("PROPFIND of the principal: %s %s", resp.Status, body)
+	}
+	resp, body = do(t, as, "PROPFIND", "/calendars/alice/", "", "Depth", "1")
+	if !strings.Contains(body, "<c:schedule-inbox/>") || !strings.Contains(body, "<c:schedule-outbox/>") {
+		t.Errorf("PROPFIND of the home: %s %s", resp.Status, body)
+	}
+
+	// the organizer invites
+	resp, _ = do(t, as, "PUT", "/calendars/alice/work/meeting.ics", meeting, "Content-Type", "text/calendar")
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") != "" {
+		t.Fatalf("PUT by the organizer: %s, ETag %q", resp.Status, resp.Header.Get("ETag"))
+	}
+	if s := unfold(t, storage, "/calendars/alice/work/meeting.ics"); !strings.Contains(s, "ATTENDEE;RSVP=TRUE;SCHEDULE-STATUS=1.2:mailto:bob@example.com") ||
+		!strings.Contains(s, "ATTENDEE;RSVP=TRUE;SCHEDULE-STATUS=3.7:mailto:carol@example.com") ||
+		strings.Contains(s, "ATTENDEE;PARTSTAT=ACCEPTED;SCHEDULE-STATUS") {
+		t.Errorf("organizer copy:\n%s", s)
+	}
+	msgs := inbox(t, storage, bob)
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "METHOD:REQUEST") || !strings.Contains(msgs[0], "DTSTAMP:20240602T120000Z") ||
+		strings.Contains(msgs[0], "SCHEDULE-STATUS") {
+		t.Fatalf("REQUEST: %q", msgs)
+	}
+	if resp, _ := do(t, bs, "PUT", "/calendars/bob/inbox/x.ics", meeting, "Content-Type", "text/calendar"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PUT into the Inbox: %s", resp.Status)
+	}
+	if resp, _ := do(t, as, "PUT", "/calendars/bob/inbox/x.ics", meeting, "Content-Type", "text/calendar"); resp.StatusCode != http.StatusForbidden ||
+		len(inbox(t, storage, bob)) != 1 {
+		t.Errorf("PUT into the Inbox of another user: %s", resp.Status)
+	}
+
+	// a change of the time stamp only is not sent, unless forced
+	resp, _ = do(t, as, "PUT", "/calendars/alice/work/meeting.ics", strings.Replace(meeting, "20240601T000000Z", "20240601T010000Z", 1), "Content-Type", "text/calendar")
+	if resp.StatusCode != http.StatusNoContent || len(inbox(t, storage, bob)) != 1 {
+		t.Errorf("insignificant change: %s, %d messages", resp.Status, len(inbox(t, storage, bob)))
+	}
+	forced := strings.Replace(meeting, "ATTENDEE;RSVP=TRUE:mailto:bob", "ATTENDEE;RSVP=TRUE;SCHEDULE-FORCE-SEND=REQUEST:mailto:bob", 1)
+	do(t, as, "PUT", "/calendars/alice/work/meeting.ics", strings.Replace(forced, "DTSTAMP:20240601T000000Z", "DTSTAMP:20240601T020000Z", 1), "Content-Type", "text/calendar")
+	if len(inbox(t, storage, bob)) != 2 {
+		t.Errorf("forced REQUEST not sent")
+	}
+	if s := unfold(t, storage, "/calendars/alice/work/meeting.ics"); strings.Contains(s, "SCHEDULE-FORCE-SEND") ||
+		!strings.Contains(s, "SCHEDULE-STATUS=3.7:mailto:carol@example.com") {
+		t.Errorf("organizer copy after the forced request:\n%s", s)
+	}
+
+	// the attendee accepts
+	accepted := strings.Replace(meeting, "ATTENDEE;RSVP=TRUE:mailto:bob", "ATTENDEE;PARTSTAT=ACCEPTED:mailto:bob", 1)
+	resp, _ = do(t, bs, "PUT", "/calendars/bob/work/meeting.ics", accepted, "Content-Type", "text/calendar")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT by the attendee: %s", resp.Status)
+	}
+	msgs = inbox(t, storage, alice)
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "METHOD:REPLY") || !strings.Contains(msgs[0], "ATTENDEE;PARTSTAT=ACCEPTED:mailto:bob@example.com") ||
+		strings.Contains(msgs[0], "carol") {
+		t.Fatalf("REPLY: %q", msgs)
+	}
+	if s := unfold(t, storage, "/calendars/alice/work/meeting.ics"); !strings.Contains(s, "ATTENDEE;RSVP=TRUE;SCHEDULE-STATUS=1.2;PARTSTAT=ACCEPTED:mailto:bob@example.com") {
+		t.Errorf("organizer copy after the reply:\n%s", s)
+	}
+	if s := unfold(t, storage, "/calendars/bob/work/meeting.ics"); !strings.Contains(s, "ORGANIZER;SCHEDULE-STATUS=1.2:mailto:alice@example.com") {
+		t.Errorf("attendee copy:\n%s", s)
+	}
+	// the calendars of the attendee are out of reach of the organizer
+	for _, method := range []string{"GET", "DELETE"} {
+		if resp, _ := do(t, as, method, "/calendars/bob/work/meeting.ics", ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s of the attendee copy by the organizer: %s", method, resp.Status)
+		}
+	}
+	if resp, _ := do(t, as, "PROPFIND", "/calendars/bob/inbox/", "", "Depth", "1"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("PROPFIND of the Inbox of another user: %s", resp.Status)
+	}
+	unfold(t, storage, "/calendars/bob/work/meeting.ics")
+
+	// free/busy of the attendee
+	resp, body = do(t, as, "POST", "/calendars/alice/outbox/", `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+METHOD:REQUEST
+BEGIN:VFREEBUSY
+UID:fb@example.com
+DTSTAMP:20240601T000000Z
+DTSTART:20240610T000000Z
+DTEND:20240611T000000Z
+ORGANIZER:mailto:alice@example.com
+ATTENDEE:mailto:bob@example.com
+ATTENDEE:mailto:dave@example.com
+END:VFREEBUSY
+END:VCALENDAR
+`, "Content-Type", "text/calendar")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "FREEBUSY;FBTYPE=BUSY:20240610T090000Z/20240610T100000Z") ||
+		!strings.Contains(body, "2.0;Success") || !strings.Contains(body, "3.7;Invalid calendar user") {
+		t.Errorf("free/busy request: %s %s", resp.Status, body)
+	}
+
+	// the organizer removes the attendee
+	do(t, as, "PUT", "/calendars/alice/work/meeting.ics", strings.Replace(meeting, "ATTENDEE;RSVP=TRUE:mailto:bob@example.com\n", "", 1), "Content-Type", "text/calendar")
+	msgs = inbox(t, storage, bob)
+	cancels := 0
+	for _, m := range msgs {
+		if strings.Contains(m, "METHOD:CANCEL") && strings.Contains(m, "STATUS:CANCELLED") {
+			cancels++
+		}
+	}
+	if cancels != 1 {
+		t.Errorf("CANCEL: %q", msgs)
+	}
+
+	// an attendee deleting the event declines it
+	do(t, bs, "DELETE", "/calendars/bob/work/meeting.ics", "")
+	msgs = inbox(t, storage, alice)
+	if len(msgs) != 2 {
+		t.Fatalf("%d messages after the delete", len(msgs))
+	}
+	declined := false
+	for _, m := range msgs {
+		declined = declined || strings.Contains(m, "PARTSTAT=DECLINED:mailto:bob@example.com")
+	}
+	if !declined {
+		t.Errorf("no declining REPLY: %q", msgs)
+	}
+}
+
+// failingStorage fails to write the resources below prefix.
+type failingStorage struct {
+	SyncStorage
+	prefix string
+}
+
+func (s *failingStorage) PutObject(ctx context.Context, p string, data []byte, ifMatch string) (*Object, error) {
+	if strings.HasPrefix(p, s.prefix) {
+		return nil, errors.New("disk full")
+	}
+	return s.SyncStorage.PutObject(ctx, p, data, ifMatch)
+}
+
+func TestScheduler_FailedDelivery(t *testing.T) {
+	ctx := context.Background()
+	alice := &User{Address: "mailto:alice@example.com", Principal: "/principals/alice/", Home: "/calendars/alice/"}
+	bob := &User{Address: "mailto:bob@example.com", Principal: "/principals/bob/", Home: "/calendars/bob/"}
+	storage := &failingStorage{SyncStorage: NewMemoryStorage(), prefix: bob.Inbox()}
+	sched, err := NewScheduler(ctx, storage, alice, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.CreateCalendar(ctx, &Collection{Path: "/calendars/alice/work/"}); err != nil {
+		t.Fatal(err)
+	}
+	as := httptest.NewServer(sched.Handler(alice))
+	defer as.Close()
+
+	// the invitation is stored, and the failure reported to the organizer
+	if resp, body := do(t, as, "PUT", "/calendars/alice/work/meeting.ics", meeting, "Content-Type", "text/calendar"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT by the organizer: %s %s", resp.Status, body)
+	}
+	if s := unfold(t, storage, "/calendars/alice/work/meeting.ics"); !strings.Contains(s, "ATTENDEE;RSVP=TRUE;SCHEDULE-STATUS=5.1:mailto:bob@example.com") ||
+		!strings.Contains(s, "SCHEDULE-STATUS=3.7:mailto:carol@example.com") {
+		t.Errorf("organizer copy:\n%s", s)
+	}
+	// the event is kept when its cancellation cannot be delivered
+	if resp, _ := do(t, as, "DELETE", "/calendars/alice/work/meeting.ics", ""); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("DELETE: %s", resp.Status)
+	}
+	unfold(t, storage, "/calendars/alice/work/meeting.ics")
+}
@@ -0,0 +1,828 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/filter"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//   RFC 4791 5.1.  Calendar Access Support
+//
+//      A server supporting the features described in this document MUST
+//      include "calendar-access" as a field in the DAV response header
+//      from an OPTIONS request on any resource that supports any calendar
+//      properties, reports, method, or privilege.
+//
+//   RFC 4791 7.  Calendaring Reports
+//
+//      This section defines the reports that CalDAV servers MUST support
+//      on calendar collections and calendar object resources.
+//
+//      CalDAV servers MUST advertise support for these reports on all
+//      calendar collections and calendar object resources with the
+//      DAV:supported-report-set property, defined in Section 3.1.5 of
+//      [RFC3253].  CalDAV servers MAY also advertise support for these
+//      reports on ordinary collections.
+
+// maxBodySize limits the bodies of requests.
+const maxBodySize = 10 << 20
+
+// Handler serves the calendar collections of a Storage to one calendar
+// user.  Authentication is left to the handlers wrapping it.
+type Handler struct {
+	Storage Storage
+	// Principal is the path of the principal resource of the user.
+	Principal string
+	// Home is the path of the collection holding the calendar
+	// collections of the user.
+	Home string
+	// Scheduler, when set, carries out the scheduling operations of
+	// RFC 6638 for the user with the calendar user address Address.
+	Scheduler *Scheduler
+	Address   string
+}
+
+// NewHandler returns a Handler for s with the principal
+// "/principals/user/" and the calendar home "/calendars/".
+func NewHandler(s Storage) *Handler {
+	return &Handler{Storage: s, Principal: "/principals/user/", Home: "/calendars/"}
+}
+
+// httpError is an error answered with a status code and, for failed
+// preconditions, a DAV:error body naming the precondition.
+type httpError struct {
+	code         int
+	precondition *element
+	msg          string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("%d %s", e.code, e.msg)
+}
+
+func errorf(code int, format string, a ...interface{}) error {
+	return &httpError{code: code, msg: fmt.Sprintf(format, a...)}
+}
+
+// preconditionError returns a 403 error naming the precondition in the
+// given namespace.
+func preconditionError(space, name string, children ...*element) error {
+	return &httpError{code: http.StatusForbidden, precondition: newElement(space, name, children...), msg: name}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if r.URL.Path == "/.well-known/caldav" {
+		// RFC 6764 6.  Locating Services
+		http.Redirect(w, r, h.Principal, http.StatusMovedPermanently)
+		return
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		if h.Scheduler != nil {
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT, MKCALENDAR, POST")
+			w.Header().Set("DAV", "1, 3, calendar-access, calendar-auto-schedule")
+		} else {
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT, MKCALENDAR")
+			w.Header().Set("DAV", "1, 3, calendar-access")
+		}
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		err = h.propfind(w, r)
+	case "REPORT":
+		err = h.report(w, r)
+	case http.MethodGet, http.MethodHead:
+		err = h.get(w, r)
+	case http.MethodPut:
+		err = h.put(w, r)
+	case http.MethodDelete:
+		err = h.delete(w, r)
+	case "MKCALENDAR":
+		err = h.mkcalendar(w, r)
+	case http.MethodPost:
+		err = h.post(w, r)
+	default:
+		err = errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*httpError)
+	switch {
+	case ok && e.precondition != nil:
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(e.code)
+		io.WriteString(w, newElement(nsDAV, "error", e.precondition).String())
+	case ok:
+		http.Error(w, e.msg, e.code)
+	case err == ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// kind is the kind of a resource of the handler.
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindPrincipal
+	kindHome
+	kindCalendar
+	kindObject
+	kindOutbox
+)
+
+type resource struct {
+	kind     kind
+	path     string
+	calendar *Collection
+	object   *Object
+}
+
+// owns reports whether the resource at p is the principal of h or lies
+// in its calendar home.  The Storage may hold the calendars of other
+// users, which the handler must not reach.
+func (h *Handler) owns(p string) bool {
+	cp := collectionPath(p)
+	return cp == collectionPath(h.Principal) || strings.HasPrefix(cp, collectionPath(h.Home))
+}
+
+// resolve returns the resource at p, or ErrNotFound.
+func (h *Handler) resolve(r *http.Request, p string) (*resource, error) {
+	cp := collectionPath(p)
+	switch cp {
+	case collectionPath(h.Principal):
+		return &resource{kind: kindPrincipal, path: cp}, nil
+	case collectionPath(h.Home):
+		return &resource{kind: kindHome, path: cp}, nil
+	case "/":
+		return &resource{kind: kindRoot, path: cp}, nil
+	}
+	if !h.owns(cp) {
+		return nil, ErrNotFound
+	}
+	if h.isOutbox(cp) {
+		return &resource{kind: kindOutbox, path: cp}, nil
+	}
+	if c, err := h.Storage.Calendar(r.Context(), cp); err == nil {
+		return &resource{kind: kindCalendar, path: cp, calendar: c}, nil
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+	o, err := h.Storage.Object(r.Context(), path.Clean(p))
+	if err != nil {
+		return nil, err
+	}
+	return &resource{kind: kindObject, path: o.Path, object: o}, nil
+}
+
+// members returns the resources in the collection res.
+func (h *Handler) members(r *http.Request, res *resource) ([]*resource, error) {
+	var members []*resource
+	switch res.kind {
+	case kindHome:
+		cs, err := h.Storage.Calendars(r.Context(), res.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cs {
+			members = append(members, &resource{kind: kindCalendar, path: c.Path, calendar: c})
+		}
+		if h.Scheduler != nil {
+			if u := h.Scheduler.User(h.Address); u != nil {
+				members = append(members, &resource{kind: kindOutbox, path: u.Outbox()})
+			}
+		}
+	case kindCalendar:
+		objs, err := h.Storage.Objects(r.Context(), res.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objs {
+			members = append(members, &resource{kind: kindObject, path: o.Path, object: o})
+		}
+	}
+	return members, nil
+}
+
+func href(p string) *element {
+	return textElement(nsDAV, "href", (&url.URL{Path: p}).EscapedPath())
+}
+
+// properties returns the live properties of res.
+func (h *Handler) properties(r *http.Request, res *resource) []*element {
+	props := []*element{
+		newElement(nsDAV, "current-user-principal", href(h.Principal)),
+	}
+	types := newElement(nsDAV, "resourcetype")
+	switch res.kind {
+	case kindRoot, kindHome:
+		types.children = append(types.children, newElement(nsDAV, "collection"))
+	case kindPrincipal:
+		types.children = append(types.children, newElement(nsDAV, "collection"), newElement(nsDAV, "principal"))
+		props = append(props,
+			newElement(nsDAV, "principal-URL", href(h.Principal)),
+			newElement(nsCalDAV, "calendar-home-set", href(h.Home)))
+		if h.Scheduler != nil {
+			props = append(props, h.scheduleProperties()...)
+		}
+	case kindCalendar:
+		c := res.calendar
+		if h.isInbox(c.Path) {
+			types.children = append(types.children, newElement(nsDAV, "collection"), newElement(nsCalDAV, "schedule-inbox"))
+		} else {
+			types.children = append(types.children, newElement(nsDAV, "collection"), newElement(nsCalDAV, "calendar"))
+		}
+		if c.DisplayName != "" {
+			props = append(props, textElement(nsDAV, "displayname", c.DisplayName))
+		}
+		if c.Description != "" {
+			props = append(props, textElement(nsCalDAV, "calendar-description", c.Description))
+		}
+		comps := c.Components
+		if len(comps) == 0 {
+			comps = []string{"VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY"}
+		}
+		set := newElement(nsCalDAV, "supported-calendar-component-set")
+		for _, name := range comps {
+			set.children = append(set.children, newElement(nsCalDAV, "comp").attr("name", strings.ToUpper(name)))
+		}
+		reports := newElement(nsDAV, "supported-report-set")
+		for _, name := range []string{"calendar-query", "calendar-multiget"} {
+			reports.children = append(reports.children,
+				newElement(nsDAV, "supported-report", newElement(nsDAV, "report", newElement(nsCalDAV, name))))
+		}
+		if s, ok := h.Storage.(SyncStorage); ok {
+			reports.children = append(reports.children,
+				newElement(nsDAV, "supported-report", newElement(nsDAV, "report", newElement(nsDAV, "sync-collection"))))
+			if token, err := s.SyncToken(r.Context(), c.Path); err == nil {
+				props = append(props, textElement(nsDAV, "sync-token", token))
+			}
+		}
+		props = append(props, set,
+			newElement(nsCalDAV, "supported-calendar-data",
+				newElement(nsCalDAV, "calendar-data").attr("content-type", "text/calendar").attr("version", "2.0")),
+			reports)
+	case kindOutbox:
+		types.children = append(types.children, newElement(nsDAV, "collection"), newElement(nsCalDAV, "schedule-outbox"))
+	case kindObject:
+		o := res.object
+		props = append(props,
+			textElement(nsDAV, "getetag", quote(o.ETag)),
+			textElement(nsDAV, "getcontenttype", "text/calendar; charset=utf-8"),
+			textElement(nsDAV, "getcontentlength", strconv.Itoa(len(o.Data))))
+		if !o.ModTime.IsZero() {
+			props = append(props, textElement(nsDAV, "getlastmodified", o.ModTime.UTC().Format(http.TimeFormat)))
+		}
+	}
+	privileges := newElement(nsDAV, "current-user-privilege-set")
+	for _, name := range []string{"read", "write", "write-properties", "write-content", "bind", "unbind"} {
+		privileges.children = append(privileges.children, newElement(nsDAV, "privilege", newElement(nsDAV, name)))
+	}
+	return append(props, types, privileges)
+}
+
+// propRequest is the prop, allprop or propname element of a request.
+type propRequest struct {
+	all   bool
+	names bool
+	props []xml.Name
+}
+
+func parsePropRequest(n *node) *propRequest {
+	if n == nil || n.child(nsDAV, "allprop") != nil {
+		return &propRequest{all: true}
+	}
+	if n.child(nsDAV, "propname") != nil {
+		return &propRequest{names: true}
+	}
+	req := &propRequest{}
+	if prop := n.child(nsDAV, "prop"); prop != nil {
+		for _, p := range prop.Nodes {
+			req.props = append(req.props, p.XMLName)
+		}
+	}
+	return req
+}
+
+func status(code int) *element {
+	return textElement(nsDAV, "status", fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code)))
+}
+
+// response returns the DAV:response of res for req, out of the
+// properties props.
+func response(p string, props []*element, req *propRequest) *element {
+	resp := newElement(nsDAV, "response", href(p))
+	found := newElement(nsDAV, "prop")
+	missing := newElement(nsDAV, "prop")
+	switch {
+	case req.all:
+		found.children = props
+	case req.names:
+		for _, e := range props {
+			found.children = append(found.children, &element{name: e.name})
+		}
+	default:
+		for _, name := range req.props {
+			var match *element
+			for _, e := range props {
+				if e.name == name {
+					match = e
+					break
+				}
+			}
+			if match != nil {
+				found.children = append(found.children, match)
+			} else {
+				missing.children = append(missing.children, &element{name: name})
+			}
+		}
+	}
+	if len(found.children) > 0 || len(missing.children) == 0 {
+		resp.children = append(resp.children, newElement(nsDAV, "propstat", found, status(http.StatusOK)))
+	}
+	if len(missing.children) > 0 {
+		resp.children = append(resp.children, newElement(nsDAV, "propstat", missing, status(http.StatusNotFound)))
+	}
+	return resp
+}
+
+func writeMultiStatus(w http.ResponseWriter, responses []*element) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, newElement(nsDAV, "multistatus", responses...).String())
+}
+
+func readBody(r *http.Request) (*node, error) {
+	n, err := readNode(r.Body)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "invalid XML body: %v", err)
+	}
+	return n, nil
+}
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request) error {
+	n, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	if n != nil && n.XMLName != (xml.Name{Space: nsDAV, Local: "propfind"}) {
+		return errorf(http.StatusBadRequest, "expected a DAV:propfind body")
+	}
+	req := parsePropRequest(n)
+	res, err := h.resolve(r, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	resources := []*resource{res}
+	// infinite depth is served as depth 1
+	if r.Header.Get("Depth") != "0" {
+		members, err := h.members(r, res)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, members...)
+	}
+	var responses []*element
+	for _, res := range resources {
+		responses = append(responses, response(res.path, h.properties(r, res), req))
+	}
+	writeMultiStatus(w, responses)
+	return nil
+}
+
+func (h *Handler) report(w http.ResponseWriter, r *http.Request) error {
+	n, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	if n == nil {
+		return errorf(http.StatusBadRequest, "REPORT without body")
+	}
+	res, err := h.resolve(r, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	req := parsePropRequest(n)
+	var objs []*resource
+	switch n.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		objs, err = h.query(r, res, n)
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		var responses []*element
+		for _, hr := range n.children(nsDAV, "href") {
+			p, err := hrefPath(hr.Text)
+			if err != nil {
+				return err
+			}
+			var o *Object
+			if h.owns(p) {
+				o, err = h.Storage.Object(r.Context(), p)
+			} else {
+				err = ErrNotFound
+			}
+			if err == ErrNotFound {
+				responses = append(responses, newElement(nsDAV, "response", href(p), status(http.StatusNotFound)))
+				continue
+			} else if err != nil {
+				return err
+			}
+			obj := &resource{kind: kindObject, path: o.Path, object: o}
+			responses = append(responses, response(obj.path, h.reportProperties(r, obj), req))
+		}
+		writeMultiStatus(w, responses)
+		return nil
+	case xml.Name{Space: nsDAV, Local: "sync-collection"}:
+		return h.syncCollection(w, r, res, n, req)
+	default:
+		return preconditionError(nsDAV, "supported-report")
+	}
+	if err != nil {
+		return err
+	}
+	var responses []*element
+	for _, obj := range objs {
+		responses = append(responses, response(obj.path, h.reportProperties(r, obj), req))
+	}
+	writeMultiStatus(w, responses)
+	return nil
+}
+
+// reportProperties returns the properties of an object resource,
+// including its calendar data.
+func (h *Handler) reportProperties(r *http.Request, res *resource) []*element {
+	return append(h.properties(r, res), textElement(nsCalDAV, "calendar-data", string(res.object.Data)))
+}
+
+//   RFC 6578 3.2.  DAV:sync-collection Report
+//
+//      If the DAV:sync-token element value is the empty string, the
+//      server MUST return all member URLs of the collection.  Otherwise,
+//      it returns the member URLs that were mapped, changed or removed
+//      since the state the token represents, together with a new
+//      DAV:sync-token.  Removed members are reported with the status
+//      404.
+//
+//      If the token is not valid, the server MUST fail the request with
+//      the DAV:valid-sync-token precondition.
+
+func (h *Handler) syncCollection(w http.ResponseWriter, r *http.Request, res *resource, n *node, req *propRequest) error {
+	s, ok := h.Storage.(SyncStorage)
+	if !ok || res.kind != kindCalendar {
+		return preconditionError(nsDAV, "supported-report")
+	}
+	if l := n.child(nsDAV, "sync-level"); l != nil && strings.TrimSpace(l.Text) != "1" {
+		return preconditionError(nsDAV, "sync-traversal-supported")
+	}
+	token := ""
+	if t := n.child(nsDAV, "sync-token"); t != nil {
+		token = strings.TrimSpace(t.Text)
+	}
+	changed, removed, newToken, err := s.Changes(r.Context(), res.path, token)
+	if err == ErrInvalidSyncToken {
+		return preconditionError(nsDAV, "valid-sync-token")
+	} else if err != nil {
+		return err
+	}
+	var responses []*element
+	for _, o := range changed {
+		obj := &resource{kind: kindObject, path: o.Path, object: o}
+		responses = append(responses, response(obj.path, h.reportProperties(r, obj), req))
+	}
+	for _, p := range removed {
+		responses = append(responses, newElement(nsDAV, "response", href(p), status(http.StatusNotFound)))
+	}
+	responses = append(responses, textElement(nsDAV, "sync-token", newToken))
+	writeMultiStatus(w, responses)
+	return nil
+}
+
+// hrefPath returns the path of an href, which may be a full URL.  The
+// paths of collections keep their trailing slash.
+func hrefPath(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return "", errorf(http.StatusBadRequest, "invalid href %q", s)
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		return collectionPath(u.Path), nil
+	}
+	return path.Clean(u.Path), nil
+}
+
+// query returns the object resources of res that match the filter of
+// the calendar-query n.
+func (h *Handler) query(r *http.Request, res *resource, n *node) ([]*resource, error) {
+	fn := n.child(nsCalDAV, "filter")
+	if fn == nil || fn.child(nsCalDAV, "comp-filter") == nil {
+		return nil, preconditionError(nsCalDAV, "valid-filter")
+	}
+	f, err := parseFilter(fn.child(nsCalDAV, "comp-filter"))
+	switch err {
+	case nil:
+	case filter.ErrUnsupportedCollation:
+		return nil, preconditionError(nsCalDAV, "supported-collation")
+	case filter.ErrUnsupportedFilter:
+		return nil, preconditionError(nsCalDAV, "supported-filter")
+	default:
+		return nil, preconditionError(nsCalDAV, "valid-filter")
+	}
+	var candidates []*resource
+	switch res.kind {
+	case kindCalendar:
+		if candidates, err = h.members(r, res); err != nil {
+			return nil, err
+		}
+	case kindObject:
+		candidates = []*resource{res}
+	}
+	var matches []*resource
+	for _, c := range candidates {
+		raw, err := objects.ReadRawComponents(bytes.NewReader(c.object.Data))
+		if err != nil || len(raw) != 1 {
+			continue
+		}
+		if f.Match(raw[0]) {
+			matches = append(matches, c)
+		}
+	}
+	return matches, nil
+}
+
+func quote(etag string) string {
+	return `"` + etag + `"`
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header value
+// v matches the resource o, which is nil when it does not exist.
+func etagMatches(v string, o *Object) bool {
+	if o == nil {
+		return false
+	}
+	for _, t := range strings.Split(v, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || strings.Trim(t, `"`) == o.ETag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConditions answers the If-Match and If-None-Match headers of a
+// request that changes the resource o, which is nil when it does not
+// exist.
+func checkConditions(r *http.Request, o *Object) error {
+	if v := r.Header.Get("If-Match"); v != "" && !etagMatches(v, o) {
+		return errorf(http.StatusPreconditionFailed, "If-Match does not match")
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" && etagMatches(v, o) {
+		return errorf(http.StatusPreconditionFailed, "If-None-Match matches")
+	}
+	return nil
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) error {
+	res, err := h.resolve(r, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	if res.kind != kindObject {
+		return errorf(http.StatusMethodNotAllowed, "GET of collections is not supported")
+	}
+	o := res.object
+	w.Header().Set("ETag", quote(o.ETag))
+	if !o.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", o.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" && etagMatches(v, o) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(o.Data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(o.Data)
+	}
+	return nil
+}
+
+// checkObject checks that data is a calendar object resource as RFC 4791
+// 4.1 defines it, and returns the UID and the name of its components.
+func checkObject(data []byte) (uid, comp string, err error) {
+	raw, err := objects.ReadRawComponents(bytes.NewReader(data))
+	if err != nil || len(raw) != 1 || raw[0].Name != "VCALENDAR" {
+		return "", "", preconditionError(nsCalDAV, "valid-calendar-data")
+	}
+	if _, err := objects.DecodeRaw(raw[0]); err != nil {
+		return "", "", preconditionError(nsCalDAV, "valid-calendar-data")
+	}
+	if raw[0].Property("METHOD") != nil {
+		return "", "", preconditionError(nsCalDAV, "valid-calendar-object-resource")
+	}
+	for _, c := range raw[0].Components {
+		if c.Name == "VTIMEZONE" {
+			continue
+		}
+		u := ""
+		if l := c.Property("UID"); l != nil {
+			u = l.Value
+		}
+		if comp != "" && (c.Name != comp || u != uid) {
+			return "", "", preconditionError(nsCalDAV, "valid-calendar-object-resource")
+		}
+		uid, comp = u, c.Name
+	}
+	if comp == "" {
+		return "", "", preconditionError(nsCalDAV, "valid-calendar-object-resource")
+	}
+	return uid, comp, nil
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) error {
+	p := path.Clean("/" + r.URL.Path)
+	if !h.owns(parent(p)) {
+		return errorf(http.StatusForbidden, "%s is outside the calendar home", p)
+	}
+	cal, err := h.Storage.Calendar(r.Context(), parent(p))
+	if err == ErrNotFound {
+		return errorf(http.StatusConflict, "no calendar collection at %s", parent(p))
+	} else if err != nil {
+		return err
+	}
+	if h.isInbox(cal.Path) {
+		return errorf(http.StatusForbidden, "the scheduling Inbox only takes delivered messages")
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "text/calendar" {
+			return preconditionError(nsCalDAV, "supported-calendar-data")
+		}
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorf(http.StatusRequestEntityTooLarge, "%v", err)
+	}
+	uid, comp, err := checkObject(data)
+	if err != nil {
+		return err
+	}
+	if !cal.Supports(comp) {
+		return preconditionError(nsCalDAV, "supported-calendar-component")
+	}
+	current, err := h.Storage.Object(r.Context(), p)
+	if err == ErrNotFound {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+	if err := checkConditions(r, current); err != nil {
+		return err
+	}
+	if o, err := h.Storage.ObjectByUID(r.Context(), cal.Path, uid); err == nil && o.Path != p {
+		return preconditionError(nsCalDAV, "no-uid-conflict", href(o.Path))
+	} else if err != nil && err != ErrNotFound {
+		return err
+	}
+	stored := data
+	if h.Scheduler != nil {
+		if stored, err = h.schedule(r.Context(), current, data); err != nil {
+			return err
+		}
+	}
+	// the conditions are checked again when the object is written, so
+	// that concurrent requests cannot both pass them
+	ifMatch := AnyETag
+	if r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != "" {
+		ifMatch = ""
+		if current != nil {
+			ifMatch = current.ETag
+		}
+	}
+	o, err := h.Storage.PutObject(r.Context(), p, stored, ifMatch)
+	if err == ErrModified {
+		return errorf(http.StatusPreconditionFailed, "%s was changed concurrently", p)
+	} else if err != nil {
+		return err
+	}
+	// RFC 6638 3.2.  a server that changes the data must not return an
+	// ETag, so that clients fetch it again
+	if bytes.Equal(stored, data) {
+		w.Header().Set("ETag", quote(o.ETag))
+	}
+	if current == nil {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) error {
+	res, err := h.resolve(r, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	switch res.kind {
+	case kindObject:
+		if err := checkConditions(r, res.object); err != nil {
+			return err
+		}
+		if h.Scheduler != nil && !h.isInbox(parent(res.path)) {
+			if _, err = h.schedule(r.Context(), res.object, nil); err != nil {
+				return err
+			}
+		}
+		err = h.Storage.DeleteObject(r.Context(), res.path)
+	case kindCalendar:
+		if h.isInbox(res.path) {
+			return errorf(http.StatusForbidden, "%s cannot be deleted", res.path)
+		}
+		err = h.Storage.DeleteCalendar(r.Context(), res.path)
+	default:
+		return errorf(http.StatusForbidden, "%s cannot be deleted", res.path)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+//   RFC 4791 5.3.1.  Creating Calendar Collections
+//
+//      The MKCALENDAR method creates a new calendar collection resource.
+//      A server MAY restrict calendar collection creation to particular
+//      collections.
+//
+//      Clients SHOULD use the DAV:displayname property for a human-
+//      readable name of the calendar.  Clients can either specify the
+//      value of the DAV:displayname property in the request body of the
+//      MKCALENDAR request, or alternatively issue a PROPPATCH request to
+//      change the DAV:displayname property to the appropriate value
+//      immediately after issuing the MKCALENDAR request.
+
+func (h *Handler) mkcalendar(w http.ResponseWriter, r *http.Request) error {
+	p := collectionPath(r.URL.Path)
+	if parent(p) != collectionPath(h.Home) {
+		return preconditionError(nsCalDAV, "calendar-collection-location-ok")
+	}
+	if _, err := h.resolve(r, p); err == nil {
+		return preconditionError(nsDAV, "resource-must-be-null")
+	} else if err != ErrNotFound {
+		return err
+	}
+	n, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	c := &Collection{Path: p}
+	if n != nil {
+		if n.XMLName != (xml.Name{Space: nsCalDAV, Local: "mkcalendar"}) {
+			return errorf(http.StatusBadRequest, "expected a CALDAV:mkcalendar body")
+		}
+		for _, set := range n.children(nsDAV, "set") {
+			prop := set.child(nsDAV, "prop")
+			if prop == nil {
+				continue
+			}
+			if d := prop.child(nsDAV, "displayname"); d != nil {
+				c.DisplayName = d.Text
+			}
+			if d := prop.child(nsCalDAV, "calendar-description"); d != nil {
+				c.Description = d.Text
+			}
+			if s := prop.child(nsCalDAV, "supported-calendar-component-set"); s != nil {
+				for _, comp := range s.children(nsCalDAV, "comp") {
+					if name, ok := comp.attr("name"); ok {
+						c.Components = append(c.Components, strings.ToUpper(name))
+					}
+				}
+			}
+		}
+	}
+	if err := h.Storage.CreateCalendar(r.Context(), c); err == ErrExists {
+		return preconditionError(nsDAV, "resource-must-be-null")
+	} else if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
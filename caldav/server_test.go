@@ -0,0 +1,299 @@
+package caldav
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const weekly = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:weekly@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T090000Z
+DURATION:PT1H
+RRULE:FREQ=WEEKLY
+SUMMARY:Team meeting
+END:VEVENT
+END:VCALENDAR
+`
+
+const once = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:once@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240301T090000Z
+DTEND:20240301T100000Z
+SUMMARY:Dentist
+END:VEVENT
+END:VCALENDAR
+`
+
+func do(t *testing.T, s *httptest.Server, method, path, body string, header ...string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	s := httptest.NewServer(NewHandler(NewMemoryStorage()))
+	resp, body := do(t, s, "MKCALENDAR", "/calendars/work/", `<?xml version="1.0"?>
+<c:mkcalendar xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:set><d:prop>
+    <d:displayname>Work</d:displayname>
+    <c:supported-calendar-component-set><c:comp name="VEVENT"/></c:supported-calendar-component-set>
+  </d:prop></d:set>
+</c:mkcalendar>`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MKCALENDAR: %s %s", resp.Status, body)
+	}
+	return s
+}
+
+func TestHandler_PutGetDelete(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	resp, _ := do(t, s, "PUT", "/calendars/work/weekly.ics", weekly, "Content-Type", "text/calendar", "If-None-Match", "*")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: %s", resp.Status)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("PUT without ETag")
+	}
+
+	resp, body := do(t, s, "GET", "/calendars/work/weekly.ics", "")
+	if resp.StatusCode != http.StatusOK || body != weekly || resp.Header.Get("ETag") != etag {
+		t.Errorf("GET: %s %q %s", resp.Status, body, resp.Header.Get("ETag"))
+	}
+	if resp, _ := do(t, s, "GET", "/calendars/work/weekly.ics", "", "If-None-Match", etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional GET: %s", resp.Status)
+	}
+
+	// creating it again or updating with an old ETag fails
+	if resp, _ := do(t, s, "PUT", "/calendars/work/weekly.ics", weekly, "If-None-Match", "*"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with If-None-Match: %s", resp.Status)
+	}
+	if resp, _ := do(t, s, "PUT", "/calendars/work/weekly.ics", weekly, "If-Match", `"old"`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with old If-Match: %s", resp.Status)
+	}
+	changed := strings.Replace(weekly, "Team meeting", "Team sync", 1)
+	resp, _ = do(t, s, "PUT", "/calendars/work/weekly.ics", changed, "If-Match", etag)
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("ETag") == etag {
+		t.Errorf("PUT with If-Match: %s %s", resp.Status, resp.Header.Get("ETag"))
+	}
+
+	// the same UID under another name
+	resp, body = do(t, s, "PUT", "/calendars/work/other.ics", weekly)
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(body, "no-uid-conflict") || !strings.Contains(body, "/calendars/work/weekly.ics") {
+		t.Errorf("PUT with UID conflict: %s %s", resp.Status, body)
+	}
+	// the collection takes events only
+	todo := strings.Replace(strings.Replace(once, "VEVENT", "VTODO", -1), "DTEND", "DUE", 1)
+	if resp, body := do(t, s, "PUT", "/calendars/work/todo.ics", todo); !strings.Contains(body, "supported-calendar-component") {
+		t.Errorf("PUT of a VTODO: %s %s", resp.Status, body)
+	}
+	if resp, body := do(t, s, "PUT", "/calendars/work/bad.ics", "BEGIN:VCALENDAR\nEND:VCALENDAR\n"); !strings.Contains(body, "valid-calendar-data") {
+		t.Errorf("PUT of invalid data: %s %s", resp.Status, body)
+	}
+	if resp, _ := do(t, s, "PUT", "/calendars/none/x.ics", once); resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT outside of a calendar: %s", resp.Status)
+	}
+
+	if resp, _ := do(t, s, "DELETE", "/calendars/work/weekly.ics", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE: %s", resp.Status)
+	}
+	if resp, _ := do(t, s, "GET", "/calendars/work/weekly.ics", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE: %s", resp.Status)
+	}
+}
+
+func TestMemoryStorage_PutObject(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStorage()
+	if err := m.CreateCalendar(ctx, &Collection{Path: "/calendars/work/"}); err != nil {
+		t.Fatal(err)
+	}
+	const p = "/calendars/work/weekly.ics"
+	o, err := m.PutObject(ctx, p, []byte(weekly), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.PutObject(ctx, p, []byte(weekly), ""); err != ErrModified {
+		t.Errorf("creating it again: %v", err)
+	}
+	// of two writers that read the same ETag, only the first succeeds
+	changed := []byte(strings.Replace(weekly, "Team meeting", "Team sync", 1))
+	if _, err := m.PutObject(ctx, p, changed, o.ETag); err != nil {
+		t.Errorf("update: %v", err)
+	}
+	if _, err := m.PutObject(ctx, p, []byte(weekly), o.ETag); err != ErrModified {
+		t.Errorf("update with an old ETag: %v", err)
+	}
+	if _, err := m.PutObject(ctx, p, []byte(weekly), AnyETag); err != nil {
+		t.Errorf("unconditional update: %v", err)
+	}
+
+	if o, err := m.ObjectByUID(ctx, "/calendars/work/", "weekly@example.com"); err != nil || o.Path != p {
+		t.Errorf("ObjectByUID: %v %v", o, err)
+	}
+	if err := m.DeleteObject(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ObjectByUID(ctx, "/calendars/work/", "weekly@example.com"); err != ErrNotFound {
+		t.Errorf("ObjectByUID after DeleteObject: %v", err)
+	}
+}
+
+func TestHandler_Propfind(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	do(t, s, "PUT", "/calendars/work/once.ics", once)
+
+	resp, body := do(t, s, "PROPFIND", "/principals/user/", `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-home-set/></d:prop>
+</d:propfind>`, "Depth", "0")
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "<c:calendar-home-set><d:href>/calendars/</d:href></c:calendar-home-set>") {
+		t.Errorf("PROPFIND of the principal: %s %s", resp.Status, body)
+	}
+
+	resp, body = do(t, s, "PROPFIND", "/calendars/", `<d:propfind xmlns:d="DAV:" xmlns:x="http://example.com/ns/">
+  <d:prop><d:resourcetype/><d:displayname/><x:color/></d:prop>
+</d:propfind>`, "Depth", "1")
+	for _, s := range []string{
+		"<d:href>/calendars/work/</d:href>",
+		"<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>",
+		"<d:displayname>Work</d:displayname>",
+		"<x1:color/>",
+		"HTTP/1.1 404 Not Found",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("PROPFIND of the home: %s lacks %s", body, s)
+		}
+	}
+
+	resp, body = do(t, s, "PROPFIND", "/calendars/work/", "", "Depth", "1")
+	if !strings.Contains(body, "<d:href>/calendars/work/once.ics</d:href>") || !strings.Contains(body, "<d:getetag>") {
+		t.Errorf("PROPFIND of the calendar: %s %s", resp.Status, body)
+	}
+
+	resp, _ = do(t, s, "PROPFIND", "/.well-known/caldav", "")
+	if resp.Request.URL.Path != "/principals/user/" {
+		t.Errorf("/.well-known/caldav led to %s", resp.Request.URL.Path)
+	}
+}
+
+func TestHandler_Report(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	do(t, s, "PUT", "/calendars/work/weekly.ics", weekly)
+	do(t, s, "PUT", "/calendars/work/once.ics", once)
+
+	query := func(filter string) string {
+		_, body := do(t, s, "REPORT", "/calendars/work/", `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR">`+filter+`</c:comp-filter></c:filter>
+</c:calendar-query>`, "Depth", "1")
+		return body
+	}
+	// only the weekly event has an instance in the middle of February
+	body := query(`<c:comp-filter name="VEVENT"><c:time-range start="20240212T000000Z" end="20240213T000000Z"/></c:comp-filter>`)
+	if !strings.Contains(body, "weekly.ics") || strings.Contains(body, "once.ics") || !strings.Contains(body, "UID:weekly@example.com") {
+		t.Errorf("time-range query: %s", body)
+	}
+	body = query(`<c:comp-filter name="VEVENT"><c:prop-filter name="SUMMARY"><c:text-match>dentist</c:text-match></c:prop-filter></c:comp-filter>`)
+	if strings.Contains(body, "weekly.ics") || !strings.Contains(body, "once.ics") {
+		t.Errorf("text-match query: %s", body)
+	}
+	body = query(`<c:comp-filter name="VEVENT"><c:prop-filter name="RRULE"><c:is-not-defined/></c:prop-filter></c:comp-filter>`)
+	if strings.Contains(body, "weekly.ics") || !strings.Contains(body, "once.ics") {
+		t.Errorf("is-not-defined query: %s", body)
+	}
+	body = query(`<c:comp-filter name="VEVENT"><c:prop-filter name="RRULE" or-not-defined="yes"><c:text-match>daily</c:text-match></c:prop-filter></c:comp-filter>`)
+	if strings.Contains(body, "weekly.ics") || !strings.Contains(body, "once.ics") {
+		t.Errorf("or-not-defined query: %s", body)
+	}
+	body = query(`<c:comp-filter name="VEVENT"><c:prop-filter name="SUMMARY"><c:text-match collation="i;unknown">x</c:text-match></c:prop-filter></c:comp-filter>`)
+	if !strings.Contains(body, "supported-collation") {
+		t.Errorf("unknown collation: %s", body)
+	}
+
+	resp, body := do(t, s, "REPORT", "/calendars/work/", `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop>
+  <d:href>/calendars/work/once.ics</d:href>
+  <d:href>/calendars/work/missing.ics</d:href>
+</c:calendar-multiget>`, "Depth", "1")
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "UID:once@example.com") ||
+		!strings.Contains(body, "<d:href>/calendars/work/missing.ics</d:href><d:status>HTTP/1.1 404 Not Found</d:status>") {
+		t.Errorf("calendar-multiget: %s %s", resp.Status, body)
+	}
+}
+
+func TestHandler_OtherHome(t *testing.T) {
+	storage := NewMemoryStorage()
+	alice := httptest.NewServer(&Handler{Storage: storage, Principal: "/principals/alice/", Home: "/calendars/alice/"})
+	defer alice.Close()
+	bob := httptest.NewServer(&Handler{Storage: storage, Principal: "/principals/bob/", Home: "/calendars/bob/"})
+	defer bob.Close()
+	for _, s := range []*httptest.Server{alice, bob} {
+		home := "/calendars/alice/"
+		if s == bob {
+			home = "/calendars/bob/"
+		}
+		if resp, body := do(t, s, "MKCALENDAR", home+"work/", ""); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("MKCALENDAR: %s %s", resp.Status, body)
+		}
+	}
+	if resp, _ := do(t, bob, "PUT", "/calendars/bob/work/once.ics", once); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT: %s", resp.Status)
+	}
+
+	for _, method := range []string{"GET", "DELETE", "PROPFIND"} {
+		if resp, _ := do(t, alice, method, "/calendars/bob/work/once.ics", ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s of another home: %s", method, resp.Status)
+		}
+	}
+	if resp, _ := do(t, alice, "GET", "/calendars/alice/../bob/work/once.ics", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET with dot segments: %s", resp.Status)
+	}
+	if resp, _ := do(t, alice, "PROPFIND", "/principals/bob/", "", "Depth", "0"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("PROPFIND of another principal: %s", resp.Status)
+	}
+	if resp, _ := do(t, alice, "PUT", "/calendars/bob/work/x.ics", weekly); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PUT into another home: %s", resp.Status)
+	}
+	resp, body := do(t, alice, "REPORT", "/calendars/alice/work/", `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><c:calendar-data/></d:prop>
+  <d:href>/calendars/bob/work/once.ics</d:href>
+</c:calendar-multiget>`, "Depth", "1")
+	if resp.StatusCode != http.StatusMultiStatus || strings.Contains(body, "UID:once@example.com") || !strings.Contains(body, "404 Not Found") {
+		t.Errorf("calendar-multiget of another home: %s %s", resp.Status, body)
+	}
+
+	if resp, body := do(t, bob, "GET", "/calendars/bob/work/once.ics", ""); resp.StatusCode != http.StatusOK || body != once {
+		t.Errorf("GET by the owner: %s", resp.Status)
+	}
+	if resp, _ := do(t, bob, "GET", "/calendars/bob/work/x.ics", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("PUT into another home wrote the object: %s", resp.Status)
+	}
+}
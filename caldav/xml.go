@@ -0,0 +1,147 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// node is an element of a request or response body.
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []node     `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+func (n *node) child(space, local string) *node {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Space == space && n.Nodes[i].XMLName.Local == local {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func (n *node) children(space, local string) []*node {
+	var c []*node
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Space == space && n.Nodes[i].XMLName.Local == local {
+			c = append(c, &n.Nodes[i])
+		}
+	}
+	return c
+}
+
+func (n *node) attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name && a.Name.Space == "" {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// readNode parses an XML body.  An empty body gives a nil node.
+func readNode(r io.Reader) (*node, error) {
+	var n node
+	if err := xml.NewDecoder(r).Decode(&n); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &n, nil
+}
+
+// element is an element of a response body.
+type element struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	text     string
+	children []*element
+}
+
+func newElement(space, local string, children ...*element) *element {
+	return &element{name: xml.Name{Space: space, Local: local}, children: children}
+}
+
+func textElement(space, local, text string) *element {
+	return &element{name: xml.Name{Space: space, Local: local}, text: text}
+}
+
+// attr adds an attribute without namespace to e.
+func (e *element) attr(name, value string) *element {
+	e.attrs = append(e.attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	return e
+}
+
+// prefixes of the namespaces declared on the root of response bodies
+var prefixes = map[string]string{
+	nsDAV:    "d",
+	nsCalDAV: "c",
+}
+
+// String returns e as an XML document, with the namespaces it uses
+// declared on its root.
+func (e *element) String() string {
+	spaces := map[string]string{}
+	e.namespaces(spaces)
+	s := &strings.Builder{}
+	s.WriteString(xml.Header)
+	e.write(s, spaces, true)
+	s.WriteString("\n")
+	return s.String()
+}
+
+func (e *element) namespaces(spaces map[string]string) {
+	if _, ok := spaces[e.name.Space]; !ok {
+		p, ok := prefixes[e.name.Space]
+		if !ok {
+			p = "x" + strconv.Itoa(len(spaces))
+		}
+		spaces[e.name.Space] = p
+	}
+	for _, c := range e.children {
+		c.namespaces(spaces)
+	}
+}
+
+func (e *element) write(s *strings.Builder, spaces map[string]string, root bool) {
+	name := e.name.Local
+	if e.name.Space != "" {
+		name = spaces[e.name.Space] + ":" + name
+	}
+	s.WriteString("<" + name)
+	if root {
+		var decls []string
+		for space, p := range spaces {
+			if space != "" {
+				v := &strings.Builder{}
+				xml.EscapeText(v, []byte(space))
+				decls = append(decls, " xmlns:"+p+"=\""+v.String()+"\"")
+			}
+		}
+		sort.Strings(decls)
+		for _, d := range decls {
+			s.WriteString(d)
+		}
+	}
+	for _, a := range e.attrs {
+		s.WriteString(" " + a.Name.Local + "=\"")
+		xml.EscapeText(s, []byte(a.Value))
+		s.WriteString("\"")
+	}
+	if e.text == "" && len(e.children) == 0 {
+		s.WriteString("/>")
+		return
+	}
+	s.WriteString(">")
+	xml.EscapeText(s, []byte(e.text))
+	for _, c := range e.children {
+		c.write(s, spaces, false)
+	}
+	s.WriteString("</" + name + ">")
+}
@@ -1,5 +1,562 @@
+// Command vcalender reads, checks and converts iCalendar objects.
+//
+// Usage:
+//
+//	vcalender validate [file ...]
+//	vcalender fmt [-w] [file ...]
+//	vcalender convert [-to ics|jcal|xcal] [file ...]
+//	vcalender expand [-from date] [-to date] [-tz zone] [file ...]
+//	vcalender freebusy [-from date] [-to date] [-tz zone] [file ...]
+//
+// Without files, or with the file "-", the standard input is read.  The
+// exit code is 0 on success, 1 when an input is not a valid calendar and
+// 2 on wrong usage or when a file cannot be read or written.
 package main
 
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/jcal"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"github.com/mmsuo/vcalender/objects/xcal"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+const usage = `usage: vcalender <command> [flags] [file ...]
+
+commands:
+  validate  report the errors of calendars with their line numbers
+  fmt       normalize, sort and fold calendars
+  convert   convert between iCalendar, jCal and xCal
+  expand    list the occurrences of events, to-dos and journal entries
+  freebusy  print the busy periods of calendars
+
+Without files, or with the file "-", the standard input is read.
+Run "vcalender <command> -h" for the flags of a command.
+`
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli holds the streams of one run of the command.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "validate":
+		return c.validate(args[1:])
+	case "fmt":
+		return c.fmt(args[1:])
+	case "convert":
+		return c.convert(args[1:])
+	case "expand":
+		return c.expand(args[1:])
+	case "freebusy":
+		return c.freeBusy(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	fmt.Fprintf(stderr, "vcalender: unknown command %q\n", args[0])
+	fmt.Fprint(stderr, usage)
+	return exitUsage
+}
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	f := flag.NewFlagSet("vcalender "+name, flag.ContinueOnError)
+	f.SetOutput(c.stderr)
+	return f
+}
+
+// input is a file named on the command line, or the standard input.
+type input struct {
+	name string
+	data []byte
+}
+
+// inputs reads the files, or the standard input when there are none.
+func (c *cli) inputs(files []string) ([]*input, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var ins []*input
+	for _, name := range files {
+		var data []byte
+		var err error
+		if name == "-" {
+			data, err = ioutil.ReadAll(c.stdin)
+			name = "<stdin>"
+		} else {
+			data, err = ioutil.ReadFile(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ins = append(ins, &input{name: name, data: data})
+	}
+	return ins, nil
+}
+
+// report prints err, prefixed by the name of the input and the line
+// numbers of syntax errors.
+func (c *cli) report(in *input, err error) {
+	switch e := err.(type) {
+	case objects.ErrorList:
+		for _, s := range e {
+			fmt.Fprintf(c.stderr, "%s:%d: %s\n", in.name, s.Line, s.Msg)
+		}
+	case *objects.SyntaxError:
+		fmt.Fprintf(c.stderr, "%s:%d: %s\n", in.name, e.Line, e.Msg)
+	default:
+		fmt.Fprintf(c.stderr, "%s: %v\n", in.name, err)
+	}
+}
+
+// format is the syntax of an input or output.
+type format string
+
+const (
+	formatICS  format = "ics"
+	formatJCal format = "jcal"
+	formatXCal format = "xcal"
+)
+
+// detect guesses the format of data from its first character.
+func detect(data []byte) format {
+	switch s := bytes.TrimLeft(data, " \t\r\n\ufeff"); {
+	case len(s) > 0 && s[0] == '[':
+		return formatJCal
+	case len(s) > 0 && s[0] == '<':
+		return formatXCal
+	}
+	return formatICS
+}
+
+// raw reads the top level components of in, in any of the formats.
+func raw(in *input) ([]*objects.RawComponent, error) {
+	switch detect(in.data) {
+	case formatJCal:
+		r, err := jcal.Decode(in.data)
+		if err != nil {
+			return nil, err
+		}
+		return []*objects.RawComponent{r}, nil
+	case formatXCal:
+		r, err := xcal.Decode(in.data)
+		if err != nil {
+			return nil, err
+		}
+		return []*objects.RawComponent{r}, nil
+	}
+	return objects.ReadRawComponents(bytes.NewReader(in.data))
+}
+
+// calendars decodes the VCALENDAR objects of in.  Other top level
+// components are errors.
+func calendars(in *input) ([]*objects.Calendar, error) {
+	comps, err := raw(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(comps) == 0 {
+		return nil, &objects.SyntaxError{Line: 1, Msg: "no VCALENDAR object"}
+	}
+	var cals []*objects.Calendar
+	var errs objects.ErrorList
+	for _, r := range comps {
+		if r.Name != "VCALENDAR" {
+			errs = append(errs, &objects.SyntaxError{Line: r.Line, Msg: fmt.Sprintf("%s outside of a VCALENDAR", r.Name)})
+			continue
+		}
+		cal, err := objects.DecodeRaw(r)
+		if err != nil {
+			errs = append(errs, err.(objects.ErrorList)...)
+		}
+		cals = append(cals, cal)
+	}
+	if len(errs) > 0 {
+		return cals, errs
+	}
+	return cals, nil
+}
+
+func (c *cli) validate(args []string) int {
+	f := c.flags("validate")
+	if err := f.Parse(args); err != nil {
+		return exitUsage
+	}
+	ins, err := c.inputs(f.Args())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "vcalender: %v\n", err)
+		return exitUsage
+	}
+	code := exitOK
+	for _, in := range ins {
+		if _, err := calendars(in); err != nil {
+			c.report(in, err)
+			code = exitInvalid
+		}
+	}
+	return code
+}
+
+func (c *cli) fmt(args []string) int {
+	f := c.flags("fmt")
+	write := f.Bool("w", false, "write the result to the files instead of the standard output")
+	if err := f.Parse(args); err != nil {
+		return exitUsage
+	}
+	ins, err := c.inputs(f.Args())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "vcalender: %v\n", err)
+		return exitUsage
+	}
+	code := exitOK
+	for _, in := range ins {
+		cals, err := calendars(in)
+		if err != nil {
+			// formatting would drop what could not be read
+			c.report(in, err)
+			code = exitInvalid
+			continue
+		}
+		s := &strings.Builder{}
+		for _, cal := range cals {
+			sortComponents(cal.Components)
+			text, err := cal.Calendar()
+			if err != nil {
+				c.report(in, err)
+				return exitInvalid
+			}
+			s.WriteString(objects.Fold(text))
+		}
+		if *write && in.name != "<stdin>" {
+			if err := ioutil.WriteFile(in.name, []byte(s.String()), 0666); err != nil {
+				fmt.Fprintf(c.stderr, "vcalender: %v\n", err)
+				return exitUsage
+			}
+			continue
+		}
+		io.WriteString(c.stdout, s.String())
+	}
+	return code
+}
+
+// sortKey returns what components are ordered by: time zones go first,
+// then events, to-dos, journal entries and free/busy information, each
+// by UID, with the master component before its overrides.
+func sortKey(c components.Component) (rank int, uid string, recurId, start time.Time) {
+	switch v := c.(type) {
+	case *components.TimeZone:
+		if v.TzId != nil && v.TzId.Value != nil {
+			uid = v.TzId.Value.V
+		}
+		return 0, uid, recurId, start
+	case *components.Event:
+		rank = 1
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.RecurId != nil {
+			recurId, _ = objects.TimeOf(v.RecurId.Parameters, v.RecurId.Value)
+		}
+		if v.DtStart != nil {
+			start, _ = objects.TimeOf(v.DtStart.Parameters, v.DtStart.Value)
+		}
+	case *components.Todo:
+		rank = 2
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.RecurId != nil {
+			recurId, _ = objects.TimeOf(v.RecurId.Parameters, v.RecurId.Value)
+		}
+		if v.DtStart != nil {
+			start, _ = objects.TimeOf(v.DtStart.Parameters, v.DtStart.Value)
+		}
+	case *components.Journal:
+		rank = 3
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.RecurId != nil {
+			recurId, _ = objects.TimeOf(v.RecurId.Parameters, v.RecurId.Value)
+		}
+		if v.DtStart != nil {
+			start, _ = objects.TimeOf(v.DtStart.Parameters, v.DtStart.Value)
+		}
+	case *components.FreeBusy:
+		rank = 4
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.DtStart != nil {
+			start, _ = objects.TimeOf(v.DtStart.Parameters, v.DtStart.Value)
+		}
+	default:
+		rank = 5
+	}
+	return rank, uid, recurId, start
+}
+
+func sortComponents(comps []components.Component) {
+	sort.SliceStable(comps, func(i, j int) bool {
+		ri, ui, ii, si := sortKey(comps[i])
+		rj, uj, ij, sj := sortKey(comps[j])
+		switch {
+		case ri != rj:
+			return ri < rj
+		case ui != uj:
+			return ui < uj
+		case !ii.Equal(ij):
+			return ii.Before(ij)
+		}
+		return si.Before(sj)
+	})
+}
+
+func (c *cli) convert(args []string) int {
+	f := c.flags("convert")
+	to := f.String("to", "", "output format: ics, jcal or xcal (default ics, or jcal for iCalendar input)")
+	if err := f.Parse(args); err != nil {
+		return exitUsage
+	}
+	switch format(*to) {
+	case "", formatICS, formatJCal, formatXCal:
+	default:
+		fmt.Fprintf(c.stderr, "vcalender: unknown format %q\n", *to)
+		return exitUsage
+	}
+	ins, err := c.inputs(f.Args())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "vcalender: %v\n", err)
+		return exitUsage
+	}
+	code := exitOK
+	for _, in := range ins {
+		out := format(*to)
+		if out == "" {
+			out = formatICS
+			if detect(in.data) == formatICS {
+				out = formatJCal
+			}
+		}
+		comps, err := raw(in)
+		if err != nil {
+			c.report(in, err)
+			code = exitInvalid
+			continue
+		}
+		for _, r := range comps {
+			var data []byte
+			switch out {
+			case formatJCal:
+				data, err = jcal.Encode(r)
+				data = append(data, '\n')
+			case formatXCal:
+				data, err = xcal.Encode(r)
+			default:
+				data = []byte(objects.Fold(r.String()))
+			}
+			if err != nil {
+				c.report(in, err)
+				code = exitInvalid
+				continue
+			}
+			c.stdout.Write(data)
+		}
+	}
+	return code
+}
+
+// window holds the flags of the commands that work on a time range.
+type window struct {
+	from, to, tz *string
+}
+
+func (c *cli) windowFlags(f *flag.FlagSet) *window {
+	return &window{
+		from: f.String("from", "", "start of the range, as 2006-01-02, 2006-01-02T15:04:05 or RFC 3339 (default today)"),
+		to:   f.String("to", "", "end of the range, excluded (default one year after the start)"),
+		tz:   f.String("tz", "", "time zone for floating times and the output (default the local one)"),
+	}
 }
 
+// parseTime reads a flag value in one of the formats of windowFlags, in
+// loc unless it has an offset.
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02", "20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// resolve returns the range and location of the flags.  The location
+// becomes time.Local, so that floating times of the calendars are read
+// in it.
+func (w *window) resolve() (from, to time.Time, err error) {
+	if *w.tz != "" {
+		loc, err := time.LoadLocation(*w.tz)
+		if err != nil {
+			return from, to, err
+		}
+		time.Local = loc
+	}
+	if *w.from == "" {
+		y, m, d := time.Now().In(time.Local).Date()
+		from = time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	} else if from, err = parseTime(*w.from, time.Local); err != nil {
+		return from, to, err
+	}
+	if *w.to == "" {
+		to = from.AddDate(1, 0, 0)
+	} else if to, err = parseTime(*w.to, time.Local); err != nil {
+		return from, to, err
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("the end of the range is not after its start")
+	}
+	return from, to, nil
+}
+
+// window decodes the inputs of a command with a time range and returns
+// their calendars with the range.
+func (c *cli) window(name string, args []string) ([]*decoded, time.Time, time.Time, int) {
+	f := c.flags(name)
+	w := c.windowFlags(f)
+	if err := f.Parse(args); err != nil {
+		return nil, time.Time{}, time.Time{}, exitUsage
+	}
+	from, to, err := w.resolve()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "vcalender: %v\n", err)
+		return nil, from, to, exitUsage
+	}
+	ins, err := c.inputs(f.Args())
+	if err != nil {
+		fmt.Fprintf(c.stderr, "vcalender: %v\n", err)
+		return nil, from, to, exitUsage
+	}
+	code := exitOK
+	var all []*decoded
+	for _, in := range ins {
+		cals, err := calendars(in)
+		if err != nil {
+			c.report(in, err)
+			code = exitInvalid
+			continue
+		}
+		for _, cal := range cals {
+			all = append(all, &decoded{in: in, cal: cal})
+		}
+	}
+	return all, from, to, code
+}
+
+// decoded is a calendar with the input it was read from.
+type decoded struct {
+	in  *input
+	cal *objects.Calendar
+}
+
+func (c *cli) expand(args []string) int {
+	cals, from, to, code := c.window("expand", args)
+	var occ []*objects.Occurrence
+	for _, d := range cals {
+		o, err := d.cal.Occurrences(from, to)
+		if err != nil {
+			c.report(d.in, err)
+			code = exitInvalid
+			continue
+		}
+		occ = append(occ, o...)
+	}
+	sort.SliceStable(occ, func(i, j int) bool { return occ[i].Start.Before(occ[j].Start) })
+	for _, o := range occ {
+		start, end := o.Start.In(time.Local).Format(time.RFC3339), o.End.In(time.Local).Format(time.RFC3339)
+		if o.AllDay {
+			start, end = o.Start.Format("2006-01-02"), o.End.Format("2006-01-02")
+		}
+		uid, summary := describe(o.Component)
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\t%s\n", start, end, summary, uid)
+	}
+	return code
+}
+
+// describe returns the UID and summary of an event, to-do or journal
+// entry.
+func describe(comp components.Component) (uid, summary string) {
+	switch v := comp.(type) {
+	case *components.Event:
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.Summary != nil && v.Summary.Value != nil {
+			summary = v.Summary.Value.V
+		}
+	case *components.Todo:
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.Summary != nil && v.Summary.Value != nil {
+			summary = v.Summary.Value.V
+		}
+	case *components.Journal:
+		if v.Uid != nil && v.Uid.Value != nil {
+			uid = v.Uid.Value.V
+		}
+		if v.Summary != nil && v.Summary.Value != nil {
+			summary = v.Summary.Value.V
+		}
+	}
+	// the output has one occurrence per line and tabs between fields
+	return uid, strings.NewReplacer("\\n", " ", "\\N", " ", "\t", " ").Replace(types.UnescapeText(summary))
+}
+
+func (c *cli) freeBusy(args []string) int {
+	cals, from, to, code := c.window("freebusy", args)
+	var periods []*objects.BusyPeriod
+	for _, d := range cals {
+		p, err := objects.BusyPeriods(d.cal.Components, from, to)
+		if err != nil {
+			c.report(d.in, err)
+			code = exitInvalid
+			continue
+		}
+		periods = append(periods, p...)
+	}
+	// the periods of different calendars may overlap too
+	for _, p := range objects.MergeBusyPeriods(periods, from, to) {
+		fmt.Fprintf(c.stdout, "%s/%s\t%s\n", p.Start.In(time.Local).Format(time.RFC3339), p.End.In(time.Local).Format(time.RFC3339), p.Type)
+	}
+	return code
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:b@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240105T090000Z
+DTEND:20240105T100000Z
+SUMMARY:Second
+END:VEVENT
+BEGIN:VEVENT
+UID:a@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T090000Z
+DURATION:PT1H
+RRULE:FREQ=DAILY;COUNT=3
+SUMMARY:Daily
+END:VEVENT
+BEGIN:VEVENT
+UID:c@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T093000Z
+DTEND:20240101T110000Z
+TRANSP:TRANSPARENT
+SUMMARY:Free
+END:VEVENT
+END:VCALENDAR
+`
+
+func runTest(args []string, stdin string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	if code, _, _ := runTest(nil, ""); code != exitUsage {
+		t.Errorf("no command: exit code %d, expected %d", code, exitUsage)
+	}
+	if code, _, _ := runTest([]string{"unknown"}, ""); code != exitUsage {
+		t.Errorf("unknown command: exit code %d, expected %d", code, exitUsage)
+	}
+	if code, _, _ := runTest([]string{"validate", "does-not-exist.ics"}, ""); code != exitUsage {
+		t.Errorf("missing file: exit code %d, expected %d", code, exitUsage)
+	}
+}
+
+func TestRun_Validate(t *testing.T) {
+	if code, _, stderr := runTest([]string{"validate"}, testCalendar); code != exitOK {
+		t.Errorf("exit code %d, expected %d: %s", code, exitOK, stderr)
+	}
+	invalid := strings.Replace(testCalendar, "UID:b@example.com\n", "", 1)
+	code, _, stderr := runTest([]string{"validate"}, invalid)
+	if code != exitInvalid {
+		t.Errorf("exit code %d, expected %d", code, exitInvalid)
+	}
+	if !strings.HasPrefix(stderr, "<stdin>:4: ") {
+		t.Errorf("expected the error at line 4, got %q", stderr)
+	}
+}
+
+func TestRun_Fmt(t *testing.T) {
+	code, stdout, stderr := runTest([]string{"fmt"}, testCalendar)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	a, b := strings.Index(stdout, "UID:a@example.com"), strings.Index(stdout, "UID:b@example.com")
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("events not sorted by UID:\n%s", stdout)
+	}
+	if !strings.Contains(stdout, "\r\n") {
+		t.Errorf("expected CRLF line breaks:\n%q", stdout)
+	}
+}
+
+func TestRun_Convert(t *testing.T) {
+	code, jcal, stderr := runTest([]string{"convert", "-to", "jcal"}, testCalendar)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(jcal, `["vcalendar",`) {
+		t.Errorf("unexpected jCal %s", jcal)
+	}
+	code, ics, stderr := runTest([]string{"convert", "-to", "ics"}, jcal)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.Contains(ics, "RRULE:FREQ=DAILY;COUNT=3\r\n") {
+		t.Errorf("RRULE lost in the round trip:\n%s", ics)
+	}
+	code, xcal, stderr := runTest([]string{"convert", "-to", "xcal"}, testCalendar)
+	if code != exitOK || !strings.Contains(xcal, "<freq>DAILY</freq>") {
+		t.Errorf("exit code %d, unexpected xCal %s%s", code, xcal, stderr)
+	}
+}
+
+func TestRun_Expand(t *testing.T) {
+	code, stdout, stderr := runTest([]string{"expand", "-from", "2024-01-02", "-to", "2024-01-06", "-tz", "UTC"}, testCalendar)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	expected := "2024-01-02T09:00:00Z\t2024-01-02T10:00:00Z\tDaily\ta@example.com\n" +
+		"2024-01-03T09:00:00Z\t2024-01-03T10:00:00Z\tDaily\ta@example.com\n" +
+		"2024-01-05T09:00:00Z\t2024-01-05T10:00:00Z\tSecond\tb@example.com\n"
+	if stdout != expected {
+		t.Errorf("got\n%s\nexpected\n%s", stdout, expected)
+	}
+}
+
+func TestRun_FreeBusy(t *testing.T) {
+	code, stdout, stderr := runTest([]string{"freebusy", "-from", "2024-01-01", "-to", "2024-01-02", "-tz", "UTC"}, testCalendar)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if expected := "2024-01-01T09:00:00Z/2024-01-01T10:00:00Z\tBUSY\n"; stdout != expected {
+		t.Errorf("got %q, expected %q", stdout, expected)
+	}
+}
@@ -0,0 +1,436 @@
+// Package builder constructs calendars and events with chained calls
+// instead of the constructors of each property:
+//
+//	e, err := builder.NewEvent().
+//		Summary("Planning").
+//		Start(start, berlin).
+//		Duration(90 * time.Minute).
+//		Attendee("mailto:bob@example.com", builder.WithRole(parameters.Chair), builder.WithRSVP(true)).
+//		Alarm(-15*time.Minute, "Planning").
+//		Build()
+//
+// Errors of the calls are kept and returned by Build, which also fills in
+// the DTSTAMP and UID that were not set and checks the start against the
+// end, so that the events it returns are valid.  The calendars built by
+// CalendarBuilder carry a VTIMEZONE for each TZID their events use.
+package builder
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/alarm"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/changemanage"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//   RFC 7986 5.3.  UID Property
+//
+//      This specification updates [RFC5545] to recommend the use of
+//      UUIDs as the values of UID properties.  ...  Implementations
+//      SHOULD generate "UID" property values using a UUID.
+
+// ProdId is the PRODID of the calendars built by NewCalendar.
+const ProdId = "-//vcalender//builder//EN"
+
+// Option sets a parameter of an ATTENDEE or ORGANIZER.
+type Option func(params []parameters.Parameter) []parameters.Parameter
+
+// set returns params with p, replacing a parameter of its type.
+func set(params []parameters.Parameter, p parameters.Parameter) []parameters.Parameter {
+	for i, q := range params {
+		if reflect.TypeOf(q) == reflect.TypeOf(p) {
+			params[i] = p
+			return params
+		}
+	}
+	return append(params, p)
+}
+
+// WithName sets the CN of a calendar user.
+func WithName(name string) Option {
+	return func(params []parameters.Parameter) []parameters.Parameter {
+		return set(params, &parameters.CommonName{V: name})
+	}
+}
+
+// WithRole sets the ROLE of an attendee, such as parameters.Chair.
+func WithRole(role parameters.ParticipationRole) Option {
+	return func(params []parameters.Parameter) []parameters.Parameter {
+		return set(params, &role)
+	}
+}
+
+// WithPartStat sets the PARTSTAT of an attendee, such as
+// parameters.Accepted.
+func WithPartStat(status parameters.PartStat) Option {
+	return func(params []parameters.Parameter) []parameters.Parameter {
+		return set(params, &status)
+	}
+}
+
+// WithCuType sets the CUTYPE of an attendee, such as
+// parameters.RoomCuType.
+func WithCuType(cuType parameters.CuType) Option {
+	return func(params []parameters.Parameter) []parameters.Parameter {
+		return set(params, &cuType)
+	}
+}
+
+// WithRSVP sets the RSVP of an attendee.
+func WithRSVP(rsvp bool) Option {
+	return func(params []parameters.Parameter) []parameters.Parameter {
+		return set(params, &parameters.Rsvp{V: rsvp})
+	}
+}
+
+// EventBuilder builds a VEVENT.  Its methods return the builder so that
+// calls can be chained; the first error is returned by Build.
+type EventBuilder struct {
+	e   *components.Event
+	err error
+
+	start    time.Time
+	loc      *time.Location
+	allDay   bool
+	floating bool
+	end      *time.Time
+	length   *time.Duration
+}
+
+// NewEvent returns a builder of an empty event.
+func NewEvent() *EventBuilder {
+	return &EventBuilder{e: &components.Event{}}
+}
+
+func (b *EventBuilder) fail(format string, a ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf("builder: "+format, a...)
+	}
+}
+
+// UID sets the UID of the event.
+func (b *EventBuilder) UID(uid string) *EventBuilder {
+	b.e.Uid = relationship.NewUid(uid)
+	return b
+}
+
+// Stamp sets the DTSTAMP of the event.
+func (b *EventBuilder) Stamp(t time.Time) *EventBuilder {
+	b.e.DtStamp = &changemanage.DtStamp{Value: &types.DateTime{V: t.UTC(), Format: types.UTCDateTimeFormat}}
+	return b
+}
+
+// Start sets the DTSTART of the event to t in loc.  Times in time.UTC
+// and time.Local, which has no TZID, are written in UTC, and times in
+// other locations as local times with the TZID of the location.  A nil
+// loc is the location of t.
+func (b *EventBuilder) Start(t time.Time, loc *time.Location) *EventBuilder {
+	if loc == nil {
+		loc = t.Location()
+	}
+	b.start, b.loc, b.allDay = t.In(loc), loc, false
+	return b
+}
+
+// Floating makes the start and end of the event floating times: the
+// wall clock times they have in the location of Start, which are the
+// same in every time zone.
+func (b *EventBuilder) Floating() *EventBuilder {
+	b.floating = true
+	return b
+}
+
+// AllDay sets the DTSTART of the event to the date of t, which makes it
+// an all-day event.
+func (b *EventBuilder) AllDay(t time.Time) *EventBuilder {
+	b.start, b.loc, b.allDay = t, t.Location(), true
+	return b
+}
+
+// End sets the DTEND of the event, which is written like the start.  It
+// replaces a duration.
+func (b *EventBuilder) End(t time.Time) *EventBuilder {
+	b.end, b.length = &t, nil
+	return b
+}
+
+// Duration sets the DURATION of the event.  It replaces an end.
+func (b *EventBuilder) Duration(d time.Duration) *EventBuilder {
+	b.end, b.length = nil, &d
+	return b
+}
+
+// Summary sets the SUMMARY of the event.
+func (b *EventBuilder) Summary(s string) *EventBuilder {
+	b.e.Summary = &descriptive.Summary{Value: types.NewText(types.EscapeText(s))}
+	return b
+}
+
+// Description sets the DESCRIPTION of the event.
+func (b *EventBuilder) Description(s string) *EventBuilder {
+	b.e.Description = &descriptive.Description{Value: types.NewText(types.EscapeText(s))}
+	return b
+}
+
+// Location sets the LOCATION of the event.
+func (b *EventBuilder) Location(s string) *EventBuilder {
+	b.e.Location = &descriptive.Location{Values: types.NewText(types.EscapeText(s))}
+	return b
+}
+
+// Status sets the STATUS of the event, one of TENTATIVE, CONFIRMED and
+// CANCELLED.
+func (b *EventBuilder) Status(s string) *EventBuilder {
+	switch s = strings.ToUpper(s); s {
+	case "TENTATIVE", "CONFIRMED", "CANCELLED":
+		b.e.Status = descriptive.NewStatus(s)
+	default:
+		b.fail("invalid STATUS %q of VEVENT", s)
+	}
+	return b
+}
+
+// Categories adds CATEGORIES to the event.
+func (b *EventBuilder) Categories(c ...string) *EventBuilder {
+	if len(c) == 0 {
+		return b
+	}
+	escaped := make([]string, 0, len(c))
+	for _, v := range c {
+		escaped = append(escaped, types.EscapeText(v))
+	}
+	b.e.Categories = append(b.e.Categories, descriptive.NewCategories(escaped...))
+	return b
+}
+
+// Sequence sets the SEQUENCE of the event.
+func (b *EventBuilder) Sequence(n int) *EventBuilder {
+	if n < 0 {
+		b.fail("negative SEQUENCE %d", n)
+		return b
+	}
+	b.e.Seq = changemanage.NewSequence(n)
+	return b
+}
+
+// RRule sets the RRULE of the event, such as "FREQ=WEEKLY;BYDAY=MO".
+func (b *EventBuilder) RRule(rule string) *EventBuilder {
+	r, err := types.ParseRecurRule(rule)
+	if err != nil {
+		b.fail("RRULE: %v", err)
+		return b
+	}
+	b.e.RRule = &recurrence.RRule{V: r}
+	return b
+}
+
+// Organizer sets the ORGANIZER of the event.  Addresses without a
+// scheme, such as "alice@example.com", are mailto addresses.
+func (b *EventBuilder) Organizer(addr string, opts ...Option) *EventBuilder {
+	a, err := address(addr)
+	if err != nil {
+		b.fail("ORGANIZER: %v", err)
+		return b
+	}
+	o := relationship.NewOrganizer(a)
+	for _, opt := range opts {
+		o.Parameters = opt(o.Parameters)
+	}
+	b.e.Organizer = o
+	return b
+}
+
+// Attendee adds an ATTENDEE to the event.  Addresses without a scheme
+// are mailto addresses.
+func (b *EventBuilder) Attendee(addr string, opts ...Option) *EventBuilder {
+	a, err := address(addr)
+	if err != nil {
+		b.fail("ATTENDEE: %v", err)
+		return b
+	}
+	at := relationship.NewAttendee(a)
+	for _, opt := range opts {
+		at.Parameters = opt(at.Parameters)
+	}
+	b.e.Attendee = append(b.e.Attendee, at)
+	return b
+}
+
+// Alarm adds a DISPLAY alarm that shows description at trigger from the
+// start of the event; negative triggers are before the start.
+func (b *EventBuilder) Alarm(trigger time.Duration, description string) *EventBuilder {
+	action := alarm.Display
+	b.e.Alarm = append(b.e.Alarm, &components.Alarm{
+		Action:      &action,
+		Trigger:     &alarm.Trigger{Value: types.NewDuration(trigger)},
+		Description: &descriptive.Description{Value: types.NewText(types.EscapeText(description))},
+	})
+	return b
+}
+
+// Build returns the event, or the first error of the calls.  It sets the
+// DTSTAMP to the current time and the UID to a random UUID when they
+// were not set.  The builder must not be used after Build.
+func (b *EventBuilder) Build() (*components.Event, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.start.IsZero() {
+		return nil, errors.New("builder: VEVENT without DTSTART")
+	}
+	params, v := b.value(b.start)
+	b.e.DtStart = &datetime.DateStart{Parameters: params, Value: v}
+	switch {
+	case b.end != nil:
+		end := b.end.In(b.loc)
+		if b.allDay {
+			if !dateOf(end).After(dateOf(b.start)) {
+				return nil, errors.New("builder: DTEND of an all-day VEVENT is not after DTSTART")
+			}
+		} else if end.Before(b.start) {
+			return nil, errors.New("builder: DTEND of VEVENT is before DTSTART")
+		}
+		params, v := b.value(end)
+		b.e.DtEnd = &datetime.DateEnd{Parameters: params, Value: v}
+	case b.length != nil:
+		d := *b.length
+		if d < 0 {
+			return nil, fmt.Errorf("builder: negative DURATION %v", d)
+		}
+		if b.allDay && d%(24*time.Hour) != 0 {
+			return nil, fmt.Errorf("builder: DURATION %v of an all-day VEVENT is not whole days", d)
+		}
+		b.e.Duration = &datetime.Duration{Value: types.NewDuration(d)}
+	}
+	if b.e.DtStamp == nil {
+		b.Stamp(time.Now())
+	}
+	if b.e.Uid == nil {
+		uid, err := newUID()
+		if err != nil {
+			return nil, err
+		}
+		b.UID(uid)
+	}
+	return b.e, nil
+}
+
+// value returns the parameters and value of a time of the event.
+func (b *EventBuilder) value(t time.Time) ([]parameters.Parameter, types.Value) {
+	switch {
+	case b.allDay:
+		d := dateOf(t)
+		return []parameters.Parameter{&parameters.ValueType{V: parameters.Date.V}}, types.NewDate(d.Year(), int(d.Month()), d.Day())
+	case b.floating:
+		return nil, &types.DateTime{V: t.In(b.loc), Format: types.LocalDateTimeFormat}
+	case b.loc == time.UTC || b.loc == time.Local:
+		return nil, &types.DateTime{V: t.UTC(), Format: types.UTCDateTimeFormat}
+	}
+	return []parameters.Parameter{&parameters.TimeZoneId{V: b.loc.String()}},
+		&types.DateTime{V: t.In(b.loc), Format: types.LocalDateTimeFormat}
+}
+
+// dateOf returns the date of t as midnight in UTC.
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// address returns the calendar user address addr, with the mailto
+// scheme added to bare email addresses.
+func address(addr string) (string, error) {
+	if !strings.Contains(addr, ":") && strings.Contains(addr, "@") {
+		addr = "mailto:" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Opaque == "" && u.Host == "" {
+		return "", fmt.Errorf("%q is not a calendar user address", addr)
+	}
+	return addr, nil
+}
+
+// newUID returns a random (version 4) UUID.
+func newUID() (string, error) {
+	u := make([]byte, 16)
+	if _, err := rand.Read(u); err != nil {
+		return "", fmt.Errorf("builder: UID: %v", err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// CalendarBuilder builds a VCALENDAR.
+type CalendarBuilder struct {
+	c   *objects.Calendar
+	err error
+}
+
+// NewCalendar returns a builder of a VERSION 2.0 calendar with the PRODID
+// ProdId.
+func NewCalendar() *CalendarBuilder {
+	version := property.Version2
+	return &CalendarBuilder{c: &objects.Calendar{
+		ProdId:  property.NewProductIdentifier(ProdId),
+		Version: &version,
+	}}
+}
+
+// ProdID sets the PRODID of the calendar.
+func (b *CalendarBuilder) ProdID(id string) *CalendarBuilder {
+	b.c.ProdId = property.NewProductIdentifier(id)
+	return b
+}
+
+// Method sets the METHOD of the calendar, such as REQUEST.
+func (b *CalendarBuilder) Method(m string) *CalendarBuilder {
+	b.c.Method = &property.Method{Value: types.NewText(strings.ToUpper(m))}
+	return b
+}
+
+// Event adds the event built by e to the calendar.
+func (b *CalendarBuilder) Event(e *EventBuilder) *CalendarBuilder {
+	ev, err := e.Build()
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	return b.Component(ev)
+}
+
+// Component adds c to the calendar.
+func (b *CalendarBuilder) Component(c components.Component) *CalendarBuilder {
+	b.c.Components = append(b.c.Components, c)
+	return b
+}
+
+// Build returns the calendar, or the first error of the calls.  It adds
+// a VTIMEZONE for each TZID of the components, and fails for locations
+// the time zone database does not know.
+func (b *CalendarBuilder) Build() (*objects.Calendar, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := b.c.AddTimeZones(); err != nil {
+		return nil, fmt.Errorf("builder: %v", err)
+	}
+	return b.c, nil
+}
@@ -0,0 +1,146 @@
+package builder
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBuilder(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	e, err := NewEvent().
+		Summary("Planning; Q3").
+		Start(time.Date(2024, 6, 10, 7, 30, 0, 0, time.UTC), berlin).
+		Duration(90*time.Minute).
+		Organizer("alice@example.com", WithName("Alice")).
+		Attendee("mailto:bob@example.com", WithRole(parameters.Chair), WithRSVP(true)).
+		Attendee("room@example.com", WithCuType(parameters.RoomCuType), WithRole(parameters.None), WithRole(parameters.Optional)).
+		Categories("work", "finance").
+		RRule("FREQ=WEEKLY;COUNT=4").
+		Alarm(-15*time.Minute, "Planning").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &strings.Builder{}
+	if err := e.WriteComponentToStrBuilder(s); err != nil {
+		t.Fatal(err)
+	}
+	out := s.String()
+	for _, want := range []string{
+		"SUMMARY:Planning\\; Q3\n",
+		"DTSTART;TZID=Europe/Berlin:20240610T093000\n",
+		"DURATION:PT1H30M\n",
+		"ORGANIZER;CN=\"Alice\":mailto:alice@example.com\n",
+		"ATTENDEE;ROLE=CHAIR;RSVP=TRUE:mailto:bob@example.com\n",
+		"ATTENDEE;CUTYPE=ROOM;ROLE=OPT-PARTICIPANT:mailto:room@example.com\n",
+		"CATEGORIES:work,finance\n",
+		"RRULE:FREQ=WEEKLY;COUNT=4\n",
+		"TRIGGER:-PT15M\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("event lacks %q:\n%s", want, out)
+		}
+	}
+	if !regexp.MustCompile(`(?m)^UID:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(out) {
+		t.Errorf("event lacks a UUID:\n%s", out)
+	}
+	if !regexp.MustCompile(`(?m)^DTSTAMP:\d{8}T\d{6}Z$`).MatchString(out) {
+		t.Errorf("event lacks a DTSTAMP:\n%s", out)
+	}
+
+	// the calendar of built events decodes without errors
+	cal, err := NewCalendar().
+		Component(e).
+		Event(NewEvent().UID("holiday@example.com").AllDay(time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)).End(time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC))).
+		Event(NewEvent().UID("call@example.com").Start(time.Date(2024, 6, 11, 8, 0, 0, 0, time.UTC), nil).End(time.Date(2024, 6, 11, 8, 30, 0, 0, time.UTC))).
+		Event(NewEvent().UID("local@example.com").Start(time.Date(2024, 6, 12, 8, 0, 0, 0, time.UTC).In(time.Local), nil)).
+		Event(NewEvent().UID("lunch@example.com").Start(time.Date(2024, 6, 13, 12, 0, 0, 0, berlin), nil).Floating()).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := cal.Calendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"BEGIN:VTIMEZONE\nTZID:Europe/Berlin\n",
+		"DTSTART;VALUE=DATE:20241224\n",
+		"DTEND;VALUE=DATE:20241227\n",
+		"DTSTART:20240611T080000Z\n",
+		// time.Local has no TZID
+		"DTSTART:20240612T080000Z\n",
+		"DTSTART:20240613T120000\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("calendar lacks %q:\n%s", want, data)
+		}
+	}
+	decoded, err := objects.Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v\n%s", err, data)
+	}
+	if _, ok := decoded.Components[0].(*components.TimeZone); !ok || strings.Count(data, "BEGIN:VTIMEZONE") != 1 {
+		t.Errorf("calendar without one VTIMEZONE:\n%s", data)
+	}
+	start, err := decoded.Components[1].(*components.Event).Start()
+	if err != nil || !start.Equal(time.Date(2024, 6, 10, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("decoded start: %v %v", start, err)
+	}
+}
+
+func TestEventBuilder_Errors(t *testing.T) {
+	at := time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC)
+	for name, b := range map[string]*EventBuilder{
+		"no start":        NewEvent().Summary("x"),
+		"end before":      NewEvent().Start(at, nil).End(at.Add(-time.Hour)),
+		"negative":        NewEvent().Start(at, nil).Duration(-time.Hour),
+		"partial day":     NewEvent().AllDay(at).Duration(36 * time.Hour),
+		"same day end":    NewEvent().AllDay(at).End(at),
+		"bad rule":        NewEvent().Start(at, nil).RRule("FREQ=SOMETIMES"),
+		"bad address":     NewEvent().Start(at, nil).Attendee("bob"),
+		"bad status":      NewEvent().Start(at, nil).Status("DONE"),
+		"negative number": NewEvent().Start(at, nil).Sequence(-1),
+	} {
+		if e, err := b.Build(); err == nil {
+			t.Errorf("%s: Build succeeded: %+v", name, e)
+		}
+	}
+	if _, err := NewCalendar().Event(NewEvent()).Build(); err == nil {
+		t.Errorf("calendar of an invalid event built")
+	}
+	// a VTIMEZONE cannot be made for zones outside the time zone database
+	if _, err := NewCalendar().Event(NewEvent().Start(at, time.FixedZone("X", 3600))).Build(); err == nil {
+		t.Errorf("calendar of an event in an unknown zone built")
+	}
+}
+
+func TestEventBuilder_OwnParameters(t *testing.T) {
+	at := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	a, err := NewEvent().AllDay(at).End(at.AddDate(0, 0, 1)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewEvent().AllDay(at).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.DtStart.Parameters[0].(*parameters.ValueType).V = "DATE-TIME"
+	if v := b.DtStart.Parameters[0].(*parameters.ValueType).V; v != "DATE" {
+		t.Errorf("changing the VALUE of one event changed another: %s", v)
+	}
+	if v := a.DtEnd.Parameters[0].(*parameters.ValueType).V; v != "DATE" {
+		t.Errorf("changing the VALUE of DTSTART changed DTEND: %s", v)
+	}
+	if parameters.Date.V != "DATE" {
+		t.Errorf("changing the VALUE of an event changed parameters.Date: %s", parameters.Date.V)
+	}
+}
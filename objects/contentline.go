@@ -1,7 +1,11 @@
 package objects
 
 import (
+	"bufio"
+	"fmt"
 	"github.com/mmsuo/vcalender/objects/property/components/properties"
+	"io"
+	"strings"
 )
 
 //     contentline   = name *(";" param ) ":" value CRLF
@@ -39,6 +43,187 @@ import (
 //     CONTROL       = %x00-08 / %x0A-1F / %x7F
 //     ; All the controls except HTAB
 
+// ContentLine is a single unfolded line of an iCalendar stream.
+type ContentLine struct {
+	Name   string
+	Params []*Param
+	Value  string
+	// Line is the number of the first physical line the content line
+	// was read from, starting at 1.
+	Line int
+}
+
+// Param is a property parameter of a content line.  Quoted values are
+// stored without their quotes.
+type Param struct {
+	Name   string
+	Values []string
+}
+
+// SyntaxError reports a malformed content line.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Param returns the first value of the named parameter.
+func (c *ContentLine) Param(name string) (string, bool) {
+	for _, p := range c.Params {
+		if strings.EqualFold(p.Name, name) {
+			if len(p.Values) == 0 {
+				return "", true
+			}
+			return p.Values[0], true
+		}
+	}
+	return "", false
+}
+
+// String returns c in its unfolded form, without line terminator.
+func (c *ContentLine) String() string {
+	s := &strings.Builder{}
+	s.WriteString(c.Name)
+	for _, p := range c.Params {
+		s.WriteString(";")
+		s.WriteString(p.Name)
+		if len(p.Values) == 0 {
+			continue
+		}
+		s.WriteString("=")
+		for i, v := range p.Values {
+			if i > 0 {
+				s.WriteString(",")
+			}
+			if strings.ContainsAny(v, ";:,") {
+				s.WriteString("\"" + v + "\"")
+			} else {
+				s.WriteString(v)
+			}
+		}
+	}
+	s.WriteString(":")
+	s.WriteString(c.Value)
+	return s.String()
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// ParseContentLine splits an unfolded content line into its name,
+// parameters and value.  Names are returned in upper case.  A parameter
+// without "=" is accepted with no values, as written by vCalendar 1.0.
+func ParseContentLine(line string) (*ContentLine, error) {
+	i := 0
+	for i < len(line) && isNameChar(line[i]) {
+		i++
+	}
+	if i == 0 {
+		return nil, fmt.Errorf("missing property name")
+	}
+	c := &ContentLine{Name: strings.ToUpper(line[:i])}
+	for i < len(line) && line[i] == ';' {
+		i++
+		start := i
+		for i < len(line) && isNameChar(line[i]) {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("missing parameter name in %s", c.Name)
+		}
+		p := &Param{Name: strings.ToUpper(line[start:i])}
+		c.Params = append(c.Params, p)
+		if i == len(line) || line[i] != '=' {
+			continue
+		}
+		for {
+			i++
+			if i < len(line) && line[i] == '"' {
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quoted value of %s", p.Name)
+				}
+				p.Values = append(p.Values, line[i+1:i+1+end])
+				i += end + 2
+			} else {
+				start := i
+				for i < len(line) && line[i] != ';' && line[i] != ':' && line[i] != ',' {
+					if line[i] == '"' {
+						return nil, fmt.Errorf("invalid quote in value of %s", p.Name)
+					}
+					i++
+				}
+				p.Values = append(p.Values, line[start:i])
+			}
+			if i == len(line) || line[i] != ',' {
+				break
+			}
+		}
+	}
+	if i == len(line) || line[i] != ':' {
+		return nil, fmt.Errorf("missing ':' after %s", c.Name)
+	}
+	c.Value = line[i+1:]
+	return c, nil
+}
+
+// ReadContentLines reads all content lines of r, unfolding lines that
+// start with a space or a horizontal tab.  Both CRLF and LF line
+// terminators are accepted, and empty lines are skipped.
+func ReadContentLines(r io.Reader) ([]*ContentLine, error) {
+	var lines []*ContentLine
+	var cur strings.Builder
+	start, n := 0, 0
+	flush := func() error {
+		if cur.Len() == 0 {
+			return nil
+		}
+		c, err := ParseContentLine(cur.String())
+		if err != nil {
+			return &SyntaxError{Line: start, Msg: err.Error()}
+		}
+		c.Line = start
+		lines = append(lines, c)
+		cur.Reset()
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		n++
+		l := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(l) > 0 && (l[0] == ' ' || l[0] == '\t') {
+			if cur.Len() == 0 {
+				return nil, &SyntaxError{Line: n, Msg: "continuation line without content line"}
+			}
+			cur.WriteString(l[1:])
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		start = n
+		cur.WriteString(l)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// ToContentLine returns the content line written for p, without line
+// terminator.
 func ToContentLine(p properties.Property) string {
-	return ""
+	s := &strings.Builder{}
+	if err := p.WritePropertyToStrBuilder(s); err != nil {
+		return ""
+	}
+	return strings.TrimRight(s.String(), "\r\n")
 }
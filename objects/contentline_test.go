@@ -0,0 +1,54 @@
+package objects
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadContentLines(t *testing.T) {
+	in := "BEGIN:VEVENT\r\n" +
+		"DESCRIPTION;ALTREP=\"cid:part1.0001@example.org\":The Fall'98 Wild\r\n" +
+		"  Wizards Conference\r\n" +
+		"attendee;role=REQ-PARTICIPANT;DELEGATED-FROM=\"mailto:a@example.com\",\"mailto:b@example.com\":mailto:c@example.com\n" +
+		"END:VEVENT\r\n"
+	lines, err := ReadContentLines(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4", len(lines))
+	}
+	d := lines[1]
+	if d.Name != "DESCRIPTION" || d.Value != "The Fall'98 Wild Wizards Conference" || d.Line != 2 {
+		t.Errorf("got %+v", d)
+	}
+	if v, _ := d.Param("altrep"); v != "cid:part1.0001@example.org" {
+		t.Errorf("ALTREP: got %q", v)
+	}
+	a := lines[2]
+	if a.Name != "ATTENDEE" || a.Line != 4 || len(a.Params) != 2 || len(a.Params[1].Values) != 2 {
+		t.Errorf("got %+v", a)
+	}
+
+	_, err = ReadContentLines(strings.NewReader("BEGIN:VEVENT\r\nSUMMARY\r\n"))
+	if e, ok := err.(*SyntaxError); !ok || e.Line != 2 {
+		t.Errorf("got %v, want a syntax error on line 2", err)
+	}
+}
+
+func TestFold(t *testing.T) {
+	in := "DESCRIPTION:" + strings.Repeat("ä", 40) + "\nUID:1\n"
+	got := Fold(in)
+	for _, l := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line of %d octets: %q", len(l), l)
+		}
+	}
+	lines, err := ReadContentLines(strings.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0].Value != strings.Repeat("ä", 40) {
+		t.Errorf("unfolded to %+v", lines)
+	}
+}
@@ -0,0 +1,939 @@
+package objects
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/alarm"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/changemanage"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/miscellaneous"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/timezone"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorList holds the errors found while decoding a calendar, in line
+// order.
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	s := make([]string, 0, len(l))
+	for _, e := range l {
+		s = append(s, e.Error())
+	}
+	return strings.Join(s, "\n")
+}
+
+// valueTypes holds the default value type of the properties of RFC 5545.
+var valueTypes = map[string]string{
+	"CALSCALE":         "TEXT",
+	"METHOD":           "TEXT",
+	"PRODID":           "TEXT",
+	"VERSION":          "TEXT",
+	"ATTACH":           "URI",
+	"CATEGORIES":       "TEXT",
+	"CLASS":            "TEXT",
+	"COMMENT":          "TEXT",
+	"DESCRIPTION":      "TEXT",
+	"GEO":              "FLOAT",
+	"LOCATION":         "TEXT",
+	"PERCENT-COMPLETE": "INTEGER",
+	"PRIORITY":         "INTEGER",
+	"RESOURCES":        "TEXT",
+	"STATUS":           "TEXT",
+	"SUMMARY":          "TEXT",
+	"COMPLETED":        "DATE-TIME",
+	"DTEND":            "DATE-TIME",
+	"DUE":              "DATE-TIME",
+	"DTSTART":          "DATE-TIME",
+	"DURATION":         "DURATION",
+	"FREEBUSY":         "PERIOD",
+	"TRANSP":           "TEXT",
+	"TZID":             "TEXT",
+	"TZNAME":           "TEXT",
+	"TZOFFSETFROM":     "UTC-OFFSET",
+	"TZOFFSETTO":       "UTC-OFFSET",
+	"TZURL":            "URI",
+	"ATTENDEE":         "CAL-ADDRESS",
+	"CONTACT":          "TEXT",
+	"ORGANIZER":        "CAL-ADDRESS",
+	"RECURRENCE-ID":    "DATE-TIME",
+	"RELATED-TO":       "TEXT",
+	"URL":              "URI",
+	"UID":              "TEXT",
+	"EXDATE":           "DATE-TIME",
+	"RDATE":            "DATE-TIME",
+	"RRULE":            "RECUR",
+	"ACTION":           "TEXT",
+	"REPEAT":           "INTEGER",
+	"TRIGGER":          "DURATION",
+	"CREATED":          "DATE-TIME",
+	"DTSTAMP":          "DATE-TIME",
+	"LAST-MODIFIED":    "DATE-TIME",
+	"SEQUENCE":         "INTEGER",
+	"REQUEST-STATUS":   "TEXT",
+}
+
+// DefaultValueType returns the value type of the property name when it
+// has no VALUE parameter, or "" for properties this package does not
+// know.
+func DefaultValueType(name string) string {
+	return valueTypes[strings.ToUpper(name)]
+}
+
+// ValueType returns the value type of l, from its VALUE parameter or the
+// default of its property.
+func ValueType(l *ContentLine) string {
+	if v, ok := l.Param("VALUE"); ok && v != "" {
+		return strings.ToUpper(v)
+	}
+	return DefaultValueType(l.Name)
+}
+
+// IsList reports whether values of the property name are lists separated
+// by commas.
+func IsList(name string) bool {
+	switch strings.ToUpper(name) {
+	case "CATEGORIES", "RESOURCES", "FREEBUSY", "RDATE", "EXDATE":
+		return true
+	}
+	return false
+}
+
+// SplitList splits a list value at the commas that are not escaped.
+func SplitList(s string) []string {
+	return SplitEscaped(s, ',', -1)
+}
+
+// SplitEscaped splits s at the occurrences of sep that are not escaped
+// with a backslash. Like strings.SplitN, it returns at most n parts, the
+// last one holding the unsplit remainder; n < 0 returns all parts.
+func SplitEscaped(s string, sep byte, n int) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s) && (n < 0 || len(parts) < n-1); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+type decoder struct {
+	errs  ErrorList
+	zones map[string]*components.TimeZone
+	locs  map[string]*time.Location
+}
+
+func (d *decoder) errorf(line int, format string, a ...interface{}) {
+	d.errs = append(d.errs, &SyntaxError{Line: line, Msg: fmt.Sprintf(format, a...)})
+}
+
+// Decode reads one iCalendar object from r.  When the object has errors
+// Decode returns them as an ErrorList together with the calendar, in
+// which the properties that could not be read are left out.  Components
+// other than VEVENT, VTODO, VJOURNAL, VFREEBUSY, VTIMEZONE and VALARM
+// are ignored.
+func Decode(r io.Reader) (*Calendar, error) {
+	raw, err := ReadRawComponents(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 1 || raw[0].Name != "VCALENDAR" {
+		line := 1
+		if len(raw) > 1 {
+			line = raw[1].Line
+		} else if len(raw) == 1 && raw[0].Name != "VCALENDAR" {
+			line = raw[0].Line
+		}
+		return nil, &SyntaxError{Line: line, Msg: "expected a single VCALENDAR object"}
+	}
+	return DecodeRaw(raw[0])
+}
+
+// DecodeRaw turns a VCALENDAR read by ReadRawComponents into a Calendar,
+// reporting errors as Decode does.
+func DecodeRaw(raw *RawComponent) (*Calendar, error) {
+	d := &decoder{zones: map[string]*components.TimeZone{}, locs: map[string]*time.Location{}}
+	c := &Calendar{}
+	// time zones go first so that the TZIDs of other components resolve
+	zones := map[*RawComponent]*components.TimeZone{}
+	for _, sub := range raw.Components {
+		if sub.Name == "VTIMEZONE" {
+			tz := d.timeZone(sub)
+			if tz.TzId != nil && tz.TzId.Value != nil {
+				d.zones[tz.TzId.Value.V] = tz
+			}
+			zones[sub] = tz
+		}
+	}
+	d.fill(c, raw)
+	if c.ProdId == nil {
+		d.errorf(raw.Line, "VCALENDAR without PRODID")
+	}
+	if c.Version == nil {
+		d.errorf(raw.Line, "VCALENDAR without VERSION")
+	} else if c.Version.Value.V != "2.0" {
+		d.errorf(raw.Line, "unsupported VERSION %s", c.Version.Value.V)
+	}
+	scheduling := c.Method != nil
+	for _, sub := range raw.Components {
+		var comp components.Component
+		switch sub.Name {
+		case "VEVENT":
+			comp = d.event(sub, scheduling)
+		case "VTODO":
+			comp = d.todo(sub)
+		case "VJOURNAL":
+			j := &components.Journal{}
+			d.fill(j, sub)
+			d.noComponents(sub)
+			d.identity(sub, j.Uid, j.DtStamp)
+			comp = j
+		case "VFREEBUSY":
+			f := &components.FreeBusy{}
+			d.fill(f, sub)
+			d.noComponents(sub)
+			d.identity(sub, f.Uid, f.DtStamp)
+			comp = f
+		case "VTIMEZONE":
+			comp = zones[sub]
+		}
+		if comp != nil {
+			c.Components = append(c.Components, comp)
+		}
+	}
+	if len(d.errs) > 0 {
+		sort.SliceStable(d.errs, func(i, j int) bool { return d.errs[i].Line < d.errs[j].Line })
+		return c, d.errs
+	}
+	return c, nil
+}
+
+func (d *decoder) noComponents(raw *RawComponent) {
+	for _, sub := range raw.Components {
+		if sub.Name == "VALARM" || sub.Name == "VTIMEZONE" || sub.Name == "STANDARD" || sub.Name == "DAYLIGHT" {
+			d.errorf(sub.Line, "%s is not allowed in %s", sub.Name, raw.Name)
+		}
+	}
+}
+
+func (d *decoder) identity(raw *RawComponent, uid *relationship.Uid, stamp *changemanage.DtStamp) {
+	if uid == nil {
+		d.errorf(raw.Line, "%s without UID", raw.Name)
+	}
+	if stamp == nil {
+		d.errorf(raw.Line, "%s without DTSTAMP", raw.Name)
+	}
+}
+
+func (d *decoder) event(raw *RawComponent, scheduling bool) *components.Event {
+	e := &components.Event{}
+	d.fill(e, raw)
+	d.identity(raw, e.Uid, e.DtStamp)
+	if e.DtStart == nil && !scheduling {
+		d.errorf(raw.Line, "VEVENT without DTSTART")
+	}
+	if e.DtEnd != nil && e.Duration != nil {
+		d.errorf(raw.Line, "VEVENT with both DTEND and DURATION")
+	}
+	if e.DtStart != nil && e.DtEnd != nil {
+		d.ordered(raw, "DTEND", e.DtStart.Value, e.DtEnd.Value)
+	}
+	e.Alarm = d.alarms(raw)
+	return e
+}
+
+func (d *decoder) todo(raw *RawComponent) *components.Todo {
+	t := &components.Todo{}
+	d.fill(t, raw)
+	d.identity(raw, t.Uid, t.DtStamp)
+	if t.Due != nil && t.Duration != nil {
+		d.errorf(raw.Line, "VTODO with both DUE and DURATION")
+	}
+	if t.Duration != nil && t.DtStart == nil {
+		d.errorf(raw.Line, "VTODO with DURATION but without DTSTART")
+	}
+	if t.DtStart != nil && t.Due != nil {
+		d.ordered(raw, "DUE", t.DtStart.Value, t.Due.Value)
+	}
+	t.Alarm = d.alarms(raw)
+	return t
+}
+
+// ordered checks that the end of a component is of the type of its start
+// and does not come before it.
+func (d *decoder) ordered(raw *RawComponent, name string, start, end types.Value) {
+	_, startDate := start.(*types.Date)
+	_, endDate := end.(*types.Date)
+	if startDate != endDate {
+		d.errorf(raw.Line, "%s of %s has not the value type of DTSTART", name, raw.Name)
+		return
+	}
+	if s, e := instant(start), instant(end); !s.IsZero() && e.Before(s) {
+		d.errorf(raw.Line, "%s of %s is before DTSTART", name, raw.Name)
+	}
+}
+
+func instant(v types.Value) time.Time {
+	switch t := v.(type) {
+	case *types.Date:
+		return t.Time()
+	case *types.DateTime:
+		return t.Time()
+	}
+	return time.Time{}
+}
+
+func (d *decoder) alarms(raw *RawComponent) []*components.Alarm {
+	var alarms []*components.Alarm
+	for _, sub := range raw.Components {
+		if sub.Name != "VALARM" {
+			if sub.Name == "VTIMEZONE" || sub.Name == "STANDARD" || sub.Name == "DAYLIGHT" {
+				d.errorf(sub.Line, "%s is not allowed in %s", sub.Name, raw.Name)
+			}
+			continue
+		}
+		a := &components.Alarm{}
+		var props []*ContentLine
+		for _, l := range sub.Properties {
+			// DURATION and REPEAT of alarms are held as bare values
+			switch l.Name {
+			case "DURATION":
+				v, err := types.ParseDuration(l.Value)
+				if err != nil {
+					d.errorf(l.Line, "DURATION: %v", err)
+					continue
+				}
+				a.Duration = v
+			case "REPEAT":
+				n, err := strconv.Atoi(l.Value)
+				if err != nil || n < 0 {
+					d.errorf(l.Line, "REPEAT: invalid integer %q", l.Value)
+					continue
+				}
+				a.Repeat = types.NewInteger(n)
+			default:
+				props = append(props, l)
+			}
+		}
+		d.fill(a, &RawComponent{Name: sub.Name, Properties: props, Line: sub.Line})
+		d.noComponents(sub)
+		switch {
+		case a.Action == nil:
+			d.errorf(sub.Line, "VALARM without ACTION")
+		case a.Trigger == nil:
+			d.errorf(sub.Line, "VALARM without TRIGGER")
+		case a.Action.Value.V == "DISPLAY" && a.Description == nil:
+			d.errorf(sub.Line, "DISPLAY VALARM without DESCRIPTION")
+		case a.Action.Value.V == "EMAIL" && (a.Description == nil || a.Summary == nil || len(a.Attendee) == 0):
+			d.errorf(sub.Line, "EMAIL VALARM without DESCRIPTION, SUMMARY or ATTENDEE")
+		}
+		if (a.Duration == nil) != (a.Repeat == nil) {
+			d.errorf(sub.Line, "VALARM with only one of DURATION and REPEAT")
+			a.Duration, a.Repeat = nil, nil
+		}
+		if a.Action == nil {
+			// the writer of alarms needs an action
+			continue
+		}
+		alarms = append(alarms, a)
+	}
+	return alarms
+}
+
+func (d *decoder) timeZone(raw *RawComponent) *components.TimeZone {
+	tz := &components.TimeZone{}
+	d.fill(tz, raw)
+	if tz.TzId == nil {
+		d.errorf(raw.Line, "VTIMEZONE without TZID")
+	}
+	for _, sub := range raw.Components {
+		p := &components.TzProp{}
+		switch sub.Name {
+		case "STANDARD":
+			tz.Standard = append(tz.Standard, &components.Standard{TzProp: p})
+		case "DAYLIGHT":
+			tz.DayLight = append(tz.DayLight, &components.DayLight{TzProp: p})
+		default:
+			if sub.Name == "VALARM" {
+				d.errorf(sub.Line, "VALARM is not allowed in VTIMEZONE")
+			}
+			continue
+		}
+		d.fill(p, sub)
+		if p.DtStart == nil || p.TzOffsetFrom == nil || p.TzOffsetTo == nil {
+			d.errorf(sub.Line, "%s without DTSTART, TZOFFSETFROM or TZOFFSETTO", sub.Name)
+		}
+	}
+	if len(tz.Standard) == 0 && len(tz.DayLight) == 0 {
+		d.errorf(raw.Line, "VTIMEZONE without STANDARD or DAYLIGHT")
+	}
+	return tz
+}
+
+// location returns the location of tzid: the time zone database entry
+// of that name or else the VTIMEZONE of the calendar.
+func (d *decoder) location(l *ContentLine, tzid string) *time.Location {
+	if loc, ok := d.locs[tzid]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+	if err != nil || tzid == "" || strings.EqualFold(tzid, "local") {
+		loc = nil
+		if tz, ok := d.zones[tzid]; ok {
+			loc, err = tz.Location()
+			if err != nil {
+				d.errorf(l.Line, "TZID %s: %v", tzid, err)
+				loc = nil
+			}
+		} else {
+			d.errorf(l.Line, "unknown TZID %s", tzid)
+		}
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	d.locs[tzid] = loc
+	return loc
+}
+
+// fieldIndex finds the field of the struct type t that holds properties
+// of type p, either directly or in a slice.
+func fieldIndex(t reflect.Type, p reflect.Type) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i).Type
+		if f == p {
+			return i, false
+		}
+		if f.Kind() == reflect.Slice && f.Elem() == p {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// fill decodes the properties of raw into the fields of dst, which is a
+// pointer to a component struct.
+func (d *decoder) fill(dst interface{}, raw *RawComponent) {
+	v := reflect.ValueOf(dst).Elem()
+	for _, l := range raw.Properties {
+		p, err := d.property(l)
+		if err != nil {
+			d.errorf(l.Line, "%s: %v", l.Name, err)
+			continue
+		}
+		i, list := fieldIndex(v.Type(), reflect.TypeOf(p))
+		if i < 0 {
+			d.errorf(l.Line, "%s is not allowed in %s", l.Name, raw.Name)
+			// keep it anyway, so that writing the component loses nothing
+			if i, _ = fieldIndex(v.Type(), reflect.TypeOf(&miscellaneous.Iana{})); i < 0 {
+				continue
+			}
+			p, list = d.iana(l), true
+		}
+		f := v.Field(i)
+		if list {
+			f.Set(reflect.Append(f, reflect.ValueOf(p)))
+			continue
+		}
+		if !f.IsNil() {
+			d.errorf(l.Line, "%s must not occur more than once in %s", l.Name, raw.Name)
+			continue
+		}
+		f.Set(reflect.ValueOf(p))
+	}
+}
+
+func (d *decoder) iana(l *ContentLine) *miscellaneous.Iana {
+	params, _ := d.params(l)
+	return &miscellaneous.Iana{Name: l.Name, Parameters: params, Values: []types.Value{&types.Text{V: l.Value}}}
+}
+
+func calAddresses(values []string) []*types.CalAddress {
+	a := make([]*types.CalAddress, 0, len(values))
+	for _, v := range values {
+		a = append(a, &types.CalAddress{V: &types.URI{V: v}})
+	}
+	return a
+}
+
+func (d *decoder) params(l *ContentLine) ([]parameters.Parameter, error) {
+	var params []parameters.Parameter
+	for _, p := range l.Params {
+		v := strings.Join(p.Values, ",")
+		var param parameters.Parameter
+		switch p.Name {
+		case "ALTREP":
+			param = &parameters.AltRep{V: &types.URI{V: v}}
+		case "CN":
+			param = &parameters.CommonName{V: v}
+		case "CUTYPE":
+			param = &parameters.CuType{V: strings.ToUpper(v)}
+		case "DELEGATED-FROM":
+			param = &parameters.DelegatedFrom{V: calAddresses(p.Values)}
+		case "DELEGATED-TO":
+			param = &parameters.DelegatedTo{V: calAddresses(p.Values)}
+		case "DIR":
+			param = &parameters.Dir{V: &types.URI{V: v}}
+		case "ENCODING":
+			param = &parameters.Encoding{V: strings.ToUpper(v)}
+		case "FMTTYPE":
+			param = &parameters.FmtType{V: v}
+		case "FBTYPE":
+			param = &parameters.FreeBusyType{V: strings.ToUpper(v)}
+		case "LANGUAGE":
+			param = &parameters.Language{V: v}
+		case "MEMBER":
+			param = &parameters.Member{V: calAddresses(p.Values)}
+		case "PARTSTAT":
+			param = &parameters.PartStat{V: strings.ToUpper(v)}
+		case "RANGE":
+			param = &parameters.RecurrenceIdRange{V: strings.ToUpper(v)}
+		case "RELATED":
+			param = &parameters.Related{V: strings.ToUpper(v)}
+		case "RELTYPE":
+			param = &parameters.RelType{V: strings.ToUpper(v)}
+		case "ROLE":
+			param = &parameters.ParticipationRole{V: strings.ToUpper(v)}
+		case "RSVP":
+			switch strings.ToUpper(v) {
+			case "TRUE":
+				param = &parameters.Rsvp{V: true}
+			case "FALSE":
+				param = &parameters.Rsvp{V: false}
+			default:
+				return nil, fmt.Errorf("invalid RSVP %q", v)
+			}
+		case "SCHEDULE-AGENT":
+			param = &parameters.ScheduleAgent{V: strings.ToUpper(v)}
+		case "SCHEDULE-FORCE-SEND":
+			param = &parameters.ScheduleForceSend{V: strings.ToUpper(v)}
+		case "SCHEDULE-STATUS":
+			param = &parameters.ScheduleStatus{V: p.Values}
+		case "SENT-BY":
+			param = &parameters.SendBy{V: &types.CalAddress{V: &types.URI{V: v}}}
+		case "TZID":
+			param = &parameters.TimeZoneId{V: v}
+		case "VALUE":
+			param = &parameters.ValueType{V: strings.ToUpper(v)}
+		default:
+			param = &parameters.OtherParam{Name: p.Name, V: p.Values}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// value decodes a single value of type vt.  Values of unknown types are
+// kept as text.
+func (d *decoder) value(l *ContentLine, vt, s string) (types.Value, error) {
+	switch vt {
+	case "BINARY":
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BINARY value")
+		}
+		return &types.Binary{V: b}, nil
+	case "BOOLEAN":
+		switch strings.ToUpper(s) {
+		case "TRUE":
+			return types.True, nil
+		case "FALSE":
+			return types.False, nil
+		}
+		return nil, fmt.Errorf("invalid BOOLEAN %q", s)
+	case "CAL-ADDRESS":
+		return &types.CalAddress{V: &types.URI{V: s}}, nil
+	case "DATE":
+		v, err := types.ParseDate(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DATE %q", s)
+		}
+		return v, nil
+	case "DATE-TIME":
+		return d.dateTime(l, s)
+	case "DURATION":
+		return types.ParseDuration(s)
+	case "FLOAT":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("invalid FLOAT %q", s)
+		}
+		return &types.Float{V: s}, nil
+	case "INTEGER":
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INTEGER %q", s)
+		}
+		return types.NewInteger(n), nil
+	case "PERIOD":
+		slash := strings.IndexByte(s, '/')
+		if slash < 0 {
+			return nil, fmt.Errorf("invalid PERIOD %q", s)
+		}
+		start, err := d.dateTime(l, s[:slash])
+		if err != nil {
+			return nil, err
+		}
+		rest := s[slash+1:]
+		if strings.HasPrefix(rest, "P") || strings.HasPrefix(rest, "+P") || strings.HasPrefix(rest, "-P") {
+			dur, err := types.ParseDuration(rest)
+			if err != nil {
+				return nil, err
+			}
+			return &types.StartPeriod{Start: start, Duration: dur}, nil
+		}
+		end, err := d.dateTime(l, rest)
+		if err != nil {
+			return nil, err
+		}
+		return &types.ExplicitPeriod{Start: start, End: end}, nil
+	case "RECUR":
+		return recurRule(s)
+	case "TIME":
+		format := types.LocalTimeFormat
+		if strings.HasSuffix(s, "Z") {
+			format = types.UTCTimeFormat
+		}
+		t, err := time.Parse(format, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIME %q", s)
+		}
+		return &types.Time{V: t, Format: format}, nil
+	case "URI":
+		return &types.URI{V: s}, nil
+	case "UTC-OFFSET":
+		return types.ParseUTCOffset(s)
+	}
+	return &types.Text{V: s}, nil
+}
+
+// recurRule parses an RRULE leniently.  Published calendars often carry
+// rules RFC 5545 forbids, such as a numbered BYDAY with FREQ=WEEKLY, so
+// only FREQ is required and each part must be well formed; Validate
+// reports the rest.
+func recurRule(s string) (*types.RecurRule, error) {
+	r := &types.RecurRule{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		eq := strings.IndexByte(part, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("recur: rule part %q has no value", part)
+		}
+		name := strings.ToUpper(part[:eq])
+		if seen[name] {
+			return nil, fmt.Errorf("recur: %s given twice", name)
+		}
+		seen[name] = true
+		rule, err := types.ParseRulePart(name, part[eq+1:])
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := rule.(types.Frequency); ok {
+			r.Frequency = f
+		} else {
+			r.Rules = append(r.Rules, rule)
+		}
+	}
+	if r.Frequency == "" {
+		return nil, fmt.Errorf("recur: FREQ is required")
+	}
+	return r, nil
+}
+
+func (d *decoder) dateTime(l *ContentLine, s string) (*types.DateTime, error) {
+	var loc *time.Location
+	if tzid, ok := l.Param("TZID"); ok && !strings.HasSuffix(s, "Z") {
+		loc = d.location(l, tzid)
+	}
+	v, err := types.ParseDateTime(s, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATE-TIME %q", s)
+	}
+	return v, nil
+}
+
+// values decodes the values of l, splitting list properties.
+func (d *decoder) values(l *ContentLine, vt string) ([]types.Value, error) {
+	items := []string{l.Value}
+	if IsList(l.Name) {
+		items = SplitList(l.Value)
+	}
+	values := make([]types.Value, 0, len(items))
+	for _, s := range items {
+		v, err := d.value(l, vt, s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (d *decoder) text(l *ContentLine) (*types.Text, error) {
+	if vt := ValueType(l); vt != "TEXT" {
+		return nil, fmt.Errorf("value type %s is not allowed", vt)
+	}
+	return &types.Text{V: l.Value}, nil
+}
+
+func (d *decoder) integer(l *ContentLine) (*types.Integer, error) {
+	n, err := strconv.Atoi(l.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid INTEGER %q", l.Value)
+	}
+	return types.NewInteger(n), nil
+}
+
+func (d *decoder) utc(l *ContentLine) (*types.DateTime, error) {
+	if vt := ValueType(l); vt != "DATE-TIME" {
+		return nil, fmt.Errorf("value type %s is not allowed", vt)
+	}
+	v, err := d.dateTime(l, l.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsUTC() {
+		return nil, fmt.Errorf("must be a UTC time")
+	}
+	return v, nil
+}
+
+// timeValue decodes properties that are a DATE-TIME or, with VALUE=DATE,
+// a DATE.
+func (d *decoder) timeValue(l *ContentLine) (types.Value, error) {
+	vt := ValueType(l)
+	if vt != "DATE-TIME" && vt != "DATE" {
+		return nil, fmt.Errorf("value type %s is not allowed", vt)
+	}
+	return d.value(l, vt, l.Value)
+}
+
+func (d *decoder) timeValues(l *ContentLine, period bool) ([]types.Value, error) {
+	vt := ValueType(l)
+	if vt != "DATE-TIME" && vt != "DATE" && !(period && vt == "PERIOD") {
+		return nil, fmt.Errorf("value type %s is not allowed", vt)
+	}
+	return d.values(l, vt)
+}
+
+func (d *decoder) textValues(l *ContentLine) ([]types.Value, error) {
+	if vt := ValueType(l); vt != "TEXT" {
+		return nil, fmt.Errorf("value type %s is not allowed", vt)
+	}
+	return d.values(l, "TEXT")
+}
+
+// property decodes the content line l into the property type of its name.
+func (d *decoder) property(l *ContentLine) (properties.Property, error) {
+	params, err := d.params(l)
+	if err != nil {
+		return nil, err
+	}
+	switch l.Name {
+	case "PRODID":
+		v, err := d.text(l)
+		return &property.ProductIdentifier{Parameters: params, Value: v}, err
+	case "VERSION":
+		v, err := d.text(l)
+		return &property.Version{Parameters: params, Value: v}, err
+	case "CALSCALE":
+		v, err := d.text(l)
+		return &property.CalendarScale{Parameters: params, Value: v}, err
+	case "METHOD":
+		v, err := d.text(l)
+		return &property.Method{Parameters: params, Value: v}, err
+	case "ATTACH":
+		vt := ValueType(l)
+		if vt != "URI" && vt != "BINARY" {
+			return nil, fmt.Errorf("value type %s is not allowed", vt)
+		}
+		v, err := d.value(l, vt, l.Value)
+		return &descriptive.Attach{Parameters: params, Value: v}, err
+	case "CATEGORIES":
+		v, err := d.textValues(l)
+		return &descriptive.Categories{Parameters: params, Values: v}, err
+	case "CLASS":
+		v, err := d.text(l)
+		return &descriptive.Classification{Parameters: params, Value: v}, err
+	case "COMMENT":
+		v, err := d.text(l)
+		return &descriptive.Comment{Parameters: params, Value: v}, err
+	case "DESCRIPTION":
+		v, err := d.text(l)
+		return &descriptive.Description{Parameters: params, Value: v}, err
+	case "GEO":
+		parts := strings.Split(l.Value, ";")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid GEO %q", l.Value)
+		}
+		g := &descriptive.Geographic{Parameters: params}
+		for _, s := range parts {
+			v, err := d.value(l, "FLOAT", s)
+			if err != nil {
+				return nil, err
+			}
+			g.Values = append(g.Values, v.(*types.Float))
+		}
+		return g, nil
+	case "LOCATION":
+		v, err := d.text(l)
+		return &descriptive.Location{Parameters: params, Values: v}, err
+	case "PERCENT-COMPLETE":
+		v, err := d.integer(l)
+		if err == nil && (v.V < 0 || v.V > 100) {
+			err = fmt.Errorf("%d is not between 0 and 100", v.V)
+		}
+		return &descriptive.PercentComplete{Parameters: params, Values: v}, err
+	case "PRIORITY":
+		v, err := d.integer(l)
+		if err == nil && (v.V < 0 || v.V > 9) {
+			err = fmt.Errorf("%d is not between 0 and 9", v.V)
+		}
+		return &descriptive.Priority{Parameters: params, Value: v}, err
+	case "RESOURCES":
+		v, err := d.textValues(l)
+		return &descriptive.Resources{Parameters: params, Values: v}, err
+	case "STATUS":
+		v, err := d.text(l)
+		return &descriptive.Status{Parameters: params, Value: v}, err
+	case "SUMMARY":
+		v, err := d.text(l)
+		return &descriptive.Summary{Parameters: params, Value: v}, err
+	case "COMPLETED":
+		v, err := d.utc(l)
+		return &datetime.Completed{Parameters: params, Value: v}, err
+	case "DTEND":
+		v, err := d.timeValue(l)
+		return &datetime.DateEnd{Parameters: params, Value: v}, err
+	case "DUE":
+		v, err := d.timeValue(l)
+		return &datetime.Due{Parameters: params, Value: v}, err
+	case "DTSTART":
+		v, err := d.timeValue(l)
+		return &datetime.DateStart{Parameters: params, Value: v}, err
+	case "DURATION":
+		v, err := types.ParseDuration(l.Value)
+		return &datetime.Duration{Parameters: params, Value: v}, err
+	case "FREEBUSY":
+		if vt := ValueType(l); vt != "PERIOD" {
+			return nil, fmt.Errorf("value type %s is not allowed", vt)
+		}
+		v, err := d.values(l, "PERIOD")
+		return &datetime.FreeBusy{Parameters: params, Values: v}, err
+	case "TRANSP":
+		v, err := d.text(l)
+		return &datetime.Transparent{Parameters: params, Values: v}, err
+	case "TZID":
+		v, err := d.text(l)
+		return &timezone.TzId{Parameters: params, Value: v}, err
+	case "TZNAME":
+		v, err := d.text(l)
+		return &timezone.TzName{Parameters: params, Value: v}, err
+	case "TZOFFSETFROM":
+		v, err := types.ParseUTCOffset(l.Value)
+		return &timezone.TzOffsetFrom{Parameters: params, Value: v}, err
+	case "TZOFFSETTO":
+		v, err := types.ParseUTCOffset(l.Value)
+		return &timezone.TzOffsetTo{Parameters: params, Value: v}, err
+	case "TZURL":
+		return &timezone.TzUrl{Parameters: params, Value: &types.URI{V: l.Value}}, nil
+	case "ATTENDEE":
+		return &relationship.Attendee{Parameters: params, Value: &types.CalAddress{V: &types.URI{V: l.Value}}}, nil
+	case "CONTACT":
+		v, err := d.text(l)
+		return &relationship.Contact{Parameters: params, Value: v}, err
+	case "ORGANIZER":
+		return &relationship.Organizer{Parameters: params, Value: &types.CalAddress{V: &types.URI{V: l.Value}}}, nil
+	case "RECURRENCE-ID":
+		v, err := d.timeValue(l)
+		return &relationship.RecurrenceId{Parameters: params, Value: v}, err
+	case "RELATED-TO":
+		v, err := d.text(l)
+		return &relationship.RelatedTo{Parameters: params, Value: v}, err
+	case "URL":
+		return &relationship.Url{Parameters: params, Value: &types.URI{V: l.Value}}, nil
+	case "UID":
+		v, err := d.text(l)
+		return &relationship.Uid{Parameters: params, Value: v}, err
+	case "EXDATE":
+		v, err := d.timeValues(l, false)
+		return &recurrence.ExDate{Parameters: params, Values: v}, err
+	case "RDATE":
+		v, err := d.timeValues(l, true)
+		return &recurrence.RDate{Parameters: params, Values: v}, err
+	case "RRULE":
+		v, err := recurRule(l.Value)
+		return &recurrence.RRule{Parameters: params, V: v}, err
+	case "ACTION":
+		v, err := d.text(l)
+		return &alarm.Action{Parameters: params, Value: v}, err
+	case "REPEAT":
+		v, err := d.integer(l)
+		return &alarm.Repeat{Parameters: params, Value: v}, err
+	case "TRIGGER":
+		vt := ValueType(l)
+		if vt != "DURATION" && vt != "DATE-TIME" {
+			return nil, fmt.Errorf("value type %s is not allowed", vt)
+		}
+		v, err := d.value(l, vt, l.Value)
+		if dt, ok := v.(*types.DateTime); ok && !dt.IsUTC() {
+			err = fmt.Errorf("must be a UTC time")
+		}
+		return &alarm.Trigger{Parameters: params, Value: v}, err
+	case "CREATED":
+		v, err := d.utc(l)
+		return &changemanage.Created{Parameters: params, Value: v}, err
+	case "DTSTAMP":
+		v, err := d.utc(l)
+		return &changemanage.DtStamp{Parameters: params, Value: v}, err
+	case "LAST-MODIFIED":
+		v, err := d.utc(l)
+		return &changemanage.LastModified{Parameters: params, Value: v}, err
+	case "SEQUENCE":
+		v, err := d.integer(l)
+		return &changemanage.Sequence{Parameters: params, Value: v}, err
+	case "REQUEST-STATUS":
+		parts := strings.SplitN(l.Value, ";", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid REQUEST-STATUS %q", l.Value)
+		}
+		r := &miscellaneous.RequestStatus{Parameters: params, StatCode: parts[0], StatDesc: parts[1]}
+		if len(parts) == 3 {
+			r.ExtData = parts[2]
+		}
+		return r, nil
+	}
+	// other properties keep their values as written unless they have
+	// a VALUE parameter
+	values := []types.Value{&types.Text{V: l.Value}}
+	if vt, ok := l.Param("VALUE"); ok && strings.ToUpper(vt) != "TEXT" {
+		values, err = d.values(l, strings.ToUpper(vt))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(l.Name, "X-") {
+		return &miscellaneous.NoStandard{Name: l.Name, Parameters: params, Values: values}, nil
+	}
+	return &miscellaneous.Iana{Name: l.Name, Parameters: params, Values: values}, nil
+}
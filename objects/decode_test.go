@@ -0,0 +1,194 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleCalendar = `BEGIN:VCALENDAR
+PRODID:-//RDU Software//NONSGML HandCal//EN
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Eastern Standard Time
+BEGIN:STANDARD
+DTSTART:19981025T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+TZNAME:EST
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:19990404T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU
+TZNAME:EDT
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VEVENT
+DTSTAMP:19980309T231000Z
+UID:guid-1.example.com
+ORGANIZER:mailto:mrbig@example.com
+ATTENDEE;RSVP=TRUE;ROLE=REQ-PARTICIPANT;CUTYPE=GROUP:
+ mailto:employee-A@example.com
+DESCRIPTION:Project XYZ Review Meeting
+CATEGORIES:MEETING,PROJECT\, XYZ
+CLASS:PUBLIC
+CREATED:19980309T130000Z
+SUMMARY:XYZ Project Review
+DTSTART;TZID=Eastern Standard Time:19990708T083000
+DTEND;TZID=Eastern Standard Time:19990708T093000
+GEO:37.386013;-122.082932
+X-WR-TEST;X-P=1:kept
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+DESCRIPTION:Reminder
+REPEAT:2
+DURATION:PT5M
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestDecode(t *testing.T) {
+	c, err := Decode(strings.NewReader(sampleCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Components) != 2 {
+		t.Fatalf("got %d components, want 2", len(c.Components))
+	}
+	e, ok := c.Components[1].(*components.Event)
+	if !ok {
+		t.Fatalf("second component is %T", c.Components[1])
+	}
+	start := e.DtStart.Value.(*types.DateTime).Time()
+	if want := time.Date(1999, 7, 8, 12, 30, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("DTSTART: got %v, want %v", start.UTC(), want)
+	}
+	if len(e.Categories) != 1 || len(e.Categories[0].Values) != 2 {
+		t.Errorf("CATEGORIES: got %+v", e.Categories)
+	}
+	if len(e.Alarm) != 1 || e.Alarm[0].Repeat.V != 2 {
+		t.Errorf("VALARM: got %+v", e.Alarm)
+	}
+	out, err := c.Calendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"DTSTART;TZID=Eastern Standard Time:19990708T083000\n",
+		"ATTENDEE;RSVP=TRUE;ROLE=REQ-PARTICIPANT;CUTYPE=GROUP:mailto:employee-A@example.com\n",
+		"ORGANIZER:mailto:mrbig@example.com\n",
+		"CATEGORIES:MEETING,PROJECT\\, XYZ\n",
+		"GEO:37.386013;-122.082932\n",
+		"X-WR-TEST;X-P=1:kept\n",
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\n",
+		"TZOFFSETFROM:-0400\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	in := "BEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"BEGIN:VEVENT\n" +
+		"UID:1\n" +
+		"DTSTART:20240101T100000Z\n" +
+		"DTEND:20240101T090000Z\n" +
+		"PRIORITY:high\n" +
+		"DTSTART;TZID=Nowhere/Special:20240101T100000\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+	_, err := Decode(strings.NewReader(in))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("got %v, want an ErrorList", err)
+	}
+	got := list.Error()
+	for _, want := range []string{
+		"line 1: VCALENDAR without PRODID",
+		"line 3: VEVENT without DTSTAMP",
+		"line 3: DTEND of VEVENT is before DTSTART",
+		"line 7: PRIORITY: invalid INTEGER \"high\"",
+		"line 8: unknown TZID Nowhere/Special",
+		"line 8: DTSTART must not occur more than once in VEVENT",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+
+	_, err = Decode(strings.NewReader("BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nEND:VCALENDAR\n"))
+	if e, ok := err.(*SyntaxError); !ok || e.Line != 4 {
+		t.Errorf("got %v, want a syntax error on line 4", err)
+	}
+}
+
+func TestDecode_LenientRRule(t *testing.T) {
+	// the numbered BYDAY is not allowed with WEEKLY, but such rules are
+	// common in published calendars
+	in := "BEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"PRODID:-//test//EN\n" +
+		"BEGIN:VEVENT\n" +
+		"UID:1\n" +
+		"DTSTAMP:20240101T000000Z\n" +
+		"DTSTART:20240101T100000Z\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=1MO\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := c.Components[0].(*components.Event); e.RRule == nil || e.RRule.V.Validate() == nil {
+		t.Errorf("RRULE: got %+v", e.RRule)
+	}
+}
+
+func TestRecurRule(t *testing.T) {
+	for _, s := range []string{
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=DAILY;BYSECOND=001",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=DAILY;COUNT=10;UNTIL=20240601T000000Z",
+	} {
+		if _, err := recurRule(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "COUNT=10", "FREQ=DAILY;FREQ=WEEKLY", "FREQ=FORTNIGHTLY", "FREQ=DAILY;INTERVAL=-1",
+		"FREQ=MONTHLY;BYDAY=XX", "FREQ=MONTHLY;BYDAY=0MO", "FREQ=DAILY;UNTIL=2024", "FREQ=DAILY;COUNT"} {
+		if _, err := recurRule(s); err == nil {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	tests := []struct {
+		s    string
+		sep  byte
+		n    int
+		want []string
+	}{
+		{`a,b\,c,d`, ',', -1, []string{"a", `b\,c`, "d"}},
+		{`2.0;Success\;ok;data;more`, ';', 3, []string{"2.0", `Success\;ok`, "data;more"}},
+		{`x`, ';', 3, []string{"x"}},
+	}
+	for _, tt := range tests {
+		got := SplitEscaped(tt.s, tt.sep, tt.n)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("SplitEscaped(%q, %q, %d) = %q, want %q", tt.s, tt.sep, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"reflect"
+	"strings"
+)
+
+//   RFC 5546 2.1.4.  Message Sequencing
+//
+//      The "SEQUENCE" property value is incremented by the "Organizer"
+//      ... whenever it makes changes to properties in the calendar
+//      component that the "Organizer" deems will jeopardize the validity
+//      of the participation status of the "Attendees".
+//
+//   RFC 5545 3.8.7.4.  Sequence Number
+//
+//      For example, changing the location of a meeting from one locations
+//      to another distant location could effectively impact the
+//      participation status of the "Attendees".
+
+// ChangeKind tells whether a property was added, removed or changed.
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+	return "changed"
+}
+
+// Change is a difference between two versions of a component.
+type Change struct {
+	Kind ChangeKind
+	// Name is the name of the property, or VALARM for alarms.
+	Name string
+	// Key tells repeated properties apart: the address of an ATTENDEE,
+	// the value of an RDATE or EXDATE in UTC, the value of other
+	// repeated properties and the position of an alarm.
+	Key string
+	// Old and New are the property before and after the change, nil
+	// when it was added or removed.  They are properties such as
+	// *datetime.DateStart, the types.Value of an RDATE or EXDATE, or a
+	// *components.Alarm.
+	Old, New interface{}
+}
+
+func (c *Change) String() string {
+	if c.Key == "" {
+		return c.Name + " " + c.Kind.String()
+	}
+	return c.Name + " " + c.Key + " " + c.Kind.String()
+}
+
+// Changes are the differences between two versions of a component, in
+// the order of the fields of the component.
+type Changes []*Change
+
+// significant lists the properties whose changes invalidate the replies
+// of attendees.
+var significant = map[string]bool{
+	"DTSTART":  true,
+	"DTEND":    true,
+	"DURATION": true,
+	"DUE":      true,
+	"RRULE":    true,
+	"RDATE":    true,
+	"EXDATE":   true,
+	"LOCATION": true,
+	"STATUS":   true,
+}
+
+// Significant reports whether the changes are significant enough for the
+// organizer to increment the SEQUENCE, that is whether they change the
+// time, the recurrence, the location or the status of the component.
+// Changes of attendees, descriptions and alarms are not.
+func (cs Changes) Significant() bool {
+	for _, c := range cs {
+		if significant[c.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the changes from old to new, which are both *Event, both
+// *Todo or both *Journal, and not nil.  Properties are compared by meaning, as Equal
+// compares them.  ATTENDEEs are matched by address, RDATE and EXDATE
+// values by their instant, other repeated properties by value and
+// alarms by position.
+func Diff(old, new components.Component) (Changes, error) {
+	switch old.(type) {
+	case *components.Event, *components.Todo, *components.Journal:
+	default:
+		return nil, fmt.Errorf("objects: Diff of %T", old)
+	}
+	if reflect.TypeOf(old) != reflect.TypeOf(new) {
+		return nil, fmt.Errorf("objects: Diff of %T and %T", old, new)
+	}
+	if reflect.ValueOf(old).IsNil() || reflect.ValueOf(new).IsNil() {
+		return nil, fmt.Errorf("objects: Diff of a nil %T", old)
+	}
+	var changes Changes
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		of, nf := ov.Field(i), nv.Field(i)
+		switch {
+		case of.Type() == reflect.TypeOf([]*components.Alarm(nil)):
+			changes = append(changes, diffAlarms(of.Interface().([]*components.Alarm), nf.Interface().([]*components.Alarm))...)
+		case of.Kind() == reflect.Slice:
+			changes = append(changes, diffList(list(of), list(nf))...)
+		default:
+			var o, n properties.Property
+			if !of.IsNil() {
+				o = of.Interface().(properties.Property)
+			}
+			if !nf.IsNil() {
+				n = nf.Interface().(properties.Property)
+			}
+			if c := diffProperty(o, n); c != nil {
+				changes = append(changes, c)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// keyed is a property or a value of one, with its key and canonical form.
+type keyed struct {
+	name  string
+	key   string
+	text  string
+	value interface{}
+}
+
+// canonicalProperty returns the parsed and the canonical content line of p.
+func canonicalProperty(p properties.Property) (*ContentLine, string) {
+	l, err := ParseContentLine(ToContentLine(p))
+	if err != nil {
+		return &ContentLine{}, ToContentLine(p)
+	}
+	return l, canonicalLine(l, nil)
+}
+
+func diffProperty(o, n properties.Property) *Change {
+	switch {
+	case o == nil && n == nil:
+		return nil
+	case o == nil:
+		l, _ := canonicalProperty(n)
+		return &Change{Kind: Added, Name: strings.ToUpper(l.Name), New: n}
+	case n == nil:
+		l, _ := canonicalProperty(o)
+		return &Change{Kind: Removed, Name: strings.ToUpper(l.Name), Old: o}
+	}
+	l, ot := canonicalProperty(o)
+	if _, nt := canonicalProperty(n); ot != nt {
+		return &Change{Kind: Changed, Name: strings.ToUpper(l.Name), Old: o, New: n}
+	}
+	return nil
+}
+
+// list returns the keyed properties of the slice v, or their values for
+// RDATE and EXDATE.
+func list(v reflect.Value) []*keyed {
+	var items []*keyed
+	for i := 0; i < v.Len(); i++ {
+		p, ok := v.Index(i).Interface().(properties.Property)
+		if !ok || v.Index(i).IsNil() {
+			continue
+		}
+		l, text := canonicalProperty(p)
+		name := strings.ToUpper(l.Name)
+		var values []types.Value
+		switch d := p.(type) {
+		case *recurrence.RDate:
+			values = d.Values
+		case *recurrence.ExDate:
+			values = d.Values
+		case *relationship.Attendee:
+			items = append(items, &keyed{name: name, key: strings.ToLower(l.Value), text: text, value: p})
+			continue
+		default:
+			items = append(items, &keyed{name: name, key: text, text: text, value: p})
+			continue
+		}
+		tzid, _ := l.Param("TZID")
+		loc := zoneOf(tzid, nil)
+		for j, s := range SplitList(l.Value) {
+			k := canonicalTime(s, loc)
+			var value interface{} = s
+			if j < len(values) {
+				value = values[j]
+			}
+			items = append(items, &keyed{name: name, key: k, text: k, value: value})
+		}
+	}
+	return items
+}
+
+func diffList(old, new []*keyed) Changes {
+	var changes Changes
+	byKey := map[string]*keyed{}
+	for _, n := range new {
+		byKey[n.name+"\x00"+n.key] = n
+	}
+	seen := map[string]bool{}
+	for _, o := range old {
+		k := o.name + "\x00" + o.key
+		seen[k] = true
+		n, ok := byKey[k]
+		switch {
+		case !ok:
+			changes = append(changes, &Change{Kind: Removed, Name: o.name, Key: o.key, Old: o.value})
+		case n.text != o.text:
+			changes = append(changes, &Change{Kind: Changed, Name: o.name, Key: o.key, Old: o.value, New: n.value})
+		}
+	}
+	for _, n := range new {
+		if !seen[n.name+"\x00"+n.key] {
+			changes = append(changes, &Change{Kind: Added, Name: n.name, Key: n.key, New: n.value})
+		}
+	}
+	return changes
+}
+
+func diffAlarms(old, new []*components.Alarm) Changes {
+	var changes Changes
+	for i := 0; i < len(old) || i < len(new); i++ {
+		key := fmt.Sprint(i + 1)
+		switch {
+		case i >= len(new):
+			changes = append(changes, &Change{Kind: Removed, Name: "VALARM", Key: key, Old: old[i]})
+		case i >= len(old):
+			changes = append(changes, &Change{Kind: Added, Name: "VALARM", Key: key, New: new[i]})
+		case !Equal(alarmComponent{old[i]}, alarmComponent{new[i]}):
+			changes = append(changes, &Change{Kind: Changed, Name: "VALARM", Key: key, Old: old[i], New: new[i]})
+		}
+	}
+	return changes
+}
+
+// alarmComponent makes an alarm a component.
+type alarmComponent struct {
+	*components.Alarm
+}
+
+func (a alarmComponent) WriteComponentToStrBuilder(s *strings.Builder) error {
+	return a.Alarm.Alarm(s)
+}
@@ -0,0 +1,78 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := decodeString(t, equalCalendar).Components[0].(*components.Event)
+	updated := strings.NewReplacer(
+		"DTSTART;TZID=Europe/Berlin:20240610T093000", "DTSTART;TZID=Europe/Berlin:20240610T103000\nLOCATION:Room 4",
+		"ATTENDEE;CN=Bob:mailto:bob@example.com\n", "ATTENDEE;CN=Carol:mailto:carol@example.com\n",
+		"ROLE=CHAIR;", "ROLE=CHAIR;PARTSTAT=ACCEPTED;",
+		"20240624T093000,20240617T093000", "20240617T093000,20240701T093000",
+		"TRIGGER:-PT15M", "TRIGGER:-PT30M",
+	).Replace(equalCalendar)
+	e := decodeString(t, updated).Components[0].(*components.Event)
+
+	changes, err := Diff(old, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"DTSTART changed",
+		"LOCATION added",
+		"ATTENDEE mailto:alice@example.com changed",
+		"ATTENDEE mailto:bob@example.com removed",
+		"ATTENDEE mailto:carol@example.com added",
+		"EXDATE 20240624T073000Z removed",
+		"EXDATE 20240701T073000Z added",
+		"VALARM 1 changed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Diff:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !changes.Significant() {
+		t.Errorf("a moved meeting is not significant")
+	}
+	if start := changes[0].New.(*datetime.DateStart).Value.(*types.DateTime); start.V.Hour() != 10 {
+		t.Errorf("new DTSTART: %v", start.V)
+	}
+	if ex := changes[6].New.(*types.DateTime); ex.V.Month() != 7 {
+		t.Errorf("added EXDATE: %v", ex.V)
+	}
+
+	// the same event in UTC, with new attendees only
+	same := strings.Replace(strings.Replace(equalCalendar, "DTSTART;TZID=Europe/Berlin:20240610T093000", "DTSTART:20240610T073000Z", 1),
+		"END:VALARM\n", "END:VALARM\nBEGIN:VALARM\nACTION:AUDIO\nTRIGGER:PT0S\nEND:VALARM\n", 1)
+	same = strings.Replace(same, "ATTENDEE;CN=Bob", "ATTENDEE;CN=Dave:mailto:dave@example.com\nATTENDEE;CN=Bob", 1)
+	changes, err = Diff(old, decodeString(t, same).Components[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].String() != "ATTENDEE mailto:dave@example.com added" || changes[1].String() != "VALARM 2 added" || changes.Significant() {
+		t.Errorf("Diff of new attendees: %v", changes)
+	}
+
+	if _, err := Diff(old, &components.Todo{}); err == nil {
+		t.Errorf("Diff of an event and a to-do succeeded")
+	}
+	var none *components.Event
+	if _, err := Diff(old, none); err == nil {
+		t.Errorf("Diff with a nil event succeeded")
+	}
+	if _, err := Diff(none, old); err == nil {
+		t.Errorf("Diff of a nil event succeeded")
+	}
+	if _, err := Diff(nil, old); err == nil {
+		t.Errorf("Diff of nil succeeded")
+	}
+}
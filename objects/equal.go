@@ -0,0 +1,226 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.1.  Content Lines
+//
+//      All names of properties, property parameters, enumerated property
+//      values and property parameter values are case-insensitive.
+//      However, all property parameter values are case-sensitive unless
+//      otherwise stated.
+//
+//   RFC 5545 3.2.  Property Parameters
+//
+//      The general property parameters defined by this memo
+//      can be specified in any order.
+
+// enumerated lists the parameters whose values are case-insensitive.
+var enumerated = map[string]bool{
+	"CUTYPE":              true,
+	"ENCODING":            true,
+	"FBTYPE":              true,
+	"PARTSTAT":            true,
+	"RANGE":               true,
+	"RELATED":             true,
+	"RELTYPE":             true,
+	"ROLE":                true,
+	"RSVP":                true,
+	"SCHEDULE-AGENT":      true,
+	"SCHEDULE-FORCE-SEND": true,
+	"VALUE":               true,
+}
+
+// Equal reports whether the components a and b have the same meaning.
+// Unlike their text, it ignores the order of properties, parameters and
+// subcomponents, the case of names and enumerated values, VALUE
+// parameters of the default type and the scheme case of addresses, and
+// it compares the local times of time zones as the instants they stand
+// for, so that DTSTART;TZID=Europe/Berlin:20240610T093000 equals
+// DTSTART:20240610T073000Z.  Time zones are looked up in the time zone
+// database; use Calendar.Equal to resolve the VTIMEZONE of calendars.
+func Equal(a, b components.Component) bool {
+	ra, err := rawOf(a)
+	if err != nil {
+		return false
+	}
+	rb, err := rawOf(b)
+	if err != nil {
+		return false
+	}
+	return canonical(ra, nil) == canonical(rb, nil)
+}
+
+// Equal reports whether c and d have the same meaning, as Equal of
+// components defines it.  The TZIDs of each calendar are resolved by its
+// own VTIMEZONE components first.
+func (c *Calendar) Equal(d *Calendar) bool {
+	rc, err := calendarRaw(c)
+	if err != nil {
+		return false
+	}
+	rd, err := calendarRaw(d)
+	if err != nil {
+		return false
+	}
+	return canonical(rc, zonesOf(c)) == canonical(rd, zonesOf(d))
+}
+
+func rawOf(c components.Component) (*RawComponent, error) {
+	s := &strings.Builder{}
+	if err := c.WriteComponentToStrBuilder(s); err != nil {
+		return nil, err
+	}
+	return singleRaw(s.String())
+}
+
+func calendarRaw(c *Calendar) (*RawComponent, error) {
+	s, err := c.Calendar()
+	if err != nil {
+		return nil, err
+	}
+	return singleRaw(s)
+}
+
+func singleRaw(s string) (*RawComponent, error) {
+	raw, err := ReadRawComponents(strings.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != 1 {
+		return nil, fmt.Errorf("objects: %d components instead of one", len(raw))
+	}
+	return raw[0], nil
+}
+
+// zonesOf returns the locations of the VTIMEZONE components of c by TZID.
+func zonesOf(c *Calendar) map[string]*time.Location {
+	zones := map[string]*time.Location{}
+	for _, comp := range c.Components {
+		tz, ok := comp.(*components.TimeZone)
+		if !ok || tz.TzId == nil || tz.TzId.Value == nil {
+			continue
+		}
+		if loc, err := tz.Location(); err == nil {
+			zones[tz.TzId.Value.V] = loc
+		}
+	}
+	return zones
+}
+
+// canonical returns c written in a canonical form, in which components
+// that mean the same are written the same.
+func canonical(c *RawComponent, zones map[string]*time.Location) string {
+	lines := make([]string, 0, len(c.Properties))
+	for _, l := range c.Properties {
+		lines = append(lines, canonicalLine(l, zones))
+	}
+	sort.Strings(lines)
+	subs := make([]string, 0, len(c.Components))
+	for _, sub := range c.Components {
+		subs = append(subs, canonical(sub, zones))
+	}
+	sort.Strings(subs)
+	name := strings.ToUpper(c.Name)
+	return "BEGIN:" + name + "\n" + strings.Join(lines, "\n") + "\n" + strings.Join(subs, "") + "END:" + name + "\n"
+}
+
+func canonicalLine(l *ContentLine, zones map[string]*time.Location) string {
+	name := strings.ToUpper(l.Name)
+	vt := ValueType(l)
+	tzid, hasTzid := l.Param("TZID")
+	var params []*Param
+	for _, p := range l.Params {
+		pn := strings.ToUpper(p.Name)
+		values := append([]string(nil), p.Values...)
+		if enumerated[pn] {
+			for i := range values {
+				values[i] = strings.ToUpper(values[i])
+			}
+		}
+		switch {
+		case pn == "VALUE" && len(values) == 1 && values[0] == DefaultValueType(name):
+			continue
+		case pn == "TZID":
+			continue
+		}
+		sort.Strings(values)
+		params = append(params, &Param{Name: pn, Values: values})
+	}
+
+	value := l.Value
+	switch vt {
+	case "DATE-TIME", "PERIOD":
+		var loc *time.Location
+		if hasTzid {
+			loc = zoneOf(tzid, zones)
+			if loc == nil {
+				// an unknown zone is compared by its name
+				params = append(params, &Param{Name: "TZID", Values: []string{tzid}})
+			}
+		}
+		values := SplitList(value)
+		for i, v := range values {
+			values[i] = canonicalTime(v, loc)
+		}
+		if IsList(name) {
+			sort.Strings(values)
+		}
+		value = strings.Join(values, ",")
+	case "CAL-ADDRESS":
+		if i := strings.IndexByte(value, ':'); i > 0 {
+			value = strings.ToLower(value[:i]) + value[i:]
+		}
+	case "BOOLEAN":
+		value = strings.ToUpper(value)
+	case "DATE":
+		if IsList(name) {
+			values := SplitList(value)
+			sort.Strings(values)
+			value = strings.Join(values, ",")
+		}
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return (&ContentLine{Name: name, Params: params, Value: value}).String()
+}
+
+// zoneOf returns the location of tzid, or nil when it is not known.
+func zoneOf(tzid string, zones map[string]*time.Location) *time.Location {
+	if loc, ok := zones[tzid]; ok {
+		return loc
+	}
+	if tzid == "" || strings.EqualFold(tzid, "local") {
+		return nil
+	}
+	loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// canonicalTime writes the local date-times of loc in v, which is a
+// DATE-TIME or a PERIOD, in UTC.  Floating times are left as they are.
+func canonicalTime(v string, loc *time.Location) string {
+	if slash := strings.IndexByte(v, '/'); slash >= 0 {
+		end := v[slash+1:]
+		if end != "" && !strings.ContainsAny(end[:1], "P+-") {
+			end = canonicalTime(end, loc)
+		}
+		return canonicalTime(v[:slash], loc) + "/" + end
+	}
+	if loc == nil || strings.HasSuffix(v, "Z") {
+		return v
+	}
+	t, err := time.ParseInLocation(types.LocalDateTimeFormat, v, loc)
+	if err != nil {
+		return v
+	}
+	return t.UTC().Format(types.UTCDateTimeFormat)
+}
@@ -0,0 +1,115 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"testing"
+)
+
+const equalCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:planning@example.com
+DTSTAMP:20240601T080000Z
+DTSTART;TZID=Europe/Berlin:20240610T093000
+DURATION:PT1H30M
+ATTENDEE;ROLE=CHAIR;CN="Alice";RSVP=TRUE:mailto:alice@example.com
+ATTENDEE;CN=Bob:mailto:bob@example.com
+EXDATE;TZID=Europe/Berlin:20240624T093000,20240617T093000
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+DESCRIPTION:Planning
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`
+
+const equivalentCalendar = `begin:vcalendar
+prodid:-//test//EN
+version:2.0
+begin:vevent
+dtstamp:20240601T080000Z
+uid:planning@example.com
+x-note:unchanged
+attendee;cn=Bob:MAILTO:bob@example.com
+dtstart;value=date-time:20240610T073000Z
+duration:PT1H30M
+attendee;rsvp=true;cn=Alice;role=chair:mailto:alice@example.com
+exdate:20240617T073000Z,20240624T073000Z
+begin:valarm
+trigger:-PT15M
+description:Planning
+action:DISPLAY
+end:valarm
+end:vevent
+end:vcalendar
+`
+
+func decodeString(t *testing.T, s string) *Calendar {
+	t.Helper()
+	cal, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal
+}
+
+func TestEqual(t *testing.T) {
+	a := decodeString(t, equalCalendar)
+	b := decodeString(t, strings.Replace(strings.Replace(equivalentCalendar, "x-note:unchanged\n", "", 1),
+		"20240617T073000Z,20240624T073000Z", "20240624T073000Z,20240617T073000Z", 1))
+	if !a.Equal(b) || !Equal(a.Components[0], b.Components[0]) {
+		t.Errorf("equivalent calendars differ:\n%s", canonical(mustRaw(t, a), nil))
+	}
+	if a.Equal(decodeString(t, equivalentCalendar)) {
+		t.Errorf("calendars with different properties are equal")
+	}
+	c := decodeString(t, strings.Replace(equalCalendar, "PT15M", "PT10M", 1))
+	if a.Equal(c) {
+		t.Errorf("calendars with different alarms are equal")
+	}
+	c = decodeString(t, strings.Replace(equalCalendar, "TZID=Europe/Berlin:20240610T093000", "TZID=Europe/London:20240610T093000", 1))
+	if Equal(a.Components[0], c.Components[0]) {
+		t.Errorf("events at different instants are equal")
+	}
+}
+
+func mustRaw(t *testing.T, c *Calendar) *RawComponent {
+	raw, err := calendarRaw(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestClone(t *testing.T) {
+	a := decodeString(t, equalCalendar)
+	b := a.Clone()
+	if !a.Equal(b) {
+		t.Fatalf("the clone of a calendar differs")
+	}
+	e := b.Components[0].(*components.Event)
+	e.Attendee[0].Parameters[0] = &parameters.Optional
+	e.Alarm[0].Description.Value.V = "Changed"
+	e.DtStart.Value.(*types.DateTime).V = e.DtStart.Value.(*types.DateTime).V.AddDate(0, 0, 1)
+	orig := a.Components[0].(*components.Event)
+	if orig.Attendee[0].Parameters[0] == e.Attendee[0].Parameters[0] || orig.Alarm[0].Description.Value.V != "Planning" || orig.DtStart.Value.(*types.DateTime).V.Day() != 10 {
+		t.Errorf("changes of the clone changed the original")
+	}
+
+	// the shared parameter of DATE values is copied
+	start := datetime.NewDateStartWithDate(2024, 12, 24)
+	clone := start.Clone()
+	clone.Parameters[0].(*parameters.ValueType).V = "DATE-TIME"
+	if parameters.Date.V != "DATE" || start.Parameters[0].(*parameters.ValueType).V != "DATE" {
+		t.Errorf("changing a cloned parameter changed %v", parameters.Date)
+	}
+	if (&components.Event{}).Clone().DtStart != nil || components.Clone(nil) != nil {
+		t.Errorf("clones of empty components are not empty")
+	}
+}
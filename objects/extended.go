@@ -0,0 +1,91 @@
+package objects
+
+import "strings"
+
+//   RFC 6321 (xCal) and RFC 7265 (jCal) write DATE, DATE-TIME, TIME and
+//   UTC-OFFSET values in the extended format of [ISO.8601.2004]:
+//
+//       iCalendar               xCal / jCal
+//       19970714                1997-07-14
+//       19970714T133000Z        1997-07-14T13:30:00Z
+//       133000                  13:30:00
+//       -0500                   -05:00
+//
+//   PERIOD values are written as their start and end or duration with a
+//   "/" in between, and the UNTIL rule part of recurrence rules as a date
+//   or date-time.
+
+func extendedTime(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if i > 0 && i%2 == 0 && s[i] != 'Z' {
+			b.WriteByte(':')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func extendedDate(s string) string {
+	if len(s) != 8 {
+		return s
+	}
+	return s[:4] + "-" + s[4:6] + "-" + s[6:]
+}
+
+// ExtendedValue converts a value of type vt from its iCalendar form to
+// the extended form used by xCal and jCal.  Values of other types are
+// returned as they are.
+func ExtendedValue(vt, s string) string {
+	switch strings.ToUpper(vt) {
+	case "DATE":
+		return extendedDate(s)
+	case "DATE-TIME":
+		if t := strings.IndexByte(s, 'T'); t == 8 {
+			return extendedDate(s[:8]) + "T" + extendedTime(s[9:])
+		}
+	case "TIME":
+		return extendedTime(s)
+	case "UTC-OFFSET":
+		if len(s) > 1 {
+			return s[:1] + extendedTime(s[1:])
+		}
+	case "PERIOD":
+		if slash := strings.IndexByte(s, '/'); slash >= 0 && slash < len(s)-1 {
+			end := s[slash+1:]
+			if !strings.ContainsAny(end[:1], "P+-") {
+				end = ExtendedValue("DATE-TIME", end)
+			}
+			return ExtendedValue("DATE-TIME", s[:slash]) + "/" + end
+		}
+	case "DATE-OR-DATE-TIME":
+		if len(s) == 8 {
+			return extendedDate(s)
+		}
+		return ExtendedValue("DATE-TIME", s)
+	}
+	return s
+}
+
+// BasicValue converts a value of type vt from the extended form used by
+// xCal and jCal back to its iCalendar form.
+func BasicValue(vt, s string) string {
+	strip := strings.NewReplacer("-", "", ":", "")
+	switch strings.ToUpper(vt) {
+	case "DATE", "DATE-TIME", "TIME", "DATE-OR-DATE-TIME":
+		return strip.Replace(s)
+	case "UTC-OFFSET":
+		if len(s) > 1 {
+			return s[:1] + strip.Replace(s[1:])
+		}
+	case "PERIOD":
+		if slash := strings.IndexByte(s, '/'); slash >= 0 && slash < len(s)-1 {
+			end := s[slash+1:]
+			if !strings.ContainsAny(end[:1], "P+-") {
+				end = BasicValue("DATE-TIME", end)
+			}
+			return BasicValue("DATE-TIME", s[:slash]) + "/" + end
+		}
+	}
+	return s
+}
@@ -0,0 +1,560 @@
+// Package filter selects calendar components by their names, time
+// ranges, properties and parameters.
+//
+// The filter model follows the CALDAV:filter element of RFC 4791 one to
+// one: a CompFilter is a comp-filter, a PropFilter a prop-filter, a
+// ParamFilter a param-filter, a TextMatch a text-match and a TimeRange a
+// time-range, so that filters convert to and from calendar-query REPORTs
+// without loss.  Parse reads filters from a short text form, such as
+//
+//	VTODO { PRIORITY <= 3 and (not STATUS or STATUS != COMPLETED) }
+package filter
+
+import (
+	"errors"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"time"
+)
+
+//   RFC 4791 9.7.  CALDAV:filter XML Element
+//
+//      The "filter" element specifies the search filter used to limit the
+//      calendar components returned by a calendar-query REPORT request.
+//
+//       <!ELEMENT filter (comp-filter)>
+//
+//       <!ELEMENT comp-filter (is-not-defined | (time-range?,
+//                              prop-filter*, comp-filter*))>
+//
+//       <!ELEMENT prop-filter (is-not-defined |
+//                              ((time-range | text-match)?,
+//                               param-filter*))>
+//
+//       <!ELEMENT param-filter (is-not-defined | text-match?)>
+//
+//       <!ELEMENT text-match (#PCDATA)>
+//       <!ATTLIST text-match collation        CDATA "i;ascii-casemap"
+//                            negate-condition (yes | no) "no">
+//
+//       <!ELEMENT time-range EMPTY>
+//       <!ATTLIST time-range start CDATA #IMPLIED
+//                            end   CDATA #IMPLIED>
+//
+//   RFC 6352 10.5.4.  CARDDAV:text-match XML Element
+//
+//       <!ATTLIST text-match match-type (equals|contains|starts-with|
+//                                        ends-with) "contains">
+
+// CompFilter matches components by name, time range, properties and
+// subcomponents.  All its parts must match.
+type CompFilter struct {
+	Name         string
+	IsNotDefined bool
+	TimeRange    *TimeRange
+	Props        []*PropFilter
+	Comps        []*CompFilter
+}
+
+// PropFilter matches properties by name, value or time and parameters.
+// OrNotDefined, an extension of this package, also matches components
+// without the property, which a prop-filter of RFC 4791 does not.
+type PropFilter struct {
+	Name         string
+	IsNotDefined bool
+	OrNotDefined bool
+	TimeRange    *TimeRange
+	TextMatch    *TextMatch
+	Params       []*ParamFilter
+}
+
+// ParamFilter matches parameters by name and value.
+type ParamFilter struct {
+	Name         string
+	IsNotDefined bool
+	TextMatch    *TextMatch
+}
+
+// TextMatch matches values that compare to Text as MatchType says under
+// Collation, or that do not when Negate is set.  The empty MatchType is
+// Contains, the only one of RFC 4791, and the empty Collation is
+// i;ascii-casemap.
+type TextMatch struct {
+	Text      string
+	Collation string
+	MatchType string
+	Negate    bool
+}
+
+// TimeRange is the range [Start, End).  A zero Start or End leaves the
+// range open on that side.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// collations of RFC 4790 that text matches support
+const (
+	CollationASCIICasemap = "i;ascii-casemap"
+	CollationOctet        = "i;octet"
+	CollationASCIINumeric = "i;ascii-numeric"
+)
+
+// match types of text matches.  Contains is that of RFC 4791; Equals,
+// StartsWith and EndsWith are those of RFC 6352.  The orderings are an
+// extension of this package.
+const (
+	Contains       = "contains"
+	Equals         = "equals"
+	StartsWith     = "starts-with"
+	EndsWith       = "ends-with"
+	Less           = "less"
+	LessOrEqual    = "less-or-equal"
+	Greater        = "greater"
+	GreaterOrEqual = "greater-or-equal"
+)
+
+var (
+	// ErrUnsupportedCollation is returned for collations that are
+	// unknown or that do not support the match type.
+	ErrUnsupportedCollation = errors.New("filter: unsupported collation")
+	// ErrUnsupportedFilter is returned for match types that are unknown
+	// and time ranges of components without time.
+	ErrUnsupportedFilter = errors.New("filter: unsupported filter")
+)
+
+// Validate reports whether f can be evaluated.  Time ranges are
+// supported on events, to-dos, journal entries and free/busy
+// information, and on properties of DATE and DATE-TIME values.
+func (f *CompFilter) Validate() error {
+	if f.TimeRange != nil {
+		switch f.Name {
+		case "VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY":
+		default:
+			return ErrUnsupportedFilter
+		}
+	}
+	for _, p := range f.Props {
+		if err := p.TextMatch.validate(); err != nil {
+			return err
+		}
+		for _, pf := range p.Params {
+			if err := pf.TextMatch.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range f.Comps {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TextMatch) validate() error {
+	if t == nil {
+		return nil
+	}
+	switch t.MatchType {
+	case "", Contains, StartsWith, EndsWith:
+		if t.Collation == CollationASCIINumeric {
+			// i;ascii-numeric has no substrings
+			return ErrUnsupportedCollation
+		}
+	case Equals, Less, LessOrEqual, Greater, GreaterOrEqual:
+	default:
+		return ErrUnsupportedFilter
+	}
+	switch t.Collation {
+	case "", CollationASCIICasemap, CollationOctet, CollationASCIINumeric:
+		return nil
+	}
+	return ErrUnsupportedCollation
+}
+
+// Match reports whether t matches the value s.
+func (t *TextMatch) Match(s string) bool {
+	return t.matchAny([]string{s})
+}
+
+// matchAny reports whether t matches one of values, negated as a whole.
+func (t *TextMatch) matchAny(values []string) bool {
+	ok := false
+	for _, v := range values {
+		if t.compare(v) {
+			ok = true
+			break
+		}
+	}
+	return ok != t.Negate
+}
+
+// compare reports whether s compares to t.Text as t.MatchType says.
+func (t *TextMatch) compare(s string) bool {
+	text := t.Text
+	switch t.Collation {
+	case CollationOctet:
+	case CollationASCIINumeric:
+		c := compareNumeric(s, text)
+		switch t.MatchType {
+		case Equals:
+			return c == 0
+		case Less:
+			return c < 0
+		case LessOrEqual:
+			return c <= 0
+		case Greater:
+			return c > 0
+		case GreaterOrEqual:
+			return c >= 0
+		}
+		return false
+	default:
+		s, text = asciiLower(s), asciiLower(text)
+	}
+	switch t.MatchType {
+	case Equals:
+		return s == text
+	case StartsWith:
+		return strings.HasPrefix(s, text)
+	case EndsWith:
+		return strings.HasSuffix(s, text)
+	case Less:
+		return s < text
+	case LessOrEqual:
+		return s <= text
+	case Greater:
+		return s > text
+	case GreaterOrEqual:
+		return s >= text
+	}
+	return strings.Contains(s, text)
+}
+
+// asciiLower maps only the ASCII letters, as i;ascii-casemap does.
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+//   RFC 4790 9.1.1.  ASCII Numeric Collation Description
+//
+//      The "i;ascii-numeric" collation is a simple collation intended for
+//      use with arbitrarily-sized, unsigned decimal integer numbers stored
+//      as octet strings.  US-ASCII digits (0x30 to 0x39) represent digits
+//      of the numbers.  Before converting from string to integer, the
+//      input string is truncated at the first non-digit character.  All
+//      input is valid; strings that do not start with a digit represent
+//      positive infinity.
+
+// compareNumeric compares a and b under i;ascii-numeric.
+func compareNumeric(a, b string) int {
+	digits := func(s string) (string, bool) {
+		n := 0
+		for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		return strings.TrimLeft(s[:n], "0"), n > 0
+	}
+	da, fa := digits(a)
+	db, fb := digits(b)
+	switch {
+	case !fa && !fb:
+		return 0
+	case !fa:
+		return 1
+	case !fb:
+		return -1
+	case len(da) != len(db):
+		return len(da) - len(db)
+	}
+	return strings.Compare(da, db)
+}
+
+// matcher evaluates filters against one calendar object.
+type matcher struct {
+	cal *objects.Calendar
+	// typed components of the components of the calendar
+	typed map[*objects.RawComponent]components.Component
+	// components with instances in a time range
+	instances map[TimeRange]map[components.Component]bool
+}
+
+func newMatcher(raw *objects.RawComponent, cal *objects.Calendar) *matcher {
+	m := &matcher{cal: cal, typed: map[*objects.RawComponent]components.Component{}, instances: map[TimeRange]map[components.Component]bool{}}
+	if cal == nil {
+		// whatever could not be read is left out of the calendar
+		m.cal, _ = objects.DecodeRaw(raw)
+		if m.cal == nil {
+			return m
+		}
+		// DecodeRaw keeps the order of the components it knows
+		i := 0
+		for _, sub := range raw.Components {
+			switch sub.Name {
+			case "VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY", "VTIMEZONE":
+				if i < len(m.cal.Components) {
+					m.typed[sub] = m.cal.Components[i]
+				}
+				i++
+			}
+		}
+		return m
+	}
+	// the raw form of a calendar has its components in order
+	for i, sub := range raw.Components {
+		if i < len(cal.Components) {
+			m.typed[sub] = cal.Components[i]
+		}
+	}
+	return m
+}
+
+// Match reports whether the calendar object raw matches f, which is the
+// comp-filter of its VCALENDAR.
+func (f *CompFilter) Match(raw *objects.RawComponent) bool {
+	if raw.Name != f.Name {
+		return f.IsNotDefined
+	}
+	if f.IsNotDefined {
+		return false
+	}
+	return newMatcher(raw, nil).matchComp(f, raw)
+}
+
+// rawCalendar returns the raw form of cal.
+func rawCalendar(cal *objects.Calendar) (*objects.RawComponent, error) {
+	text, err := cal.Calendar()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := objects.ReadRawComponents(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	return raw[0], nil
+}
+
+// MatchCalendar reports whether cal matches f, which is the comp-filter
+// of its VCALENDAR.
+func (f *CompFilter) MatchCalendar(cal *objects.Calendar) (bool, error) {
+	raw, err := rawCalendar(cal)
+	if err != nil {
+		return false, err
+	}
+	if f.Name != "VCALENDAR" || f.IsNotDefined {
+		return f.IsNotDefined && f.Name != "VCALENDAR", nil
+	}
+	return newMatcher(raw, cal).matchComp(f, raw), nil
+}
+
+// Select returns the components of cal that match one of the
+// comp-filters of f, which is the comp-filter of its VCALENDAR, or all
+// of them when f has none.  Nothing is selected when cal does not match
+// f as a whole.
+func (f *CompFilter) Select(cal *objects.Calendar) ([]components.Component, error) {
+	raw, err := rawCalendar(cal)
+	if err != nil {
+		return nil, err
+	}
+	if f.Name != "VCALENDAR" || f.IsNotDefined {
+		return nil, nil
+	}
+	m := newMatcher(raw, cal)
+	if !m.matchComp(f, raw) {
+		return nil, nil
+	}
+	var selected []components.Component
+	for _, sub := range raw.Components {
+		c, ok := m.typed[sub]
+		if !ok {
+			continue
+		}
+		match, any := false, false
+		for _, cf := range f.Comps {
+			if cf.IsNotDefined {
+				continue
+			}
+			any = true
+			if cf.Name == sub.Name && m.matchComp(cf, sub) {
+				match = true
+				break
+			}
+		}
+		if match || !any {
+			selected = append(selected, c)
+		}
+	}
+	return selected, nil
+}
+
+func (m *matcher) matchComp(f *CompFilter, c *objects.RawComponent) bool {
+	if f.TimeRange != nil && !m.inRange(c, f.TimeRange) {
+		return false
+	}
+	for _, p := range f.Props {
+		if !m.matchProp(p, c) {
+			return false
+		}
+	}
+	for _, sub := range f.Comps {
+		found := false
+		for _, s := range c.Components {
+			if s.Name == sub.Name && (sub.IsNotDefined || m.matchComp(sub, s)) {
+				found = true
+				break
+			}
+		}
+		if found == sub.IsNotDefined {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *matcher) matchProp(f *PropFilter, c *objects.RawComponent) bool {
+	defined := false
+	for _, l := range c.Properties {
+		if l.Name != f.Name {
+			continue
+		}
+		if f.IsNotDefined {
+			return false
+		}
+		if matchLine(f, l) {
+			return true
+		}
+		defined = true
+	}
+	return f.IsNotDefined || f.OrNotDefined && !defined
+}
+
+func matchLine(f *PropFilter, l *objects.ContentLine) bool {
+	if f.TimeRange != nil && !lineInRange(l, f.TimeRange) {
+		return false
+	}
+	if f.TextMatch != nil {
+		values := []string{l.Value}
+		if objects.IsList(l.Name) {
+			values = objects.SplitList(l.Value)
+		}
+		if objects.ValueType(l) == "TEXT" {
+			for i, v := range values {
+				values[i] = types.UnescapeText(v)
+			}
+		}
+		if !f.TextMatch.matchAny(values) {
+			return false
+		}
+	}
+	for _, pf := range f.Params {
+		var values []string
+		ok := false
+		for _, p := range l.Params {
+			if strings.EqualFold(p.Name, pf.Name) {
+				values, ok = append(values, p.Values...), true
+			}
+		}
+		if ok == pf.IsNotDefined {
+			return false
+		}
+		if ok && pf.TextMatch != nil && !pf.TextMatch.matchAny(values) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineInRange reports whether a value of the DATE or DATE-TIME property l
+// falls in r.  DATE values take the whole day.
+func lineInRange(l *objects.ContentLine, r *TimeRange) bool {
+	loc := time.Local
+	if id, ok := l.Param("TZID"); ok {
+		if z, err := time.LoadLocation(id); err == nil {
+			loc = z
+		}
+	}
+	for _, v := range objects.SplitList(l.Value) {
+		var start, end time.Time
+		if t, err := time.ParseInLocation("20060102", v, loc); err == nil {
+			start, end = t, t.AddDate(0, 0, 1)
+		} else if t, err := time.Parse("20060102T150405Z", v); err == nil {
+			start, end = t, t
+		} else if t, err := time.ParseInLocation("20060102T150405", v, loc); err == nil {
+			start, end = t, t
+		} else {
+			continue
+		}
+		after := r.Start.IsZero() || end.After(r.Start) || start.Equal(end) && !start.Before(r.Start)
+		if after && (r.End.IsZero() || start.Before(r.End)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange reports whether c has an instance overlapping r.  Recurring
+// components are expanded, so that a master component matches when one
+// of the instances it has after its overrides does.
+func (m *matcher) inRange(c *objects.RawComponent, r *TimeRange) bool {
+	typed, ok := m.typed[c]
+	if !ok {
+		return false
+	}
+	start, end := r.Start, r.End
+	if start.IsZero() {
+		start = time.Unix(-1<<40, 0)
+	}
+	if end.IsZero() {
+		end = time.Unix(1<<40, 0)
+	}
+	if fb, ok := typed.(*components.FreeBusy); ok {
+		return freeBusyInRange(fb, start, end)
+	}
+	in, ok := m.instances[*r]
+	if !ok {
+		in = map[components.Component]bool{}
+		occ, err := m.cal.Occurrences(start, end)
+		if err == nil {
+			for _, o := range occ {
+				in[o.Component] = true
+			}
+		}
+		m.instances[*r] = in
+	}
+	return in[typed]
+}
+
+func freeBusyInRange(fb *components.FreeBusy, start, end time.Time) bool {
+	if fb.DtStart != nil && fb.DtEnd != nil {
+		s, _ := objects.TimeOf(fb.DtStart.Parameters, fb.DtStart.Value)
+		e, _ := objects.TimeOf(fb.DtEnd.Parameters, fb.DtEnd.Value)
+		return s.Before(end) && e.After(start)
+	}
+	for _, p := range fb.FreeBusyTime {
+		for _, v := range p.Values {
+			var s, e time.Time
+			switch t := v.(type) {
+			case *types.ExplicitPeriod:
+				s, e = t.Start.Time(), t.End.Time()
+			case *types.StartPeriod:
+				s = t.Start.Time()
+				e = s.Add(t.Duration.Duration())
+			default:
+				continue
+			}
+			if s.Before(end) && e.After(start) {
+				return true
+			}
+		}
+	}
+	return false
+}
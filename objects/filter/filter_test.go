@@ -0,0 +1,227 @@
+package filter
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ics = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:review@example.com
+DTSTAMP:20240601T080000Z
+DTSTART:20240605T090000Z
+DTEND:20240605T100000Z
+SUMMARY:Design review
+CATEGORIES:work,design
+ATTENDEE;PARTSTAT=NEEDS-ACTION;ROLE=REQ-PARTICIPANT:mailto:alice@example.com
+ATTENDEE;PARTSTAT=ACCEPTED:mailto:bob@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:retro@example.com
+DTSTAMP:20240601T080000Z
+DTSTART:20240620T090000Z
+DTEND:20240620T100000Z
+SUMMARY:Retro
+ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:alice@example.com
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT5M
+DESCRIPTION:Retro
+END:VALARM
+END:VEVENT
+BEGIN:VTODO
+UID:report@example.com
+DTSTAMP:20240601T080000Z
+PRIORITY:2
+X-ESTIMATE:2
+STATUS:NEEDS-ACTION
+SUMMARY:Report
+END:VTODO
+BEGIN:VTODO
+UID:slides@example.com
+DTSTAMP:20240601T080000Z
+PRIORITY:5
+X-ESTIMATE:10
+STATUS:NEEDS-ACTION
+SUMMARY:Slides
+END:VTODO
+BEGIN:VTODO
+UID:mail@example.com
+DTSTAMP:20240601T080000Z
+PRIORITY:1
+STATUS:COMPLETED
+COMPLETED:20240603T120000Z
+SUMMARY:Mail
+END:VTODO
+BEGIN:VTODO
+UID:call@example.com
+DTSTAMP:20240601T080000Z
+PRIORITY:3
+SUMMARY:Call
+END:VTODO
+END:VCALENDAR
+`
+
+var now = time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+
+func uids(list []components.Component) string {
+	var s []string
+	for _, c := range list {
+		switch v := c.(type) {
+		case *components.Event:
+			s = append(s, strings.TrimSuffix(v.Uid.Value.V, "@example.com"))
+		case *components.Todo:
+			s = append(s, strings.TrimSuffix(v.Uid.Value.V, "@example.com"))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestSelect(t *testing.T) {
+	cal, err := objects.Decode(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`VEVENT { ATTENDEE[PARTSTAT = NEEDS-ACTION] and in now/P7D }`, "review"},
+		{`VEVENT { ATTENDEE[PARTSTAT = NEEDS-ACTION] }`, "review retro"},
+		{`VTODO { PRIORITY <= 3 and STATUS != COMPLETED }`, "report"},
+		{`VTODO { PRIORITY <= 3 and not STATUS }`, "call"},
+		{`VTODO { PRIORITY <= 3 and (not STATUS or STATUS != COMPLETED) }`, "report call"},
+		{`VTODO { PRIORITY <= 3 and STATUS != COMPLETED or not STATUS }`, "report call"},
+		{`VTODO { not X-ESTIMATE or X-ESTIMATE > 5 }`, "slides mail call"},
+		{`VTODO { X-ESTIMATE <= 3 }`, "report"},
+		{`VTODO { X-ESTIMATE <= 3 i;ascii-casemap }`, "report slides"},
+		{`VTODO { PRIORITY > 1 }`, "report slides call"},
+		{`VEVENT { SUMMARY ~ review }`, "review"},
+		{`VEVENT { SUMMARY ~ Review i;octet }`, ""},
+		{`VEVENT { SUMMARY ^= "Design " }`, "review"},
+		{`VEVENT { CATEGORIES = design }`, "review"},
+		{`VEVENT { not CATEGORIES }`, "retro"},
+		{`VEVENT { VALARM { ACTION = display } }`, "retro"},
+		{`VEVENT { not VALARM {} }`, "review"},
+		{`VEVENT { ATTENDEE[ROLE, PARTSTAT !~ accepted] }`, "review"},
+		{`VEVENT { ATTENDEE[not ROLE] $= "bob@example.com" }`, "review"},
+		{`VTODO { COMPLETED in today/P1D }`, "mail"},
+		{`VCALENDAR { VERSION = "2.0" and VTODO { STATUS = completed } }`, "mail"},
+		{`VCALENDAR { VERSION = "1.0" and VTODO {} }`, ""},
+		{`VCALENDAR {}`, "review retro report slides mail call"},
+	}
+	for _, tt := range tests {
+		f, err := ParseAt(tt.filter, now)
+		if err != nil {
+			t.Errorf("%s: %v", tt.filter, err)
+			continue
+		}
+		got, err := f.Select(cal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g := uids(got); g != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.filter, g, tt.want)
+		}
+	}
+
+	f, _ := ParseAt(`VTODO { PRIORITY <= 3 }`, now)
+	if ok, err := f.MatchCalendar(cal); !ok || err != nil {
+		t.Errorf("MatchCalendar: %v, %v", ok, err)
+	}
+	f, _ = ParseAt(`not VTODO {}`, now)
+	if ok, _ := f.MatchCalendar(cal); ok {
+		t.Errorf("MatchCalendar of not VTODO matched")
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := ParseAt(`VEVENT { ATTENDEE[PARTSTAT = NEEDS-ACTION] and in now/P7D }`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &CompFilter{Name: "VCALENDAR", Comps: []*CompFilter{{
+		Name:      "VEVENT",
+		TimeRange: &TimeRange{Start: now, End: now.AddDate(0, 0, 7)},
+		Props: []*PropFilter{{Name: "ATTENDEE", Params: []*ParamFilter{{
+			Name:      "PARTSTAT",
+			TextMatch: &TextMatch{Text: "NEEDS-ACTION", Collation: CollationASCIICasemap, MatchType: Equals},
+		}}}},
+	}}}
+	if f.String() != want.String() || *f.Comps[0].TimeRange != *want.Comps[0].TimeRange || *f.Comps[0].Props[0].Params[0].TextMatch != *want.Comps[0].Props[0].Params[0].TextMatch {
+		t.Errorf("got %s, want %s", f, want)
+	}
+
+	for _, s := range []string{
+		`VEVENT { in 20240603T120000Z/20240610T120000Z and ATTENDEE[PARTSTAT = NEEDS-ACTION] }`,
+		`VTODO { PRIORITY <= 3 and STATUS != COMPLETED }`,
+		`VTODO { PRIORITY <= 3 and (not STATUS or STATUS != COMPLETED) }`,
+		`VEVENT { (not ATTENDEE or ATTENDEE[PARTSTAT = "(or)"]) }`,
+		`VCALENDAR { VERSION = 2.0 and VEVENT {} and not VTODO {} }`,
+		`VEVENT { SUMMARY ~ "a \"b\", c" i;octet and DESCRIPTION !^= x and not LOCATION and VALARM { TRIGGER[RELATED = END] } }`,
+		`VTODO { DUE in */20240701T000000Z and PRIORITY < 5 i;ascii-casemap and X-RANK >= "" }`,
+		`not VEVENT {}`,
+	} {
+		f, err := Parse(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if f.String() != s {
+			t.Errorf("String: got %s, want %s", f, s)
+		}
+	}
+
+	for _, s := range []string{
+		`VEVENT`,
+		`VEVENT { SUMMARY ~ }`,
+		`VEVENT { SUMMARY ~ x or DESCRIPTION ~ y }`,
+		`VEVENT { SUMMARY ~ x or not DESCRIPTION }`,
+		`VEVENT { not SUMMARY or DESCRIPTION ~ y }`,
+		`VEVENT { (SUMMARY ~ x }`,
+		`VEVENT { (VALARM {}) }`,
+		`VEVENT { in now }`,
+		`VEVENT { in */* }`,
+		`VEVENT { in now/P1D and in now/P2D }`,
+		`not VEVENT { SUMMARY }`,
+		`VEVENT { SUMMARY ~ "x }`,
+		`VEVENT {} VTODO {}`,
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%s parsed", s)
+		} else if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("%s: %v is no SyntaxError", s, err)
+		}
+	}
+	for _, s := range []string{
+		`VEVENT { SUMMARY ~ 1 i;ascii-numeric }`,
+		`VEVENT { SUMMARY = x i;unknown }`,
+		`VEVENT { VALARM { in now/P1D } }`,
+	} {
+		if _, err := Parse(s); err != ErrUnsupportedCollation && err != ErrUnsupportedFilter {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+}
+
+func TestCompareNumeric(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1},
+		{"007", "7", 0},
+		{"12abc", "12", 0},
+		{"x", "999", 1},
+		{"x", "y", 0},
+	} {
+		if c := compareNumeric(tt.a, tt.b); c < 0 && tt.want >= 0 || c > 0 && tt.want <= 0 || c == 0 && tt.want != 0 {
+			t.Errorf("compareNumeric(%q, %q) = %d", tt.a, tt.b, c)
+		}
+	}
+}
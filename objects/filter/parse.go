@@ -0,0 +1,569 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The text form of a filter is a comp-filter, whose conditions are the
+// time range, prop-filters and comp-filters of RFC 4791, joined by
+// "and":
+//
+//	filter      = comp-filter
+//	comp-filter = ["not"] NAME "{" [condition *("and" condition)] "}"
+//	condition   = "in" range / comp-filter / prop-filter
+//	            / "(" prop-filter ")"
+//	prop-filter = "not" NAME ["or" NAME prop-match]
+//	            / NAME prop-match ["or" "not" NAME]
+//	prop-match  = ["[" param-filter *("," param-filter) "]"]
+//	              ["in" range / text-match]
+//	param-filter = "not" NAME / NAME [text-match]
+//	text-match  = ["!"] op value [collation]
+//	op          = "~" / "=" / "^=" / "$=" / "<" / "<=" / ">" / ">="
+//	range       = (time / "*") "/" (time / duration / "*")
+//	time        = date-time / date / ("now" / "today") [("+" / "-") duration]
+//
+// "not" marks is-not-defined; components are told from properties by
+// their braces, so that "not VALARM {}" is a component and "not
+// DESCRIPTION" a property.  The operators are the match types contains,
+// equals, starts-with, ends-with and the orderings, and "!" negates
+// them.  Values are words or strings in double quotes, with backslash
+// escapes; collations are words such as i;octet.  Ordering numbers
+// defaults to i;ascii-numeric, everything else to i;ascii-casemap.  A
+// filter whose outer component is not VCALENDAR is wrapped in one.
+//
+// As in RFC 4791, a prop-filter only matches components that have the
+// property, even when its text-match is negated.  "or" with "not" of the
+// same property also takes the components without it, which sets
+// OrNotDefined:
+//
+//	VEVENT { ATTENDEE[PARTSTAT = NEEDS-ACTION] and in now/P7D }
+//	VTODO { PRIORITY <= 3 and (not STATUS or STATUS != COMPLETED) }
+
+// SyntaxError reports a malformed filter.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: offset %d: %s", e.Offset, e.Msg)
+}
+
+var operators = map[string]string{
+	"~":  Contains,
+	"=":  Equals,
+	"^=": StartsWith,
+	"$=": EndsWith,
+	"<":  Less,
+	"<=": LessOrEqual,
+	">":  Greater,
+	">=": GreaterOrEqual,
+}
+
+// token kinds
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenString
+	tokenPunct
+	tokenOp
+)
+
+type token struct {
+	kind   int
+	text   string
+	offset int
+}
+
+type parser struct {
+	s      string
+	pos    int
+	tok    token
+	now    time.Time
+	peeked bool
+}
+
+// Parse parses the text form of a filter.  Relative times are taken
+// from the current time.
+func Parse(s string) (*CompFilter, error) {
+	return ParseAt(s, time.Now())
+}
+
+// ParseAt is Parse with relative times taken from now.  The local day
+// of now is "today".
+func ParseAt(s string, now time.Time) (f *CompFilter, err error) {
+	p := &parser{s: s, now: now}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			f, err = nil, e
+		}
+	}()
+	f = p.compFilter(p.negation(), p.name())
+	if t := p.next(); t.kind != tokenEOF {
+		p.fail(t, "unexpected %q", t.text)
+	}
+	if f.Name != "VCALENDAR" {
+		f = &CompFilter{Name: "VCALENDAR", Comps: []*CompFilter{f}}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) fail(t token, format string, args ...interface{}) {
+	panic(&SyntaxError{Offset: t.offset, Msg: fmt.Sprintf(format, args...)})
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	if p.peeked {
+		return p.tok
+	}
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	start := p.pos
+	t := token{offset: start}
+	switch {
+	case p.pos == len(p.s):
+		t.kind = tokenEOF
+	case strings.IndexByte("{}[](),/", p.s[p.pos]) >= 0:
+		t.kind, t.text = tokenPunct, p.s[p.pos:p.pos+1]
+		p.pos++
+	case strings.IndexByte("!~=^$<>", p.s[p.pos]) >= 0:
+		if p.s[p.pos] == '!' {
+			p.pos++
+		}
+		op := ""
+		for _, o := range []string{"<=", ">=", "^=", "$=", "~", "=", "<", ">"} {
+			if strings.HasPrefix(p.s[p.pos:], o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			p.fail(t, "invalid operator")
+		}
+		p.pos += len(op)
+		t.kind, t.text = tokenOp, p.s[start:p.pos]
+	case p.s[p.pos] == '"':
+		var b strings.Builder
+		p.pos++
+		for {
+			if p.pos == len(p.s) {
+				p.fail(t, "unterminated string")
+			}
+			c := p.s[p.pos]
+			p.pos++
+			if c == '"' {
+				break
+			}
+			if c == '\\' && p.pos < len(p.s) {
+				c = p.s[p.pos]
+				p.pos++
+			}
+			b.WriteByte(c)
+		}
+		t.kind, t.text = tokenString, b.String()
+	default:
+		for p.pos < len(p.s) && strings.IndexByte(" \t\r\n{}[](),/\"!~=^$<>", p.s[p.pos]) < 0 {
+			p.pos++
+		}
+		t.kind, t.text = tokenWord, p.s[start:p.pos]
+	}
+	p.tok, p.peeked = t, true
+	return t
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.peeked = false
+	return t
+}
+
+// keyword consumes the next token if it is the keyword k.
+func (p *parser) keyword(k string) bool {
+	if t := p.peek(); t.kind == tokenWord && strings.EqualFold(t.text, k) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// punct consumes the next token if it is c.
+func (p *parser) punct(c string) bool {
+	if t := p.peek(); t.kind == tokenPunct && t.text == c {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(c string) {
+	if t := p.next(); t.kind != tokenPunct || t.text != c {
+		p.fail(t, "expected %q", c)
+	}
+}
+
+func (p *parser) negation() bool {
+	return p.keyword("not")
+}
+
+func (p *parser) name() string {
+	t := p.next()
+	if t.kind != tokenWord || t.text == "" {
+		p.fail(t, "expected a name")
+	}
+	for i := 0; i < len(t.text); i++ {
+		if c := t.text[i]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			p.fail(t, "invalid name %q", t.text)
+		}
+	}
+	return strings.ToUpper(t.text)
+}
+
+// compFilter parses the body of a comp-filter after its name.
+func (p *parser) compFilter(notDefined bool, name string) *CompFilter {
+	f := &CompFilter{Name: name, IsNotDefined: notDefined}
+	open := p.peek()
+	p.expect("{")
+	if p.punct("}") {
+		return f
+	}
+	if notDefined {
+		p.fail(open, "conditions of a component that is not defined")
+	}
+	for {
+		p.condition(f)
+		if p.punct("}") {
+			return f
+		}
+		if t := p.peek(); !p.keyword("and") {
+			p.fail(t, "expected \"and\" or \"}\"")
+		}
+	}
+}
+
+func (p *parser) condition(f *CompFilter) {
+	t := p.peek()
+	if p.keyword("in") {
+		if f.TimeRange != nil {
+			p.fail(t, "second time range")
+		}
+		f.TimeRange = p.timeRange()
+		return
+	}
+	if p.punct("(") {
+		f.Props = append(f.Props, p.propFilter(p.negation(), p.name()))
+		p.expect(")")
+		return
+	}
+	notDefined := p.negation()
+	name := p.name()
+	if t := p.peek(); t.kind == tokenPunct && t.text == "{" {
+		f.Comps = append(f.Comps, p.compFilter(notDefined, name))
+		return
+	}
+	f.Props = append(f.Props, p.propFilter(notDefined, name))
+}
+
+// propFilter parses a prop-filter after its name, with the "or" of the
+// property that is not defined.
+func (p *parser) propFilter(notDefined bool, name string) *PropFilter {
+	pf := &PropFilter{Name: name, IsNotDefined: notDefined}
+	if notDefined {
+		if !p.keyword("or") {
+			return pf
+		}
+		if t := p.peek(); p.name() != name {
+			p.fail(t, "expected %s after \"or\"", name)
+		}
+		pf.IsNotDefined = false
+		p.propMatch(pf)
+	} else {
+		p.propMatch(pf)
+		if !p.keyword("or") {
+			return pf
+		}
+		t := p.peek()
+		if !p.negation() || p.name() != name {
+			p.fail(t, "expected \"not %s\" after \"or\"", name)
+		}
+	}
+	pf.OrNotDefined = true
+	return pf
+}
+
+// propMatch parses the param-filters and the match of a prop-filter.
+func (p *parser) propMatch(pf *PropFilter) {
+	if p.punct("[") {
+		for {
+			pf.Params = append(pf.Params, p.paramFilter())
+			if p.punct("]") {
+				break
+			}
+			p.expect(",")
+		}
+	}
+	if p.keyword("in") {
+		pf.TimeRange = p.timeRange()
+	} else if t := p.peek(); t.kind == tokenOp {
+		pf.TextMatch = p.textMatch()
+	}
+}
+
+func (p *parser) paramFilter() *ParamFilter {
+	notDefined := p.negation()
+	f := &ParamFilter{Name: p.name(), IsNotDefined: notDefined}
+	if t := p.peek(); !notDefined && t.kind == tokenOp {
+		f.TextMatch = p.textMatch()
+	}
+	return f
+}
+
+func (p *parser) textMatch() *TextMatch {
+	op := p.next()
+	m := &TextMatch{}
+	text := op.text
+	if strings.HasPrefix(text, "!") {
+		m.Negate, text = true, text[1:]
+	}
+	m.MatchType = operators[text]
+	v := p.next()
+	if v.kind != tokenWord && v.kind != tokenString {
+		p.fail(v, "expected a value")
+	}
+	m.Text = v.text
+	if t := p.peek(); t.kind == tokenWord && strings.HasPrefix(strings.ToLower(t.text), "i;") {
+		m.Collation = strings.ToLower(p.next().text)
+	} else {
+		m.Collation = defaultCollation(m.MatchType, m.Text)
+	}
+	if m.MatchType == Contains {
+		// the default of RFC 4791
+		m.MatchType = ""
+	}
+	return m
+}
+
+// defaultCollation returns the collation of a text match of the text
+// form without one.
+func defaultCollation(matchType, text string) string {
+	switch matchType {
+	case Less, LessOrEqual, Greater, GreaterOrEqual:
+		if _, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return CollationASCIINumeric
+		}
+	}
+	return CollationASCIICasemap
+}
+
+func (p *parser) timeRange() *TimeRange {
+	r := &TimeRange{}
+	t := p.peek()
+	start, _ := p.time(false)
+	p.expect("/")
+	end, d := p.time(true)
+	if d != nil {
+		if start.IsZero() {
+			p.fail(t, "duration after an open start")
+		}
+		end = start.Add(d.Duration())
+	}
+	if start.IsZero() && end.IsZero() {
+		p.fail(t, "time range without start and end")
+	}
+	r.Start, r.End = start, end
+	return r
+}
+
+// time parses a time of a range, or a duration when duration is set.
+// The zero time stands for "*".
+func (p *parser) time(duration bool) (time.Time, *types.Duration) {
+	t := p.next()
+	if t.kind != tokenWord {
+		p.fail(t, "expected a time")
+	}
+	s := t.text
+	if s == "*" {
+		return time.Time{}, nil
+	}
+	if duration && strings.HasPrefix(s, "P") {
+		d, err := types.ParseDuration(s)
+		if err != nil {
+			p.fail(t, "%v", err)
+		}
+		return time.Time{}, d
+	}
+	var base time.Time
+	rest := ""
+	switch lower := strings.ToLower(s); {
+	case strings.HasPrefix(lower, "now"):
+		base, rest = p.now, s[3:]
+	case strings.HasPrefix(lower, "today"):
+		y, m, d := p.now.In(time.Local).Date()
+		base, rest = time.Date(y, m, d, 0, 0, 0, 0, time.Local), s[5:]
+	default:
+		for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+			loc := time.Local
+			if strings.HasSuffix(layout, "Z") {
+				loc = time.UTC
+			}
+			if v, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return v, nil
+			}
+		}
+		p.fail(t, "invalid time %q", s)
+	}
+	if rest == "" {
+		return base, nil
+	}
+	d, err := types.ParseDuration(rest)
+	if err != nil || rest[0] != '+' && rest[0] != '-' {
+		p.fail(t, "invalid time %q", s)
+	}
+	return base.Add(d.Duration()), nil
+}
+
+// String returns f in the text form.  A VCALENDAR filter of a single
+// comp-filter is written as that comp-filter.
+func (f *CompFilter) String() string {
+	if f.Name == "VCALENDAR" && !f.IsNotDefined && f.TimeRange == nil && len(f.Props) == 0 && len(f.Comps) == 1 && f.Comps[0].Name != "VCALENDAR" {
+		f = f.Comps[0]
+	}
+	var b strings.Builder
+	f.write(&b)
+	return b.String()
+}
+
+func (f *CompFilter) write(b *strings.Builder) {
+	if f.IsNotDefined {
+		b.WriteString("not ")
+	}
+	b.WriteString(f.Name)
+	b.WriteString(" {")
+	var conditions []string
+	if f.TimeRange != nil {
+		conditions = append(conditions, "in "+f.TimeRange.String())
+	}
+	for _, p := range f.Props {
+		conditions = append(conditions, p.String())
+	}
+	for _, c := range f.Comps {
+		var s strings.Builder
+		c.write(&s)
+		conditions = append(conditions, s.String())
+	}
+	if len(conditions) > 0 {
+		b.WriteString(" " + strings.Join(conditions, " and ") + " ")
+	}
+	b.WriteString("}")
+}
+
+// String returns f in the text form.
+func (f *PropFilter) String() string {
+	if f.IsNotDefined {
+		return "not " + f.Name
+	}
+	var b strings.Builder
+	if f.OrNotDefined {
+		b.WriteString("(not " + f.Name + " or ")
+	}
+	b.WriteString(f.Name)
+	if len(f.Params) > 0 {
+		var params []string
+		for _, p := range f.Params {
+			params = append(params, p.String())
+		}
+		b.WriteString("[" + strings.Join(params, ", ") + "]")
+	}
+	if f.TimeRange != nil {
+		b.WriteString(" in " + f.TimeRange.String())
+	} else if f.TextMatch != nil {
+		b.WriteString(" " + f.TextMatch.String())
+	}
+	if f.OrNotDefined {
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// String returns f in the text form.
+func (f *ParamFilter) String() string {
+	switch {
+	case f.IsNotDefined:
+		return "not " + f.Name
+	case f.TextMatch != nil:
+		return f.Name + " " + f.TextMatch.String()
+	}
+	return f.Name
+}
+
+// String returns t in the text form: operator, value and collation,
+// where it is not the default.
+func (t *TextMatch) String() string {
+	var b strings.Builder
+	if t.Negate {
+		b.WriteString("!")
+	}
+	matchType := t.MatchType
+	if matchType == "" {
+		matchType = Contains
+	}
+	for op, m := range operators {
+		if m == matchType {
+			b.WriteString(op)
+		}
+	}
+	b.WriteString(" " + quote(t.Text))
+	collation := t.Collation
+	if collation == "" {
+		collation = CollationASCIICasemap
+	}
+	if collation != defaultCollation(matchType, t.Text) {
+		b.WriteString(" " + collation)
+	}
+	return b.String()
+}
+
+// quote returns s as a word when it reads as one, and in double quotes
+// otherwise.
+func quote(s string) string {
+	word := s != "" && !strings.HasPrefix(strings.ToLower(s), "i;")
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c >= 0x7f || strings.IndexByte("{}[](),/\"!~=^$<>\\", c) >= 0 {
+			word = false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "in":
+		word = false
+	}
+	if word {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+const timeFormat = "20060102T150405Z"
+
+// String returns r in the text form, with times in UTC.
+func (r *TimeRange) String() string {
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return "*"
+		}
+		return t.UTC().Format(timeFormat)
+	}
+	return stamp(r.Start) + "/" + stamp(r.End)
+}
@@ -0,0 +1,47 @@
+package objects
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+//   Lines of text SHOULD NOT be longer than 75 octets, excluding the line
+//   break.  Long content lines SHOULD be split into a multiple line
+//   representations using a line "folding" technique.  That is, a long
+//   line can be split between any two characters by inserting a CRLF
+//   immediately followed by a single linear white-space character (i.e.,
+//   SPACE or HTAB).  Any sequence of CRLF followed immediately by a
+//   single linear white-space character is ignored (i.e., removed) when
+//   processing the content type.
+//
+//   Note: It is possible for very simple implementations to generate
+//   improperly folded lines in the middle of a UTF-8 multi-octet
+//   sequence.  For this reason, implementations need to unfold lines in
+//   such a way to properly restore the original sequence.
+
+const foldLength = 75
+
+// Fold turns text written by this package, with "\n" line breaks, into
+// the form RFC 5545 asks for on the wire: lines end in CRLF and are
+// folded after at most 75 octets, never inside a UTF-8 sequence.
+func Fold(s string) string {
+	b := &strings.Builder{}
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		limit := foldLength
+		for len(line) > limit {
+			i := limit
+			for i > 0 && !utf8.RuneStart(line[i]) {
+				i--
+			}
+			b.WriteString(line[:i])
+			b.WriteString("\r\n ")
+			line = line[i:]
+			// the leading space counts on continuation lines
+			limit = foldLength - 1
+		}
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
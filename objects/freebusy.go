@@ -0,0 +1,139 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.2.9.  Free/Busy Time Type
+//
+//      The value FREE indicates that the time interval is free for
+//      scheduling.  The value BUSY indicates that the time interval is
+//      busy because one or more events have been scheduled for that
+//      interval.  The value BUSY-UNAVAILABLE indicates that the time
+//      interval is busy and that the interval can not be scheduled.  The
+//      value BUSY-TENTATIVE indicates that the time interval is busy
+//      because one or more events have been tentatively scheduled for
+//      that interval.
+//
+//   RFC 5545 3.8.2.7.  Time Transparency
+//
+//      Events that consume actual time for the individual or resource
+//      associated with the calendar SHOULD be recorded as OPAQUE,
+//      allowing them to be detected by free/busy time searches.
+
+// BusyPeriod is a period of busy time.
+type BusyPeriod struct {
+	Start time.Time
+	End   time.Time
+	// Type is the FBTYPE of the period, such as BUSY or BUSY-TENTATIVE.
+	Type string
+}
+
+// BusyPeriods returns the busy time of comps within [after, before):
+// the instances of opaque events that are not cancelled, as BUSY or as
+// BUSY-TENTATIVE for tentative ones, and the FREEBUSY periods of
+// VFREEBUSY components other than FREE.  Periods of the same type that
+// overlap or touch are joined, and the result is sorted by start.
+func BusyPeriods(comps []components.Component, after, before time.Time) ([]*BusyPeriod, error) {
+	occ, err := Occurrences(comps, after, before)
+	if err != nil {
+		return nil, err
+	}
+	var periods []*BusyPeriod
+	for _, o := range occ {
+		e, ok := o.Component.(*components.Event)
+		if !ok {
+			continue
+		}
+		if e.Transparent != nil && e.Transparent.Values != nil && strings.EqualFold(e.Transparent.Values.V, datetime.TransparentType) {
+			continue
+		}
+		fbType := parameters.Busy.V
+		if e.Status != nil && e.Status.Value != nil {
+			switch strings.ToUpper(e.Status.Value.V) {
+			case "CANCELLED":
+				continue
+			case "TENTATIVE":
+				fbType = parameters.BusyTentative.V
+			}
+		}
+		periods = append(periods, &BusyPeriod{Start: o.Start, End: o.End, Type: fbType})
+	}
+	for _, c := range comps {
+		if fb, ok := c.(*components.FreeBusy); ok {
+			for _, p := range fb.FreeBusyTime {
+				periods = append(periods, freeBusyPeriods(p)...)
+			}
+		}
+	}
+	return MergeBusyPeriods(periods, after, before), nil
+}
+
+// freeBusyPeriods returns the busy periods of a FREEBUSY property.
+func freeBusyPeriods(p *datetime.FreeBusy) []*BusyPeriod {
+	fbType := parameters.Busy.V
+	for _, param := range p.Parameters {
+		if t, ok := param.(*parameters.FreeBusyType); ok {
+			fbType = t.V
+		}
+	}
+	if fbType == parameters.Free.V {
+		return nil
+	}
+	var periods []*BusyPeriod
+	for _, v := range p.Values {
+		switch t := v.(type) {
+		case *types.ExplicitPeriod:
+			periods = append(periods, &BusyPeriod{Start: t.Start.Time(), End: t.End.Time(), Type: fbType})
+		case *types.StartPeriod:
+			s := t.Start.Time()
+			periods = append(periods, &BusyPeriod{Start: s, End: s.Add(t.Duration.Duration()), Type: fbType})
+		}
+	}
+	return periods
+}
+
+// MergeBusyPeriods clips periods to [after, before) and joins those of
+// the same type that overlap or touch, sorted by start.  The periods are
+// not modified.
+func MergeBusyPeriods(periods []*BusyPeriod, after, before time.Time) []*BusyPeriod {
+	var clipped []*BusyPeriod
+	for _, p := range periods {
+		if !p.End.After(after) || !p.Start.Before(before) || !p.End.After(p.Start) {
+			continue
+		}
+		b := *p
+		if b.Start.Before(after) {
+			b.Start = after
+		}
+		if b.End.After(before) {
+			b.End = before
+		}
+		clipped = append(clipped, &b)
+	}
+	sort.SliceStable(clipped, func(i, j int) bool {
+		if !clipped[i].Start.Equal(clipped[j].Start) {
+			return clipped[i].Start.Before(clipped[j].Start)
+		}
+		return clipped[i].Type < clipped[j].Type
+	})
+	var merged []*BusyPeriod
+	last := map[string]*BusyPeriod{}
+	for _, p := range clipped {
+		if l, ok := last[p.Type]; ok && !p.Start.After(l.End) {
+			if p.End.After(l.End) {
+				l.End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+		last[p.Type] = p
+	}
+	return merged
+}
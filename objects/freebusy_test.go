@@ -0,0 +1,76 @@
+package objects
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBusyPeriods(t *testing.T) {
+	in := `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:daily
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T090000Z
+DTEND:20240304T100000Z
+RRULE:FREQ=DAILY;COUNT=2
+END:VEVENT
+BEGIN:VEVENT
+UID:adjacent
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T100000Z
+DURATION:PT30M
+END:VEVENT
+BEGIN:VEVENT
+UID:tentative
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T093000Z
+DURATION:PT1H
+STATUS:TENTATIVE
+END:VEVENT
+BEGIN:VEVENT
+UID:free
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T120000Z
+DURATION:PT1H
+TRANSP:TRANSPARENT
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T130000Z
+DURATION:PT1H
+STATUS:CANCELLED
+END:VEVENT
+BEGIN:VFREEBUSY
+UID:fb
+DTSTAMP:20240101T000000Z
+FREEBUSY;FBTYPE=BUSY-UNAVAILABLE:20240305T150000Z/PT2H
+FREEBUSY;FBTYPE=FREE:20240305T170000Z/PT1H
+END:VFREEBUSY
+END:VCALENDAR
+`
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	periods, err := BusyPeriods(c.Components, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 16, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range periods {
+		got = append(got, p.Start.UTC().Format("02T15:04")+"/"+p.End.UTC().Format("02T15:04")+" "+p.Type)
+	}
+	want := []string{
+		"04T09:00/04T10:30 BUSY",
+		"04T09:30/04T10:30 BUSY-TENTATIVE",
+		"05T09:00/05T10:00 BUSY",
+		"05T15:00/05T16:00 BUSY-UNAVAILABLE",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("BusyPeriods:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -1,12 +1,22 @@
 package objects
 
 import (
+	"fmt"
 	"github.com/mmsuo/vcalender/objects/property"
 	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties"
 	"github.com/mmsuo/vcalender/objects/property/components/properties/miscellaneous"
+	"github.com/mmsuo/vcalender/objects/property/types"
 	"strings"
+	"time"
 )
 
+//   RFC 5545 3.6.5.  Time Zone Component
+//
+//      An individual "VTIMEZONE" calendar component MUST be specified for
+//      each unique "TZID" parameter value specified in the iCalendar
+//      object.
+
 type Calendar struct {
 	ProdId     *property.ProductIdentifier
 	Version    *property.Version
@@ -35,3 +45,83 @@ func (c *Calendar) Calendar() (string, error) {
 	s.WriteString("END:VCALENDAR\n")
 	return s.String(), nil
 }
+
+// Clone returns a deep copy of c, with copies of its components.
+func (c *Calendar) Clone() *Calendar {
+	return properties.DeepCopy(c).(*Calendar)
+}
+
+// AddTimeZones adds a VTIMEZONE for each TZID that the components of c
+// use without c having one.  They are made by components.NewTimeZone
+// from the time zone database, starting at the earliest time given in
+// each zone, and go before the other components.
+func (c *Calendar) AddTimeZones() error {
+	have := map[string]bool{}
+	for _, comp := range c.Components {
+		if tz, ok := comp.(*components.TimeZone); ok && tz.TzId != nil && tz.TzId.Value != nil {
+			have[tz.TzId.Value.V] = true
+		}
+	}
+	var order []string
+	locs := map[string]*time.Location{}
+	from := map[string]time.Time{}
+	var visit func(raw *RawComponent) error
+	visit = func(raw *RawComponent) error {
+		for _, l := range raw.Properties {
+			tzid, ok := l.Param("TZID")
+			if !ok || have[tzid] {
+				continue
+			}
+			loc, ok := locs[tzid]
+			if !ok {
+				var err error
+				if loc, err = time.LoadLocation(tzid); err != nil {
+					return fmt.Errorf("objects: no VTIMEZONE for TZID %s: %v", tzid, err)
+				}
+				locs[tzid] = loc
+				order = append(order, tzid)
+			}
+			for _, v := range SplitList(l.Value) {
+				if slash := strings.IndexByte(v, '/'); slash >= 0 {
+					v = v[:slash]
+				}
+				if dt, err := types.ParseDateTime(v, loc); err == nil {
+					if t, ok := from[tzid]; !ok || dt.V.Before(t) {
+						from[tzid] = dt.V
+					}
+				}
+			}
+		}
+		for _, sub := range raw.Components {
+			if err := visit(sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, comp := range c.Components {
+		if _, ok := comp.(*components.TimeZone); ok {
+			continue
+		}
+		raw, err := rawOf(comp)
+		if err != nil {
+			return err
+		}
+		if err := visit(raw); err != nil {
+			return err
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+	zones := make([]components.Component, 0, len(order)+len(c.Components))
+	for _, tzid := range order {
+		t, ok := from[tzid]
+		if !ok {
+			t = time.Now()
+		}
+		zones = append(zones, components.NewTimeZone(locs[tzid], t))
+	}
+	c.Components = append(zones, c.Components...)
+	return nil
+}
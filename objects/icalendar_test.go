@@ -8,7 +8,11 @@ import (
 	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
 	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
 	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCalendar_Calendar(t *testing.T) {
@@ -59,3 +63,57 @@ func BenchmarkCalendar_Calendar(b *testing.B) {
 		_, _ = c.Calendar()
 	}
 }
+
+func TestCalendar_AddTimeZones(t *testing.T) {
+	in := "BEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"PRODID:-//test//EN\n" +
+		"BEGIN:VTIMEZONE\n" +
+		"TZID:Custom\n" +
+		"BEGIN:STANDARD\n" +
+		"DTSTART:19700101T000000\n" +
+		"TZOFFSETFROM:+0300\n" +
+		"TZOFFSETTO:+0300\n" +
+		"END:STANDARD\n" +
+		"END:VTIMEZONE\n" +
+		"BEGIN:VEVENT\n" +
+		"UID:1\n" +
+		"DTSTAMP:20240101T000000Z\n" +
+		"DTSTART;TZID=Europe/Berlin:20240610T093000\n" +
+		"DTEND;TZID=America/New_York:20240610T100000\n" +
+		"EXDATE;TZID=Custom:20240617T093000\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddTimeZones(); err != nil {
+		t.Fatal(err)
+	}
+	var tzids []string
+	for _, comp := range c.Components {
+		if tz, ok := comp.(*components.TimeZone); ok {
+			tzids = append(tzids, tz.TzId.Value.V)
+		}
+	}
+	if strings.Join(tzids, " ") != "Europe/Berlin America/New_York Custom" {
+		t.Errorf("VTIMEZONEs: %v", tzids)
+	}
+	// the calendar decodes to the same times with the added zones only
+	s, _ := c.Calendar()
+	d, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, s)
+	}
+	if !c.Equal(d) {
+		t.Errorf("the calendar changed:\n%s", s)
+	}
+
+	c.Components = append(c.Components, &components.Event{
+		DtStart: &datetime.DateStart{Parameters: []parameters.Parameter{&parameters.TimeZoneId{V: "Mars/Olympus"}}, Value: &types.DateTime{V: time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC), Format: types.LocalDateTimeFormat}},
+	})
+	if err := c.AddTimeZones(); err == nil {
+		t.Errorf("AddTimeZones of an unknown TZID succeeded")
+	}
+}
@@ -0,0 +1,345 @@
+// Package jcal converts between iCalendar objects and jCal, their JSON
+// form defined by RFC 7265.
+//
+// Both directions work on objects.RawComponent, so that components and
+// properties unknown to the typed model are converted as well.
+package jcal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//   RFC 7265 3.  Converting from iCalendar to jCal
+//
+//   3.3.  Components
+//
+//      Each calendar component is represented by a fixed-length array with
+//      three elements: the name of the component in lowercase, an array
+//      of jCal properties and an array of jCal subcomponents.
+//
+//       ["vcalendar", [ /* properties */ ], [ /* components */ ]]
+//
+//   3.4.  Properties
+//
+//      Each property is represented by an array with at least four
+//      elements: the name in lowercase, an object of the parameters, the
+//      value type in lowercase and one or more values.
+//
+//       ["dtstart", { "tzid": "Europe/Berlin" }, "date-time",
+//        "2013-02-14T12:30:00"]
+//
+//      Properties with multiple values, such as CATEGORIES, list them as
+//      further elements.  Structured values, such as GEO and
+//      REQUEST-STATUS, are arrays.  Properties of unknown type use the
+//      type "unknown" and keep their iCalendar value as a string.
+
+// recurParts is the order in which rule parts are written back.
+var recurParts = []string{"freq", "rscale", "skip", "until", "count", "interval",
+	"bysecond", "byminute", "byhour", "byday", "bymonthday", "byyearday",
+	"byweekno", "bymonth", "bysetpos", "wkst"}
+
+// Encode returns the jCal form of c.
+func Encode(c *objects.RawComponent) ([]byte, error) {
+	v, err := component(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func component(c *objects.RawComponent) ([]interface{}, error) {
+	props := make([]interface{}, 0, len(c.Properties))
+	for _, l := range c.Properties {
+		p, err := property(l)
+		if err != nil {
+			return nil, &objects.SyntaxError{Line: l.Line, Msg: fmt.Sprintf("%s: %v", l.Name, err)}
+		}
+		props = append(props, p)
+	}
+	subs := make([]interface{}, 0, len(c.Components))
+	for _, sub := range c.Components {
+		s, err := component(sub)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return []interface{}{strings.ToLower(c.Name), props, subs}, nil
+}
+
+func property(l *objects.ContentLine) ([]interface{}, error) {
+	params := map[string]interface{}{}
+	for _, p := range l.Params {
+		if p.Name == "VALUE" {
+			continue
+		}
+		switch len(p.Values) {
+		case 0:
+			params[strings.ToLower(p.Name)] = ""
+		case 1:
+			params[strings.ToLower(p.Name)] = p.Values[0]
+		default:
+			params[strings.ToLower(p.Name)] = p.Values
+		}
+	}
+	vt := objects.ValueType(l)
+	if vt == "" {
+		vt = "UNKNOWN"
+	}
+	p := []interface{}{strings.ToLower(l.Name), params, strings.ToLower(vt)}
+	switch l.Name {
+	case "GEO":
+		parts := strings.Split(l.Value, ";")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid GEO %q", l.Value)
+		}
+		return append(p, []interface{}{json.Number(parts[0]), json.Number(parts[1])}), nil
+	case "REQUEST-STATUS":
+		var parts []interface{}
+		for _, s := range objects.SplitEscaped(l.Value, ';', 3) {
+			parts = append(parts, types.UnescapeText(s))
+		}
+		return append(p, parts), nil
+	}
+	items := []string{l.Value}
+	if objects.IsList(l.Name) {
+		items = objects.SplitList(l.Value)
+	}
+	for _, s := range items {
+		v, err := value(vt, s)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, v)
+	}
+	return p, nil
+}
+
+func value(vt, s string) (interface{}, error) {
+	switch vt {
+	case "TEXT":
+		return types.UnescapeText(s), nil
+	case "INTEGER":
+		if _, err := strconv.Atoi(s); err != nil {
+			return nil, fmt.Errorf("invalid INTEGER %q", s)
+		}
+		return json.Number(s), nil
+	case "FLOAT":
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("invalid FLOAT %q", s)
+		}
+		return json.Number(s), nil
+	case "BOOLEAN":
+		return strings.EqualFold(s, "TRUE"), nil
+	case "RECUR":
+		return recur(s)
+	}
+	return objects.ExtendedValue(vt, s), nil
+}
+
+// recur returns the object form of a recurrence rule.  Rule parts with
+// several values are arrays, numbers are numbers.
+func recur(s string) (map[string]interface{}, error) {
+	r := map[string]interface{}{}
+	for _, part := range strings.Split(s, ";") {
+		eq := strings.IndexByte(part, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		name, v := strings.ToLower(part[:eq]), part[eq+1:]
+		var values []interface{}
+		for _, item := range strings.Split(v, ",") {
+			switch name {
+			case "until":
+				values = append(values, objects.ExtendedValue("DATE-OR-DATE-TIME", item))
+			case "count", "interval", "bysecond", "byminute", "byhour", "bymonthday",
+				"byyearday", "byweekno", "bysetpos", "bymonth":
+				if _, err := strconv.Atoi(item); err == nil {
+					values = append(values, json.Number(strings.TrimPrefix(item, "+")))
+					continue
+				}
+				values = append(values, item)
+			default:
+				values = append(values, item)
+			}
+		}
+		if len(values) == 1 {
+			r[name] = values[0]
+		} else {
+			r[name] = values
+		}
+	}
+	return r, nil
+}
+
+// Decode reads a jCal object.
+func Decode(data []byte) (*objects.RawComponent, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return decodeComponent(v)
+}
+
+func decodeComponent(v interface{}) (*objects.RawComponent, error) {
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 3 {
+		return nil, fmt.Errorf("jcal: a component must be an array of three elements")
+	}
+	name, ok := a[0].(string)
+	props, ok2 := a[1].([]interface{})
+	subs, ok3 := a[2].([]interface{})
+	if !ok || !ok2 || !ok3 {
+		return nil, fmt.Errorf("jcal: a component must be [name, properties, components]")
+	}
+	c := &objects.RawComponent{Name: strings.ToUpper(name)}
+	for _, p := range props {
+		l, err := decodeProperty(p)
+		if err != nil {
+			return nil, fmt.Errorf("jcal: %s: %v", c.Name, err)
+		}
+		c.Properties = append(c.Properties, l)
+	}
+	for _, s := range subs {
+		sub, err := decodeComponent(s)
+		if err != nil {
+			return nil, err
+		}
+		c.Components = append(c.Components, sub)
+	}
+	return c, nil
+}
+
+func decodeProperty(v interface{}) (*objects.ContentLine, error) {
+	a, ok := v.([]interface{})
+	if !ok || len(a) < 4 {
+		return nil, fmt.Errorf("a property must be an array of at least four elements")
+	}
+	name, ok := a[0].(string)
+	params, ok2 := a[1].(map[string]interface{})
+	vt, ok3 := a[2].(string)
+	if !ok || !ok2 || !ok3 {
+		return nil, fmt.Errorf("a property must be [name, parameters, type, values...]")
+	}
+	l := &objects.ContentLine{Name: strings.ToUpper(name)}
+	for _, k := range sortedKeys(params) {
+		p := &objects.Param{Name: strings.ToUpper(k)}
+		switch pv := params[k].(type) {
+		case string:
+			p.Values = []string{pv}
+		case []interface{}:
+			for _, x := range pv {
+				p.Values = append(p.Values, fmt.Sprint(x))
+			}
+		default:
+			p.Values = []string{fmt.Sprint(pv)}
+		}
+		l.Params = append(l.Params, p)
+	}
+	vt = strings.ToUpper(vt)
+	if vt != "UNKNOWN" && vt != objects.DefaultValueType(l.Name) {
+		l.Params = append(l.Params, &objects.Param{Name: "VALUE", Values: []string{vt}})
+	}
+	values := make([]string, 0, len(a)-3)
+	for _, x := range a[3:] {
+		s, err := decodeValue(l.Name, vt, x)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", l.Name, err)
+		}
+		values = append(values, s)
+	}
+	l.Value = strings.Join(values, ",")
+	return l, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// objects have no order in JSON; a fixed one keeps output stable
+	sort.Strings(keys)
+	return keys
+}
+
+func decodeValue(name, vt string, v interface{}) (string, error) {
+	switch x := v.(type) {
+	case []interface{}:
+		// structured values such as GEO and REQUEST-STATUS
+		parts := make([]string, 0, len(x))
+		for _, p := range x {
+			s, err := decodeValue(name, vt, p)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ";"), nil
+	case map[string]interface{}:
+		if vt != "RECUR" {
+			return "", fmt.Errorf("object value for type %s", vt)
+		}
+		return decodeRecur(x), nil
+	case bool:
+		if x {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case json.Number:
+		return x.String(), nil
+	case string:
+		switch vt {
+		case "TEXT":
+			return types.EscapeText(x), nil
+		case "UNKNOWN":
+			return x, nil
+		}
+		return objects.BasicValue(vt, x), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("unexpected value %v", v)
+}
+
+func decodeRecur(r map[string]interface{}) string {
+	var parts []string
+	add := func(name string, v interface{}) {
+		var items []string
+		switch x := v.(type) {
+		case []interface{}:
+			for _, i := range x {
+				items = append(items, fmt.Sprint(i))
+			}
+		default:
+			items = []string{fmt.Sprint(x)}
+		}
+		if name == "until" {
+			for i := range items {
+				items[i] = objects.BasicValue("DATE-OR-DATE-TIME", items[i])
+			}
+		}
+		parts = append(parts, strings.ToUpper(name)+"="+strings.Join(items, ","))
+	}
+	done := map[string]bool{}
+	for _, name := range recurParts {
+		if v, ok := r[name]; ok {
+			add(name, v)
+			done[name] = true
+		}
+	}
+	for _, name := range sortedKeys(r) {
+		if !done[name] {
+			add(name, r[name])
+		}
+	}
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,72 @@
+package jcal
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"strings"
+	"testing"
+)
+
+const ics = `BEGIN:VCALENDAR
+CALSCALE:GREGORIAN
+PRODID:-//Example Inc.//Example Calendar//EN
+VERSION:2.0
+BEGIN:VEVENT
+DTSTAMP:20080205T191224Z
+DTSTART;VALUE=DATE:20081006
+SUMMARY:Planning meeting\, room 2
+UID:4088E990AD89CB3DBB484909
+CATEGORIES:WORK,PROJECT\, X
+GEO:37.386013;-122.082932
+RRULE:FREQ=MONTHLY;UNTIL=20081231T000000Z;BYDAY=-1FR;BYMONTH=1,4
+X-LIC-ERROR;X-A=1,2:Oops
+ATTENDEE;DELEGATED-FROM="mailto:a@example.com":mailto:b@example.com
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestEncode(t *testing.T) {
+	raw, err := objects.ReadRawComponents(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(raw[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`["vcalendar",[["calscale",{},"text","GREGORIAN"]`,
+		`["dtstart",{},"date","2008-10-06"]`,
+		`["dtstamp",{},"date-time","2008-02-05T19:12:24Z"]`,
+		`["summary",{},"text","Planning meeting, room 2"]`,
+		`["categories",{},"text","WORK","PROJECT, X"]`,
+		`["geo",{},"float",[37.386013,-122.082932]]`,
+		`["rrule",{},"recur",{"byday":"-1FR","bymonth":[1,4],"freq":"MONTHLY","until":"2008-12-31T00:00:00Z"}]`,
+		`["x-lic-error",{"x-a":["1","2"]},"unknown","Oops"]`,
+		`["attendee",{"delegated-from":"mailto:a@example.com"},"cal-address","mailto:b@example.com"]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in\n%s", want, got)
+		}
+	}
+
+	back, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != raw[0].String() {
+		t.Errorf("round trip:\n got: %s\nwant: %s", back.String(), raw[0].String())
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	for _, in := range []string{
+		`{"vcalendar": []}`,
+		`["vcalendar", [["summary", {}, "text"]], []]`,
+		`["vcalendar", [], [["vevent", []]]]`,
+	} {
+		if _, err := Decode([]byte(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
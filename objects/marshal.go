@@ -0,0 +1,399 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshal and Unmarshal map the fields of structs to the properties of a
+// VEVENT by their "ical" tags.  A tag names the property and may add
+// options after commas:
+//
+//	Summary  string        `ical:"SUMMARY"`
+//	Start    time.Time     `ical:"DTSTART,tzid=Europe/Berlin"`
+//	Day      time.Time     `ical:"DTSTART,date"`
+//	Length   time.Duration `ical:"DURATION"`
+//	Tags     []string      `ical:"CATEGORIES"`
+//	Notes    string        `ical:"DESCRIPTION,language=en"`
+//	Internal string        `ical:"-"`
+//
+// The tzid option writes times as local times of that zone, and date
+// writes them as DATE values.  Any other option is written as a
+// parameter of the property.  Exported fields without a tag are kept in
+// X-properties named after the field, so that Field becomes X-FIELD.
+//
+// Fields may be strings, integers, floats and booleans, time.Time,
+// time.Duration, url.URL, mail.Address, pointers to them and slices of
+// them.  Strings are written as TEXT values where the property takes
+// text and as written otherwise, mail addresses as CAL-ADDRESS values
+// with a CN parameter for the name.  Slices of list properties such as
+// CATEGORIES are written as one property, other slices as one property
+// per element.  Zero values are left out.
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	urlType      = reflect.TypeOf(url.URL{})
+	addressType  = reflect.TypeOf(mail.Address{})
+)
+
+// tag is the parsed ical tag of a field.
+type tag struct {
+	name   string
+	tzid   string
+	date   bool
+	params []*Param
+}
+
+// fieldTag returns the tag of the field f, and false for fields that
+// are not marshaled.
+func fieldTag(f reflect.StructField) (*tag, bool) {
+	if f.PkgPath != "" {
+		return nil, false
+	}
+	s, ok := f.Tag.Lookup("ical")
+	if s == "-" {
+		return nil, false
+	}
+	if !ok || s == "" {
+		return &tag{name: "X-" + strings.ToUpper(f.Name)}, true
+	}
+	parts := strings.Split(s, ",")
+	t := &tag{name: strings.ToUpper(strings.TrimSpace(parts[0]))}
+	for _, opt := range parts[1:] {
+		opt = strings.TrimSpace(opt)
+		i := strings.Index(opt, "=")
+		switch {
+		case strings.EqualFold(opt, "date"):
+			t.date = true
+		case i > 0 && strings.EqualFold(opt[:i], "tzid"):
+			t.tzid = opt[i+1:]
+		case i > 0:
+			t.params = append(t.params, &Param{Name: strings.ToUpper(opt[:i]), Values: []string{opt[i+1:]}})
+		}
+	}
+	return t, true
+}
+
+// Marshal returns the VEVENT described by the ical tags of v, which is a
+// struct or a pointer to one.  When v sets no DTSTAMP it is set to the
+// current time.  The event must be valid as Decode defines it.
+func Marshal(v interface{}) (*components.Event, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("objects: Marshal of %T, not a struct", v)
+	}
+	comp := &RawComponent{Name: "VEVENT"}
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		t, ok := fieldTag(f)
+		if !ok {
+			continue
+		}
+		lines, err := t.lines(rv.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("objects: Marshal of field %s: %v", f.Name, err)
+		}
+		comp.Properties = append(comp.Properties, lines...)
+	}
+	if comp.Property("DTSTAMP") == nil {
+		comp.Properties = append(comp.Properties, &ContentLine{Name: "DTSTAMP", Value: time.Now().UTC().Format(types.UTCDateTimeFormat)})
+	}
+	cal, err := DecodeRaw(&RawComponent{
+		Name: "VCALENDAR",
+		Properties: []*ContentLine{
+			{Name: "VERSION", Value: "2.0"},
+			{Name: "PRODID", Value: "-//vcalender//marshal//EN"},
+		},
+		Components: []*RawComponent{comp},
+	})
+	if errs, ok := err.(ErrorList); ok {
+		msgs := make([]string, 0, len(errs))
+		for _, e := range errs {
+			msgs = append(msgs, e.Msg)
+		}
+		return nil, fmt.Errorf("objects: Marshal: %s", strings.Join(msgs, "; "))
+	} else if err != nil {
+		return nil, err
+	}
+	return cal.Components[0].(*components.Event), nil
+}
+
+// lines returns the content lines of the field value v.
+func (t *tag) lines(v reflect.Value) ([]*ContentLine, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	var values []reflect.Value
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			e := v.Index(i)
+			for e.Kind() == reflect.Ptr && !e.IsNil() {
+				e = e.Elem()
+			}
+			if e.Kind() != reflect.Ptr && !isZero(e) {
+				values = append(values, e)
+			}
+		}
+	} else if !isZero(v) {
+		values = append(values, v)
+	}
+	var lines []*ContentLine
+	for _, e := range values {
+		l, err := t.line(e)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) > 0 && IsList(t.name) && sameParams(lines[len(lines)-1], l) {
+			last := lines[len(lines)-1]
+			last.Value += "," + l.Value
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+func isZero(v reflect.Value) bool {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func sameParams(a, b *ContentLine) bool {
+	x, y := *a, *b
+	x.Value, y.Value = "", ""
+	return x.String() == y.String()
+}
+
+// line returns the content line of a single value.
+func (t *tag) line(v reflect.Value) (*ContentLine, error) {
+	l := &ContentLine{Name: t.name}
+	for _, p := range t.params {
+		l.Params = append(l.Params, &Param{Name: p.Name, Values: p.Values})
+	}
+	// the value type of the Go type, written as VALUE when the property
+	// defaults to another one; strings take the default
+	vt := ""
+	switch v.Type() {
+	case timeType:
+		tm := v.Interface().(time.Time)
+		switch {
+		case t.date:
+			vt, l.Value = "DATE", tm.Format("20060102")
+		case t.tzid != "":
+			loc, err := time.LoadLocation(t.tzid)
+			if err != nil {
+				return nil, err
+			}
+			l.Params = append(l.Params, &Param{Name: "TZID", Values: []string{t.tzid}})
+			vt, l.Value = "DATE-TIME", tm.In(loc).Format(types.LocalDateTimeFormat)
+		case tm.Location() == time.UTC || tm.Location() == time.Local:
+			vt, l.Value = "DATE-TIME", tm.UTC().Format(types.UTCDateTimeFormat)
+		default:
+			l.Params = append(l.Params, &Param{Name: "TZID", Values: []string{tm.Location().String()}})
+			vt, l.Value = "DATE-TIME", tm.Format(types.LocalDateTimeFormat)
+		}
+	case durationType:
+		s := &strings.Builder{}
+		types.NewDuration(v.Interface().(time.Duration)).WriteValueToStrBuilder(s)
+		vt, l.Value = "DURATION", s.String()
+	case urlType:
+		u := v.Interface().(url.URL)
+		vt, l.Value = "URI", u.String()
+	case addressType:
+		a := v.Interface().(mail.Address)
+		if a.Name != "" {
+			l.Params = append(l.Params, &Param{Name: "CN", Values: []string{a.Name}})
+		}
+		vt, l.Value = "CAL-ADDRESS", "mailto:"+a.Address
+	default:
+		switch v.Kind() {
+		case reflect.String:
+			l.Value = v.String()
+			if dt := DefaultValueType(t.name); dt == "" || dt == "TEXT" {
+				l.Value = types.EscapeText(l.Value)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			vt, l.Value = "INTEGER", strconv.FormatInt(v.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			vt, l.Value = "INTEGER", strconv.FormatUint(v.Uint(), 10)
+		case reflect.Float32, reflect.Float64:
+			vt, l.Value = "FLOAT", strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		case reflect.Bool:
+			vt, l.Value = "BOOLEAN", strings.ToUpper(strconv.FormatBool(v.Bool()))
+		default:
+			return nil, fmt.Errorf("unsupported type %s", v.Type())
+		}
+	}
+	if vt != "" && vt != DefaultValueType(t.name) {
+		l.Params = append(l.Params, &Param{Name: "VALUE", Values: []string{vt}})
+	}
+	return l, nil
+}
+
+// Unmarshal stores the properties of e in the fields of the struct v
+// points to, by the ical tags of its fields as Marshal writes them.
+// Fields of properties that e does not have are left as they are.
+func Unmarshal(e *components.Event, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("objects: Unmarshal into %T, not a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	s := &strings.Builder{}
+	if err := e.WriteComponentToStrBuilder(s); err != nil {
+		return err
+	}
+	raw, err := ReadRawComponents(strings.NewReader(s.String()))
+	if err != nil {
+		return err
+	}
+	comp := raw[0]
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		t, ok := fieldTag(f)
+		if !ok {
+			continue
+		}
+		var lines []*ContentLine
+		for _, l := range comp.Properties {
+			if l.Name == t.name {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if err := t.set(rv.Field(i), lines); err != nil {
+			return fmt.Errorf("objects: Unmarshal of %s into field %s: %v", t.name, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// set stores the values of lines in the field value v.
+func (t *tag) set(v reflect.Value, lines []*ContentLine) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(v.Type(), 0, len(lines))
+		for _, l := range lines {
+			items := []string{l.Value}
+			if IsList(l.Name) {
+				items = SplitList(l.Value)
+			}
+			for _, item := range items {
+				e := reflect.New(v.Type().Elem()).Elem()
+				if err := setValue(e, l, item); err != nil {
+					return err
+				}
+				s = reflect.Append(s, e)
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, lines[0], lines[0].Value)
+}
+
+// setValue stores the value s of the content line l in v.
+func setValue(v reflect.Value, l *ContentLine, s string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), l, s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	switch v.Type() {
+	case timeType:
+		if ValueType(l) == "DATE" {
+			d, err := types.ParseDate(s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(d.Time()))
+			return nil
+		}
+		var loc *time.Location
+		if tzid, ok := l.Param("TZID"); ok {
+			var err error
+			if loc, err = time.LoadLocation(tzid); err != nil {
+				return err
+			}
+		}
+		dt, err := types.ParseDateTime(s, loc)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(dt.Time()))
+	case durationType:
+		d, err := types.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(d.Duration()))
+	case urlType:
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+	case addressType:
+		a := mail.Address{Address: s}
+		if len(s) >= 7 && strings.EqualFold(s[:7], "mailto:") {
+			a.Address = s[7:]
+		}
+		a.Name, _ = l.Param("CN")
+		v.Set(reflect.ValueOf(a))
+	default:
+		switch v.Kind() {
+		case reflect.String:
+			if vt := ValueType(l); vt == "TEXT" || vt == "" {
+				s = types.UnescapeText(s)
+			}
+			v.SetString(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+			if err != nil {
+				return err
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+			if err != nil {
+				return err
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(s, v.Type().Bits())
+			if err != nil {
+				return err
+			}
+			v.SetFloat(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(strings.ToLower(s))
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+		default:
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package objects
+
+import (
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type meeting struct {
+	ID        string          `ical:"UID"`
+	Stamp     time.Time       `ical:"DTSTAMP"`
+	Title     string          `ical:"SUMMARY"`
+	Notes     string          `ical:"DESCRIPTION,language=en"`
+	Start     time.Time       `ical:"DTSTART,tzid=Europe/Berlin"`
+	Length    time.Duration   `ical:"DURATION"`
+	Tags      []string        `ical:"CATEGORIES"`
+	Link      *url.URL        `ical:"URL"`
+	Host      mail.Address    `ical:"ORGANIZER"`
+	Guests    []*mail.Address `ical:"ATTENDEE"`
+	Revision  int             `ical:"SEQUENCE"`
+	Room      string
+	Confirmed bool
+	internal  string
+	Secret    string `ical:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	link, _ := url.Parse("https://example.com/planning")
+	m := &meeting{
+		ID:        "planning@example.com",
+		Stamp:     time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC),
+		Title:     "Planning; Q3, budget",
+		Notes:     "Bring the numbers\nand the slides",
+		Start:     time.Date(2024, 6, 10, 7, 30, 0, 0, time.UTC),
+		Length:    90 * time.Minute,
+		Tags:      []string{"work", "finance,budget"},
+		Link:      link,
+		Host:      mail.Address{Name: "Alice", Address: "alice@example.com"},
+		Guests:    []*mail.Address{{Address: "bob@example.com"}, {Name: "Carol", Address: "carol@example.com"}},
+		Revision:  2,
+		Room:      "4.01",
+		Confirmed: true,
+		internal:  "x",
+		Secret:    "y",
+	}
+	e, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &strings.Builder{}
+	if err := e.WriteComponentToStrBuilder(s); err != nil {
+		t.Fatal(err)
+	}
+	out := s.String()
+	for _, want := range []string{
+		"UID:planning@example.com\n",
+		"DTSTAMP:20240601T080000Z\n",
+		"SUMMARY:Planning\\; Q3\\, budget\n",
+		"DESCRIPTION;LANGUAGE=en:Bring the numbers\\nand the slides\n",
+		"DTSTART;TZID=Europe/Berlin:20240610T093000\n",
+		"DURATION:PT1H30M\n",
+		"CATEGORIES:work,finance\\,budget\n",
+		"URL:https://example.com/planning\n",
+		"ORGANIZER;CN=\"Alice\":mailto:alice@example.com\n",
+		"ATTENDEE:mailto:bob@example.com\n",
+		"ATTENDEE;CN=\"Carol\":mailto:carol@example.com\n",
+		"SEQUENCE:2\n",
+		"X-ROOM:4.01\n",
+		"X-CONFIRMED;VALUE=BOOLEAN:TRUE\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Marshal output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "X-INTERNAL") || strings.Contains(out, "X-SECRET") {
+		t.Errorf("Marshal wrote skipped fields:\n%s", out)
+	}
+
+	var got meeting
+	if err := Unmarshal(e, &got); err != nil {
+		t.Fatal(err)
+	}
+	m.internal, m.Secret = "", ""
+	if !got.Start.Equal(m.Start) || got.Start.Location().String() != berlin.String() {
+		t.Errorf("Start: %v", got.Start)
+	}
+	got.Start = m.Start
+	if !reflect.DeepEqual(&got, m) {
+		t.Errorf("Unmarshal:\n got %+v\nwant %+v", got, *m)
+	}
+
+	// a missing UID is reported, a missing DTSTAMP is set
+	if _, err := Marshal(struct {
+		Start time.Time `ical:"DTSTART"`
+	}{time.Now()}); err == nil || !strings.Contains(err.Error(), "without UID") {
+		t.Errorf("Marshal without UID: %v", err)
+	}
+	if _, err := Marshal(42); err == nil {
+		t.Errorf("Marshal of an int succeeded")
+	}
+	if err := Unmarshal(e, got); err == nil {
+		t.Errorf("Unmarshal into a struct value succeeded")
+	}
+}
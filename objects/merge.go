@@ -0,0 +1,432 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/changemanage"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Conflict is a property that local and remote changed in different
+// ways, and that SEQUENCE and LAST-MODIFIED could not decide.  Base,
+// Local and Remote are the property, value or alarm on each side, nil
+// where it is missing.
+type Conflict struct {
+	Name   string
+	Key    string
+	Base   interface{}
+	Local  interface{}
+	Remote interface{}
+}
+
+func (c *Conflict) String() string {
+	if c.Key == "" {
+		return c.Name + " conflicts"
+	}
+	return c.Name + " " + c.Key + " conflicts"
+}
+
+var (
+	dtStampType      = reflect.TypeOf((*changemanage.DtStamp)(nil))
+	lastModifiedType = reflect.TypeOf((*changemanage.LastModified)(nil))
+	sequenceType     = reflect.TypeOf((*changemanage.Sequence)(nil))
+	alarmsType       = reflect.TypeOf([]*components.Alarm(nil))
+	propertyType     = reflect.TypeOf((*properties.Property)(nil)).Elem()
+)
+
+// Merge merges the changes that local and remote made to base, which is
+// their last common version, property by property.  A property changed
+// on one side only takes that change; repeated properties are merged
+// item by item, ATTENDEEs by address, the values of CATEGORIES, RDATE
+// and EXDATE one by one and other properties by value.  Alarms are
+// matched by their UID (RFC 9074), or else by ACTION and position, and
+// an alarm both sides changed is merged property by property.  When
+// both sides changed a property differently, the side with the higher
+// SEQUENCE wins, then the side with the later LAST-MODIFIED; when they
+// are the same too, the local property is kept and the conflict is
+// returned.  The merged event has the higher SEQUENCE and the later
+// DTSTAMP and LAST-MODIFIED of the two.  A nil base merges two versions
+// without a common ancestor.
+func Merge(base, local, remote *components.Event) (*components.Event, []*Conflict, error) {
+	if base == nil {
+		base = &components.Event{}
+	}
+	if uidOf(local.Uid) != uidOf(remote.Uid) || base.Uid != nil && uidOf(base.Uid) != uidOf(local.Uid) {
+		return nil, nil, fmt.Errorf("objects: Merge of events with different UIDs")
+	}
+	m := &merger{winner: newer(local, remote)}
+	merged := &components.Event{}
+	mv := reflect.ValueOf(merged).Elem()
+	bv, lv, rv := reflect.ValueOf(base).Elem(), reflect.ValueOf(local).Elem(), reflect.ValueOf(remote).Elem()
+	for i := 0; i < mv.NumField(); i++ {
+		switch t := mv.Field(i).Type(); {
+		case t == dtStampType || t == lastModifiedType || t == sequenceType:
+			continue
+		case t == alarmsType:
+			alarms := m.alarms(bv.Field(i).Interface().([]*components.Alarm), lv.Field(i).Interface().([]*components.Alarm), rv.Field(i).Interface().([]*components.Alarm))
+			mv.Field(i).Set(reflect.ValueOf(alarms))
+		case t.Kind() == reflect.Slice:
+			mv.Field(i).Set(m.list(bv.Field(i), lv.Field(i), rv.Field(i)))
+		default:
+			mv.Field(i).Set(m.single(bv.Field(i), lv.Field(i), rv.Field(i)))
+		}
+	}
+	merged.DtStamp = later(local.DtStamp, remote.DtStamp).(*changemanage.DtStamp)
+	merged.LastModified = later(local.LastModified, remote.LastModified).(*changemanage.LastModified)
+	switch {
+	case sequence(local) >= sequence(remote) && local.Seq != nil:
+		merged.Seq = local.Seq.Clone()
+	case remote.Seq != nil:
+		merged.Seq = remote.Seq.Clone()
+	}
+	return merged, m.conflicts, nil
+}
+
+func uidOf(u *relationship.Uid) string {
+	if u == nil || u.Value == nil {
+		return ""
+	}
+	return u.Value.V
+}
+
+func sequence(e *components.Event) int {
+	if e.Seq == nil || e.Seq.Value == nil {
+		return 0
+	}
+	return e.Seq.Value.V
+}
+
+func modified(p *changemanage.LastModified) time.Time {
+	if p == nil || p.Value == nil {
+		return time.Time{}
+	}
+	return p.Value.Time()
+}
+
+// newer returns 1 when local is the newer version, -1 when remote is and
+// 0 when they cannot be told apart.
+func newer(local, remote *components.Event) int {
+	switch l, r := sequence(local), sequence(remote); {
+	case l > r:
+		return 1
+	case l < r:
+		return -1
+	}
+	switch l, r := modified(local.LastModified), modified(remote.LastModified); {
+	case l.After(r):
+		return 1
+	case l.Before(r):
+		return -1
+	}
+	return 0
+}
+
+// later returns a copy of the later of two DTSTAMP or LAST-MODIFIED
+// properties, typed as they are.
+func later(a, b interface{}) interface{} {
+	ta, tb := stampOf(a), stampOf(b)
+	if ta == nil || tb != nil && tb.Time().After(ta.Time()) {
+		a = b
+	}
+	if reflect.ValueOf(a).IsNil() {
+		return a
+	}
+	return properties.DeepCopy(a)
+}
+
+func stampOf(p interface{}) *types.DateTime {
+	switch p := p.(type) {
+	case *changemanage.DtStamp:
+		if p != nil {
+			return p.Value
+		}
+	case *changemanage.LastModified:
+		if p != nil {
+			return p.Value
+		}
+	}
+	return nil
+}
+
+type merger struct {
+	winner    int
+	conflicts []*Conflict
+}
+
+// resolve returns the side to take, 1 for local and 2 for remote, given
+// the canonical forms of base, local and remote, which are empty where
+// the property is missing.
+func (m *merger) resolve(name, key string, b, l, r string, values [3]interface{}) int {
+	switch {
+	case l == r || r == b:
+		return 1
+	case l == b:
+		return 2
+	case m.winner < 0:
+		return 2
+	case m.winner == 0:
+		m.conflicts = append(m.conflicts, &Conflict{Name: name, Key: key, Base: values[0], Local: values[1], Remote: values[2]})
+	}
+	return 1
+}
+
+// single merges a field that holds at most one property.
+func (m *merger) single(b, l, r reflect.Value) reflect.Value {
+	var texts [3]string
+	var values [3]interface{}
+	name := ""
+	for i, v := range []reflect.Value{b, l, r} {
+		if v.IsNil() {
+			continue
+		}
+		p := v.Interface().(properties.Property)
+		line, text := canonicalProperty(p)
+		texts[i], values[i], name = text, p, strings.ToUpper(line.Name)
+	}
+	v := l
+	if m.resolve(name, "", texts[0], texts[1], texts[2], values) == 2 {
+		v = r
+	}
+	if v.IsNil() {
+		return v
+	}
+	return reflect.ValueOf(properties.DeepCopy(v.Interface()))
+}
+
+// value merges a field that holds at most one value, such as the
+// DURATION and REPEAT of alarms.
+func (m *merger) value(name string, b, l, r reflect.Value) reflect.Value {
+	var texts [3]string
+	var values [3]interface{}
+	for i, v := range []reflect.Value{b, l, r} {
+		if !v.IsNil() {
+			texts[i], values[i] = valueText(v.Interface().(types.Value)), v.Interface()
+		}
+	}
+	v := l
+	if m.resolve(name, "", texts[0], texts[1], texts[2], values) == 2 {
+		v = r
+	}
+	if v.IsNil() {
+		return v
+	}
+	return reflect.ValueOf(properties.DeepCopy(v.Interface()))
+}
+
+func valueText(v types.Value) string {
+	s := &strings.Builder{}
+	_ = v.WriteValueToStrBuilder(s)
+	return s.String()
+}
+
+// alarms merges the alarms of a component.  Alarms that one side added
+// or removed are taken as a whole; alarms on both sides are merged by
+// alarm.
+func (m *merger) alarms(b, l, r []*components.Alarm) []*components.Alarm {
+	sides := [3]map[string]*components.Alarm{}
+	var keys []string
+	seen := map[string]bool{}
+	for i, list := range [][]*components.Alarm{b, l, r} {
+		var order []string
+		order, sides[i] = alarmKeys(list)
+		for _, key := range order {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	var merged []*components.Alarm
+	for _, key := range keys {
+		ba, la, ra := sides[0][key], sides[1][key], sides[2][key]
+		if la != nil && ra != nil {
+			merged = append(merged, m.alarm(key, ba, la, ra))
+			continue
+		}
+		var texts [3]string
+		var values [3]interface{}
+		for i, a := range []*components.Alarm{ba, la, ra} {
+			if a != nil {
+				texts[i], values[i] = alarmText(a), a
+			}
+		}
+		if take := values[m.resolve("VALARM", key, texts[0], texts[1], texts[2], values)]; take != nil {
+			merged = append(merged, properties.DeepCopy(take).(*components.Alarm))
+		}
+	}
+	return merged
+}
+
+// alarm merges an alarm that both local and remote have, property by
+// property.  Properties both sides changed differently make the whole
+// alarm conflict.
+func (m *merger) alarm(key string, b, l, r *components.Alarm) *components.Alarm {
+	sub := &merger{winner: m.winner}
+	base := b
+	if base == nil {
+		base = &components.Alarm{}
+	}
+	merged := &components.Alarm{}
+	mv := reflect.ValueOf(merged).Elem()
+	bv, lv, rv := reflect.ValueOf(base).Elem(), reflect.ValueOf(l).Elem(), reflect.ValueOf(r).Elem()
+	for i := 0; i < mv.NumField(); i++ {
+		switch t := mv.Field(i).Type(); {
+		case t.Kind() == reflect.Slice:
+			mv.Field(i).Set(sub.list(bv.Field(i), lv.Field(i), rv.Field(i)))
+		case t.Implements(propertyType):
+			mv.Field(i).Set(sub.single(bv.Field(i), lv.Field(i), rv.Field(i)))
+		default:
+			mv.Field(i).Set(sub.value(strings.ToUpper(mv.Type().Field(i).Name), bv.Field(i), lv.Field(i), rv.Field(i)))
+		}
+	}
+	if len(sub.conflicts) > 0 {
+		c := &Conflict{Name: "VALARM", Key: key, Local: l, Remote: r}
+		if b != nil {
+			c.Base = b
+		}
+		m.conflicts = append(m.conflicts, c)
+	}
+	return merged
+}
+
+// alarmKeys returns the keys of alarms in order, and the alarms by key.
+// The key of an alarm is its UID, or the X-WR-ALARMUID that clients wrote
+// before RFC 9074, or else its ACTION and its position among the alarms
+// with that ACTION.
+func alarmKeys(alarms []*components.Alarm) ([]string, map[string]*components.Alarm) {
+	var order []string
+	byKey := map[string]*components.Alarm{}
+	n := map[string]int{}
+	for _, a := range alarms {
+		if a == nil {
+			continue
+		}
+		key := ""
+		for _, p := range a.IANAProp {
+			if strings.EqualFold(p.Name, "UID") && len(p.Values) > 0 {
+				key = valueText(p.Values[0])
+			}
+		}
+		for _, p := range a.XProp {
+			if key == "" && strings.EqualFold(p.Name, "X-WR-ALARMUID") && len(p.Values) > 0 {
+				key = valueText(p.Values[0])
+			}
+		}
+		if key == "" {
+			action := ""
+			if a.Action != nil && a.Action.Value != nil {
+				action = strings.ToUpper(a.Action.Value.V)
+			}
+			n[action]++
+			key = fmt.Sprintf("%s %d", action, n[action])
+		}
+		if _, ok := byKey[key]; !ok {
+			order = append(order, key)
+		}
+		byKey[key] = a
+	}
+	return order, byKey
+}
+
+// alarmText returns the canonical form of an alarm.
+func alarmText(a *components.Alarm) string {
+	raw, err := rawOf(alarmComponent{a})
+	if err != nil {
+		return ""
+	}
+	return canonical(raw, nil)
+}
+
+// list merges a field of repeated properties.
+func (m *merger) list(b, l, r reflect.Value) reflect.Value {
+	sides := [3]map[string]*keyed{}
+	var keys []string
+	for i, v := range []reflect.Value{b, l, r} {
+		sides[i] = map[string]*keyed{}
+		for _, item := range items(v) {
+			if _, ok := sides[0][item.key]; !ok && sides[1][item.key] == nil && sides[2][item.key] == nil {
+				keys = append(keys, item.key)
+			}
+			sides[i][item.key] = item
+		}
+	}
+	merged := reflect.MakeSlice(b.Type(), 0, len(keys))
+	for _, key := range keys {
+		var texts [3]string
+		var values [3]interface{}
+		name := ""
+		for i, side := range sides {
+			if item, ok := side[key]; ok {
+				texts[i], values[i], name = item.text, item.value, item.name
+			}
+		}
+		k := key
+		if name != "ATTENDEE" {
+			k = ""
+		}
+		take := values[m.resolve(name, k, texts[0], texts[1], texts[2], values)]
+		if take != nil {
+			merged = reflect.Append(merged, reflect.ValueOf(properties.DeepCopy(take)))
+		}
+	}
+	if categories, ok := merged.Interface().([]*descriptive.Categories); ok && len(categories) > 1 {
+		joined := categories[0]
+		for _, c := range categories[1:] {
+			joined.Values = append(joined.Values, c.Values...)
+		}
+		merged = reflect.ValueOf([]*descriptive.Categories{joined})
+	}
+	return merged
+}
+
+// items returns the keyed items of a field of repeated properties.
+// CATEGORIES, RDATE and EXDATE are split into properties of one value
+// each.
+func items(v reflect.Value) []*keyed {
+	var list []*keyed
+	for i := 0; i < v.Len(); i++ {
+		if v.Index(i).IsNil() {
+			continue
+		}
+		p := v.Index(i).Interface().(properties.Property)
+		var split []properties.Property
+		switch d := p.(type) {
+		case *descriptive.Categories:
+			for _, value := range d.Values {
+				c := d.Clone()
+				c.Values = []types.Value{value}
+				split = append(split, c)
+			}
+		case *recurrence.RDate:
+			for _, value := range d.Values {
+				c := d.Clone()
+				c.Values = []types.Value{value}
+				split = append(split, c)
+			}
+		case *recurrence.ExDate:
+			for _, value := range d.Values {
+				c := d.Clone()
+				c.Values = []types.Value{value}
+				split = append(split, c)
+			}
+		default:
+			split = []properties.Property{p}
+		}
+		for _, p := range split {
+			line, text := canonicalProperty(p)
+			key := text
+			if _, ok := p.(*relationship.Attendee); ok {
+				key = strings.ToLower(line.Value)
+			}
+			list = append(list, &keyed{name: strings.ToUpper(line.Name), key: key, text: text, value: p})
+		}
+	}
+	return list
+}
@@ -0,0 +1,141 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"testing"
+)
+
+const mergeBase = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:review@example.com
+DTSTAMP:20240601T080000Z
+DTSTART:20240610T080000Z
+DTEND:20240610T090000Z
+SUMMARY:Review
+SEQUENCE:1
+CATEGORIES:work
+ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:alice@example.com
+ATTENDEE:mailto:bob@example.com
+END:VEVENT
+END:VCALENDAR
+`
+
+func mergeEvent(t *testing.T, r *strings.Replacer) *components.Event {
+	t.Helper()
+	return decodeString(t, r.Replace(mergeBase)).Components[0].(*components.Event)
+}
+
+func TestMerge(t *testing.T) {
+	base := mergeEvent(t, strings.NewReplacer())
+	local := mergeEvent(t, strings.NewReplacer(
+		"DTSTAMP:20240601T080000Z", "DTSTAMP:20240602T080000Z",
+		"SUMMARY:Review", "SUMMARY:Design review",
+		"CATEGORIES:work", "CATEGORIES:work,design",
+		"ATTENDEE:mailto:bob@example.com", "ATTENDEE:mailto:bob@example.com\nATTENDEE:mailto:carol@example.com",
+	))
+	remote := mergeEvent(t, strings.NewReplacer(
+		"DTSTAMP:20240601T080000Z", "DTSTAMP:20240603T080000Z",
+		"SUMMARY:Review", "SUMMARY:Review\nLOCATION:Room 4",
+		"CATEGORIES:work", "CATEGORIES:work,review",
+		"PARTSTAT=NEEDS-ACTION", "PARTSTAT=ACCEPTED",
+		"ATTENDEE:mailto:bob@example.com\n", "",
+		"END:VEVENT", "BEGIN:VALARM\nACTION:DISPLAY\nTRIGGER:-PT5M\nDESCRIPTION:Review\nEND:VALARM\nEND:VEVENT",
+	))
+	merged, conflicts, err := Merge(base, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("conflicts: %v", conflicts)
+	}
+	want := mergeEvent(t, strings.NewReplacer(
+		"DTSTAMP:20240601T080000Z", "DTSTAMP:20240603T080000Z",
+		"SUMMARY:Review", "SUMMARY:Design review\nLOCATION:Room 4",
+		"CATEGORIES:work", "CATEGORIES:work,design,review",
+		"PARTSTAT=NEEDS-ACTION", "PARTSTAT=ACCEPTED",
+		"ATTENDEE:mailto:bob@example.com", "ATTENDEE:mailto:carol@example.com",
+		"END:VEVENT", "BEGIN:VALARM\nACTION:DISPLAY\nTRIGGER:-PT5M\nDESCRIPTION:Review\nEND:VALARM\nEND:VEVENT",
+	))
+	if !Equal(merged, want) {
+		changes, _ := Diff(want, merged)
+		t.Errorf("merged event differs: %v", changes)
+	}
+
+	// both change the summary: equal versions conflict, newer ones win
+	remote = mergeEvent(t, strings.NewReplacer("SUMMARY:Review", "SUMMARY:Code review"))
+	merged, conflicts, err = Merge(base, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].String() != "SUMMARY conflicts" || merged.Summary.Value.V != "Design review" {
+		t.Errorf("conflicts: %v, summary %q", conflicts, merged.Summary.Value.V)
+	}
+	remote = mergeEvent(t, strings.NewReplacer("SUMMARY:Review", "SUMMARY:Code review", "SEQUENCE:1", "SEQUENCE:2"))
+	merged, conflicts, _ = Merge(base, local, remote)
+	if len(conflicts) != 0 || merged.Summary.Value.V != "Code review" || merged.Seq.Value.V != 2 {
+		t.Errorf("higher SEQUENCE: %v, %q", conflicts, merged.Summary.Value.V)
+	}
+	remote = mergeEvent(t, strings.NewReplacer("SUMMARY:Review", "SUMMARY:Code review\nLAST-MODIFIED:20240605T000000Z"))
+	merged, conflicts, _ = Merge(base, local, remote)
+	if len(conflicts) != 0 || merged.Summary.Value.V != "Code review" || merged.LastModified == nil {
+		t.Errorf("later LAST-MODIFIED: %v, %q", conflicts, merged.Summary.Value.V)
+	}
+	if merged.Summary == remote.Summary {
+		t.Errorf("the merged event shares properties with remote")
+	}
+
+	remote = mergeEvent(t, strings.NewReplacer("UID:review@example.com", "UID:other@example.com"))
+	if _, _, err := Merge(base, local, remote); err == nil {
+		t.Errorf("Merge of different UIDs succeeded")
+	}
+}
+
+func TestMerge_Alarms(t *testing.T) {
+	alarm := func(trigger, description string) *strings.Replacer {
+		return strings.NewReplacer("END:VEVENT", "BEGIN:VALARM\nACTION:DISPLAY\nTRIGGER:"+trigger+"\nDESCRIPTION:"+description+"\nEND:VALARM\nEND:VEVENT")
+	}
+	base := mergeEvent(t, alarm("-PT5M", "Review"))
+
+	// the same alarm edited on both sides
+	local := mergeEvent(t, alarm("-PT10M", "Review"))
+	remote := mergeEvent(t, alarm("-PT30M", "Review"))
+	merged, conflicts, err := Merge(base, local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Alarm) != 1 || len(conflicts) != 1 || conflicts[0].String() != "VALARM DISPLAY 1 conflicts" {
+		t.Errorf("%d alarms, conflicts %v", len(merged.Alarm), conflicts)
+	}
+	if len(merged.Alarm) == 1 && !Equal(alarmComponent{merged.Alarm[0]}, alarmComponent{local.Alarm[0]}) {
+		t.Errorf("the local alarm was not kept")
+	}
+
+	// different properties of the same alarm
+	remote = mergeEvent(t, alarm("-PT5M", "Code review"))
+	merged, conflicts, _ = Merge(base, local, remote)
+	want := mergeEvent(t, alarm("-PT10M", "Code review"))
+	if len(conflicts) != 0 || !Equal(merged, want) {
+		changes, _ := Diff(want, merged)
+		t.Errorf("conflicts %v, changes %v", conflicts, changes)
+	}
+
+	// alarms with a UID are matched by it, whatever their position
+	withUid := func(uid, trigger string) string {
+		return "BEGIN:VALARM\nX-WR-ALARMUID:" + uid + "\nACTION:DISPLAY\nTRIGGER:" + trigger + "\nDESCRIPTION:Review\nEND:VALARM\n"
+	}
+	alarms := func(list ...string) *strings.Replacer {
+		return strings.NewReplacer("END:VEVENT", strings.Join(list, "")+"END:VEVENT")
+	}
+	base = mergeEvent(t, alarms(withUid("a", "-PT5M"), withUid("b", "-PT1H")))
+	local = mergeEvent(t, alarms(withUid("b", "-PT2H"), withUid("a", "-PT5M")))
+	remote = mergeEvent(t, alarms(withUid("a", "-PT15M")))
+	merged, conflicts, _ = Merge(base, local, remote)
+	want = mergeEvent(t, alarms(withUid("a", "-PT15M"), withUid("b", "-PT2H")))
+	if len(conflicts) != 1 || conflicts[0].String() != "VALARM b conflicts" || !Equal(merged, want) {
+		changes, _ := Diff(want, merged)
+		t.Errorf("conflicts %v, changes %v", conflicts, changes)
+	}
+}
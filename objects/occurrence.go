@@ -0,0 +1,307 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"time"
+)
+
+// Occurrence is one instance of an event, to-do or journal entry.
+type Occurrence struct {
+	// Component is the component the instance comes from: the master
+	// component of a recurring set or the component that overrides this
+	// instance.
+	Component components.Component
+	Start     time.Time
+	End       time.Time
+	// RecurrenceId is the start the instance has in the recurrence set,
+	// or the zero time for components that do not recur.
+	RecurrenceId time.Time
+	AllDay       bool
+}
+
+// timing holds the properties of a component that place it in time.
+type timing struct {
+	uid      string
+	start    types.Value
+	startP   []parameters.Parameter
+	end      types.Value
+	endP     []parameters.Parameter
+	duration *types.Duration
+	recurId  *relationship.RecurrenceId
+	rrule    *recurrence.RRule
+	rdate    []*recurrence.RDate
+	exdate   []*recurrence.ExDate
+	todo     bool
+}
+
+func timingOf(c components.Component) (*timing, bool) {
+	t := &timing{}
+	var uid *relationship.Uid
+	var start *datetime.DateStart
+	var dur *datetime.Duration
+	switch v := c.(type) {
+	case *components.Event:
+		uid, start, dur = v.Uid, v.DtStart, v.Duration
+		t.recurId, t.rrule, t.rdate, t.exdate = v.RecurId, v.RRule, v.RDate, v.ExDate
+		if v.DtEnd != nil {
+			t.end, t.endP = v.DtEnd.Value, v.DtEnd.Parameters
+		}
+	case *components.Todo:
+		uid, start, dur = v.Uid, v.DtStart, v.Duration
+		t.recurId, t.rrule, t.rdate, t.exdate = v.RecurId, v.RRule, v.RDate, v.ExDate
+		if v.Due != nil {
+			t.end, t.endP = v.Due.Value, v.Due.Parameters
+		}
+		t.todo = true
+	case *components.Journal:
+		uid, start = v.Uid, v.DtStart
+		t.recurId, t.rrule, t.rdate, t.exdate = v.RecurId, v.RRule, v.RDate, v.ExDate
+	default:
+		return nil, false
+	}
+	if uid != nil && uid.Value != nil {
+		t.uid = uid.Value.V
+	}
+	if start != nil && start.Value != nil {
+		t.start, t.startP = start.Value, start.Parameters
+	}
+	if dur != nil {
+		t.duration = dur.Value
+	}
+	if t.start == nil {
+		// to-dos without DTSTART take place at their DUE
+		if !t.todo || t.end == nil {
+			return nil, false
+		}
+		t.start, t.startP = t.end, t.endP
+	}
+	return t, true
+}
+
+// TimeOf returns the instant of a DATE or DATE-TIME value as
+// components.Instant does, except that a TZID the time zone database does
+// not know leaves the value as it is instead of failing.
+func TimeOf(params []parameters.Parameter, v types.Value) (time.Time, bool) {
+	t, err := components.Instant(params, v)
+	if dt, ok := v.(*types.DateTime); ok && err != nil {
+		return dt.Time(), true
+	}
+	return t, err == nil
+}
+
+func (t *timing) allDay() bool {
+	_, ok := t.start.(*types.Date)
+	return ok
+}
+
+// length returns the duration of the instances of t.
+func (t *timing) length() time.Duration {
+	start, _ := TimeOf(t.startP, t.start)
+	if t.end != nil && !(t.todo && t.start == t.end) {
+		end, _ := TimeOf(t.endP, t.end)
+		return end.Sub(start)
+	}
+	if t.duration != nil {
+		return t.duration.Duration()
+	}
+	if t.allDay() && !t.todo {
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func overlaps(start, end, after, before time.Time) bool {
+	if start.Equal(end) {
+		return !start.Before(after) && start.Before(before)
+	}
+	return start.Before(before) && end.After(after)
+}
+
+// instances returns the recurrence instances of t that may overlap
+// [after, before), keyed by their recurrence id.
+func (t *timing) instances(after, before time.Time, length time.Duration) (map[int64]time.Time, map[int64]time.Duration, error) {
+	starts := map[int64]time.Time{}
+	lengths := map[int64]time.Duration{}
+	first, _ := TimeOf(t.startP, t.start)
+	add := func(s time.Time, l time.Duration) {
+		starts[s.Unix()] = s
+		lengths[s.Unix()] = l
+	}
+	add(first, length)
+	if t.rrule != nil && t.rrule.V != nil {
+		// the iterator works in the location the start was resolved in
+		dtstart := t.start
+		if dt, ok := t.start.(*types.DateTime); ok && !dt.IsUTC() {
+			dtstart = &types.DateTime{V: first, Format: dt.Format}
+		}
+		from := after.Add(-length)
+		if length < 0 {
+			from = after
+		}
+		ts, err := t.rrule.V.Between(dtstart, from.Add(-time.Second), before)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range ts {
+			add(s, length)
+		}
+	}
+	for _, r := range t.rdate {
+		for _, v := range r.Values {
+			switch p := v.(type) {
+			case *types.ExplicitPeriod:
+				s, e := p.Start.Time(), p.End.Time()
+				add(s, e.Sub(s))
+			case *types.StartPeriod:
+				s := p.Start.Time()
+				add(s, p.Duration.Duration())
+			default:
+				if s, ok := TimeOf(r.Parameters, v); ok {
+					if d, ok := v.(*types.Date); ok {
+						s = time.Date(d.V.Year(), d.V.Month(), d.V.Day(), 0, 0, 0, 0, first.Location())
+					}
+					add(s, length)
+				}
+			}
+		}
+	}
+	for _, x := range t.exdate {
+		for _, v := range x.Values {
+			s, ok := TimeOf(x.Parameters, v)
+			if !ok {
+				continue
+			}
+			if d, ok := v.(*types.Date); ok {
+				s = time.Date(d.V.Year(), d.V.Month(), d.V.Day(), 0, 0, 0, 0, first.Location())
+			}
+			delete(starts, s.Unix())
+		}
+	}
+	return starts, lengths, nil
+}
+
+// Occurrences returns the instances of the events, to-dos and journal
+// entries of c that overlap the time range [after, before), sorted by
+// start.  Recurring components are expanded with their RRULE, RDATE and
+// EXDATE properties, and instances are replaced by the components that
+// override them by RECURRENCE-ID, including RANGE=THISANDFUTURE.
+func (c *Calendar) Occurrences(after, before time.Time) ([]*Occurrence, error) {
+	return Occurrences(c.Components, after, before)
+}
+
+// Occurrences is Calendar.Occurrences for a list of components.
+func Occurrences(comps []components.Component, after, before time.Time) ([]*Occurrence, error) {
+	type set struct {
+		master    components.Component
+		timing    *timing
+		overrides []components.Component
+	}
+	var sets []*set
+	byUid := map[string]*set{}
+	for _, comp := range comps {
+		t, ok := timingOf(comp)
+		if !ok {
+			continue
+		}
+		// components without UID are unrelated to each other
+		s, ok := byUid[t.uid]
+		if !ok || t.uid == "" {
+			s = &set{}
+			sets = append(sets, s)
+			if t.uid != "" {
+				byUid[t.uid] = s
+			}
+		}
+		if t.recurId != nil && t.uid != "" {
+			s.overrides = append(s.overrides, comp)
+		} else if s.master == nil {
+			s.master, s.timing = comp, t
+		}
+	}
+	var occurrences []*Occurrence
+	for _, s := range sets {
+		exact := map[int64]components.Component{}
+		var future []components.Component
+		var futureIds []time.Time
+		for _, o := range s.overrides {
+			t, _ := timingOf(o)
+			id, _ := TimeOf(t.recurId.Parameters, t.recurId.Value)
+			exact[id.Unix()] = o
+			for _, p := range t.recurId.Parameters {
+				if r, ok := p.(*parameters.RecurrenceIdRange); ok && r.V == parameters.ThisAndFuture.V {
+					future = append(future, o)
+					futureIds = append(futureIds, id)
+				}
+			}
+			// overrides are instances on their own
+			start, _ := TimeOf(t.startP, t.start)
+			end := components.EndOf(start, t.length())
+			if overlaps(start, end, after, before) {
+				occurrences = append(occurrences, &Occurrence{Component: o, Start: start, End: end, RecurrenceId: id, AllDay: t.allDay()})
+			}
+		}
+		if s.master == nil {
+			continue
+		}
+		t := s.timing
+		length := t.length()
+		if t.rrule == nil && len(t.rdate) == 0 {
+			start, _ := TimeOf(t.startP, t.start)
+			end := components.EndOf(start, length)
+			if overlaps(start, end, after, before) {
+				occurrences = append(occurrences, &Occurrence{Component: s.master, Start: start, End: end, AllDay: t.allDay()})
+			}
+			continue
+		}
+		// instances that a THISANDFUTURE override moves may come from
+		// before the range
+		from := after
+		for i, o := range future {
+			ot, _ := timingOf(o)
+			os, _ := TimeOf(ot.startP, ot.start)
+			if shift := futureIds[i].Sub(os); shift > 0 {
+				from = from.Add(-shift)
+			}
+		}
+		starts, lengths, err := t.instances(from, before, length)
+		if err != nil {
+			return nil, err
+		}
+		for key, id := range starts {
+			if _, ok := exact[key]; ok {
+				continue
+			}
+			o := &Occurrence{Component: s.master, Start: id, RecurrenceId: id, AllDay: t.allDay()}
+			l := lengths[key]
+			var latest time.Time
+			for i, f := range future {
+				if !futureIds[i].After(id) && !futureIds[i].Before(latest) {
+					ft, _ := timingOf(f)
+					fs, _ := TimeOf(ft.startP, ft.start)
+					o.Component = f
+					o.Start = id.Add(fs.Sub(futureIds[i]))
+					l = ft.length()
+					latest = futureIds[i]
+				}
+			}
+			o.End = components.EndOf(o.Start, l)
+			if overlaps(o.Start, o.End, after, before) {
+				occurrences = append(occurrences, o)
+			}
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		a, b := occurrences[i], occurrences[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		return a.End.Before(b.End)
+	})
+	return occurrences, nil
+}
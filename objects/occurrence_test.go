@@ -0,0 +1,110 @@
+package objects
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar_Occurrences(t *testing.T) {
+	in := `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:weekly
+DTSTAMP:20240101T000000Z
+DTSTART;TZID=Europe/Berlin:20240304T100000
+DURATION:PT1H
+RRULE:FREQ=WEEKLY;COUNT=6
+EXDATE;TZID=Europe/Berlin:20240318T100000
+RDATE;VALUE=PERIOD:20240320T120000Z/PT30M
+SUMMARY:Weekly
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly
+DTSTAMP:20240101T000000Z
+RECURRENCE-ID;TZID=Europe/Berlin:20240311T100000
+DTSTART;TZID=Europe/Berlin:20240312T150000
+DURATION:PT2H
+SUMMARY:Moved
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly
+DTSTAMP:20240101T000000Z
+RECURRENCE-ID;RANGE=THISANDFUTURE;TZID=Europe/Berlin:20240401T100000
+DTSTART;TZID=Europe/Berlin:20240401T110000
+DURATION:PT1H
+SUMMARY:Later
+END:VEVENT
+BEGIN:VEVENT
+UID:allday
+DTSTAMP:20240101T000000Z
+DTSTART;VALUE=DATE:20240325
+SUMMARY:Holiday
+END:VEVENT
+END:VCALENDAR
+`
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	occ, err := c.Occurrences(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range occ {
+		// all-day instances are floating and stay in time.Local
+		start, end := o.Start, o.End
+		if !o.AllDay {
+			start, end = start.In(berlin), end.In(berlin)
+		}
+		got = append(got, start.Format("0102T1504")+"-"+end.Format("0102T1504"))
+	}
+	want := "0304T1000-0304T1100 0312T1500-0312T1700 0320T1300-0320T1330 0325T0000-0326T0000 " +
+		"0325T1000-0325T1100 0401T1100-0401T1200 0408T1100-0408T1200"
+	if g := strings.Join(got, " "); g != want {
+		t.Errorf("got:  %s\nwant: %s", g, want)
+	}
+
+	occ, err = c.Occurrences(time.Date(2024, 3, 12, 14, 30, 0, 0, time.UTC), time.Date(2024, 3, 12, 14, 31, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occ) != 1 || !occ[0].RecurrenceId.Equal(time.Date(2024, 3, 11, 10, 0, 0, 0, berlin)) {
+		t.Errorf("got %+v, want the moved instance", occ)
+	}
+}
+
+func TestCalendar_OccurrencesUnmatchedStart(t *testing.T) {
+	// DTSTART is a Monday, the rule only has Tuesdays: DTSTART is the
+	// first of the three instances
+	in := `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:tuesdays
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T090000Z
+DURATION:PT1H
+RRULE:FREQ=DAILY;COUNT=3;BYDAY=TU
+END:VEVENT
+END:VCALENDAR
+`
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	occ, err := c.Occurrences(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range occ {
+		got = append(got, o.Start.Format("0102T1504"))
+	}
+	if g := strings.Join(got, " "); g != "0304T0900 0305T0900 0312T0900" {
+		t.Errorf("got %s, want 0304T0900 0305T0900 0312T0900", g)
+	}
+}
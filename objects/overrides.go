@@ -0,0 +1,200 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"time"
+)
+
+//   RFC 5545 3.8.4.4.  Recurrence ID
+//
+//      The full range of calendar components specified by a
+//      recurrence set is referenced by referring to just the "UID"
+//      property value corresponding to the calendar component.  The
+//      "RECURRENCE-ID" property allows the reference to an individual
+//      instance within the recurrence set.
+//
+//   RFC 5546 2.1.5.  Message Sequencing
+//
+//      The "SEQUENCE" property value is incremented ... when the
+//      "DTSTART", "DTEND", "DURATION", "DUE", "RRULE", "RDATE", or
+//      "EXDATE" properties are changed ... when the "STATUS" property
+//      value is changed.
+
+// Detach returns an override of the instance whose recurrence id is at
+// of the recurring event series events, the master and its overrides,
+// for editing it apart from the series: a copy of the master with
+// RECURRENCE-ID at and the start and end of the instance, or a copy of
+// the override the instance already has.  events are not changed; the
+// override is added to them with Replace.
+func Detach(events []*components.Event, at time.Time) (*components.Event, error) {
+	master, overrides, err := series(events)
+	if err != nil {
+		return nil, err
+	}
+	if o := overrideAt(overrides, at); o != nil {
+		return o.Clone(), nil
+	}
+	t, _ := timingOf(master)
+	if err := isInstance(t, at); err != nil {
+		return nil, err
+	}
+	return override(master, t, at), nil
+}
+
+// Move returns events with the instance whose recurrence id is at
+// rescheduled to start, keeping its length: its override, detached if
+// it has none, gets the new DTSTART and DTEND in the value types and
+// time zones they have, a date for an all-day event, and a higher
+// SEQUENCE.
+func Move(events []*components.Event, at, start time.Time) ([]*components.Event, error) {
+	o, err := Detach(events, at)
+	if err != nil {
+		return nil, err
+	}
+	t, _ := timingOf(o)
+	length := t.length()
+	o.DtStart.Value = valueAt(t.startP, t.start, start)
+	if o.DtEnd != nil {
+		o.DtEnd.Value = valueAt(o.DtEnd.Parameters, o.DtEnd.Value, components.EndOf(start, length))
+	}
+	o.Seq = bumpSequence(o.Seq)
+	return Replace(events, o), nil
+}
+
+// Cancel returns events with the instance whose recurrence id is at
+// cancelled.  With exclude the instance is removed from the series: the
+// master gets an EXDATE for it in the value type and time zone of
+// DTSTART and a higher SEQUENCE, and an override of the instance is
+// dropped.  Otherwise the instance stays as an override with
+// STATUS:CANCELLED, as organizers send it to attendees.
+func Cancel(events []*components.Event, at time.Time, exclude bool) ([]*components.Event, error) {
+	if !exclude {
+		o, err := Detach(events, at)
+		if err != nil {
+			return nil, err
+		}
+		o.Status = descriptive.NewStatus("CANCELLED")
+		o.Seq = bumpSequence(o.Seq)
+		return Replace(events, o), nil
+	}
+	master, overrides, err := series(events)
+	if err != nil {
+		return nil, err
+	}
+	t, _ := timingOf(master)
+	if overrideAt(overrides, at) == nil {
+		if err := isInstance(t, at); err != nil {
+			return nil, err
+		}
+	}
+	m := master.Clone()
+	m.ExDate = append(m.ExDate, &recurrence.ExDate{Parameters: m.DtStart.Clone().Parameters, Values: []types.Value{valueAt(t.startP, t.start, at)}})
+	m.Seq = bumpSequence(m.Seq)
+	list := []*components.Event{m}
+	for _, o := range overrides {
+		if !isOverrideOf(o.RecurId, at) {
+			list = append(list, o)
+		}
+	}
+	return list, nil
+}
+
+// Reattach returns events with the instance whose recurrence id is at
+// back in the series as the master makes it: its override is dropped
+// and an EXDATE of it removed, and the master gets a higher SEQUENCE.
+func Reattach(events []*components.Event, at time.Time) ([]*components.Event, error) {
+	master, overrides, err := series(events)
+	if err != nil {
+		return nil, err
+	}
+	m := master.Clone()
+	exdates := m.ExDate
+	m.ExDate = nil
+	excluded := false
+	for _, x := range exdates {
+		c := &recurrence.ExDate{Parameters: x.Parameters}
+		for _, v := range x.Values {
+			if t, _ := TimeOf(x.Parameters, v); t.Equal(at) {
+				excluded = true
+			} else {
+				c.Values = append(c.Values, v)
+			}
+		}
+		if len(c.Values) > 0 {
+			m.ExDate = append(m.ExDate, c)
+		}
+	}
+	if !excluded && overrideAt(overrides, at) == nil {
+		return nil, fmt.Errorf("objects: instance %s of %s is not detached", at.Format(time.RFC3339), types.UnescapeText(master.Uid.Value.V))
+	}
+	if excluded {
+		t, _ := timingOf(m)
+		if err := isInstance(t, at); err != nil {
+			return nil, err
+		}
+	}
+	m.Seq = bumpSequence(m.Seq)
+	list := []*components.Event{m}
+	for _, o := range overrides {
+		if !isOverrideOf(o.RecurId, at) {
+			list = append(list, o)
+		}
+	}
+	return list, nil
+}
+
+// Replace returns events with o in place of the component with the
+// same RECURRENCE-ID, or added to them when there is none.  o should
+// have the UID of events.
+func Replace(events []*components.Event, o *components.Event) []*components.Event {
+	list := make([]*components.Event, 0, len(events)+1)
+	replaced := false
+	for _, e := range events {
+		if sameRecurrence(e.RecurId, o.RecurId) {
+			list = append(list, o)
+			replaced = true
+		} else {
+			list = append(list, e)
+		}
+	}
+	if !replaced {
+		list = append(list, o)
+	}
+	return list
+}
+
+// overrideAt returns the override of the instance at, or nil.
+func overrideAt(overrides []*components.Event, at time.Time) *components.Event {
+	for _, o := range overrides {
+		if isOverrideOf(o.RecurId, at) {
+			return o
+		}
+	}
+	return nil
+}
+
+// isOverrideOf reports whether id, the RECURRENCE-ID of a component,
+// is that of the instance at.
+func isOverrideOf(id *relationship.RecurrenceId, at time.Time) bool {
+	if id == nil {
+		return false
+	}
+	t, _ := TimeOf(id.Parameters, id.Value)
+	return t.Equal(at)
+}
+
+// sameRecurrence reports whether a and b, RECURRENCE-IDs of components
+// of a series, identify the same component: both masters, or overrides
+// of the same instance.
+func sameRecurrence(a, b *relationship.RecurrenceId) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	at, _ := TimeOf(b.Parameters, b.Value)
+	return isOverrideOf(a, at)
+}
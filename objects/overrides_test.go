@@ -0,0 +1,178 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeComponents decodes the calendar in and returns it with its
+// components.
+func decodeComponents(t *testing.T, in string) (*Calendar, []components.Component) {
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, c.Components
+}
+
+// encodeComponents returns c encoded with the components of list, a
+// slice of events or to-dos, in place of its own.
+func encodeComponents(t *testing.T, c *Calendar, list interface{}) string {
+	c = c.Clone()
+	c.Components = nil
+	v := reflect.ValueOf(list)
+	for i := 0; i < v.Len(); i++ {
+		c.Components = append(c.Components, v.Index(i).Interface().(components.Component))
+	}
+	s, err := c.Calendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestOverrides(t *testing.T) {
+	c, comps := decodeComponents(t, `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:weekly
+DTSTAMP:20240101T000000Z
+DTSTART;TZID=Europe/Berlin:20240304T100000
+DTEND;TZID=Europe/Berlin:20240304T110000
+RRULE:FREQ=WEEKLY;COUNT=4
+SEQUENCE:2
+SUMMARY:Weekly
+END:VEVENT
+END:VCALENDAR
+`)
+	events := []*components.Event{comps[0].(*components.Event)}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	second := time.Date(2024, 3, 11, 10, 0, 0, 0, berlin)
+	third := time.Date(2024, 3, 18, 10, 0, 0, 0, berlin)
+
+	if _, err := Detach(events, second.Add(time.Hour)); err == nil {
+		t.Error("Detach of no instance: no error")
+	}
+	o, err := Detach(events, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Summary.Value.V = "Changed"
+	events = Replace(events, o)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+
+	events, err = Move(events, second, time.Date(2024, 3, 12, 15, 0, 0, 0, berlin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = Cancel(events, third, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := encodeComponents(t, c, events)
+	for _, want := range []string{
+		"RECURRENCE-ID;TZID=Europe/Berlin:20240311T100000",
+		"DTSTART;TZID=Europe/Berlin:20240312T150000",
+		"DTEND;TZID=Europe/Berlin:20240312T160000",
+		"SUMMARY:Changed",
+		"SEQUENCE:3",
+		"RECURRENCE-ID;TZID=Europe/Berlin:20240318T100000",
+		"STATUS:CANCELLED",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+
+	events, err = Cancel(events, second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	s = encodeComponents(t, c, events)
+	if !strings.Contains(s, "EXDATE;TZID=Europe/Berlin:20240311T100000") || strings.Contains(s, "SUMMARY:Changed") {
+		t.Errorf("instance not excluded:\n%s", s)
+	}
+
+	if _, err := Reattach(events, time.Date(2024, 3, 25, 10, 0, 0, 0, berlin)); err == nil {
+		t.Error("Reattach of an instance that is not detached: no error")
+	}
+	prev := encodeComponents(t, c, events)
+	list, err := Reattach(events, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTZID(list[0], "Europe/Paris")
+	if s := encodeComponents(t, c, events); s != prev {
+		t.Errorf("Reattach shares properties with the events:\n%s", s)
+	}
+	events, err = Reattach(events, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = Reattach(events, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = encodeComponents(t, c, events)
+	if len(events) != 1 || strings.Contains(s, "EXDATE") || !strings.Contains(s, "SEQUENCE:5") {
+		t.Errorf("instances not reattached:\n%s", s)
+	}
+}
+
+func TestOverrides_AllDay(t *testing.T) {
+	c, comps := decodeComponents(t, `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:days
+DTSTAMP:20240101T000000Z
+DTSTART;VALUE=DATE:20240301
+DTEND;VALUE=DATE:20240302
+RRULE:FREQ=DAILY;COUNT=5
+SUMMARY:Days
+END:VEVENT
+END:VCALENDAR
+`)
+	events := []*components.Event{comps[0].(*components.Event)}
+	at := time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)
+	events, err := Move(events, at, time.Date(2024, 3, 9, 13, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err = Cancel(events, at.AddDate(0, 0, 1), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := encodeComponents(t, c, events)
+	for _, want := range []string{
+		"RECURRENCE-ID;VALUE=DATE:20240302",
+		"DTSTART;VALUE=DATE:20240309",
+		"DTEND;VALUE=DATE:20240310",
+		"EXDATE;VALUE=DATE:20240303",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+	c, _ = decodeComponents(t, s)
+	occ, err := c.Occurrences(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range occ {
+		got = append(got, o.Start.Format("0102"))
+	}
+	if g := strings.Join(got, " "); g != "0301 0304 0305 0309" {
+		t.Errorf("got %s", g)
+	}
+}
@@ -0,0 +1,23 @@
+package property
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of a.
+func (a *CalendarScale) Clone() *CalendarScale {
+	return properties.DeepCopy(a).(*CalendarScale)
+}
+
+// Clone returns a deep copy of a.
+func (a *Method) Clone() *Method {
+	return properties.DeepCopy(a).(*Method)
+}
+
+// Clone returns a deep copy of a.
+func (a *ProductIdentifier) Clone() *ProductIdentifier {
+	return properties.DeepCopy(a).(*ProductIdentifier)
+}
+
+// Clone returns a deep copy of a.
+func (a *Version) Clone() *Version {
+	return properties.DeepCopy(a).(*Version)
+}
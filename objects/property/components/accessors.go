@@ -0,0 +1,312 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.6.1.  Event Component
+//
+//      For cases where a "VEVENT" calendar component specifies a
+//      "DTSTART" property with a DATE value type but no "DTEND" nor
+//      "DURATION" property, the event's duration is taken to be one day.
+//      For cases where a "VEVENT" calendar component specifies a
+//      "DTSTART" property with a DATE-TIME value type but no "DTEND"
+//      property, the event will end at the same calendar date and time
+//      of day specified by the "DTSTART" property.
+
+// ErrNoStart is returned by the accessors of components without DTSTART.
+var ErrNoStart = errors.New("components: no DTSTART")
+
+// Participant is an ATTENDEE with its parameters, defaults applied.
+type Participant struct {
+	// Address is the calendar user address, such as
+	// "mailto:jane@example.com".
+	Address    string
+	CommonName string
+	// CuType is INDIVIDUAL unless the CUTYPE parameter says otherwise.
+	CuType string
+	// Role is REQ-PARTICIPANT unless the ROLE parameter says otherwise.
+	Role string
+	// PartStat is NEEDS-ACTION unless the PARTSTAT parameter says
+	// otherwise.
+	PartStat string
+	RSVP     bool
+}
+
+// Instant returns the instant of a DATE or DATE-TIME value.  A local
+// DATE-TIME with a TZID that was not resolved when it was created, as
+// with the constructors that use time.Local, is resolved by the time
+// zone database.
+func Instant(params []parameters.Parameter, v types.Value) (time.Time, error) {
+	switch t := v.(type) {
+	case *types.Date:
+		return t.Time(), nil
+	case *types.DateTime:
+		if t.IsUTC() || t.V.Location() != time.Local {
+			return t.Time(), nil
+		}
+		for _, p := range params {
+			if id, ok := p.(*parameters.TimeZoneId); ok {
+				loc, err := time.LoadLocation(strings.TrimPrefix(id.V, "/"))
+				if err != nil {
+					return time.Time{}, fmt.Errorf("components: unknown TZID %s", id.V)
+				}
+				w := t.V
+				return time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), w.Nanosecond(), loc), nil
+			}
+		}
+		return t.V, nil
+	}
+	return time.Time{}, fmt.Errorf("components: %T is not a date or date-time", v)
+}
+
+// isDate reports whether v is a DATE value.
+func isDate(v types.Value) bool {
+	_, ok := v.(*types.Date)
+	return ok
+}
+
+// EndOf returns the end of a component starting at start that lasts
+// length.  Lengths of whole days keep the wall clock across changes of
+// the UTC offset.
+func EndOf(start time.Time, length time.Duration) time.Time {
+	if length%(24*time.Hour) == 0 {
+		return start.AddDate(0, 0, int(length/(24*time.Hour)))
+	}
+	return start.Add(length)
+}
+
+func participants(attendees []*relationship.Attendee) []*Participant {
+	list := make([]*Participant, 0, len(attendees))
+	for _, a := range attendees {
+		p := &Participant{
+			CuType:   parameters.IndividualCuType.V,
+			Role:     parameters.Required.V,
+			PartStat: parameters.NeedAction.V,
+		}
+		if a.Value != nil && a.Value.V != nil {
+			p.Address = a.Value.V.V
+		}
+		for _, param := range a.Parameters {
+			switch v := param.(type) {
+			case *parameters.CommonName:
+				p.CommonName = v.V
+			case *parameters.CuType:
+				p.CuType = v.V
+			case *parameters.ParticipationRole:
+				p.Role = v.V
+			case *parameters.PartStat:
+				p.PartStat = v.V
+			case *parameters.Rsvp:
+				p.RSVP = v.V
+			}
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+func categories(props []*descriptive.Categories) []string {
+	var list []string
+	for _, c := range props {
+		for _, v := range c.Values {
+			s := &strings.Builder{}
+			if err := v.WriteValueToStrBuilder(s); err == nil {
+				list = append(list, types.UnescapeText(s.String()))
+			}
+		}
+	}
+	return list
+}
+
+// Start returns the DTSTART of e, in its time zone.
+func (e *Event) Start() (time.Time, error) {
+	if e.DtStart == nil || e.DtStart.Value == nil {
+		return time.Time{}, ErrNoStart
+	}
+	return Instant(e.DtStart.Parameters, e.DtStart.Value)
+}
+
+// End returns the end of e: its DTEND, its DTSTART plus DURATION, or
+// the default of RFC 5545, which is the next day for all-day events and
+// the start for others.
+func (e *Event) End() (time.Time, error) {
+	start, err := e.Start()
+	if err != nil {
+		return start, err
+	}
+	switch {
+	case e.DtEnd != nil && e.DtEnd.Value != nil:
+		return Instant(e.DtEnd.Parameters, e.DtEnd.Value)
+	case e.Duration != nil && e.Duration.Value != nil:
+		return EndOf(start, e.Duration.Value.Duration()), nil
+	case e.IsAllDay():
+		return start.AddDate(0, 0, 1), nil
+	}
+	return start, nil
+}
+
+// Length returns the time from the start to the end of e.
+func (e *Event) Length() (time.Duration, error) {
+	start, err := e.Start()
+	if err != nil {
+		return 0, err
+	}
+	end, err := e.End()
+	return end.Sub(start), err
+}
+
+// IsAllDay reports whether the DTSTART of e is a DATE.
+func (e *Event) IsAllDay() bool {
+	return e.DtStart != nil && isDate(e.DtStart.Value)
+}
+
+// Attendees returns the ATTENDEE properties of e.
+func (e *Event) Attendees() []*Participant {
+	return participants(e.Attendee)
+}
+
+// CategoryList returns the values of the CATEGORIES properties of e.
+func (e *Event) CategoryList() []string {
+	return categories(e.Categories)
+}
+
+// Start returns the DTSTART of t, in its time zone.
+func (t *Todo) Start() (time.Time, error) {
+	if t.DtStart == nil || t.DtStart.Value == nil {
+		return time.Time{}, ErrNoStart
+	}
+	return Instant(t.DtStart.Parameters, t.DtStart.Value)
+}
+
+// End returns the DUE of t, or its DTSTART plus DURATION.  To-dos with
+// neither end at their start.
+func (t *Todo) End() (time.Time, error) {
+	if t.Due != nil && t.Due.Value != nil {
+		return Instant(t.Due.Parameters, t.Due.Value)
+	}
+	start, err := t.Start()
+	if err != nil {
+		return start, err
+	}
+	if t.Duration != nil && t.Duration.Value != nil {
+		return EndOf(start, t.Duration.Value.Duration()), nil
+	}
+	return start, nil
+}
+
+// Length returns the time from the start to the end of t.
+func (t *Todo) Length() (time.Duration, error) {
+	start, err := t.Start()
+	if err != nil {
+		return 0, err
+	}
+	end, err := t.End()
+	return end.Sub(start), err
+}
+
+// IsAllDay reports whether the DTSTART of t, or its DUE when it has no
+// DTSTART, is a DATE.
+func (t *Todo) IsAllDay() bool {
+	if t.DtStart != nil {
+		return isDate(t.DtStart.Value)
+	}
+	return t.Due != nil && isDate(t.Due.Value)
+}
+
+// Attendees returns the ATTENDEE properties of t.
+func (t *Todo) Attendees() []*Participant {
+	return participants(t.Attendee)
+}
+
+// CategoryList returns the values of the CATEGORIES properties of t.
+func (t *Todo) CategoryList() []string {
+	return categories(t.Categories)
+}
+
+// Start returns the DTSTART of j, in its time zone.
+func (j *Journal) Start() (time.Time, error) {
+	if j.DtStart == nil || j.DtStart.Value == nil {
+		return time.Time{}, ErrNoStart
+	}
+	return Instant(j.DtStart.Parameters, j.DtStart.Value)
+}
+
+// End returns the end of the day of an all-day journal entry, and the
+// start of others.
+func (j *Journal) End() (time.Time, error) {
+	start, err := j.Start()
+	if err == nil && j.IsAllDay() {
+		return start.AddDate(0, 0, 1), nil
+	}
+	return start, err
+}
+
+// Length returns the time from the start to the end of j.
+func (j *Journal) Length() (time.Duration, error) {
+	start, err := j.Start()
+	if err != nil {
+		return 0, err
+	}
+	end, err := j.End()
+	return end.Sub(start), err
+}
+
+// IsAllDay reports whether the DTSTART of j is a DATE.
+func (j *Journal) IsAllDay() bool {
+	return j.DtStart != nil && isDate(j.DtStart.Value)
+}
+
+// Attendees returns the ATTENDEE properties of j.
+func (j *Journal) Attendees() []*Participant {
+	return participants(j.Attendee)
+}
+
+// CategoryList returns the values of the CATEGORIES properties of j.
+func (j *Journal) CategoryList() []string {
+	return categories(j.Categories)
+}
+
+// Start returns the DTSTART of f.
+func (f *FreeBusy) Start() (time.Time, error) {
+	if f.DtStart == nil || f.DtStart.Value == nil {
+		return time.Time{}, ErrNoStart
+	}
+	return Instant(f.DtStart.Parameters, f.DtStart.Value)
+}
+
+// End returns the DTEND of f, or its start when it has none.
+func (f *FreeBusy) End() (time.Time, error) {
+	if f.DtEnd != nil && f.DtEnd.Value != nil {
+		return Instant(f.DtEnd.Parameters, f.DtEnd.Value)
+	}
+	return f.Start()
+}
+
+// Length returns the time from the start to the end of f.
+func (f *FreeBusy) Length() (time.Duration, error) {
+	start, err := f.Start()
+	if err != nil {
+		return 0, err
+	}
+	end, err := f.End()
+	return end.Sub(start), err
+}
+
+// IsAllDay reports whether the DTSTART of f is a DATE, which RFC 5545
+// does not allow.
+func (f *FreeBusy) IsAllDay() bool {
+	return f.DtStart != nil && isDate(f.DtStart.Value)
+}
+
+// Attendees returns the ATTENDEE properties of f.
+func (f *FreeBusy) Attendees() []*Participant {
+	return participants(f.Attendee)
+}
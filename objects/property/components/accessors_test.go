@@ -0,0 +1,133 @@
+package components
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_Accessors(t *testing.T) {
+	if _, err := (&Event{}).Start(); err != ErrNoStart {
+		t.Errorf("Start without DTSTART: %v", err)
+	}
+
+	e := &Event{
+		DtStart: datetime.NewDateStartWithDatetime(2024, 3, 30, 9, 0, 0),
+		DtEnd:   datetime.NewDateTimeDateEnd(2024, 3, 30, 10, 30, 0),
+	}
+	if start, err := e.Start(); err != nil || !start.Equal(time.Date(2024, 3, 30, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Start: %v %v", start, err)
+	}
+	if d, err := e.Length(); err != nil || d != 90*time.Minute {
+		t.Errorf("Length with DTEND: %v %v", d, err)
+	}
+	if e.IsAllDay() {
+		t.Errorf("IsAllDay of a DATE-TIME start")
+	}
+
+	// a duration of whole days keeps the wall clock across DST
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	start, _ := types.ParseDateTime("20240330T090000", time.Local)
+	e = &Event{
+		DtStart:  &datetime.DateStart{Parameters: []parameters.Parameter{&parameters.TimeZoneId{V: "Europe/Berlin"}}, Value: start},
+		Duration: &datetime.Duration{Value: types.NewDuration(24 * time.Hour)},
+	}
+	if got, err := e.Start(); err != nil || got.Location().String() != berlin.String() || got.Hour() != 9 {
+		t.Errorf("Start with TZID: %v %v", got, err)
+	}
+	if end, err := e.End(); err != nil || !end.Equal(time.Date(2024, 3, 31, 9, 0, 0, 0, berlin)) {
+		t.Errorf("End with DURATION: %v %v", end, err)
+	}
+	if d, _ := e.Length(); d != 23*time.Hour {
+		t.Errorf("Length across DST: %v", d)
+	}
+	e.DtStart.Parameters = []parameters.Parameter{&parameters.TimeZoneId{V: "Nowhere/Else"}}
+	if _, err := e.Start(); err == nil {
+		t.Errorf("Start with an unknown TZID succeeded")
+	}
+
+	e = &Event{DtStart: datetime.NewDateStartWithDate(2024, 12, 24)}
+	if !e.IsAllDay() {
+		t.Errorf("IsAllDay of a DATE start")
+	}
+	if d, err := e.Length(); err != nil || d != 24*time.Hour {
+		t.Errorf("Length of an all-day event: %v %v", d, err)
+	}
+	e = &Event{DtStart: datetime.NewDateStartWithDatetime(2024, 12, 24, 18, 0, 0)}
+	if d, err := e.Length(); err != nil || d != 0 {
+		t.Errorf("Length without an end: %v %v", d, err)
+	}
+}
+
+func TestEvent_Attendees(t *testing.T) {
+	chair := relationship.NewAttendee("mailto:alice@example.com")
+	chair.Parameters = []parameters.Parameter{
+		&parameters.CommonName{V: "Alice"},
+		&parameters.Chair,
+		&parameters.Accepted,
+	}
+	room := relationship.NewAttendee("mailto:room@example.com")
+	room.Parameters = []parameters.Parameter{&parameters.RoomCuType, &parameters.Rsvp{V: true}}
+	e := &Event{
+		Attendee: []*relationship.Attendee{chair, room},
+		Categories: []*descriptive.Categories{
+			descriptive.NewCategories("BUSINESS", types.EscapeText("Q3, budget")),
+			descriptive.NewCategories("MEETING"),
+		},
+	}
+	got := e.Attendees()
+	if len(got) != 2 {
+		t.Fatalf("Attendees: %+v", got)
+	}
+	if a := got[0]; a.Address != "mailto:alice@example.com" || a.CommonName != "Alice" || a.Role != "CHAIR" || a.PartStat != "ACCEPTED" || a.CuType != "INDIVIDUAL" || a.RSVP {
+		t.Errorf("Attendees[0]: %+v", a)
+	}
+	if a := got[1]; a.Role != "REQ-PARTICIPANT" || a.PartStat != "NEEDS-ACTION" || a.CuType != "ROOM" || !a.RSVP {
+		t.Errorf("Attendees[1]: %+v", a)
+	}
+	if c := strings.Join(e.CategoryList(), "|"); c != "BUSINESS|Q3, budget|MEETING" {
+		t.Errorf("CategoryList: %q", c)
+	}
+}
+
+func TestTodo_Accessors(t *testing.T) {
+	todo := &Todo{Due: datetime.NewDueWithDate(2024, 5, 1)}
+	if !todo.IsAllDay() {
+		t.Errorf("IsAllDay of a DATE due")
+	}
+	if end, err := todo.End(); err != nil || end.Day() != 1 {
+		t.Errorf("End without DTSTART: %v %v", end, err)
+	}
+	if _, err := todo.Length(); err != ErrNoStart {
+		t.Errorf("Length without DTSTART: %v", err)
+	}
+	todo = &Todo{
+		DtStart:  datetime.NewDateStartWithDatetime(2024, 5, 1, 8, 0, 0),
+		Duration: &datetime.Duration{Value: types.NewDuration(2 * time.Hour)},
+	}
+	if d, err := todo.Length(); err != nil || d != 2*time.Hour {
+		t.Errorf("Length with DURATION: %v %v", d, err)
+	}
+}
+
+func TestJournal_Accessors(t *testing.T) {
+	j := &Journal{DtStart: datetime.NewDateStartWithDate(2024, 5, 1)}
+	if d, err := j.Length(); err != nil || d != 24*time.Hour {
+		t.Errorf("Length of an all-day entry: %v %v", d, err)
+	}
+	f := &FreeBusy{
+		DtStart: datetime.NewDateStartWithDatetime(2024, 5, 1, 8, 0, 0),
+		DtEnd:   datetime.NewDateTimeDateEnd(2024, 5, 2, 8, 0, 0),
+	}
+	if d, err := f.Length(); err != nil || d != 24*time.Hour {
+		t.Errorf("Length of a free/busy: %v %v", d, err)
+	}
+}
@@ -0,0 +1,52 @@
+package components
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of c, which may be any component including
+// the ones of other packages, such as the raw components of objects.
+func Clone(c Component) Component {
+	if c == nil {
+		return nil
+	}
+	return properties.DeepCopy(c).(Component)
+}
+
+// Clone returns a deep copy of a.
+func (a *Alarm) Clone() *Alarm {
+	return properties.DeepCopy(a).(*Alarm)
+}
+
+// Clone returns a deep copy of d.
+func (d *DayLight) Clone() *DayLight {
+	return properties.DeepCopy(d).(*DayLight)
+}
+
+// Clone returns a deep copy of e, including its alarms.
+func (e *Event) Clone() *Event {
+	return properties.DeepCopy(e).(*Event)
+}
+
+// Clone returns a deep copy of f.
+func (f *FreeBusy) Clone() *FreeBusy {
+	return properties.DeepCopy(f).(*FreeBusy)
+}
+
+// Clone returns a deep copy of j.
+func (j *Journal) Clone() *Journal {
+	return properties.DeepCopy(j).(*Journal)
+}
+
+// Clone returns a deep copy of s.
+func (s *Standard) Clone() *Standard {
+	return properties.DeepCopy(s).(*Standard)
+}
+
+// Clone returns a deep copy of t, including its STANDARD and DAYLIGHT rules.
+func (t *TimeZone) Clone() *TimeZone {
+	return properties.DeepCopy(t).(*TimeZone)
+}
+
+// Clone returns a deep copy of t, including its alarms.
+func (t *Todo) Clone() *Todo {
+	return properties.DeepCopy(t).(*Todo)
+}
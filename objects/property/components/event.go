@@ -195,7 +195,7 @@ type Event struct {
 	RDate        []*recurrence.RDate
 	Xprop        []*miscellaneous.NoStandard
 	IanaProp     []*miscellaneous.Iana
-	Alarm        *Alarm
+	Alarm        []*Alarm
 }
 
 func (e *Event) Event(b *strings.Builder) error {
@@ -233,8 +233,8 @@ func (e *Event) Event(b *strings.Builder) error {
 	WriteProperties(b, e.RDate)
 	WriteProperties(b, e.Xprop)
 	WriteProperties(b, e.IanaProp)
-	if e.Alarm != nil {
-		e.Alarm.Alarm(b)
+	for _, a := range e.Alarm {
+		a.Alarm(b)
 	}
 	b.WriteString("END:VEVENT\n")
 	return nil
@@ -0,0 +1,18 @@
+package alarm
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of a.
+func (a *Action) Clone() *Action {
+	return properties.DeepCopy(a).(*Action)
+}
+
+// Clone returns a deep copy of r.
+func (r *Repeat) Clone() *Repeat {
+	return properties.DeepCopy(r).(*Repeat)
+}
+
+// Clone returns a deep copy of t.
+func (t *Trigger) Clone() *Trigger {
+	return properties.DeepCopy(t).(*Trigger)
+}
@@ -0,0 +1,23 @@
+package changemanage
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of c.
+func (c *Created) Clone() *Created {
+	return properties.DeepCopy(c).(*Created)
+}
+
+// Clone returns a deep copy of d.
+func (d *DtStamp) Clone() *DtStamp {
+	return properties.DeepCopy(d).(*DtStamp)
+}
+
+// Clone returns a deep copy of l.
+func (l *LastModified) Clone() *LastModified {
+	return properties.DeepCopy(l).(*LastModified)
+}
+
+// Clone returns a deep copy of s.
+func (s *Sequence) Clone() *Sequence {
+	return properties.DeepCopy(s).(*Sequence)
+}
@@ -0,0 +1,38 @@
+package datetime
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of s.
+func (s *Completed) Clone() *Completed {
+	return properties.DeepCopy(s).(*Completed)
+}
+
+// Clone returns a deep copy of d.
+func (d *DateEnd) Clone() *DateEnd {
+	return properties.DeepCopy(d).(*DateEnd)
+}
+
+// Clone returns a deep copy of d.
+func (d *DateStart) Clone() *DateStart {
+	return properties.DeepCopy(d).(*DateStart)
+}
+
+// Clone returns a deep copy of d.
+func (d *Due) Clone() *Due {
+	return properties.DeepCopy(d).(*Due)
+}
+
+// Clone returns a deep copy of d.
+func (d *Duration) Clone() *Duration {
+	return properties.DeepCopy(d).(*Duration)
+}
+
+// Clone returns a deep copy of f.
+func (f *FreeBusy) Clone() *FreeBusy {
+	return properties.DeepCopy(f).(*FreeBusy)
+}
+
+// Clone returns a deep copy of t.
+func (t *Transparent) Clone() *Transparent {
+	return properties.DeepCopy(t).(*Transparent)
+}
@@ -0,0 +1,63 @@
+package descriptive
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of a.
+func (a *Attach) Clone() *Attach {
+	return properties.DeepCopy(a).(*Attach)
+}
+
+// Clone returns a deep copy of c.
+func (c *Categories) Clone() *Categories {
+	return properties.DeepCopy(c).(*Categories)
+}
+
+// Clone returns a deep copy of c.
+func (c *Classification) Clone() *Classification {
+	return properties.DeepCopy(c).(*Classification)
+}
+
+// Clone returns a deep copy of c.
+func (c *Comment) Clone() *Comment {
+	return properties.DeepCopy(c).(*Comment)
+}
+
+// Clone returns a deep copy of d.
+func (d *Description) Clone() *Description {
+	return properties.DeepCopy(d).(*Description)
+}
+
+// Clone returns a deep copy of g.
+func (g *Geographic) Clone() *Geographic {
+	return properties.DeepCopy(g).(*Geographic)
+}
+
+// Clone returns a deep copy of l.
+func (l *Location) Clone() *Location {
+	return properties.DeepCopy(l).(*Location)
+}
+
+// Clone returns a deep copy of p.
+func (p *PercentComplete) Clone() *PercentComplete {
+	return properties.DeepCopy(p).(*PercentComplete)
+}
+
+// Clone returns a deep copy of p.
+func (p *Priority) Clone() *Priority {
+	return properties.DeepCopy(p).(*Priority)
+}
+
+// Clone returns a deep copy of r.
+func (r *Resources) Clone() *Resources {
+	return properties.DeepCopy(r).(*Resources)
+}
+
+// Clone returns a deep copy of s.
+func (s *Status) Clone() *Status {
+	return properties.DeepCopy(s).(*Status)
+}
+
+// Clone returns a deep copy of s.
+func (s *Summary) Clone() *Summary {
+	return properties.DeepCopy(s).(*Summary)
+}
@@ -96,6 +96,7 @@ func (g *Geographic) WritePropertyToStrBuilder(s *strings.Builder) error {
 	g.Values[0].WriteValueToStrBuilder(s)
 	s.WriteString(";")
 	g.Values[1].WriteValueToStrBuilder(s)
+	s.WriteString("\n")
 	return nil
 }
 
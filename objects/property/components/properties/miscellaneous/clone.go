@@ -0,0 +1,18 @@
+package miscellaneous
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of i.
+func (i *Iana) Clone() *Iana {
+	return properties.DeepCopy(i).(*Iana)
+}
+
+// Clone returns a deep copy of n.
+func (n *NoStandard) Clone() *NoStandard {
+	return properties.DeepCopy(n).(*NoStandard)
+}
+
+// Clone returns a deep copy of r.
+func (r *RequestStatus) Clone() *RequestStatus {
+	return properties.DeepCopy(r).(*RequestStatus)
+}
@@ -0,0 +1,18 @@
+package recurrence
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of a.
+func (a *ExDate) Clone() *ExDate {
+	return properties.DeepCopy(a).(*ExDate)
+}
+
+// Clone returns a deep copy of r.
+func (r *RDate) Clone() *RDate {
+	return properties.DeepCopy(r).(*RDate)
+}
+
+// Clone returns a deep copy of r.
+func (r *RRule) Clone() *RRule {
+	return properties.DeepCopy(r).(*RRule)
+}
@@ -0,0 +1,38 @@
+package relationship
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of a.
+func (a *Attendee) Clone() *Attendee {
+	return properties.DeepCopy(a).(*Attendee)
+}
+
+// Clone returns a deep copy of c.
+func (c *Contact) Clone() *Contact {
+	return properties.DeepCopy(c).(*Contact)
+}
+
+// Clone returns a deep copy of c.
+func (c *Organizer) Clone() *Organizer {
+	return properties.DeepCopy(c).(*Organizer)
+}
+
+// Clone returns a deep copy of c.
+func (c *RecurrenceId) Clone() *RecurrenceId {
+	return properties.DeepCopy(c).(*RecurrenceId)
+}
+
+// Clone returns a deep copy of r.
+func (r *RelatedTo) Clone() *RelatedTo {
+	return properties.DeepCopy(r).(*RelatedTo)
+}
+
+// Clone returns a deep copy of u.
+func (u *Uid) Clone() *Uid {
+	return properties.DeepCopy(u).(*Uid)
+}
+
+// Clone returns a deep copy of r.
+func (r *Url) Clone() *Url {
+	return properties.DeepCopy(r).(*Url)
+}
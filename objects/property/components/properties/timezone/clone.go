@@ -0,0 +1,28 @@
+package timezone
+
+import "github.com/mmsuo/vcalender/objects/property/components/properties"
+
+// Clone returns a deep copy of t.
+func (t *TzId) Clone() *TzId {
+	return properties.DeepCopy(t).(*TzId)
+}
+
+// Clone returns a deep copy of t.
+func (t *TzName) Clone() *TzName {
+	return properties.DeepCopy(t).(*TzName)
+}
+
+// Clone returns a deep copy of t.
+func (t *TzOffsetFrom) Clone() *TzOffsetFrom {
+	return properties.DeepCopy(t).(*TzOffsetFrom)
+}
+
+// Clone returns a deep copy of t.
+func (t *TzOffsetTo) Clone() *TzOffsetTo {
+	return properties.DeepCopy(t).(*TzOffsetTo)
+}
+
+// Clone returns a deep copy of t.
+func (t *TzUrl) Clone() *TzUrl {
+	return properties.DeepCopy(t).(*TzUrl)
+}
@@ -0,0 +1,309 @@
+package components
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/timezone"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"time"
+)
+
+// transitions of a VTIMEZONE are computed up to this year
+const locationLastYear = 2100
+
+type transition struct {
+	at   int64
+	zone int
+}
+
+type zoneType struct {
+	offset int
+	dst    bool
+	name   string
+}
+
+// Location returns a time.Location that follows the STANDARD and
+// DAYLIGHT observances of t.  It is meant for TZIDs that the time zone
+// database does not know, such as the Windows names written by some
+// clients.
+func (t *TimeZone) Location() (*time.Location, error) {
+	if t.TzId == nil || t.TzId.Value == nil {
+		return nil, fmt.Errorf("VTIMEZONE without TZID")
+	}
+	var zones []zoneType
+	var trans []transition
+	add := func(p *TzProp, dst bool) error {
+		if p == nil || p.DtStart == nil || p.TzOffsetFrom == nil || p.TzOffsetTo == nil ||
+			p.TzOffsetFrom.Value == nil || p.TzOffsetTo.Value == nil {
+			return fmt.Errorf("observance of %s without DTSTART, TZOFFSETFROM or TZOFFSETTO", t.TzId.Value.V)
+		}
+		start, ok := p.DtStart.Value.(*types.DateTime)
+		if !ok {
+			return fmt.Errorf("observance of %s with a DTSTART that is not a DATE-TIME", t.TzId.Value.V)
+		}
+		z := zoneType{offset: p.TzOffsetTo.Value.Seconds(), dst: dst}
+		if len(p.TzName) > 0 && p.TzName[0].Value != nil {
+			z.name = p.TzName[0].Value.V
+		} else {
+			s := &bytes.Buffer{}
+			fmt.Fprintf(s, "%+03d%02d", z.offset/3600, abs(z.offset)/60%60)
+			z.name = s.String()
+		}
+		zones = append(zones, z)
+		index := len(zones) - 1
+		from := int64(p.TzOffsetFrom.Value.Seconds())
+		// onsets are local times before the transition; they are expanded
+		// as floating times in UTC so that no other rules interfere
+		w := start.V
+		wall := &types.DateTime{
+			V:      time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, time.UTC),
+			Format: types.LocalDateTimeFormat,
+		}
+		onset := func(v time.Time) {
+			trans = append(trans, transition{at: v.Unix() - from, zone: index})
+		}
+		onset(wall.V)
+		if p.RRule != nil && p.RRule.V != nil {
+			it, err := p.RRule.V.Iterator(wall)
+			if err != nil {
+				return err
+			}
+			for {
+				v, ok := it.Next()
+				if !ok || v.Year() > locationLastYear {
+					break
+				}
+				onset(v)
+			}
+		}
+		for _, r := range p.RDate {
+			for _, v := range r.Values {
+				if d, ok := v.(*types.DateTime); ok {
+					x := d.V
+					onset(time.Date(x.Year(), x.Month(), x.Day(), x.Hour(), x.Minute(), x.Second(), 0, time.UTC))
+				}
+			}
+		}
+		return nil
+	}
+	for _, s := range t.Standard {
+		if err := add(s.TzProp, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range t.DayLight {
+		if err := add(d.TzProp, true); err != nil {
+			return nil, err
+		}
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("VTIMEZONE %s has no observances", t.TzId.Value.V)
+	}
+	sort.SliceStable(trans, func(i, j int) bool { return trans[i].at < trans[j].at })
+	return time.LoadLocationFromTZData(t.TzId.Value.V, tzif(zones, trans))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// tzif encodes zones and transitions as version 2 TZif data, the format
+// read by time.LoadLocationFromTZData.  The version 1 block is left
+// empty, which readers of version 2 data skip.
+func tzif(zones []zoneType, trans []transition) []byte {
+	b := &bytes.Buffer{}
+	header := func(timecnt, typecnt, charcnt int) {
+		b.WriteString("TZif2")
+		b.Write(make([]byte, 15))
+		for _, n := range []int{0, 0, 0, timecnt, typecnt, charcnt} {
+			binary.Write(b, binary.BigEndian, uint32(n))
+		}
+	}
+	header(0, 0, 0)
+	var chars bytes.Buffer
+	index := map[string]int{}
+	for _, z := range zones {
+		if _, ok := index[z.name]; !ok {
+			index[z.name] = chars.Len()
+			chars.WriteString(z.name)
+			chars.WriteByte(0)
+		}
+	}
+	header(len(trans), len(zones), chars.Len())
+	for _, t := range trans {
+		binary.Write(b, binary.BigEndian, t.at)
+	}
+	for _, t := range trans {
+		b.WriteByte(byte(t.zone))
+	}
+	for _, z := range zones {
+		binary.Write(b, binary.BigEndian, int32(z.offset))
+		if z.dst {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+		b.WriteByte(byte(index[z.name]))
+	}
+	b.Write(chars.Bytes())
+	b.WriteString("\n\n")
+	return b.Bytes()
+}
+
+// zoneChange is a change of the UTC offset of a location.
+type zoneChange struct {
+	at       time.Time
+	from, to int
+	name     string
+}
+
+// zoneChanges returns the changes of the offset of loc in [start, end).
+func zoneChanges(loc *time.Location, start, end time.Time) []zoneChange {
+	offset := func(sec int64) int {
+		_, off := time.Unix(sec, 0).In(loc).Zone()
+		return off
+	}
+	// offsets change at most a few times a year, so the changes are
+	// looked for in steps and then narrowed to the second
+	const step = 6 * 3600
+	var changes []zoneChange
+	prev := offset(start.Unix())
+	for t := start.Unix(); t < end.Unix(); t += step {
+		off := offset(t + step)
+		if off == prev {
+			continue
+		}
+		lo, hi := t, t+step
+		for hi-lo > 1 {
+			if mid := (lo + hi) / 2; offset(mid) == prev {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		at := time.Unix(hi, 0).In(loc)
+		name, _ := at.Zone()
+		changes = append(changes, zoneChange{at: at, from: prev, to: off, name: name})
+		prev = off
+	}
+	return changes
+}
+
+// yearly returns the occurrence in year of the change c, moved by the
+// rule "the same weekday of the same week of the month" that daylight
+// saving time follows.  last is the last such weekday of the month.
+func (c zoneChange) yearly(year int, last bool) time.Time {
+	wall := c.at.In(time.FixedZone("", c.from))
+	first := time.Date(year, wall.Month(), 1, wall.Hour(), wall.Minute(), wall.Second(), 0, time.FixedZone("", c.from))
+	d := first.AddDate(0, 0, (int(wall.Weekday())-int(first.Weekday())+7)%7+(wall.Day()-1)/7*7)
+	if last {
+		d = first.AddDate(0, 1, -1)
+		d = d.AddDate(0, 0, -((int(d.Weekday()) - int(wall.Weekday()) + 7) % 7))
+	}
+	return d
+}
+
+// NewTimeZone returns a VTIMEZONE with the TZID of loc that follows loc
+// from the year before from.  When the changes of the offset of that
+// year repeat in the next years, as those of daylight saving time do,
+// each is an observance with a yearly RRULE.  Otherwise each change of
+// the following three years is an observance of its own, and the last
+// one stays in effect.
+func NewTimeZone(loc *time.Location, from time.Time) *TimeZone {
+	tz := &TimeZone{TzId: timezone.NewTzId(loc.String())}
+	year := from.In(loc).Year() - 1
+	yearOf := func(c zoneChange) int { return c.at.In(time.FixedZone("", c.from)).Year() }
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	changes := zoneChanges(loc, start, start.AddDate(3, 0, 0))
+	_, low := start.Zone()
+	for _, c := range changes {
+		if c.to < low {
+			low = c.to
+		}
+	}
+
+	observance := func(c zoneChange, rule *types.RecurRule) {
+		wall := c.at.In(time.FixedZone("", c.from))
+		p := &TzProp{
+			DtStart: &datetime.DateStart{Value: &types.DateTime{
+				V:      time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC),
+				Format: types.LocalDateTimeFormat,
+			}},
+			TzOffsetFrom: &timezone.TzOffsetFrom{Value: utcOffset(c.from)},
+			TzOffsetTo:   &timezone.TzOffsetTo{Value: utcOffset(c.to)},
+			TzName:       []*timezone.TzName{timezone.NewTzName(c.name)},
+		}
+		if rule != nil {
+			p.RRule = &recurrence.RRule{V: rule}
+		}
+		// of the offsets a location switches between, the larger ones are
+		// daylight saving time
+		if c.to > low {
+			tz.DayLight = append(tz.DayLight, &DayLight{TzProp: p})
+		} else {
+			tz.Standard = append(tz.Standard, &Standard{TzProp: p})
+		}
+	}
+
+	if len(changes) == 0 {
+		name, off := start.Zone()
+		observance(zoneChange{at: time.Date(1970, 1, 1, 0, 0, 0, 0, time.FixedZone("", off)), from: off, to: off, name: name}, nil)
+		return tz
+	}
+	var first []zoneChange
+	for _, c := range changes {
+		if yearOf(c) == year {
+			first = append(first, c)
+		}
+	}
+	rules := make([]*types.RecurRule, len(first))
+	for i, c := range first {
+		wall := c.at.In(time.FixedZone("", c.from))
+		last := wall.AddDate(0, 0, 7).Month() != wall.Month()
+		for _, y := range []int{year + 1, year + 2} {
+			next := c.yearly(y, last)
+			found := false
+			for _, d := range changes {
+				found = found || d.at.Equal(next) && d.from == c.from && d.to == c.to
+			}
+			if !found {
+				rules = nil
+				break
+			}
+		}
+		if rules == nil {
+			break
+		}
+		n := &types.WeekDayNum{WeekDay: weekDays[wall.Weekday()], OrdWk: (wall.Day()-1)/7 + 1}
+		if last {
+			n.Operator, n.OrdWk = types.Minus, 1
+		}
+		rules[i] = &types.RecurRule{Frequency: types.FreqYearly, Rules: []types.Rule{
+			&types.ByMonth{V: []int{int(wall.Month())}},
+			&types.ByDay{V: []*types.WeekDayNum{n}},
+		}}
+	}
+	if len(first) == 0 || rules == nil || len(changes) != 3*len(first) {
+		for _, c := range changes {
+			observance(c, nil)
+		}
+		return tz
+	}
+	for i, c := range first {
+		observance(c, rules[i])
+	}
+	return tz
+}
+
+var weekDays = [...]types.WeekDay{types.Sunday, types.Monday, types.Tuesday, types.Wednesday, types.Thursday, types.Friday, types.Saturday}
+
+func utcOffset(sec int) *types.UTCOffset {
+	return types.NewUTCOffset(sec >= 0, abs(sec)/3600, abs(sec)/60%60, abs(sec)%60)
+}
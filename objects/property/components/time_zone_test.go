@@ -8,6 +8,7 @@ import (
 	"github.com/mmsuo/vcalender/objects/property/types"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestTimeZone_TimeZone(t *testing.T) {
@@ -266,3 +267,40 @@ func TestTimeZone_TimeZone(t *testing.T) {
 	v.ContainsOrError("END:VTIMEZONE")
 
 }
+
+func TestNewTimeZone(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"Europe/Berlin", "America/New_York", "Australia/Sydney", "Asia/Tokyo", "Asia/Kolkata", "America/Sao_Paulo"} {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tz := NewTimeZone(loc, from)
+		got, err := tz.Location()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for at := from.AddDate(-1, 0, 0); at.Year() < 2040; at = at.Add(7 * time.Hour) {
+			_, want := at.In(loc).Zone()
+			if _, off := at.In(got).Zone(); off != want {
+				b := &strings.Builder{}
+				tz.TimeZone(b)
+				t.Errorf("%s at %v: offset %d, want %d\n%s", name, at, off, want, b)
+				break
+			}
+		}
+	}
+
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	b := &strings.Builder{}
+	NewTimeZone(loc, from).TimeZone(b)
+	for _, want := range []string{
+		"TZID:Europe/Berlin\n",
+		"BEGIN:DAYLIGHT\nDTSTART:20230326T020000\nTZOFFSETTO:+0200\nTZOFFSETFROM:+0100\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\nTZNAME:CEST\n",
+		"BEGIN:STANDARD\nDTSTART:20231029T030000\nTZOFFSETTO:+0100\nTZOFFSETFROM:+0200\nRRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\nTZNAME:CET\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("VTIMEZONE of Europe/Berlin misses %q:\n%s", want, b)
+		}
+	}
+}
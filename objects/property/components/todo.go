@@ -140,7 +140,7 @@ type Todo struct {
 	RDate      []*recurrence.RDate
 	Xprop      []*miscellaneous.NoStandard
 	IanaProp   []*miscellaneous.Iana
-	Alarm      *Alarm
+	Alarm      []*Alarm
 }
 
 func (t *Todo) Todo(b *strings.Builder) error {
@@ -179,8 +179,8 @@ func (t *Todo) Todo(b *strings.Builder) error {
 	WriteProperties(b, t.RDate)
 	WriteProperties(b, t.Xprop)
 	WriteProperties(b, t.IanaProp)
-	if t.Alarm != nil {
-		t.Alarm.Alarm(b)
+	for _, a := range t.Alarm {
+		a.Alarm(b)
 	}
 	b.WriteString("END:VTODO\n")
 	return nil
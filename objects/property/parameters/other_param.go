@@ -0,0 +1,43 @@
+package parameters
+
+import "strings"
+
+//   Format Definition:  Property parameters that are not defined by this
+//      document are allowed on any property:
+//
+//       other-param   = (iana-param / x-param)
+//
+//       iana-param  = iana-token "=" param-value *("," param-value)
+//       ; Some other IANA-registered iCalendar parameter.
+//
+//       x-param     = x-name "=" param-value *("," param-value)
+//       ; A non-standard, experimental parameter.
+//
+//   Description:  Applications MUST ignore x-param and iana-param values
+//      they don't recognize, but SHOULD preserve them when the property
+//      is written again.
+//
+//   Example:
+//
+//       ATTENDEE;X-NUM-GUESTS=2:mailto:jsmith@example.com
+
+type OtherParam struct {
+	Name string
+	V    []string
+}
+
+func (o *OtherParam) WriteParameterToStrBuilder(s *strings.Builder) error {
+	s.WriteString(o.Name)
+	s.WriteString("=")
+	for index, v := range o.V {
+		if index != 0 {
+			s.WriteString(",")
+		}
+		if strings.ContainsAny(v, ";:,") {
+			s.WriteString("\"" + v + "\"")
+		} else {
+			s.WriteString(v)
+		}
+	}
+	return nil
+}
@@ -77,7 +77,7 @@ type PartStat struct {
 	V string
 }
 
-var NeedAction = PartStat{"NEED_ACTION"}
+var NeedAction = PartStat{"NEEDS-ACTION"}
 var Accepted = PartStat{"ACCEPTED"}
 var Declined = PartStat{"DECLINED"}
 var Tentative = PartStat{"TENTATIVE"}
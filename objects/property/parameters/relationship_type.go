@@ -48,6 +48,17 @@ var Parent = RelType{"PARENT"}
 var Child = RelType{"CHILD"}
 var Sibling = RelType{"SIBLING"}
 
+//   RFC 9253 5.  Updates to RFC 5545
+//
+//      FIRST:  Indicates that the referenced calendar component is the
+//         first in a series the referenced calendar component is part of.
+//
+//      NEXT:  Indicates that the referenced calendar component is the
+//         next in a series the referenced calendar component is part of.
+
+var First = RelType{"FIRST"}
+var Next = RelType{"NEXT"}
+
 func (r *RelType) WriteParameterToStrBuilder(s *strings.Builder) error {
 	s.WriteString(fmt.Sprintf("RELTYPE=%s", r.V))
 	return nil
@@ -0,0 +1,46 @@
+package parameters
+
+import (
+	"fmt"
+	"strings"
+)
+
+//   Parameter Name:  SCHEDULE-AGENT
+//
+//   Purpose:  To specify the agent expected to deliver scheduling
+//      messages to the corresponding "ORGANIZER" or "ATTENDEE" property.
+//
+//   Format Definition:  This property parameter is defined by the
+//      following notation:
+//
+//       scheduleagentparam = "SCHEDULE-AGENT" "="
+//                           ("SERVER"       ; The server handles scheduling
+//                          / "CLIENT"       ; The client handles scheduling
+//                          / "NONE"         ; No automatic scheduling
+//                          / x-name         ; Experimental type
+//                          / iana-token)    ; Other IANA-registered type
+//                          ; Default is SERVER
+//
+//   Description:  This parameter specifies what agent is expected to
+//      deliver scheduling messages to the corresponding Organizer or
+//      Attendee.  If this parameter is not specified, then the default
+//      value "SERVER" is assumed.
+//
+//   Example:
+//
+//       ORGANIZER;SCHEDULE-AGENT=SERVER:mailto:bernard@example.com
+//
+//       ATTENDEE;SCHEDULE-AGENT=NONE:mailto:cyrus@example.com
+
+type ScheduleAgent struct {
+	V string
+}
+
+var ScheduleAgentServer = ScheduleAgent{"SERVER"}
+var ScheduleAgentClient = ScheduleAgent{"CLIENT"}
+var ScheduleAgentNone = ScheduleAgent{"NONE"}
+
+func (a *ScheduleAgent) WriteParameterToStrBuilder(s *strings.Builder) error {
+	s.WriteString(fmt.Sprintf("SCHEDULE-AGENT=%s", a.V))
+	return nil
+}
@@ -0,0 +1,40 @@
+package parameters
+
+import (
+	"fmt"
+	"strings"
+)
+
+//   Parameter Name:  SCHEDULE-FORCE-SEND
+//
+//   Purpose:  To force a scheduling message to be sent to the calendar
+//      user specified by the property.
+//
+//   Format Definition:  This property parameter is defined by the
+//      following notation:
+//
+//       scheduleforcesendparam = "SCHEDULE-FORCE-SEND" "="
+//                               ("REQUEST"     ; Force a "REQUEST"
+//                              / "REPLY"       ; Force a "REPLY"
+//                              / iana-token)   ; IANA-registered method
+//
+//   Description:  This parameter is used in a calendar object resource
+//      stored by a client to ask the server to send a scheduling message
+//      even though it would not do so on its own, for instance to resend
+//      an invitation.  Servers do not store it.
+//
+//   Example:
+//
+//       ATTENDEE;SCHEDULE-FORCE-SEND=REQUEST:mailto:cyrus@example.com
+
+type ScheduleForceSend struct {
+	V string
+}
+
+var ForceSendRequest = ScheduleForceSend{"REQUEST"}
+var ForceSendReply = ScheduleForceSend{"REPLY"}
+
+func (f *ScheduleForceSend) WriteParameterToStrBuilder(s *strings.Builder) error {
+	s.WriteString(fmt.Sprintf("SCHEDULE-FORCE-SEND=%s", f.V))
+	return nil
+}
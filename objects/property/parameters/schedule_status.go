@@ -0,0 +1,55 @@
+package parameters
+
+import (
+	"strings"
+)
+
+//   Parameter Name:  SCHEDULE-STATUS
+//
+//   Purpose:  To specify the status codes returned from processing of
+//      the most recent scheduling message sent to the corresponding
+//      "ATTENDEE" property, or received from the corresponding
+//      "ORGANIZER" property.
+//
+//   Format Definition:  This property parameter is defined by the
+//      following notation:
+//
+//       schedulestatusparam = "SCHEDULE-STATUS" "="
+//                            (statcode / DQUOTE statcode
+//                            *("," statcode) DQUOTE)
+//                            ; statcode defined in Section 3.8.8.3 of
+//                            ; [RFC5545]
+//
+//   Description:  This parameter is used to indicate the status of the
+//      most recent scheduling message sent to an Attendee or received
+//      from the Organizer.  It is set by servers; values stored by
+//      clients are ignored.
+//
+//   Example:
+//
+//       ATTENDEE;SCHEDULE-STATUS="1.2":mailto:cyrus@example.com
+
+type ScheduleStatus struct {
+	V []string
+}
+
+// status codes of RFC 6638 3.2.9
+const (
+	ScheduleStatusPending   = "1.0"
+	ScheduleStatusSent      = "1.1"
+	ScheduleStatusDelivered = "1.2"
+	ScheduleStatusInvalid   = "3.7"
+	ScheduleStatusNoAuth    = "3.8"
+	ScheduleStatusFailed    = "5.1"
+	ScheduleStatusNoSupport = "5.3"
+)
+
+func (st *ScheduleStatus) WriteParameterToStrBuilder(s *strings.Builder) error {
+	s.WriteString("SCHEDULE-STATUS=")
+	v := strings.Join(st.V, ",")
+	if len(st.V) > 1 {
+		v = "\"" + v + "\""
+	}
+	s.WriteString(v)
+	return nil
+}
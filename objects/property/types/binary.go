@@ -24,11 +24,14 @@ func (b *Binary) binary(s *strings.Builder) error {
 
 	buf := new(bytes.Buffer)
 	w := base64.NewEncoder(base64.StdEncoding, buf)
-	defer w.Close()
 	_, err := w.Write(b.V)
 	if err != nil {
 		return err
 	}
+	// the encoder holds back the last partial block until it is closed
+	if err := w.Close(); err != nil {
+		return err
+	}
 	s.WriteString(buf.String())
 	return nil
 }
@@ -33,6 +33,7 @@ var (
 func (b Boolean) WriteValueToStrBuilder(s *strings.Builder) error {
 	if b.V {
 		s.WriteString("TRUE")
+		return nil
 	}
 	s.WriteString("FALSE")
 	return nil
@@ -30,7 +30,11 @@ type CalAddress struct {
 }
 
 func (c *CalAddress) WriteValueToStrBuilder(s *strings.Builder) error {
-	s.WriteString("mailto:")
+	// bare addresses are e-mail addresses; ones read from a stream
+	// already carry their scheme
+	if !strings.Contains(c.V.V, ":") {
+		s.WriteString("mailto:")
+	}
 	c.V.WriteValueToStrBuilder(s)
 	return nil
 }
@@ -49,3 +49,12 @@ func NewDate(year, month, day int) *Date {
 		time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local),
 	}
 }
+
+// ParseDate parses a DATE value such as "19970714".
+func ParseDate(s string) (*Date, error) {
+	t, err := time.ParseInLocation("20060102", s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &Date{V: t}, nil
+}
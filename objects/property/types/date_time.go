@@ -162,3 +162,23 @@ func NewLocalDateTime(year, month, day, hour, minute, seconds int) *DateTime {
 		V:      time.Date(year, time.Month(month), day, hour, minute, seconds, 0, time.Local),
 	}
 }
+
+// ParseDateTime parses a DATE-TIME value in the UTC or the local form.
+// Local values are resolved in loc, or in time.Local when loc is nil.
+func ParseDateTime(s string, loc *time.Location) (*DateTime, error) {
+	if strings.HasSuffix(s, "Z") {
+		t, err := time.ParseInLocation(UTCDateTimeFormat, s, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		return &DateTime{V: t, Format: UTCDateTimeFormat}, nil
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	t, err := time.ParseInLocation(LocalDateTimeFormat, s, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &DateTime{V: t, Format: LocalDateTimeFormat}, nil
+}
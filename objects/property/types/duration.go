@@ -2,7 +2,9 @@ package types
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //   V Name:  DURATION
@@ -79,6 +81,10 @@ func (d *Duration) duration(s *strings.Builder) {
 		s.WriteString("W")
 		return
 	}
+	if d.DurDay == 0 && d.DurHour == 0 && d.DurMinute == 0 && d.DurSecond == 0 {
+		s.WriteString("T0S")
+		return
+	}
 	if d.DurDay != 0 {
 		s.WriteString(fmt.Sprint(d.DurDay))
 		s.WriteString("D")
@@ -97,3 +103,100 @@ func (d *Duration) duration(s *strings.Builder) {
 		}
 	}
 }
+
+// Duration returns d as a time.Duration, counting days as 24 hours.
+func (d *Duration) Duration() time.Duration {
+	v := time.Duration(d.DurWeek)*7*24*time.Hour +
+		time.Duration(d.DurDay)*24*time.Hour +
+		time.Duration(d.DurHour)*time.Hour +
+		time.Duration(d.DurMinute)*time.Minute +
+		time.Duration(d.DurSecond)*time.Second
+	if d.Negative {
+		return -v
+	}
+	return v
+}
+
+// NewDuration returns the DURATION value of v.  Whole days are written as
+// days, and seconds are truncated.
+func NewDuration(v time.Duration) *Duration {
+	d := &Duration{}
+	if v < 0 {
+		d.Negative = true
+		v = -v
+	}
+	secs := int(v / time.Second)
+	d.DurDay = secs / 86400
+	d.DurHour = secs / 3600 % 24
+	d.DurMinute = secs / 60 % 60
+	d.DurSecond = secs % 60
+	if d.DurDay%7 == 0 && d.DurDay != 0 && d.DurHour == 0 && d.DurMinute == 0 && d.DurSecond == 0 {
+		d.DurWeek = d.DurDay / 7
+		d.DurDay = 0
+	}
+	return d
+}
+
+// ParseDuration parses a DURATION value such as "-P1DT2H" or "P2W".
+func ParseDuration(s string) (*Duration, error) {
+	d := &Duration{}
+	v := s
+	switch {
+	case strings.HasPrefix(v, "-"):
+		d.Negative = true
+		v = v[1:]
+	case strings.HasPrefix(v, "+"):
+		v = v[1:]
+	}
+	if !strings.HasPrefix(v, "P") || len(v) == 1 {
+		return nil, fmt.Errorf("invalid duration %q", s)
+	}
+	v = v[1:]
+	inTime := false
+	seen := ""
+	for len(v) > 0 {
+		if v[0] == 'T' && !inTime {
+			inTime = true
+			v = v[1:]
+			if len(v) == 0 {
+				return nil, fmt.Errorf("invalid duration %q", s)
+			}
+			continue
+		}
+		i := 0
+		for i < len(v) && v[i] >= '0' && v[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(v) {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.Atoi(v[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		unit := v[i]
+		if strings.IndexByte(seen, unit) >= 0 {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		seen += string(unit)
+		switch {
+		case unit == 'W' && !inTime:
+			d.DurWeek = n
+		case unit == 'D' && !inTime:
+			d.DurDay = n
+		case unit == 'H' && inTime:
+			d.DurHour = n
+		case unit == 'M' && inTime:
+			d.DurMinute = n
+		case unit == 'S' && inTime:
+			d.DurSecond = n
+		default:
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		v = v[i+1:]
+	}
+	if d.DurWeek != 0 && len(seen) > 1 {
+		return nil, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
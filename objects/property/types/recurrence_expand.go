@@ -0,0 +1,769 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mmsuo/vcalender/objects/property/types/rscale"
+)
+
+// RecurIterator yields the instances of a recurrence rule in order.
+//
+// The rule is evaluated as described in RFC 5545 Section 3.3.10: the
+// FREQ and INTERVAL rule parts select a period, the BYxxx rule parts
+// expand or limit the days and times of that period, then BYSETPOS, and
+// finally COUNT and UNTIL are applied.  With a RSCALE rule part (RFC
+// 7529) the periods, months and days are those of the named calendar
+// system, and SKIP decides what happens to instances falling on days
+// that do not exist.
+//
+// Instances before DTSTART are never returned.  DTSTART itself is only
+// returned when it matches the rule; adding it to the recurrence set is
+// up to the caller.  Either way DTSTART counts as the first instance
+// toward COUNT.
+type RecurIterator struct {
+	spec   recurSpec
+	start  time.Time
+	loc    *time.Location
+	allDay bool
+
+	startFixed int
+	year       int // YEARLY, MONTHLY: current year of the calendar system
+	month      int // MONTHLY: index into the months of year
+	fixed      int // WEEKLY, DAILY and shorter: first day of the period
+	sec        int // HOURLY and shorter: second of the day of the period
+	lastHit    int
+	buf        []time.Time
+	emitted    int
+	started    bool // DTSTART was counted
+	done       bool
+	err        error
+}
+
+type weekdayNum struct {
+	weekday time.Weekday
+	n       int
+}
+
+type recurSpec struct {
+	freq       Frequency
+	interval   int
+	count      int
+	hasUntil   bool
+	until      time.Time
+	untilFixed int
+	bySecond   []int
+	byMinute   []int
+	byHour     []int
+	byDay      []weekdayNum
+	byMonthDay []int
+	byYearDay  []int
+	byWeekNo   []int
+	byMonth    []rscale.Month
+	bySetpos   []int
+	wkst       time.Weekday
+	cal        rscale.Calendar
+	skip       Skip
+}
+
+// ErrUnsupportedRScale is returned for a RSCALE naming a calendar system
+// without an implementation in package rscale.
+var ErrUnsupportedRScale = errors.New("unsupported RSCALE")
+
+// ErrRScaleRange ends the instances of a rule that runs past the years
+// its RSCALE calendar system supports, such as those of the Chinese
+// calendar.
+var ErrRScaleRange = errors.New("recurrence runs past the years its RSCALE supports")
+
+// give up on rules that stop producing instances, e.g. BYMONTHDAY=30
+// with BYMONTH=2, after 400 years without an instance
+const maxEmptyDays = 146097
+
+var weekdays = map[WeekDay]time.Weekday{
+	Sunday:    time.Sunday,
+	Monday:    time.Monday,
+	Tuesday:   time.Tuesday,
+	Wednesday: time.Wednesday,
+	Thursday:  time.Thursday,
+	Friday:    time.Friday,
+	Saturday:  time.Saturday,
+}
+
+// Weekday returns the time.Weekday of w.
+func (w WeekDay) Weekday() time.Weekday {
+	return weekdays[w]
+}
+
+func signed(o Operator, v int) int {
+	if o == Minus {
+		return -v
+	}
+	return v
+}
+
+// Time returns the instant of d.  A value written with the UTC format
+// holds its UTC wall clock in V whatever the location of V is, so it is
+// read as UTC; any other value keeps the location of V, which is the
+// time zone it was resolved in.
+func (d *DateTime) Time() time.Time {
+	if d.IsUTC() {
+		y, m, day := d.V.Date()
+		h, min, s := d.V.Clock()
+		return time.Date(y, m, day, h, min, s, d.V.Nanosecond(), time.UTC)
+	}
+	return d.V
+}
+
+// IsUTC reports whether d is a date with UTC time.
+func (d *DateTime) IsUTC() bool {
+	return d.Format == UTCDateTimeFormat || d.Format == ""
+}
+
+// Time returns midnight at the start of d in the location of V.
+func (d *Date) Time() time.Time {
+	y, m, day := d.V.Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, d.V.Location())
+}
+
+// Iterator returns an iterator over the instances of r for the given
+// DTSTART, which must be a *Date or a *DateTime.  Instances are returned
+// in the location of dtstart; for a *Date they fall on midnight and the
+// BYHOUR, BYMINUTE and BYSECOND rule parts are ignored.
+func (r *RecurRule) Iterator(dtstart Value) (*RecurIterator, error) {
+	it := &RecurIterator{}
+	switch v := dtstart.(type) {
+	case *DateTime:
+		it.start = v.Time()
+	case *Date:
+		it.start = v.Time()
+		it.allDay = true
+	default:
+		return nil, fmt.Errorf("recurrence rule needs a DATE or DATE-TIME start, got %T", dtstart)
+	}
+	it.loc = it.start.Location()
+	if err := it.spec.init(r, it); err != nil {
+		return nil, err
+	}
+	it.startFixed = rscale.FixedFromTime(it.start)
+	it.lastHit = it.startFixed
+	d := it.spec.cal.FromFixed(it.startFixed)
+	if len(it.spec.cal.Months(d.Year)) == 0 {
+		return nil, fmt.Errorf("%s calendar does not support %s", it.spec.cal.Name(), it.start.Format("2006-01-02"))
+	}
+	it.spec.defaults(it, d)
+
+	it.year = d.Year
+	for i, m := range it.spec.cal.Months(d.Year) {
+		if m == d.Month {
+			it.month = i
+		}
+	}
+	switch it.spec.freq {
+	case FreqWeekly:
+		it.fixed = it.startFixed - int((rscale.Weekday(it.startFixed)-it.spec.wkst+7)%7)
+	default:
+		it.fixed = it.startFixed
+	}
+	h, m, s := it.start.Clock()
+	switch it.spec.freq {
+	case FreqHourly:
+		it.sec = h * 3600
+	case FreqMinutely:
+		it.sec = h*3600 + m*60
+	case FreqSecondly:
+		it.sec = h*3600 + m*60 + s
+	}
+	return it, nil
+}
+
+// Between returns the instances of r starting at or after after and
+// before before.
+func (r *RecurRule) Between(dtstart Value, after, before time.Time) ([]time.Time, error) {
+	it, err := r.Iterator(dtstart)
+	if err != nil {
+		return nil, err
+	}
+	var ts []time.Time
+	for {
+		t, ok := it.Next()
+		if !ok {
+			return ts, it.Err()
+		}
+		if !t.Before(before) {
+			return ts, nil
+		}
+		if !t.Before(after) {
+			ts = append(ts, t)
+		}
+	}
+}
+
+func (s *recurSpec) init(r *RecurRule, it *RecurIterator) error {
+	s.freq = r.Frequency
+	s.interval = 1
+	s.wkst = time.Monday
+	s.cal = rscale.Gregorian
+	s.skip = SkipOmit
+	rscaled := false
+	for _, rule := range r.Rules {
+		switch v := rule.(type) {
+		case *RScale:
+			c, ok := rscale.Lookup(v.V)
+			if !ok {
+				return fmt.Errorf("%w %s", ErrUnsupportedRScale, v.V)
+			}
+			s.cal = c
+			rscaled = true
+		case Skip:
+			s.skip = v
+		case *Count:
+			s.count = v.V
+		case *Interval:
+			if v.V > 0 {
+				s.interval = v.V
+			}
+		case *Until:
+			s.hasUntil = true
+			switch u := v.Time.(type) {
+			case *DateTime:
+				s.until = u.Time()
+				if !u.IsUTC() {
+					y, m, d := u.V.Date()
+					h, min, sec := u.V.Clock()
+					s.until = time.Date(y, m, d, h, min, sec, 0, it.loc)
+				}
+			case *Date:
+				y, m, d := u.V.Date()
+				s.until = time.Date(y, m, d, 23, 59, 59, 0, it.loc)
+			default:
+				return fmt.Errorf("UNTIL needs a DATE or DATE-TIME, got %T", v.Time)
+			}
+			s.untilFixed = rscale.FixedFromTime(s.until.In(it.loc))
+			if it.allDay {
+				// all-day instances compare with the date UNTIL is written on
+				s.untilFixed = rscale.FixedFromTime(s.until)
+			}
+		case *BySecond:
+			s.bySecond = append(s.bySecond, v.V...)
+		case *ByMinute:
+			s.byMinute = append(s.byMinute, v.V...)
+		case *ByHour:
+			s.byHour = append(s.byHour, v.V...)
+		case *ByDay:
+			for _, w := range v.V {
+				s.byDay = append(s.byDay, weekdayNum{weekday: w.WeekDay.Weekday(), n: signed(w.Operator, w.OrdWk)})
+			}
+		case *ByMonthDay:
+			for _, d := range v.V {
+				s.byMonthDay = append(s.byMonthDay, signed(d.Operator, d.OrdMoDay))
+			}
+		case *ByYearDay:
+			for _, d := range v.V {
+				s.byYearDay = append(s.byYearDay, signed(d.Operator, d.OrdYrDay))
+			}
+		case *ByWeekNo:
+			for _, w := range v.V {
+				s.byWeekNo = append(s.byWeekNo, signed(w.Operator, w.OrdWk))
+			}
+		case *ByMonth:
+			for i, m := range v.V {
+				s.byMonth = append(s.byMonth, rscale.Month{Number: m, Leap: v.IsLeap(i)})
+			}
+		case *BySetpos:
+			for _, p := range v.V {
+				s.bySetpos = append(s.bySetpos, signed(p.Operator, p.OrdYrDay))
+			}
+		case *Wkst:
+			s.wkst = v.V.Weekday()
+		}
+	}
+	if !rscaled {
+		// SKIP MUST NOT be present unless RSCALE is present
+		s.skip = SkipOmit
+	}
+	switch s.freq {
+	case FreqSecondly, FreqMinutely, FreqHourly, FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+	default:
+		return fmt.Errorf("unknown recurrence frequency %q", s.freq)
+	}
+	if it.allDay {
+		s.byHour, s.byMinute, s.bySecond = nil, nil, nil
+	}
+	return nil
+}
+
+// defaults fills in the information missing from the rule from DTSTART,
+// d being DTSTART in the calendar system of the rule.
+func (s *recurSpec) defaults(it *RecurIterator, d rscale.Date) {
+	if len(s.byWeekNo) == 0 && len(s.byYearDay) == 0 && len(s.byMonthDay) == 0 && len(s.byDay) == 0 {
+		switch s.freq {
+		case FreqYearly:
+			if len(s.byMonth) == 0 {
+				s.byMonth = []rscale.Month{d.Month}
+			}
+			s.byMonthDay = []int{d.Day}
+		case FreqMonthly:
+			s.byMonthDay = []int{d.Day}
+		case FreqWeekly:
+			s.byDay = []weekdayNum{{weekday: rscale.Weekday(it.startFixed)}}
+		}
+	}
+	if it.allDay {
+		return
+	}
+	h, m, sec := it.start.Clock()
+	if len(s.byHour) == 0 && s.freq != FreqHourly && s.freq != FreqMinutely && s.freq != FreqSecondly {
+		s.byHour = []int{h}
+	}
+	if len(s.byMinute) == 0 && s.freq != FreqMinutely && s.freq != FreqSecondly {
+		s.byMinute = []int{m}
+	}
+	if len(s.bySecond) == 0 && s.freq != FreqSecondly {
+		s.bySecond = []int{sec}
+	}
+}
+
+// Next returns the next instance, or false once the rule is exhausted.
+func (it *RecurIterator) Next() (time.Time, bool) {
+	for !it.done {
+		if len(it.buf) > 0 {
+			t := it.buf[0]
+			it.buf = it.buf[1:]
+			if t.Before(it.start) {
+				continue
+			}
+			if !it.started {
+				it.started = true
+				if !t.Equal(it.start) {
+					// DTSTART did not match the rule, but is the first
+					// instance all the same
+					it.emitted++
+				}
+			}
+			if it.spec.hasUntil && it.afterUntil(t) {
+				it.done = true
+				break
+			}
+			if it.spec.count > 0 && it.emitted >= it.spec.count {
+				it.done = true
+				break
+			}
+			it.emitted++
+			return t, true
+		}
+		first := it.periodStart()
+		if !it.inRange(first) {
+			it.done, it.err = true, ErrRScaleRange
+			break
+		}
+		if first-it.lastHit > maxEmptyDays || first > rscale.Gregorian.ToFixed(rscale.Date{Year: 9999, Month: rscale.Month{Number: 12}, Day: 31}) {
+			it.done = true
+			break
+		}
+		if it.spec.hasUntil && first > it.spec.untilFixed+1 {
+			it.done = true
+			break
+		}
+		it.buf = it.period()
+		if len(it.buf) > 0 {
+			it.lastHit = first
+		}
+	}
+	return time.Time{}, false
+}
+
+// Err returns the error that ended the instances before the rule did,
+// ErrRScaleRange, or nil.
+func (it *RecurIterator) Err() error {
+	return it.err
+}
+
+// inRange reports whether the calendar system of the rule supports the
+// year of the day fixed.
+func (it *RecurIterator) inRange(fixed int) bool {
+	if it.spec.cal == rscale.Gregorian {
+		return true
+	}
+	return len(it.spec.cal.Months(it.spec.cal.FromFixed(fixed).Year)) > 0
+}
+
+func (it *RecurIterator) afterUntil(t time.Time) bool {
+	if it.allDay {
+		return rscale.FixedFromTime(t) > it.spec.untilFixed
+	}
+	return t.After(it.spec.until)
+}
+
+// periodStart returns the first day of the current period.
+func (it *RecurIterator) periodStart() int {
+	switch it.spec.freq {
+	case FreqYearly:
+		return rscale.YearStart(it.spec.cal, it.year)
+	case FreqMonthly:
+		months := it.spec.cal.Months(it.year)
+		if it.month >= len(months) {
+			return 0
+		}
+		return it.spec.cal.ToFixed(rscale.Date{Year: it.year, Month: months[it.month], Day: 1})
+	}
+	return it.fixed
+}
+
+// period returns the instances of the current period and advances to
+// the next one.
+func (it *RecurIterator) period() []time.Time {
+	s := &it.spec
+	var days []int
+	var secs []int
+	switch s.freq {
+	case FreqYearly:
+		months := s.cal.Months(it.year)
+		if len(months) == 0 {
+			it.done = true
+			return nil
+		}
+		days = it.filter(it.monthDays(it.year, it.expandMonths(it.year, months)))
+		it.year += s.interval
+	case FreqMonthly:
+		months := s.cal.Months(it.year)
+		if len(months) == 0 {
+			it.done = true
+			return nil
+		}
+		m := months[it.month]
+		if len(s.byMonth) == 0 || containsMonth(s.byMonth, m) {
+			days = it.filter(it.monthDays(it.year, []rscale.Month{m}))
+		}
+		it.month += s.interval
+		for {
+			months = s.cal.Months(it.year)
+			if len(months) == 0 || it.month < len(months) {
+				break
+			}
+			it.month -= len(months)
+			it.year++
+		}
+	case FreqWeekly, FreqDaily:
+		n := 1
+		if s.freq == FreqWeekly {
+			n = 7
+		}
+		for d := it.fixed; d < it.fixed+n; d++ {
+			if it.dayMatches(d, true) {
+				days = append(days, d)
+			}
+		}
+		it.fixed += n * s.interval
+	default:
+		step := map[Frequency]int{FreqHourly: 3600, FreqMinutely: 60, FreqSecondly: 1}[s.freq] * s.interval
+		if !it.dayMatches(it.fixed, true) {
+			// jump to the first period of the next day
+			n := (86400 - it.sec + step - 1) / step
+			it.advance(n * step)
+			return nil
+		}
+		h, m, sec := it.sec/3600, it.sec/60%60, it.sec%60
+		if (len(s.byHour) == 0 || containsInt(s.byHour, h)) &&
+			(s.freq == FreqHourly || len(s.byMinute) == 0 || containsInt(s.byMinute, m)) &&
+			(s.freq != FreqSecondly || len(s.bySecond) == 0 || containsInt(s.bySecond, sec)) {
+			days = []int{it.fixed}
+			switch s.freq {
+			case FreqHourly:
+				secs = it.times([]int{h}, s.byMinute, s.bySecond)
+			case FreqMinutely:
+				secs = it.times([]int{h}, []int{m}, s.bySecond)
+			default:
+				secs = []int{it.sec}
+			}
+		}
+		it.advance(step)
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	if secs == nil {
+		secs = it.times(s.byHour, s.byMinute, s.bySecond)
+	}
+	set := make([]time.Time, 0, len(days)*len(secs))
+	for _, d := range days {
+		for _, sec := range secs {
+			set = append(set, rscale.TimeFromFixed(d, sec/3600, sec/60%60, sec%60, it.loc))
+		}
+	}
+	if len(s.bySetpos) > 0 {
+		set = setpos(set, s.bySetpos)
+	}
+	return set
+}
+
+func (it *RecurIterator) advance(seconds int) {
+	it.sec += seconds
+	it.fixed += it.sec / 86400
+	it.sec %= 86400
+}
+
+// times returns the seconds of the day selected by the hour, minute and
+// second lists in ascending order.
+func (it *RecurIterator) times(hours, minutes, seconds []int) []int {
+	if it.allDay {
+		return []int{0}
+	}
+	var secs []int
+	for _, h := range hours {
+		for _, m := range minutes {
+			for _, s := range seconds {
+				if s == 60 {
+					// leap seconds are read as the second 59
+					s = 59
+				}
+				if h >= 0 && h < 24 && m >= 0 && m < 60 && s >= 0 && s < 60 {
+					secs = append(secs, h*3600+m*60+s)
+				}
+			}
+		}
+	}
+	return uniqueInts(secs)
+}
+
+// expandMonths returns the months of year selected by BYMONTH, resolving
+// leap months missing from year according to SKIP.
+func (it *RecurIterator) expandMonths(year int, months []rscale.Month) []rscale.Month {
+	s := &it.spec
+	if len(s.byMonth) == 0 {
+		return months
+	}
+	var selected []rscale.Month
+	for _, m := range s.byMonth {
+		if s.cal.DaysInMonth(year, m) > 0 {
+			selected = append(selected, m)
+			continue
+		}
+		if !m.Leap {
+			continue
+		}
+		switch s.skip {
+		case SkipBackward:
+			selected = append(selected, rscale.Month{Number: m.Number})
+		case SkipForward:
+			if next := (rscale.Month{Number: m.Number + 1}); s.cal.DaysInMonth(year, next) > 0 {
+				selected = append(selected, next)
+			}
+		}
+	}
+	// keep the order of the calendar and drop duplicates
+	var ordered []rscale.Month
+	for _, m := range months {
+		if containsMonth(selected, m) {
+			ordered = append(ordered, m)
+		}
+	}
+	return ordered
+}
+
+// monthDays returns the days of the given months selected by BYMONTHDAY,
+// resolving days missing from a month according to SKIP.
+func (it *RecurIterator) monthDays(year int, months []rscale.Month) []int {
+	s := &it.spec
+	var days []int
+	for _, m := range months {
+		first := s.cal.ToFixed(rscale.Date{Year: year, Month: m, Day: 1})
+		n := s.cal.DaysInMonth(year, m)
+		if len(s.byMonthDay) == 0 {
+			for d := 0; d < n; d++ {
+				days = append(days, first+d)
+			}
+			continue
+		}
+		for _, v := range s.byMonthDay {
+			d := v
+			if v < 0 {
+				d = n + v + 1
+			}
+			switch {
+			case d >= 1 && d <= n:
+				days = append(days, first+d-1)
+			case s.skip == SkipBackward && v > 0:
+				days = append(days, first+n-1)
+			case s.skip == SkipBackward:
+				days = append(days, first-1)
+			case s.skip == SkipForward && v > 0:
+				days = append(days, first+n)
+			case s.skip == SkipForward:
+				days = append(days, first)
+			}
+		}
+	}
+	return uniqueInts(days)
+}
+
+// filter returns the days that match the remaining BYxxx rule parts of a
+// YEARLY or MONTHLY rule.
+func (it *RecurIterator) filter(days []int) []int {
+	var matched []int
+	for _, d := range days {
+		if it.dayMatches(d, false) {
+			matched = append(matched, d)
+		}
+	}
+	return matched
+}
+
+// dayMatches checks the day fixed against the BYxxx rule parts.  When
+// limit is set BYMONTH and BYMONTHDAY are checked too; otherwise they
+// were already used to build the candidate days.
+func (it *RecurIterator) dayMatches(fixed int, limit bool) bool {
+	s := &it.spec
+	d := s.cal.FromFixed(fixed)
+	if limit && len(s.byMonth) > 0 && !containsMonth(s.byMonth, d.Month) {
+		return false
+	}
+	if limit && len(s.byMonthDay) > 0 {
+		n := s.cal.DaysInMonth(d.Year, d.Month)
+		ok := false
+		for _, v := range s.byMonthDay {
+			if v == d.Day || (v < 0 && n+v+1 == d.Day) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(s.byWeekNo) > 0 {
+		no, weeks := it.weekNo(fixed, d.Year)
+		ok := false
+		for _, v := range s.byWeekNo {
+			if v == no || (v < 0 && weeks+v+1 == no) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(s.byYearDay) > 0 {
+		start := rscale.YearStart(s.cal, d.Year)
+		doy, n := fixed-start+1, rscale.DaysInYear(s.cal, d.Year)
+		ok := false
+		for _, v := range s.byYearDay {
+			if v == doy || (v < 0 && n+v+1 == doy) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(s.byDay) > 0 {
+		wd := rscale.Weekday(fixed)
+		ok := false
+		for _, v := range s.byDay {
+			if v.weekday != wd {
+				continue
+			}
+			if v.n == 0 || (s.freq != FreqMonthly && s.freq != FreqYearly) {
+				ok = true
+				break
+			}
+			// the n-th weekday of the month, or of the year for a YEARLY
+			// rule without BYMONTH
+			var first, last int
+			if s.freq == FreqMonthly || len(s.byMonth) > 0 {
+				first = s.cal.ToFixed(rscale.Date{Year: d.Year, Month: d.Month, Day: 1})
+				last = first + s.cal.DaysInMonth(d.Year, d.Month) - 1
+			} else {
+				first = rscale.YearStart(s.cal, d.Year)
+				last = first + rscale.DaysInYear(s.cal, d.Year) - 1
+			}
+			if (v.n > 0 && (fixed-first)/7+1 == v.n) || (v.n < 0 && -((last-fixed)/7+1) == v.n) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// weekNo returns the week number of the day fixed as defined by ISO 8601
+// with the week starting on WKST, and the number of weeks of the year
+// the week belongs to.
+func (it *RecurIterator) weekNo(fixed, year int) (int, int) {
+	w0, w1 := it.firstWeek(year), it.firstWeek(year+1)
+	switch {
+	case fixed < w0:
+		prev := it.firstWeek(year - 1)
+		return (fixed-prev)/7 + 1, (w0 - prev) / 7
+	case fixed >= w1:
+		return 1, (it.firstWeek(year+2) - w1) / 7
+	}
+	return (fixed-w0)/7 + 1, (w1 - w0) / 7
+}
+
+// firstWeek returns the first day of the week 1 of year, the first week
+// having at least four days in the year.
+func (it *RecurIterator) firstWeek(year int) int {
+	start := rscale.YearStart(it.spec.cal, year)
+	next := start + int((it.spec.wkst-rscale.Weekday(start)+7)%7)
+	if next-start >= 4 {
+		return next - 7
+	}
+	return next
+}
+
+func setpos(set []time.Time, positions []int) []time.Time {
+	var selected []time.Time
+	for _, p := range positions {
+		i := p - 1
+		if p < 0 {
+			i = len(set) + p
+		}
+		if i >= 0 && i < len(set) {
+			selected = append(selected, set[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	unique := selected[:0]
+	for i, t := range selected {
+		if i == 0 || !t.Equal(selected[i-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+func containsMonth(months []rscale.Month, m rscale.Month) bool {
+	for _, v := range months {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, i := range values {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueInts(values []int) []int {
+	sort.Ints(values)
+	unique := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,206 @@
+package types
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func instances(t *testing.T, r *RecurRule, dtstart Value, max int) string {
+	it, err := r.Iterator(dtstart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for len(got) < max {
+		v, ok := it.Next()
+		if !ok {
+			break
+		}
+		if _, date := dtstart.(*Date); date {
+			got = append(got, v.Format("20060102"))
+		} else {
+			got = append(got, v.Format("20060102T1504"))
+		}
+	}
+	return strings.Join(got, " ")
+}
+
+func expect(t *testing.T, name, got, want string) {
+	if got != want {
+		t.Errorf("%s:\n got: %s\nwant: %s", name, got, want)
+	}
+}
+
+func TestRecurRule_Iterator(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := func(y, m, d, h, min int) *DateTime {
+		return &DateTime{V: time.Date(y, time.Month(m), d, h, min, 0, 0, ny), Format: LocalDateTimeFormat}
+	}
+
+	// Daily for 10 occurrences
+	r := &RecurRule{Frequency: FreqDaily, Rules: []Rule{&Count{V: 10}}}
+	expect(t, "daily", instances(t, r, start(1997, 9, 2, 9, 0), 20),
+		"19970902T0900 19970903T0900 19970904T0900 19970905T0900 19970906T0900 19970907T0900 19970908T0900 19970909T0900 19970910T0900 19970911T0900")
+
+	// Monthly on the first Friday for 10 occurrences
+	r = &RecurRule{Frequency: FreqMonthly, Rules: []Rule{&Count{V: 10}, &ByDay{V: []*WeekDayNum{{OrdWk: 1, WeekDay: Friday}}}}}
+	expect(t, "first friday", instances(t, r, start(1997, 9, 5, 9, 0), 20),
+		"19970905T0900 19971003T0900 19971107T0900 19971205T0900 19980102T0900 19980206T0900 19980306T0900 19980403T0900 19980501T0900 19980605T0900")
+
+	// Every other week on Monday, Wednesday, and Friday until December 24, 1997
+	r = &RecurRule{Frequency: FreqWeekly, Rules: []Rule{
+		&Interval{V: 2},
+		&Until{Time: &DateTime{V: time.Date(1997, 12, 24, 0, 0, 0, 0, time.UTC), Format: UTCDateTimeFormat}},
+		&Wkst{V: Sunday},
+		&ByDay{V: []*WeekDayNum{{WeekDay: Monday}, {WeekDay: Wednesday}, {WeekDay: Friday}}},
+	}}
+	expect(t, "every other week", instances(t, r, start(1997, 9, 1, 9, 0), 40),
+		"19970901T0900 19970903T0900 19970905T0900 19970915T0900 19970917T0900 19970919T0900 19970929T0900 "+
+			"19971001T0900 19971003T0900 19971013T0900 19971015T0900 19971017T0900 19971027T0900 19971029T0900 19971031T0900 "+
+			"19971110T0900 19971112T0900 19971114T0900 19971124T0900 19971126T0900 19971128T0900 "+
+			"19971208T0900 19971210T0900 19971212T0900 19971222T0900")
+
+	// Monday of week number 20
+	r = &RecurRule{Frequency: FreqYearly, Rules: []Rule{&ByWeekNo{V: []*WeekNum{{OrdWk: 20}}}, &ByDay{V: []*WeekDayNum{{WeekDay: Monday}}}}}
+	expect(t, "week 20", instances(t, r, start(1997, 5, 12, 9, 0), 3),
+		"19970512T0900 19980511T0900 19990517T0900")
+
+	// The last work day of the month
+	r = &RecurRule{Frequency: FreqMonthly, Rules: []Rule{
+		&ByDay{V: []*WeekDayNum{{WeekDay: Monday}, {WeekDay: Tuesday}, {WeekDay: Wednesday}, {WeekDay: Thursday}, {WeekDay: Friday}}},
+		&BySetpos{V: []*YearDayNum{{Operator: Minus, OrdYrDay: 1}}},
+	}}
+	expect(t, "last work day", instances(t, r, start(1997, 9, 29, 9, 0), 3),
+		"19970930T0900 19971031T0900 19971128T0900")
+
+	// Every Friday the 13th
+	r = &RecurRule{Frequency: FreqMonthly, Rules: []Rule{
+		&ByDay{V: []*WeekDayNum{{WeekDay: Friday}}},
+		&ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 13}}},
+	}}
+	expect(t, "friday 13th", instances(t, r, start(1997, 9, 2, 9, 0), 5),
+		"19980213T0900 19980313T0900 19981113T0900 19990813T0900 20001013T0900")
+
+	// U.S. Presidential Election day
+	r = &RecurRule{Frequency: FreqYearly, Rules: []Rule{
+		&Interval{V: 4},
+		&ByMonth{V: []int{11}},
+		&ByDay{V: []*WeekDayNum{{WeekDay: Tuesday}}},
+		&ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 2}, {OrdMoDay: 3}, {OrdMoDay: 4}, {OrdMoDay: 5}, {OrdMoDay: 6}, {OrdMoDay: 7}, {OrdMoDay: 8}}},
+	}}
+	expect(t, "election day", instances(t, r, start(1996, 11, 5, 9, 0), 3),
+		"19961105T0900 20001107T0900 20041102T0900")
+
+	// invalid dates are ignored
+	r = &RecurRule{Frequency: FreqMonthly, Rules: []Rule{&ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 15}, {OrdMoDay: 30}}}, &Count{V: 5}}}
+	expect(t, "invalid dates", instances(t, r, start(2007, 1, 15, 9, 0), 10),
+		"20070115T0900 20070130T0900 20070215T0900 20070315T0900 20070330T0900")
+
+	// a DTSTART that does not match the rule is the first of the COUNT
+	r = &RecurRule{Frequency: FreqDaily, Rules: []Rule{&Count{V: 3}, &ByDay{V: []*WeekDayNum{{WeekDay: Tuesday}}}}}
+	expect(t, "unmatched dtstart", instances(t, r, start(2024, 3, 4, 9, 0), 10),
+		"20240305T0900 20240312T0900")
+
+	// WKST changes the weeks of an INTERVAL=2 rule
+	r = &RecurRule{Frequency: FreqWeekly, Rules: []Rule{&Interval{V: 2}, &Count{V: 4}, &ByDay{V: []*WeekDayNum{{WeekDay: Tuesday}, {WeekDay: Sunday}}}, &Wkst{V: Sunday}}}
+	expect(t, "wkst", instances(t, r, start(1997, 8, 5, 9, 0), 10),
+		"19970805T0900 19970817T0900 19970819T0900 19970831T0900")
+
+	// Every 3 hours until 5 PM
+	r = &RecurRule{Frequency: FreqHourly, Rules: []Rule{&Interval{V: 3},
+		&Until{Time: &DateTime{V: time.Date(1997, 9, 2, 21, 0, 0, 0, time.UTC), Format: UTCDateTimeFormat}}}}
+	expect(t, "hourly", instances(t, r, start(1997, 9, 2, 9, 0), 10),
+		"19970902T0900 19970902T1200 19970902T1500")
+
+	// Every 20 minutes from 9:00 AM to 4:40 PM
+	r = &RecurRule{Frequency: FreqMinutely, Rules: []Rule{&Interval{V: 20}, &ByHour{V: []int{9, 10, 11, 12, 13, 14, 15, 16}}}}
+	got := instances(t, r, start(1997, 9, 2, 9, 0), 26)
+	expect(t, "minutely", got[len(got)-55:], "19970902T1620 19970902T1640 19970903T0900 19970903T0920")
+}
+
+func TestRecurRule_RScale(t *testing.T) {
+	date := func(y, m, d int) *Date {
+		return &Date{V: time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)}
+	}
+
+	// Chinese New Year
+	r := &RecurRule{Frequency: FreqYearly, Rules: []Rule{&RScale{V: "CHINESE"}}}
+	expect(t, "chinese new year", instances(t, r, date(2013, 2, 10), 5),
+		"20130210 20140131 20150219 20160208 20170128")
+
+	// the table of the Chinese calendar ends with 2100
+	it, err := r.Iterator(date(2098, 2, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+		n++
+	}
+	if n != 3 || it.Err() != ErrRScaleRange {
+		t.Errorf("chinese years after 2100: got %d instances and %v", n, it.Err())
+	}
+	if _, err := r.Between(date(2098, 2, 1), date(2098, 1, 1).V, date(2200, 1, 1).V); err != ErrRScaleRange {
+		t.Errorf("Between past 2100: %v", err)
+	}
+
+	// the 8th day of Adar I, or of Adar in years without a leap month
+	r = &RecurRule{Frequency: FreqYearly, Rules: []Rule{
+		&RScale{V: "HEBREW"}, &ByMonth{V: []int{5}, Leap: []bool{true}},
+		&ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 8}}}, SkipForward,
+	}}
+	expect(t, "adar I", instances(t, r, date(2014, 2, 8), 4),
+		"20140208 20150227 20160217 20170306")
+
+	// ... and only in leap years
+	r.Rules = r.Rules[:3]
+	expect(t, "adar I omit", instances(t, r, date(2014, 2, 8), 3),
+		"20140208 20160217 20190213")
+
+	// the first day of Ramadan
+	r = &RecurRule{Frequency: FreqYearly, Rules: []Rule{&RScale{V: "islamic-civil"}, &ByMonth{V: []int{9}}, &ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 1}}}}}
+	expect(t, "ramadan", instances(t, r, date(2023, 1, 1), 3),
+		"20230323 20240311 20250301")
+
+	// leap day anniversaries
+	r = &RecurRule{Frequency: FreqYearly, Rules: []Rule{&RScale{V: "GREGORIAN"}, SkipForward}}
+	expect(t, "leap day forward", instances(t, r, date(2016, 2, 29), 5),
+		"20160229 20170301 20180301 20190301 20200229")
+	r.Rules[1] = SkipBackward
+	expect(t, "leap day backward", instances(t, r, date(2016, 2, 29), 5),
+		"20160229 20170228 20180228 20190228 20200229")
+	r.Rules = r.Rules[:1]
+	expect(t, "leap day omit", instances(t, r, date(2016, 2, 29), 3),
+		"20160229 20200229 20240229")
+
+	// the 30th day of every Chinese month, or its last day
+	r = &RecurRule{Frequency: FreqMonthly, Rules: []Rule{&RScale{V: "CHINESE"}, &ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 30}}}, SkipBackward, &Count{V: 4}}}
+	expect(t, "chinese month end", instances(t, r, date(2023, 3, 21), 4),
+		"20230321 20230419 20230518 20230617")
+
+	r = &RecurRule{Frequency: FreqYearly, Rules: []Rule{&RScale{V: "MAYAN"}}}
+	if _, err := r.Iterator(date(2013, 2, 10)); err == nil {
+		t.Error("expected an error for an unsupported RSCALE")
+	}
+}
+
+func TestRecurRule_WriteRScale(t *testing.T) {
+	b := &strings.Builder{}
+	r := RecurRule{
+		Frequency: FreqYearly,
+		Rules: []Rule{
+			&ByMonth{V: []int{5}, Leap: []bool{true}},
+			&ByMonthDay{V: []*MonthDayNum{{OrdMoDay: 8}}},
+			&RScale{V: "HEBREW"},
+			SkipForward,
+		},
+	}
+	r.WriteValueToStrBuilder(b)
+	if b.String() != "RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=5L;BYMONTHDAY=8;SKIP=FORWARD" {
+		t.Error(b.String())
+	}
+}
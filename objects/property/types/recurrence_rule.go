@@ -582,6 +582,14 @@ func (w *WeekNum) WriteRule(s *strings.Builder) error {
 
 type ByMonth struct {
 	V []int
+	// Leap marks V[i] as the leap month following month V[i] ("5L", see
+	// RFC 7529) when Leap[i] is true.  It may be shorter than V or nil.
+	Leap []bool
+}
+
+// IsLeap reports whether the i-th month of b is a leap month.
+func (b *ByMonth) IsLeap(i int) bool {
+	return i < len(b.Leap) && b.Leap[i]
 }
 
 func (b *ByMonth) WriteRule(s *strings.Builder) error {
@@ -589,6 +597,9 @@ func (b *ByMonth) WriteRule(s *strings.Builder) error {
 	l := len(b.V) - 1
 	for index, v := range b.V {
 		s.WriteString(strconv.Itoa(v))
+		if b.IsLeap(index) {
+			s.WriteString("L")
+		}
 		if index != l {
 			s.WriteString(",")
 		}
@@ -623,13 +634,21 @@ func (w *Wkst) WriteRule(s *strings.Builder) error {
 }
 
 func (r *RecurRule) WriteValueToStrBuilder(s *strings.Builder) error {
-	r.Frequency.WriteRule(s)
-	s.WriteString(";")
-	for index, r := range r.Rules {
-		if index != 0 {
+	// RSCALE goes in front of FREQ as in the examples of RFC 7529, so
+	// that it is seen before any rule part it changes the meaning of.
+	for _, rule := range r.Rules {
+		if rs, ok := rule.(*RScale); ok {
+			rs.WriteRule(s)
 			s.WriteString(";")
 		}
-		r.WriteRule(s)
+	}
+	r.Frequency.WriteRule(s)
+	for _, rule := range r.Rules {
+		if _, ok := rule.(*RScale); ok {
+			continue
+		}
+		s.WriteString(";")
+		rule.WriteRule(s)
 	}
 	return nil
 }
@@ -0,0 +1,419 @@
+package types
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+//   RFC 5545 3.3.10.  Recurrence Rule
+//
+//      The value of the UNTIL rule part MUST have the same
+//      value type as the "DTSTART" property.  Furthermore, if the
+//      "DTSTART" property is specified as a date with local time, then
+//      the UNTIL rule part MUST also be specified as a date with local
+//      time.  If the "DTSTART" property is specified as a date with UTC
+//      time or a date with local time and time zone reference, then the
+//      UNTIL rule part MUST be specified as a date with UTC time.
+//
+//      The WKST rule part specifies the day on which the workweek starts.
+//      Valid values are MO, TU, WE, TH, FR, SA, and SU.  This is
+//      significant when a WEEKLY "RRULE" has an interval greater than 1,
+//      and a BYDAY rule part is specified.  This is also significant when
+//      in a YEARLY "RRULE" when a BYWEEKNO rule part is specified.  The
+//      default value is MO.
+
+// ruleOrder is the order Normalize puts rule parts in, that of the ABNF.
+var ruleOrder = []string{"UNTIL", "COUNT", "INTERVAL", "BYSECOND", "BYMINUTE", "BYHOUR", "BYDAY",
+	"BYMONTHDAY", "BYYEARDAY", "BYWEEKNO", "BYMONTH", "BYSETPOS", "WKST", "RSCALE", "SKIP"}
+
+// ErrNoInstances is returned when a rule converted to COUNT has no
+// instances.
+var ErrNoInstances = errors.New("recur: rule has no instances")
+
+// Normalize returns a copy of r written in one way, so that rules that
+// mean the same are equal:
+//
+//   - rule parts are in the order of the ABNF, list values sorted and
+//     without duplicates, and "+" signs dropped;
+//   - INTERVAL=1, WKST=MO, WKST where it has no effect and SKIP=OMIT are
+//     removed;
+//   - given dtstart, the BYMONTH, BYMONTHDAY, BYDAY, BYHOUR, BYMINUTE and
+//     BYSECOND that only repeat what DTSTART implies are removed, as are
+//     the BYHOUR, BYMINUTE and BYSECOND of a DATE DTSTART, which are
+//     ignored;
+//   - given dtstart, UNTIL takes the value type of DTSTART: a DATE, a
+//     floating DATE-TIME for a floating DTSTART, a UTC DATE-TIME
+//     otherwise.
+//
+// A floating dtstart is a DateTime in the local format in time.Local, as
+// the decoder makes it.  Days are only compared with DTSTART in the
+// Gregorian calendar.
+func (r *RecurRule) Normalize(dtstart Value) *RecurRule {
+	n := &RecurRule{Frequency: r.Frequency}
+	parts := map[string]Rule{}
+	for _, rule := range r.Rules {
+		parts[ruleName(rule)] = normalizePart(rule)
+	}
+
+	if v, ok := parts["INTERVAL"].(*Interval); ok && v.V <= 1 {
+		delete(parts, "INTERVAL")
+	}
+	if v, ok := parts["WKST"].(*Wkst); ok {
+		_, byDay := parts["BYDAY"]
+		_, byWeekNo := parts["BYWEEKNO"]
+		weekly := r.Frequency == FreqWeekly && parts["INTERVAL"] != nil && byDay
+		if v.V == Monday || !weekly && !(r.Frequency == FreqYearly && byWeekNo) {
+			delete(parts, "WKST")
+		}
+	}
+	if _, ok := parts["RSCALE"]; !ok || parts["SKIP"] == SkipOmit {
+		delete(parts, "SKIP")
+	}
+	if dtstart != nil {
+		normalizeToStart(r.Frequency, parts, dtstart)
+	}
+
+	for _, name := range ruleOrder {
+		if rule, ok := parts[name]; ok {
+			n.Rules = append(n.Rules, rule)
+		}
+	}
+	return n
+}
+
+// normalizeToStart removes the rule parts dtstart implies and converts
+// UNTIL to the value type of dtstart.
+func normalizeToStart(freq Frequency, parts map[string]Rule, dtstart Value) {
+	var start time.Time
+	allDay := false
+	switch v := dtstart.(type) {
+	case *Date:
+		start, allDay = v.Time(), true
+	case *DateTime:
+		start = v.Time()
+	default:
+		return
+	}
+	if u, ok := parts["UNTIL"].(*Until); ok {
+		if t := untilValue(u.Time, dtstart); t != nil {
+			parts["UNTIL"] = &Until{Time: t}
+		}
+	}
+	if allDay {
+		delete(parts, "BYHOUR")
+		delete(parts, "BYMINUTE")
+		delete(parts, "BYSECOND")
+	} else {
+		h, m, s := start.Clock()
+		if freq != FreqHourly && freq != FreqMinutely && freq != FreqSecondly && onlyInt(parts["BYHOUR"], h) {
+			delete(parts, "BYHOUR")
+		}
+		if freq != FreqMinutely && freq != FreqSecondly && onlyInt(parts["BYMINUTE"], m) {
+			delete(parts, "BYMINUTE")
+		}
+		if freq != FreqSecondly && onlyInt(parts["BYSECOND"], s) {
+			delete(parts, "BYSECOND")
+		}
+	}
+
+	if rs, ok := parts["RSCALE"].(*RScale); ok && rs.V != "GREGORIAN" {
+		return
+	}
+	for _, name := range []string{"BYSETPOS", "BYYEARDAY", "BYWEEKNO"} {
+		if _, ok := parts[name]; ok {
+			return
+		}
+	}
+	switch freq {
+	case FreqWeekly:
+		if d, ok := parts["BYDAY"].(*ByDay); ok && len(d.V) == 1 && d.V[0].OrdWk == 0 && d.V[0].WeekDay.Weekday() == start.Weekday() {
+			delete(parts, "BYDAY")
+		}
+	case FreqMonthly, FreqYearly:
+		if _, ok := parts["BYDAY"]; ok {
+			return
+		}
+		// a yearly rule without BYMONTH expands BYMONTHDAY to every month
+		_, byMonth := parts["BYMONTH"]
+		if d, ok := parts["BYMONTHDAY"].(*ByMonthDay); ok && len(d.V) == 1 && d.V[0].Operator != Minus && d.V[0].OrdMoDay == start.Day() &&
+			(freq == FreqMonthly || byMonth) {
+			delete(parts, "BYMONTHDAY")
+		}
+		if _, ok := parts["BYMONTHDAY"]; !ok && freq == FreqYearly {
+			if m, ok := parts["BYMONTH"].(*ByMonth); ok && len(m.V) == 1 && !m.IsLeap(0) && m.V[0] == int(start.Month()) {
+				delete(parts, "BYMONTH")
+			}
+		}
+	}
+}
+
+// untilValue returns the bound of UNTIL as a value of the type of
+// dtstart, read as the iterator reads it.
+func untilValue(until, dtstart Value) Value {
+	switch d := dtstart.(type) {
+	case *Date:
+		switch u := until.(type) {
+		case *Date:
+			return u
+		case *DateTime:
+			y, m, day := u.Time().Date()
+			if !u.IsUTC() {
+				y, m, day = u.V.Date()
+			}
+			return &Date{V: time.Date(y, m, day, 0, 0, 0, 0, time.UTC)}
+		}
+	case *DateTime:
+		loc := d.Time().Location()
+		var t time.Time
+		switch u := until.(type) {
+		case *Date:
+			y, m, day := u.V.Date()
+			t = time.Date(y, m, day, 23, 59, 59, 0, loc)
+		case *DateTime:
+			t = u.Time()
+			if !u.IsUTC() {
+				w := u.V
+				t = time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, loc)
+			}
+		default:
+			return nil
+		}
+		if !d.IsUTC() && d.V.Location() == time.Local {
+			return &DateTime{V: t.In(time.Local), Format: LocalDateTimeFormat}
+		}
+		return &DateTime{V: t.UTC(), Format: UTCDateTimeFormat}
+	}
+	return nil
+}
+
+// onlyInt reports whether rule is a list of the single value v.
+func onlyInt(rule Rule, v int) bool {
+	var list []int
+	switch b := rule.(type) {
+	case *BySecond:
+		list = b.V
+	case *ByMinute:
+		list = b.V
+	case *ByHour:
+		list = b.V
+	}
+	return len(list) == 1 && list[0] == v
+}
+
+// normalizePart returns a copy of rule with its values sorted, without
+// duplicates and without "+" signs.
+func normalizePart(rule Rule) Rule {
+	switch v := rule.(type) {
+	case *Count:
+		return &Count{V: v.V}
+	case *Interval:
+		return &Interval{V: v.V}
+	case *Until:
+		return &Until{Time: v.Time}
+	case *BySecond:
+		return &BySecond{V: uniqueSorted(v.V)}
+	case *ByMinute:
+		return &ByMinute{V: uniqueSorted(v.V)}
+	case *ByHour:
+		return &ByHour{V: uniqueSorted(v.V)}
+	case *ByDay:
+		// Monday first, by ordinal within the day
+		seen := map[WeekDayNum]bool{}
+		b := &ByDay{}
+		for _, d := range v.V {
+			w := WeekDayNum{OrdWk: d.OrdWk, WeekDay: d.WeekDay}
+			if d.Operator == Minus {
+				w.Operator = Minus
+			}
+			if !seen[w] {
+				seen[w] = true
+				b.V = append(b.V, &w)
+			}
+		}
+		sort.SliceStable(b.V, func(i, j int) bool {
+			a, c := b.V[i], b.V[j]
+			da, dc := (a.WeekDay.Weekday()+6)%7, (c.WeekDay.Weekday()+6)%7
+			if da != dc {
+				return da < dc
+			}
+			return signed(a.Operator, a.OrdWk) < signed(c.Operator, c.OrdWk)
+		})
+		return b
+	case *ByMonthDay:
+		var values []int
+		for _, d := range v.V {
+			values = append(values, signed(d.Operator, d.OrdMoDay))
+		}
+		b := &ByMonthDay{}
+		for _, n := range uniqueSorted(values) {
+			op, abs := unsigned(n)
+			b.V = append(b.V, &MonthDayNum{Operator: op, OrdMoDay: abs})
+		}
+		return b
+	case *ByYearDay:
+		return &ByYearDay{V: normalizeYearDays(v.V)}
+	case *BySetpos:
+		return &BySetpos{V: normalizeYearDays(v.V)}
+	case *ByWeekNo:
+		var values []int
+		for _, w := range v.V {
+			values = append(values, signed(w.Operator, w.OrdWk))
+		}
+		b := &ByWeekNo{}
+		for _, n := range uniqueSorted(values) {
+			op, abs := unsigned(n)
+			b.V = append(b.V, &WeekNum{Operator: op, OrdWk: abs})
+		}
+		return b
+	case *ByMonth:
+		// leap months after the month they follow
+		var values []int
+		for i, m := range v.V {
+			n := m * 2
+			if v.IsLeap(i) {
+				n++
+			}
+			values = append(values, n)
+		}
+		b := &ByMonth{}
+		leap := false
+		for _, n := range uniqueSorted(values) {
+			b.V = append(b.V, n/2)
+			b.Leap = append(b.Leap, n%2 == 1)
+			leap = leap || n%2 == 1
+		}
+		if !leap {
+			b.Leap = nil
+		}
+		return b
+	case *Wkst:
+		return &Wkst{V: v.V}
+	case *RScale:
+		return &RScale{V: v.V}
+	}
+	return rule
+}
+
+func normalizeYearDays(days []*YearDayNum) []*YearDayNum {
+	var values []int
+	for _, d := range days {
+		values = append(values, signed(d.Operator, d.OrdYrDay))
+	}
+	var list []*YearDayNum
+	for _, n := range uniqueSorted(values) {
+		op, abs := unsigned(n)
+		list = append(list, &YearDayNum{Operator: op, OrdYrDay: abs})
+	}
+	return list
+}
+
+func unsigned(n int) (Operator, int) {
+	if n < 0 {
+		return Minus, -n
+	}
+	return "", n
+}
+
+// uniqueSorted returns the values of v in ascending order, once each.
+func uniqueSorted(v []int) []int {
+	sorted := append([]int(nil), v...)
+	sort.Ints(sorted)
+	var list []int
+	for i, n := range sorted {
+		if i == 0 || n != sorted[i-1] {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+// CountToUntil returns a copy of r with COUNT replaced by an UNTIL of
+// its last instance, in the value type of dtstart.  As for COUNT,
+// DTSTART is the first instance whether the rule matches it or not.  A
+// rule without COUNT is returned as is.
+func (r *RecurRule) CountToUntil(dtstart Value) (*RecurRule, error) {
+	found := false
+	for _, rule := range r.Rules {
+		if _, ok := rule.(*Count); ok {
+			found = true
+		}
+	}
+	if !found {
+		return r, nil
+	}
+	it, err := r.Iterator(dtstart)
+	if err != nil {
+		return nil, err
+	}
+	// DTSTART is the first instance even when the rule does not match it
+	last := it.start
+	for t, ok := it.Next(); ok; t, ok = it.Next() {
+		last = t
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	var until Value
+	switch d := dtstart.(type) {
+	case *Date:
+		until = &Date{V: time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)}
+	case *DateTime:
+		if !d.IsUTC() && d.V.Location() == time.Local {
+			until = &DateTime{V: last, Format: LocalDateTimeFormat}
+		} else {
+			until = &DateTime{V: last.UTC(), Format: UTCDateTimeFormat}
+		}
+	}
+	c := &RecurRule{Frequency: r.Frequency}
+	for _, rule := range r.Rules {
+		if _, ok := rule.(*Count); ok {
+			rule = &Until{Time: until}
+		}
+		c.Rules = append(c.Rules, rule)
+	}
+	return c, nil
+}
+
+// UntilToCount returns a copy of r with UNTIL replaced by the COUNT of
+// its instances.  A rule without UNTIL is returned as is; ErrNoInstances
+// is returned for a rule without instances, which no COUNT can express.
+func (r *RecurRule) UntilToCount(dtstart Value) (*RecurRule, error) {
+	found := false
+	for _, rule := range r.Rules {
+		if _, ok := rule.(*Until); ok {
+			found = true
+		}
+	}
+	if !found {
+		return r, nil
+	}
+	it, err := r.Iterator(dtstart)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	matched := false
+	for t, ok := it.Next(); ok; t, ok = it.Next() {
+		matched = matched || t.Equal(it.start)
+		n++
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if !matched && !it.afterUntil(it.start) {
+		// DTSTART counts even when the rule does not match it
+		n++
+	}
+	if n == 0 {
+		return nil, ErrNoInstances
+	}
+	c := &RecurRule{Frequency: r.Frequency}
+	for _, rule := range r.Rules {
+		if _, ok := rule.(*Until); ok {
+			rule = &Count{V: n}
+		}
+		c.Rules = append(c.Rules, rule)
+	}
+	return c, nil
+}
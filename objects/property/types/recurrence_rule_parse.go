@@ -0,0 +1,233 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseRecurRule parses the text form of a RECUR value, such as
+// "FREQ=MONTHLY;BYDAY=-1FR".  Rule parts are kept in the order they are
+// written, except that FREQ is taken out of the Rule slice.  A floating
+// or UTC UNTIL is read as a DateTime, a date-only one as a Date.
+//
+// The value must follow RFC 5545: numbers have the digits the ABNF
+// allows them, and the rule must pass Validate.
+func ParseRecurRule(s string) (*RecurRule, error) {
+	r := &RecurRule{}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		eq := strings.IndexByte(part, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("recur: rule part %q has no value", part)
+		}
+		name, value := strings.ToUpper(part[:eq]), part[eq+1:]
+		if seen[name] {
+			return nil, fmt.Errorf("recur: %s given twice", name)
+		}
+		seen[name] = true
+		rule, err := parseRulePart(name, value, true)
+		if err != nil {
+			return nil, err
+		}
+		switch v := rule.(type) {
+		case Frequency:
+			r.Frequency = v
+		default:
+			r.Rules = append(r.Rules, rule)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseRulePart parses the value of the rule part name, such as "BYDAY"
+// and "-1FR".  It only checks that the value is well formed, not the
+// ranges or the digits of its numbers, so that callers reading
+// calendars found in the wild can accept rules ParseRecurRule rejects.
+func ParseRulePart(name, value string) (Rule, error) {
+	return parseRulePart(strings.ToUpper(name), value, false)
+}
+
+// parseRulePart parses a rule part.  Strict parsing limits the digits of
+// numbers to those of the ABNF.
+func parseRulePart(name, value string, strict bool) (Rule, error) {
+	digits := func(n int) int {
+		if strict {
+			return n
+		}
+		return 0
+	}
+	switch name {
+	case "FREQ":
+		f := Frequency(strings.ToUpper(value))
+		switch f {
+		case FreqSecondly, FreqMinutely, FreqHourly, FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+			return f, nil
+		}
+		return nil, fmt.Errorf("recur: unknown FREQ %q", value)
+	case "UNTIL":
+		var t Value
+		var err error
+		if len(value) == 8 {
+			t, err = ParseDate(value)
+		} else {
+			t, err = ParseDateTime(strings.ToUpper(value), nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("recur: bad UNTIL %q", value)
+		}
+		return &Until{Time: t}, nil
+	case "COUNT":
+		n, err := recurInt(name, value, 0)
+		return &Count{V: n}, err
+	case "INTERVAL":
+		n, err := recurInt(name, value, 0)
+		return &Interval{V: n}, err
+	case "BYSECOND":
+		v, err := recurInts(name, value, digits(2))
+		return &BySecond{V: v}, err
+	case "BYMINUTE":
+		v, err := recurInts(name, value, digits(2))
+		return &ByMinute{V: v}, err
+	case "BYHOUR":
+		v, err := recurInts(name, value, digits(2))
+		return &ByHour{V: v}, err
+	case "BYDAY":
+		b := &ByDay{}
+		for _, item := range strings.Split(value, ",") {
+			item = strings.ToUpper(item)
+			if len(item) < 2 {
+				return nil, fmt.Errorf("recur: bad BYDAY %q", item)
+			}
+			day := WeekDay(item[len(item)-2:])
+			if _, ok := weekdays[day]; !ok {
+				return nil, fmt.Errorf("recur: bad BYDAY %q", item)
+			}
+			w := &WeekDayNum{WeekDay: day}
+			if num := item[:len(item)-2]; num != "" {
+				op, n, err := recurSigned(name, num, digits(2))
+				if err != nil {
+					return nil, err
+				}
+				w.Operator, w.OrdWk = op, n
+			}
+			b.V = append(b.V, w)
+		}
+		return b, nil
+	case "BYMONTHDAY":
+		b := &ByMonthDay{}
+		for _, item := range strings.Split(value, ",") {
+			op, n, err := recurSigned(name, item, digits(2))
+			if err != nil {
+				return nil, err
+			}
+			b.V = append(b.V, &MonthDayNum{Operator: op, OrdMoDay: n})
+		}
+		return b, nil
+	case "BYYEARDAY", "BYSETPOS":
+		var days []*YearDayNum
+		for _, item := range strings.Split(value, ",") {
+			op, n, err := recurSigned(name, item, digits(3))
+			if err != nil {
+				return nil, err
+			}
+			days = append(days, &YearDayNum{Operator: op, OrdYrDay: n})
+		}
+		if name == "BYSETPOS" {
+			return &BySetpos{V: days}, nil
+		}
+		return &ByYearDay{V: days}, nil
+	case "BYWEEKNO":
+		b := &ByWeekNo{}
+		for _, item := range strings.Split(value, ",") {
+			op, n, err := recurSigned(name, item, digits(2))
+			if err != nil {
+				return nil, err
+			}
+			b.V = append(b.V, &WeekNum{Operator: op, OrdWk: n})
+		}
+		return b, nil
+	case "BYMONTH":
+		b := &ByMonth{}
+		for _, item := range strings.Split(value, ",") {
+			leap := strings.HasSuffix(strings.ToUpper(item), "L")
+			if leap {
+				item = item[:len(item)-1]
+			}
+			n, err := recurInt(name, item, digits(2))
+			if err != nil {
+				return nil, err
+			}
+			b.V = append(b.V, n)
+			if leap {
+				for len(b.Leap) < len(b.V)-1 {
+					b.Leap = append(b.Leap, false)
+				}
+				b.Leap = append(b.Leap, true)
+			}
+		}
+		return b, nil
+	case "WKST":
+		day := WeekDay(strings.ToUpper(value))
+		if _, ok := weekdays[day]; !ok {
+			return nil, fmt.Errorf("recur: bad WKST %q", value)
+		}
+		return &Wkst{V: day}, nil
+	case "RSCALE":
+		return &RScale{V: strings.ToUpper(value)}, nil
+	case "SKIP":
+		k := Skip(strings.ToUpper(value))
+		switch k {
+		case SkipOmit, SkipBackward, SkipForward:
+			return k, nil
+		}
+		return nil, fmt.Errorf("recur: unknown SKIP %q", value)
+	}
+	return nil, fmt.Errorf("recur: unknown rule part %s", name)
+}
+
+// recurInt parses a number of 1 to digits digits, or of any number of
+// digits when digits is 0.
+func recurInt(name, value string, digits int) (int, error) {
+	if value == "" || digits > 0 && len(value) > digits || strings.Trim(value, "0123456789") != "" {
+		return 0, fmt.Errorf("recur: bad %s %q", name, value)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("recur: bad %s %q", name, value)
+	}
+	return n, nil
+}
+
+func recurInts(name, value string, digits int) ([]int, error) {
+	var v []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := recurInt(name, item, digits)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, n)
+	}
+	return v, nil
+}
+
+func recurSigned(name, value string, digits int) (Operator, int, error) {
+	var op Operator
+	switch {
+	case strings.HasPrefix(value, "+"):
+		op, value = Plus, value[1:]
+	case strings.HasPrefix(value, "-"):
+		op, value = Minus, value[1:]
+	}
+	n, err := recurInt(name, value, digits)
+	if err != nil || n == 0 {
+		return "", 0, fmt.Errorf("recur: bad %s %q", name, value)
+	}
+	return op, n, nil
+}
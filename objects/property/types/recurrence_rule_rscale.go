@@ -0,0 +1,75 @@
+package types
+
+import "strings"
+
+//   RFC 7529 Non-Gregorian Recurrence Rules in iCalendar
+//
+//   Format Definition:  This value type is extended by the following
+//      notation:
+//
+//       recur-rule-part =/ ("RSCALE" "=" rscale)
+//                        / ("SKIP" "=" skip)
+//
+//       rscale      = (iana-token  ; A CLDR-registered calendar system
+//                                  ; name.
+//                    / x-name)     ; A non-standard, experimental
+//                                  ; calendar system name.
+//                                  ; Names are case insensitive,
+//                                  ; but uppercase values are preferred.
+//
+//       skip        = ("OMIT" / "BACKWARD" / "FORWARD")
+//                    ; Optional, with default value "OMIT", and
+//                    ; MUST NOT be present unless "RSCALE" is present.
+//
+//       monthnum    = 1*2DIGIT ["L"]
+//                    ; Existing ABNF rule modified to include leap
+//                    ; month indicator suffix.
+//
+//   Description:  The "RSCALE" rule part specifies a non-Gregorian
+//      calendar system to be used for evaluating the recurrence rule.
+//      The FREQ, INTERVAL and BYxxx rule parts are then interpreted in
+//      terms of that calendar system, while "DTSTART" and "UNTIL" keep
+//      their Gregorian values.
+//
+//      The "SKIP" rule part specifies how to handle instances that would
+//      fall on a day that does not exist in the year or month of the
+//      instance, e.g. the 30th day of a 29-day lunar month, or a leap
+//      month in a year without one.  "OMIT" ignores the instance,
+//      "BACKWARD" moves it to the previous valid day (or month), and
+//      "FORWARD" moves it to the next valid day (or month).
+//
+//   Example:  Chinese New Year:
+//
+//       RRULE:RSCALE=CHINESE;FREQ=YEARLY
+//
+//      Every Ramadan, starting with the first day of the month:
+//
+//       RRULE:RSCALE=ISLAMIC-CIVIL;FREQ=YEARLY;BYMONTH=9
+//
+//      Purim Katan, the 14th day of Adar I, only in Hebrew leap years:
+//
+//       RRULE:RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=5L;BYMONTHDAY=14
+
+type RScale struct {
+	V string
+}
+
+func (r *RScale) WriteRule(s *strings.Builder) error {
+	s.WriteString("RSCALE=")
+	s.WriteString(r.V)
+	return nil
+}
+
+type Skip string
+
+const (
+	SkipOmit     Skip = "OMIT"
+	SkipBackward Skip = "BACKWARD"
+	SkipForward  Skip = "FORWARD"
+)
+
+func (k Skip) WriteRule(s *strings.Builder) error {
+	s.WriteString("SKIP=")
+	s.WriteString(string(k))
+	return nil
+}
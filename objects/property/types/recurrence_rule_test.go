@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRecurRule_Value(t *testing.T) {
@@ -42,3 +43,175 @@ func TestRecurRule_Value(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestParseRecurRule(t *testing.T) {
+	for _, s := range []string{
+		"FREQ=MONTHLY;BYDAY=-1FR;BYMONTH=1,4,7,10;COUNT=10",
+		"FREQ=WEEKLY;UNTIL=20240601T000000Z;INTERVAL=2;BYDAY=TU;WKST=SU",
+		"FREQ=YEARLY;BYWEEKNO=-53,1;BYDAY=MO",
+		"FREQ=YEARLY;BYYEARDAY=366,-366;BYSECOND=60;BYMINUTE=59;BYHOUR=23",
+		"FREQ=MONTHLY;BYDAY=+53MO;BYMONTHDAY=31,-31;BYSETPOS=-366",
+		"FREQ=DAILY;UNTIL=20240601",
+		"RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=13,5L;SKIP=FORWARD",
+	} {
+		r, err := ParseRecurRule(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		b := &strings.Builder{}
+		r.WriteValueToStrBuilder(b)
+		if b.String() != s {
+			t.Errorf("got %s, want %s", b, s)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"COUNT=10",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=FORTNIGHTLY",
+		"FREQ=DAILY;COUNT=10;UNTIL=20240601T000000Z",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101T000000Z",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;BYSECOND=61",
+		"FREQ=DAILY;BYSECOND=001",
+		"FREQ=DAILY;BYMINUTE=60",
+		"FREQ=DAILY;BYHOUR=24",
+		"FREQ=DAILY;BYHOUR=+1",
+		"FREQ=MONTHLY;BYDAY=54MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=YEARLY;BYWEEKNO=1;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-0",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=YEARLY;BYYEARDAY=367",
+		"FREQ=MONTHLY;BYYEARDAY=1",
+		"FREQ=YEARLY;BYWEEKNO=54",
+		"FREQ=MONTHLY;BYWEEKNO=1",
+		"FREQ=MONTHLY;BYWEEKNO=3",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=YEARLY;BYMONTH=0",
+		"FREQ=YEARLY;BYMONTH=5L",
+		"FREQ=YEARLY;SKIP=OMIT",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=367",
+		"FREQ=WEEKLY;WKST=XX",
+		"FREQ=DAILY;UNTIL=2024",
+		"FREQ=DAILY;X-NAME=1",
+		"FREQ=DAILY;COUNT",
+	} {
+		if _, err := ParseRecurRule(s); err == nil {
+			t.Errorf("%q parsed", s)
+		}
+	}
+
+	r := &RecurRule{Frequency: FreqDaily, Rules: []Rule{&Count{V: 1}, &Count{V: 2}}}
+	if err := r.Validate(); err == nil {
+		t.Errorf("Validate of two COUNTs succeeded")
+	}
+}
+
+func TestRecurRule_Normalize(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	zoned := &DateTime{V: time.Date(2024, 3, 15, 9, 30, 0, 0, ny), Format: LocalDateTimeFormat}
+	floating := &DateTime{V: time.Date(2024, 3, 15, 9, 30, 0, 0, time.Local), Format: LocalDateTimeFormat}
+	day := &Date{V: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		rule    string
+		dtstart Value
+		want    string
+	}{
+		{"FREQ=MONTHLY;BYDAY=FR,+1MO,MO,-1SU;WKST=MO;INTERVAL=1", nil, "FREQ=MONTHLY;BYDAY=MO,1MO,FR,-1SU"},
+		{"FREQ=MONTHLY;BYSETPOS=-1,+1,1;BYMONTHDAY=15,-1,1,15", nil, "FREQ=MONTHLY;BYMONTHDAY=-1,1,15;BYSETPOS=-1,1"},
+		{"FREQ=WEEKLY;WKST=SU;BYDAY=TU", nil, "FREQ=WEEKLY;BYDAY=TU"},
+		{"FREQ=WEEKLY;WKST=SU;BYDAY=TU;INTERVAL=2", nil, "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;WKST=SU"},
+		{"RSCALE=HEBREW;FREQ=YEARLY;SKIP=OMIT;BYMONTH=6,5L,5", nil, "RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=5,5L,6"},
+		{"FREQ=MONTHLY;BYMONTHDAY=15;BYHOUR=9;BYMINUTE=30;BYSECOND=0", zoned, "FREQ=MONTHLY"},
+		{"FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=15", day, "FREQ=YEARLY"},
+		{"FREQ=YEARLY;BYMONTH=3,6;BYMONTHDAY=15", day, "FREQ=YEARLY;BYMONTH=3,6"},
+		{"FREQ=YEARLY;BYMONTHDAY=15;COUNT=4", day, "FREQ=YEARLY;COUNT=4;BYMONTHDAY=15"},
+		{"FREQ=YEARLY;BYMONTHDAY=15;BYMONTH=6", zoned, "FREQ=YEARLY;BYMONTH=6"},
+		{"FREQ=YEARLY;BYMONTH=3;BYDAY=FR", day, "FREQ=YEARLY;BYDAY=FR;BYMONTH=3"},
+		{"FREQ=WEEKLY;BYDAY=FR;BYHOUR=9,17", day, "FREQ=WEEKLY"},
+		{"FREQ=WEEKLY;BYDAY=FR;BYSETPOS=1", day, "FREQ=WEEKLY;BYDAY=FR;BYSETPOS=1"},
+		{"FREQ=HOURLY;BYMINUTE=30", zoned, "FREQ=HOURLY"},
+		{"FREQ=HOURLY;BYHOUR=9", zoned, "FREQ=HOURLY;BYHOUR=9"},
+		{"FREQ=DAILY;UNTIL=20240401", zoned, "FREQ=DAILY;UNTIL=20240402T035959Z"},
+		{"FREQ=DAILY;UNTIL=20240401T093000", zoned, "FREQ=DAILY;UNTIL=20240401T133000Z"},
+		{"FREQ=DAILY;UNTIL=20240401T133000Z", floating, "FREQ=DAILY;UNTIL=" + time.Date(2024, 4, 1, 13, 30, 0, 0, time.UTC).In(time.Local).Format(LocalDateTimeFormat)},
+		{"FREQ=DAILY;UNTIL=20240401T033000Z", day, "FREQ=DAILY;UNTIL=20240401"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurRule(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := &strings.Builder{}
+		n := r.Normalize(tt.dtstart)
+		n.WriteValueToStrBuilder(b)
+		if b.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.rule, b, tt.want)
+		}
+		// normalizing keeps the meaning
+		if tt.dtstart != nil {
+			expect(t, tt.rule+" normalized", instances(t, n, tt.dtstart, 20), instances(t, r, tt.dtstart, 20))
+		}
+	}
+
+	r, _ := ParseRecurRule("FREQ=WEEKLY;COUNT=3;BYDAY=MO,FR")
+	u, err := r.CountToUntil(zoned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &strings.Builder{}
+	u.WriteValueToStrBuilder(b)
+	if b.String() != "FREQ=WEEKLY;UNTIL=20240322T133000Z;BYDAY=MO,FR" {
+		t.Errorf("CountToUntil: %s", b)
+	}
+	c, err := u.UntilToCount(zoned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	c.WriteValueToStrBuilder(b)
+	if b.String() != "FREQ=WEEKLY;COUNT=3;BYDAY=MO,FR" {
+		t.Errorf("UntilToCount: %s", b)
+	}
+	if u, _ := r.CountToUntil(day); u != nil {
+		b.Reset()
+		u.WriteValueToStrBuilder(b)
+		if b.String() != "FREQ=WEEKLY;UNTIL=20240322;BYDAY=MO,FR" {
+			t.Errorf("CountToUntil of a date: %s", b)
+		}
+	}
+	// DTSTART, a Friday, is the first of the three instances
+	r, _ = ParseRecurRule("FREQ=WEEKLY;COUNT=3;BYDAY=MO")
+	if u, err = r.CountToUntil(zoned); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	u.WriteValueToStrBuilder(b)
+	if b.String() != "FREQ=WEEKLY;UNTIL=20240325T133000Z;BYDAY=MO" {
+		t.Errorf("CountToUntil of an unmatched DTSTART: %s", b)
+	}
+	if c, err = u.UntilToCount(zoned); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	c.WriteValueToStrBuilder(b)
+	if b.String() != "FREQ=WEEKLY;COUNT=3;BYDAY=MO" {
+		t.Errorf("UntilToCount of an unmatched DTSTART: %s", b)
+	}
+	r, _ = ParseRecurRule("FREQ=DAILY;UNTIL=20240301")
+	if _, err := r.UntilToCount(day); err != ErrNoInstances {
+		t.Errorf("UntilToCount without instances: %v", err)
+	}
+}
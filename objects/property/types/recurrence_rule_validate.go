@@ -0,0 +1,255 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//   RFC 5545 3.3.10.  Recurrence Rule
+//
+//      The BYDAY rule part MUST NOT be specified with a numeric value when
+//      the FREQ rule part is not set to MONTHLY or YEARLY.  Furthermore,
+//      the BYDAY rule part MUST NOT be specified with a numeric value
+//      with the FREQ rule part set to YEARLY when the BYWEEKNO rule part
+//      is specified.
+//
+//      The BYMONTHDAY rule part MUST NOT be specified when the FREQ rule
+//      part is set to WEEKLY.
+//
+//      The BYYEARDAY rule part MUST NOT be specified when the FREQ rule
+//      part is set to DAILY, WEEKLY, or MONTHLY.
+//
+//      This rule part MUST NOT be used when the FREQ rule part is set to
+//      anything other than YEARLY.  [BYWEEKNO]
+//
+//      It MUST only be used in conjunction with another BYxxx rule part.
+//      [BYSETPOS]
+
+// Validate checks r against RFC 5545 and RFC 7529: FREQ is given, no
+// rule part is given twice, UNTIL and COUNT are not both given, the
+// values are within the ranges of the ABNF, and rule parts are only
+// combined with the frequencies they are allowed with.  Leap months and
+// SKIP need RSCALE, which also allows month 13.
+func (r *RecurRule) Validate() error {
+	switch r.Frequency {
+	case FreqSecondly, FreqMinutely, FreqHourly, FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+	case "":
+		return fmt.Errorf("recur: FREQ is required")
+	default:
+		return fmt.Errorf("recur: unknown FREQ %q", string(r.Frequency))
+	}
+	seen := map[reflect.Type]bool{}
+	rscale := false
+	for _, rule := range r.Rules {
+		t := reflect.TypeOf(rule)
+		if seen[t] {
+			return fmt.Errorf("recur: %s given twice", ruleName(rule))
+		}
+		seen[t] = true
+		if _, ok := rule.(*RScale); ok {
+			rscale = true
+		}
+	}
+	if seen[reflect.TypeOf(&Until{})] && seen[reflect.TypeOf(&Count{})] {
+		return fmt.Errorf("recur: UNTIL and COUNT must not both be given")
+	}
+
+	by := false
+	for _, rule := range r.Rules {
+		var err error
+		switch v := rule.(type) {
+		case *Until:
+			switch v.Time.(type) {
+			case *Date, *DateTime:
+			default:
+				err = fmt.Errorf("recur: UNTIL must be a DATE or DATE-TIME")
+			}
+		case *Count:
+			err = inRange("COUNT", v.V, 1, -1)
+		case *Interval:
+			err = inRange("INTERVAL", v.V, 1, -1)
+		case *BySecond:
+			by = true
+			for _, n := range v.V {
+				if err = inRange("BYSECOND", n, 0, 60); err != nil {
+					break
+				}
+			}
+		case *ByMinute:
+			by = true
+			for _, n := range v.V {
+				if err = inRange("BYMINUTE", n, 0, 59); err != nil {
+					break
+				}
+			}
+		case *ByHour:
+			by = true
+			for _, n := range v.V {
+				if err = inRange("BYHOUR", n, 0, 23); err != nil {
+					break
+				}
+			}
+		case *ByDay:
+			by = true
+			for _, d := range v.V {
+				if err = validWeekDay("BYDAY", d.WeekDay); err != nil {
+					break
+				}
+				if d.OrdWk == 0 && d.Operator == "" {
+					continue
+				}
+				if err = signedInRange("BYDAY", d.Operator, d.OrdWk, 53); err != nil {
+					break
+				}
+				if r.Frequency != FreqMonthly && r.Frequency != FreqYearly {
+					err = fmt.Errorf("recur: BYDAY with a number needs FREQ=MONTHLY or YEARLY")
+				} else if r.Frequency == FreqYearly && seen[reflect.TypeOf(&ByWeekNo{})] {
+					err = fmt.Errorf("recur: BYDAY with a number must not be given with BYWEEKNO")
+				}
+				if err != nil {
+					break
+				}
+			}
+		case *ByMonthDay:
+			by = true
+			if r.Frequency == FreqWeekly {
+				err = fmt.Errorf("recur: BYMONTHDAY must not be given with FREQ=WEEKLY")
+			}
+			for _, d := range v.V {
+				if err == nil {
+					err = signedInRange("BYMONTHDAY", d.Operator, d.OrdMoDay, 31)
+				}
+			}
+		case *ByYearDay:
+			by = true
+			switch r.Frequency {
+			case FreqDaily, FreqWeekly, FreqMonthly:
+				err = fmt.Errorf("recur: BYYEARDAY must not be given with FREQ=%s", string(r.Frequency))
+			}
+			for _, d := range v.V {
+				if err == nil {
+					err = signedInRange("BYYEARDAY", d.Operator, d.OrdYrDay, 366)
+				}
+			}
+		case *ByWeekNo:
+			by = true
+			if r.Frequency != FreqYearly {
+				err = fmt.Errorf("recur: BYWEEKNO needs FREQ=YEARLY")
+			}
+			for _, w := range v.V {
+				if err == nil {
+					err = signedInRange("BYWEEKNO", w.Operator, w.OrdWk, 53)
+				}
+			}
+		case *ByMonth:
+			by = true
+			max := 12
+			if rscale {
+				max = 13
+			}
+			for i, n := range v.V {
+				if err = inRange("BYMONTH", n, 1, max); err != nil {
+					break
+				}
+				if v.IsLeap(i) && !rscale {
+					err = fmt.Errorf("recur: leap month %dL needs RSCALE", n)
+					break
+				}
+			}
+		case *BySetpos:
+			for _, d := range v.V {
+				if err = signedInRange("BYSETPOS", d.Operator, d.OrdYrDay, 366); err != nil {
+					break
+				}
+			}
+		case *Wkst:
+			err = validWeekDay("WKST", v.V)
+		case *RScale:
+			if v.V == "" {
+				err = fmt.Errorf("recur: RSCALE has no value")
+			}
+		case Skip:
+			switch v {
+			case SkipOmit, SkipBackward, SkipForward:
+				if !rscale {
+					err = fmt.Errorf("recur: SKIP needs RSCALE")
+				}
+			default:
+				err = fmt.Errorf("recur: unknown SKIP %q", string(v))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if seen[reflect.TypeOf(&BySetpos{})] && !by {
+		return fmt.Errorf("recur: BYSETPOS needs another BYxxx rule part")
+	}
+	return nil
+}
+
+// ruleName returns the name of the rule part of rule.
+func ruleName(rule Rule) string {
+	switch rule.(type) {
+	case Frequency:
+		return "FREQ"
+	case *Until:
+		return "UNTIL"
+	case *Count:
+		return "COUNT"
+	case *Interval:
+		return "INTERVAL"
+	case *BySecond:
+		return "BYSECOND"
+	case *ByMinute:
+		return "BYMINUTE"
+	case *ByHour:
+		return "BYHOUR"
+	case *ByDay:
+		return "BYDAY"
+	case *ByMonthDay:
+		return "BYMONTHDAY"
+	case *ByYearDay:
+		return "BYYEARDAY"
+	case *ByWeekNo:
+		return "BYWEEKNO"
+	case *ByMonth:
+		return "BYMONTH"
+	case *BySetpos:
+		return "BYSETPOS"
+	case *Wkst:
+		return "WKST"
+	case *RScale:
+		return "RSCALE"
+	case Skip:
+		return "SKIP"
+	}
+	return fmt.Sprintf("%T", rule)
+}
+
+// inRange checks that n is within [min, max]; a negative max is no
+// bound.
+func inRange(name string, n, min, max int) error {
+	if n < min || max >= 0 && n > max {
+		return fmt.Errorf("recur: %s %d out of range", name, n)
+	}
+	return nil
+}
+
+// signedInRange checks a signed value of 1 to max or -max to -1.
+func signedInRange(name string, o Operator, n, max int) error {
+	if o != "" && o != Plus && o != Minus {
+		return fmt.Errorf("recur: bad %s sign %q", name, string(o))
+	}
+	if n < 1 || n > max {
+		return fmt.Errorf("recur: %s %s%d out of range", name, string(o), n)
+	}
+	return nil
+}
+
+func validWeekDay(name string, d WeekDay) error {
+	if _, ok := weekdays[d]; !ok {
+		return fmt.Errorf("recur: bad %s %q", name, string(d))
+	}
+	return nil
+}
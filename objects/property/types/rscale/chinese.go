@@ -0,0 +1,144 @@
+package rscale
+
+// Chinese is the Chinese lunisolar calendar as observed in China.
+//
+// Computing the calendar needs the instants of new moons and solar terms
+// at the meridian of Beijing.  The months of the years 1900 to 2100 are
+// taken from the table below instead, which holds the result of those
+// computations; years outside that range are not supported.  Months
+// returns nil for them, and the iterators of recurrence rules that run
+// past 2100 stop with an error instead of expanding further.
+//
+// Years are numbered by the Gregorian year in which they start, so the
+// year 2023 begins on the new year's day 2023-01-22.
+var Chinese Calendar = chinese{}
+
+type chinese struct{}
+
+const (
+	chineseFirstYear = 1900
+	chineseLastYear  = 2100
+	// fixed day number of 1900-01-31, the first day of the year 1900
+	chineseFirstNewYear = 693626
+)
+
+// chineseYears describes one year per entry:
+//
+//	bits 0-3   the number of the month followed by a leap month, or 0
+//	bits 4-15  the length of the months 12 down to 1, set bits are 30 days
+//	bit 16     the length of the leap month
+var chineseYears = [...]uint32{
+	0x04bd8, 0x04ae0, 0x0a570, 0x054d5, 0x0d260, 0x0d950, 0x16554, 0x056a0, 0x09ad0, 0x055d2, // 1900
+	0x04ae0, 0x0a5b6, 0x0a4d0, 0x0d250, 0x1d255, 0x0b540, 0x0d6a0, 0x0ada2, 0x095b0, 0x14977, // 1910
+	0x04970, 0x0a4b0, 0x0b4b5, 0x06a50, 0x06d40, 0x1ab54, 0x02b60, 0x09570, 0x052f2, 0x04970, // 1920
+	0x06566, 0x0d4a0, 0x0ea50, 0x16a95, 0x05ad0, 0x02b60, 0x186e3, 0x092e0, 0x1c8d7, 0x0c950, // 1930
+	0x0d4a0, 0x1d8a6, 0x0b550, 0x056a0, 0x1a5b4, 0x025d0, 0x092d0, 0x0d2b2, 0x0a950, 0x0b557, // 1940
+	0x06ca0, 0x0b550, 0x15355, 0x04da0, 0x0a5b0, 0x14573, 0x052b0, 0x0a9a8, 0x0e950, 0x06aa0, // 1950
+	0x0aea6, 0x0ab50, 0x04b60, 0x0aae4, 0x0a570, 0x05260, 0x0f263, 0x0d950, 0x05b57, 0x056a0, // 1960
+	0x096d0, 0x04dd5, 0x04ad0, 0x0a4d0, 0x0d4d4, 0x0d250, 0x0d558, 0x0b540, 0x0b6a0, 0x195a6, // 1970
+	0x095b0, 0x049b0, 0x0a974, 0x0a4b0, 0x0b27a, 0x06a50, 0x06d40, 0x0af46, 0x0ab60, 0x09570, // 1980
+	0x04af5, 0x04970, 0x064b0, 0x074a3, 0x0ea50, 0x06b58, 0x05ac0, 0x0ab60, 0x096d5, 0x092e0, // 1990
+	0x0c960, 0x0d954, 0x0d4a0, 0x0da50, 0x07552, 0x056a0, 0x0abb7, 0x025d0, 0x092d0, 0x0cab5, // 2000
+	0x0a950, 0x0b4a0, 0x0baa4, 0x0ad50, 0x055d9, 0x04ba0, 0x0a5b0, 0x15176, 0x052b0, 0x0a930, // 2010
+	0x07954, 0x06aa0, 0x0ad50, 0x05b52, 0x04b60, 0x0a6e6, 0x0a4e0, 0x0d260, 0x0ea65, 0x0d530, // 2020
+	0x05aa0, 0x076a3, 0x096d0, 0x04afb, 0x04ad0, 0x0a4d0, 0x1d0b6, 0x0d250, 0x0d520, 0x0dd45, // 2030
+	0x0b5a0, 0x056d0, 0x055b2, 0x049b0, 0x0a577, 0x0a4b0, 0x0aa50, 0x1b255, 0x06d20, 0x0ada0, // 2040
+	0x14b63, 0x09370, 0x049f8, 0x04970, 0x064b0, 0x168a6, 0x0ea50, 0x06b20, 0x1a6c4, 0x0aae0, // 2050
+	0x092e0, 0x0d2e3, 0x0c960, 0x0d557, 0x0d4a0, 0x0da50, 0x05d55, 0x056a0, 0x0a6d0, 0x055d4, // 2060
+	0x052d0, 0x0a9b8, 0x0a950, 0x0b4a0, 0x0b6a6, 0x0ad50, 0x055a0, 0x0aba4, 0x0a5b0, 0x052b0, // 2070
+	0x0b273, 0x06930, 0x07337, 0x06aa0, 0x0ad50, 0x14b55, 0x04b60, 0x0a570, 0x054e4, 0x0d160, // 2080
+	0x0e968, 0x0d520, 0x0daa0, 0x16aa6, 0x056d0, 0x04ae0, 0x0a9d4, 0x0a2d0, 0x0d150, 0x0f252, // 2090
+	0x0d520, // 2100
+}
+
+// chineseNewYears holds the fixed day number of the first day of every
+// supported year, plus the day after the last one.
+var chineseNewYears []int
+
+func init() {
+	chineseNewYears = make([]int, 0, len(chineseYears)+1)
+	fixed := chineseFirstNewYear
+	for year := chineseFirstYear; year <= chineseLastYear; year++ {
+		chineseNewYears = append(chineseNewYears, fixed)
+		for _, m := range Chinese.Months(year) {
+			fixed += Chinese.DaysInMonth(year, m)
+		}
+	}
+	chineseNewYears = append(chineseNewYears, fixed)
+}
+
+func (chinese) Name() string {
+	return "CHINESE"
+}
+
+func chineseLeapMonth(year int) int {
+	return int(chineseYears[year-chineseFirstYear] & 0xf)
+}
+
+func (chinese) Months(year int) []Month {
+	if year < chineseFirstYear || year > chineseLastYear {
+		return nil
+	}
+	leap := chineseLeapMonth(year)
+	months := make([]Month, 0, 13)
+	for n := 1; n <= 12; n++ {
+		months = append(months, Month{Number: n})
+		if n == leap {
+			months = append(months, Month{Number: n, Leap: true})
+		}
+	}
+	return months
+}
+
+func (chinese) DaysInMonth(year int, month Month) int {
+	if year < chineseFirstYear || year > chineseLastYear || month.Number < 1 || month.Number > 12 {
+		return 0
+	}
+	info := chineseYears[year-chineseFirstYear]
+	if month.Leap {
+		if chineseLeapMonth(year) != month.Number {
+			return 0
+		}
+		if info&0x10000 != 0 {
+			return 30
+		}
+		return 29
+	}
+	if info&(0x10000>>uint(month.Number)) != 0 {
+		return 30
+	}
+	return 29
+}
+
+func (c chinese) ToFixed(d Date) int {
+	if d.Year < chineseFirstYear || d.Year > chineseLastYear {
+		return 0
+	}
+	fixed := chineseNewYears[d.Year-chineseFirstYear]
+	for _, m := range c.Months(d.Year) {
+		if m == d.Month {
+			break
+		}
+		fixed += c.DaysInMonth(d.Year, m)
+	}
+	return fixed + d.Day - 1
+}
+
+func (c chinese) FromFixed(fixed int) Date {
+	if fixed < chineseNewYears[0] || fixed >= chineseNewYears[len(chineseNewYears)-1] {
+		return Date{}
+	}
+	year := chineseFirstYear
+	for chineseNewYears[year-chineseFirstYear+1] <= fixed {
+		year++
+	}
+	day := fixed - chineseNewYears[year-chineseFirstYear]
+	for _, m := range c.Months(year) {
+		n := c.DaysInMonth(year, m)
+		if day < n {
+			return Date{Year: year, Month: m, Day: day + 1}
+		}
+		day -= n
+	}
+	return Date{}
+}
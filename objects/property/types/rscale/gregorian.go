@@ -0,0 +1,78 @@
+package rscale
+
+// Gregorian is the proleptic Gregorian calendar, the default calendar
+// scale of iCalendar.
+var Gregorian Calendar = gregorian{}
+
+type gregorian struct{}
+
+var gregorianMonths = []Month{{Number: 1}, {Number: 2}, {Number: 3}, {Number: 4}, {Number: 5}, {Number: 6},
+	{Number: 7}, {Number: 8}, {Number: 9}, {Number: 10}, {Number: 11}, {Number: 12}}
+
+func (gregorian) Name() string {
+	return "GREGORIAN"
+}
+
+func (gregorian) Months(year int) []Month {
+	return gregorianMonths
+}
+
+func isGregorianLeapYear(year int) bool {
+	return mod(year, 4) == 0 && (mod(year, 100) != 0 || mod(year, 400) == 0)
+}
+
+func (gregorian) DaysInMonth(year int, month Month) int {
+	if month.Leap || month.Number < 1 || month.Number > 12 {
+		return 0
+	}
+	switch month.Number {
+	case 2:
+		if isGregorianLeapYear(year) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	}
+	return 31
+}
+
+func (gregorian) ToFixed(d Date) int {
+	y := d.Year - 1
+	fixed := 365*y + floorDiv(y, 4) - floorDiv(y, 100) + floorDiv(y, 400) + floorDiv(367*d.Month.Number-362, 12)
+	if d.Month.Number > 2 {
+		if isGregorianLeapYear(d.Year) {
+			fixed--
+		} else {
+			fixed -= 2
+		}
+	}
+	return fixed + d.Day
+}
+
+func (g gregorian) FromFixed(fixed int) Date {
+	d0 := fixed - 1
+	n400 := floorDiv(d0, 146097)
+	d1 := mod(d0, 146097)
+	n100 := d1 / 36524
+	d2 := d1 % 36524
+	n4 := d2 / 1461
+	d3 := d2 % 1461
+	n1 := d3 / 365
+	year := 400*n400 + 100*n100 + 4*n4 + n1
+	if n100 != 4 && n1 != 4 {
+		year++
+	}
+	prior := fixed - g.ToFixed(Date{Year: year, Month: Month{Number: 1}, Day: 1})
+	correction := 0
+	if fixed >= g.ToFixed(Date{Year: year, Month: Month{Number: 3}, Day: 1}) {
+		if isGregorianLeapYear(year) {
+			correction = 1
+		} else {
+			correction = 2
+		}
+	}
+	month := (12*(prior+correction) + 373) / 367
+	day := fixed - g.ToFixed(Date{Year: year, Month: Month{Number: month}, Day: 1}) + 1
+	return Date{Year: year, Month: Month{Number: month}, Day: day}
+}
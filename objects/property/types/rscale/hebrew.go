@@ -0,0 +1,172 @@
+package rscale
+
+// Hebrew is the arithmetic Hebrew calendar.  Months are numbered as in
+// CLDR starting with Tishri: 1 Tishri, 2 Heshvan, 3 Kislev, 4 Tevet,
+// 5 Shevat, 5L Adar I (leap years only), 6 Adar (Adar II in leap years),
+// 7 Nisan, 8 Iyar, 9 Sivan, 10 Tamuz, 11 Av and 12 Elul.
+//
+// The computations follow Reingold & Dershowitz, "Calendrical
+// Calculations", which numbers the months from Nisan; the conversion
+// between both numberings is done by toCC and fromCC.
+var Hebrew Calendar = hebrew{}
+
+type hebrew struct{}
+
+const hebrewEpoch = -1373427
+
+func (hebrew) Name() string {
+	return "HEBREW"
+}
+
+func isHebrewLeapYear(year int) bool {
+	return mod(7*year+1, 19) < 7
+}
+
+var (
+	hebrewMonths     = []Month{{Number: 1}, {Number: 2}, {Number: 3}, {Number: 4}, {Number: 5}, {Number: 6}, {Number: 7}, {Number: 8}, {Number: 9}, {Number: 10}, {Number: 11}, {Number: 12}}
+	hebrewLeapMonths = []Month{{Number: 1}, {Number: 2}, {Number: 3}, {Number: 4}, {Number: 5}, {Number: 5, Leap: true}, {Number: 6}, {Number: 7}, {Number: 8}, {Number: 9}, {Number: 10}, {Number: 11}, {Number: 12}}
+)
+
+func (hebrew) Months(year int) []Month {
+	if isHebrewLeapYear(year) {
+		return hebrewLeapMonths
+	}
+	return hebrewMonths
+}
+
+// toCC converts a CLDR month into the month number used by Calendrical
+// Calculations, returning 0 for months that do not occur in year.
+func (hebrew) toCC(year int, m Month) int {
+	leap := isHebrewLeapYear(year)
+	switch {
+	case m.Leap:
+		if m.Number == 5 && leap {
+			return 12
+		}
+		return 0
+	case m.Number >= 1 && m.Number <= 5:
+		return m.Number + 6
+	case m.Number == 6:
+		if leap {
+			return 13
+		}
+		return 12
+	case m.Number >= 7 && m.Number <= 12:
+		return m.Number - 6
+	}
+	return 0
+}
+
+func (hebrew) fromCC(year int, cc int) Month {
+	switch {
+	case cc >= 7 && cc <= 11:
+		return Month{Number: cc - 6}
+	case cc == 12:
+		if isHebrewLeapYear(year) {
+			return Month{Number: 5, Leap: true}
+		}
+		return Month{Number: 6}
+	case cc == 13:
+		return Month{Number: 6}
+	}
+	return Month{Number: cc + 6}
+}
+
+func hebrewElapsedDays(year int) int {
+	monthsElapsed := floorDiv(235*year-234, 19)
+	partsElapsed := 12084 + 13753*monthsElapsed
+	days := 29*monthsElapsed + floorDiv(partsElapsed, 25920)
+	if mod(3*(days+1), 7) < 3 {
+		return days + 1
+	}
+	return days
+}
+
+func hebrewYearLengthCorrection(year int) int {
+	ny0 := hebrewElapsedDays(year - 1)
+	ny1 := hebrewElapsedDays(year)
+	ny2 := hebrewElapsedDays(year + 1)
+	if ny2-ny1 == 356 {
+		return 2
+	}
+	if ny1-ny0 == 382 {
+		return 1
+	}
+	return 0
+}
+
+func hebrewNewYear(year int) int {
+	return hebrewEpoch + hebrewElapsedDays(year) + hebrewYearLengthCorrection(year)
+}
+
+func hebrewLastMonth(year int) int {
+	if isHebrewLeapYear(year) {
+		return 13
+	}
+	return 12
+}
+
+// hebrewLastDay returns the length of the month cc, numbered as in
+// Calendrical Calculations.
+func hebrewLastDay(year, cc int) int {
+	daysInYear := hebrewNewYear(year+1) - hebrewNewYear(year)
+	switch {
+	case cc == 2 || cc == 4 || cc == 6 || cc == 10 || cc == 13:
+		return 29
+	case cc == 12 && !isHebrewLeapYear(year):
+		return 29
+	case cc == 8 && daysInYear != 355 && daysInYear != 385:
+		// Heshvan is long only in complete years
+		return 29
+	case cc == 9 && (daysInYear == 353 || daysInYear == 383):
+		// Kislev is short in deficient years
+		return 29
+	}
+	return 30
+}
+
+func (h hebrew) DaysInMonth(year int, month Month) int {
+	cc := h.toCC(year, month)
+	if cc == 0 {
+		return 0
+	}
+	return hebrewLastDay(year, cc)
+}
+
+func hebrewFixed(year, cc, day int) int {
+	fixed := hebrewNewYear(year) + day - 1
+	if cc < 7 {
+		for m := 7; m <= hebrewLastMonth(year); m++ {
+			fixed += hebrewLastDay(year, m)
+		}
+		for m := 1; m < cc; m++ {
+			fixed += hebrewLastDay(year, m)
+		}
+		return fixed
+	}
+	for m := 7; m < cc; m++ {
+		fixed += hebrewLastDay(year, m)
+	}
+	return fixed
+}
+
+func (h hebrew) ToFixed(d Date) int {
+	return hebrewFixed(d.Year, h.toCC(d.Year, d.Month), d.Day)
+}
+
+func (h hebrew) FromFixed(fixed int) Date {
+	approx := floorDiv((fixed-hebrewEpoch)*98496, 35975351) + 1
+	year := approx - 1
+	for hebrewNewYear(year+1) <= fixed {
+		year++
+	}
+	start := 1
+	if fixed < hebrewFixed(year, 1, 1) {
+		start = 7
+	}
+	cc := start
+	for fixed > hebrewFixed(year, cc, hebrewLastDay(year, cc)) {
+		cc++
+	}
+	return Date{Year: year, Month: h.fromCC(year, cc), Day: fixed - hebrewFixed(year, cc, 1) + 1}
+}
@@ -0,0 +1,54 @@
+package rscale
+
+// The tabular Islamic calendars have twelve months of alternately 30 and
+// 29 days.  In the 11 leap years of every 30 year cycle the twelfth
+// month, Dhu al-Hijja, has 30 days.  "ISLAMIC-CIVIL" counts from the
+// civil epoch (Friday, July 16, 622 Julian), "ISLAMIC-TBLA" from the
+// astronomical epoch one day earlier.
+var (
+	IslamicCivil   Calendar = islamic{name: "ISLAMIC-CIVIL", epoch: 227015}
+	IslamicTabular Calendar = islamic{name: "ISLAMIC-TBLA", epoch: 227014}
+)
+
+type islamic struct {
+	name  string
+	epoch int
+}
+
+func (i islamic) Name() string {
+	return i.name
+}
+
+func (islamic) Months(year int) []Month {
+	return gregorianMonths
+}
+
+func isIslamicLeapYear(year int) bool {
+	return mod(14+11*year, 30) < 11
+}
+
+func (islamic) DaysInMonth(year int, month Month) int {
+	if month.Leap || month.Number < 1 || month.Number > 12 {
+		return 0
+	}
+	if month.Number == 12 && isIslamicLeapYear(year) {
+		return 30
+	}
+	if month.Number%2 == 1 {
+		return 30
+	}
+	return 29
+}
+
+func (i islamic) ToFixed(d Date) int {
+	m := d.Month.Number
+	return d.Day + 29*(m-1) + floorDiv(6*m-1, 11) + (d.Year-1)*354 + floorDiv(3+11*d.Year, 30) + i.epoch - 1
+}
+
+func (i islamic) FromFixed(fixed int) Date {
+	year := floorDiv(30*(fixed-i.epoch)+10646, 10631)
+	prior := fixed - i.ToFixed(Date{Year: year, Month: Month{Number: 1}, Day: 1})
+	month := floorDiv(11*prior+330, 325)
+	day := fixed - i.ToFixed(Date{Year: year, Month: Month{Number: month}, Day: 1}) + 1
+	return Date{Year: year, Month: Month{Number: month}, Day: day}
+}
@@ -0,0 +1,133 @@
+package rscale
+
+import (
+	"strings"
+	"time"
+)
+
+//   RFC 7529 Non-Gregorian Recurrence Rules in iCalendar
+//
+//   The "RSCALE" rule part identifies the calendar system that is used
+//   to evaluate a recurrence rule.  Its value is one of the calendar
+//   system names defined by the Unicode Common Locale Data Repository
+//   [CLDR], e.g. "GREGORIAN", "CHINESE", "HEBREW" or "ISLAMIC-CIVIL".
+//
+//   The "DTSTART", "RDATE", "EXDATE" and "UNTIL" values are still given
+//   as Gregorian dates; only the BYxxx rule parts, the FREQ periods and
+//   the INTERVAL are evaluated in the calendar system named by RSCALE.
+//
+//   A calendar is described here by its months: a year is an ordered
+//   list of months, some of which may be leap months (written with the
+//   "L" suffix in BYMONTH, e.g. "5L" for the Hebrew Adar I), and every
+//   day is identified by a fixed day number so that the different
+//   calendar systems can be converted into each other.
+
+// Month identifies a month within a year of a calendar system.
+// Leap months share the Number of the month they follow.
+type Month struct {
+	Number int
+	Leap   bool
+}
+
+// Date is a day in a calendar system.
+type Date struct {
+	Year  int
+	Month Month
+	Day   int
+}
+
+// Calendar is a calendar system usable by the RSCALE rule part.
+type Calendar interface {
+	// Name returns the upper case CLDR name of the calendar system.
+	Name() string
+	// Months returns the months of the year in order, or nil when the
+	// year is out of the range supported by the calendar.
+	Months(year int) []Month
+	// DaysInMonth returns the length of month in year, or 0 when the
+	// month does not occur in that year.
+	DaysInMonth(year int, month Month) int
+	// ToFixed returns the fixed day number of a valid date.
+	ToFixed(d Date) int
+	// FromFixed returns the date of a fixed day number.  The zero Date
+	// is returned when fixed is out of the supported range.
+	FromFixed(fixed int) Date
+}
+
+var calendars = map[string]Calendar{}
+
+// Register makes c available to Lookup under its name.
+func Register(c Calendar) {
+	calendars[strings.ToUpper(c.Name())] = c
+}
+
+// Lookup returns the calendar system with the given RSCALE name; the
+// name is matched case-insensitively.
+func Lookup(name string) (Calendar, bool) {
+	c, ok := calendars[strings.ToUpper(name)]
+	return c, ok
+}
+
+func init() {
+	Register(Gregorian)
+	Register(Chinese)
+	Register(Hebrew)
+	Register(IslamicCivil)
+	Register(IslamicTabular)
+}
+
+// Fixed day numbers count days from the proleptic Gregorian date
+// 0001-01-01, which is day 1 and a Monday.
+const unixEpochFixed = 719163
+
+// FixedFromTime returns the fixed day number of the calendar date of t
+// in its own location.
+func FixedFromTime(t time.Time) int {
+	y, m, d := t.Date()
+	return Gregorian.ToFixed(Date{Year: y, Month: Month{Number: int(m)}, Day: d})
+}
+
+// TimeFromFixed returns the time of day given by hour, minute and sec on
+// the day fixed, in loc.
+func TimeFromFixed(fixed, hour, minute, sec int, loc *time.Location) time.Time {
+	d := Gregorian.FromFixed(fixed)
+	return time.Date(d.Year, time.Month(d.Month.Number), d.Day, hour, minute, sec, 0, loc)
+}
+
+// Weekday returns the day of the week of the day fixed.
+func Weekday(fixed int) time.Weekday {
+	return time.Weekday(mod(fixed, 7))
+}
+
+// YearStart returns the fixed day number of the first day of year.
+func YearStart(c Calendar, year int) int {
+	months := c.Months(year)
+	if len(months) == 0 {
+		return 0
+	}
+	return c.ToFixed(Date{Year: year, Month: months[0], Day: 1})
+}
+
+// DaysInYear returns the number of days of year.
+func DaysInYear(c Calendar, year int) int {
+	n := 0
+	for _, m := range c.Months(year) {
+		n += c.DaysInMonth(year, m)
+	}
+	return n
+}
+
+func mod(a, b int) int {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
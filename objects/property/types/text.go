@@ -67,3 +67,53 @@ func (t *Text) WriteValueToStrBuilder(s *strings.Builder) error {
 func NewText(text string) *Text {
 	return &Text{V: text}
 }
+
+// EscapeText returns s in the escaped form of a TEXT value: backslashes,
+// semicolons, commas and newlines are preceded by a backslash.
+func EscapeText(s string) string {
+	if !strings.ContainsAny(s, "\\;,\n\r") {
+		return s
+	}
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', ';', ',':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString("\\n")
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				continue
+			}
+			b.WriteString("\\n")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// UnescapeText reverses EscapeText.  Unknown escapes keep the escaped
+// character.
+func UnescapeText(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
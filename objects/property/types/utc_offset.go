@@ -39,7 +39,9 @@ type UTCOffset struct {
 }
 
 func (u *UTCOffset) WriteValueToStrBuilder(s *strings.Builder) error {
-	if !u.Positive {
+	if u.Positive {
+		s.WriteString("+")
+	} else {
 		s.WriteString("-")
 	}
 	s.WriteString(fmt.Sprintf("%02d", u.Hour))
@@ -58,3 +60,30 @@ func NewUTCOffset(positive bool, hour, minute, second int) *UTCOffset {
 		Second:   second,
 	}
 }
+
+// Seconds returns the offset in seconds east of UTC.
+func (u *UTCOffset) Seconds() int {
+	s := u.Hour*3600 + u.Minute*60 + u.Second
+	if !u.Positive {
+		return -s
+	}
+	return s
+}
+
+// ParseUTCOffset parses a UTC-OFFSET value such as "-0500" or "+013045".
+func ParseUTCOffset(s string) (*UTCOffset, error) {
+	if (len(s) != 5 && len(s) != 7) || (s[0] != '+' && s[0] != '-') {
+		return nil, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	var f [3]int
+	for i := 1; i < len(s); i += 2 {
+		if s[i] < '0' || s[i] > '9' || s[i+1] < '0' || s[i+1] > '9' {
+			return nil, fmt.Errorf("invalid UTC offset %q", s)
+		}
+		f[i/2] = int(s[i]-'0')*10 + int(s[i+1]-'0')
+	}
+	if f[1] > 59 || f[2] > 59 {
+		return nil, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	return &UTCOffset{Positive: s[0] == '+', Hour: f[0], Minute: f[1], Second: f[2]}, nil
+}
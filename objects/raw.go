@@ -0,0 +1,116 @@
+package objects
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//   Format Definition:  A component is a grouping of component properties
+//      and possibly other components, delimited by a pair of BEGIN and
+//      END properties:
+//
+//       component  = "BEGIN" ":" comp-name CRLF
+//                    1*contentline
+//                    *component
+//                    "END" ":" comp-name CRLF
+//
+//   Description:  The body of an iCalendar object consists of a sequence
+//      of calendar properties and one or more calendar components.
+//      Applications MUST ignore components they do not recognize, which
+//      are x-comp and iana-comp components.
+
+// RawComponent is a component as read from a stream, with its content
+// lines kept as written.  It keeps components and properties that the
+// typed model of this package has no place for.
+type RawComponent struct {
+	Name       string
+	Properties []*ContentLine
+	Components []*RawComponent
+	// Line is the line of the BEGIN property.
+	Line int
+}
+
+// Property returns the first property of c with the given name, or nil.
+func (c *RawComponent) Property(name string) *ContentLine {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (c *RawComponent) WriteComponentToStrBuilder(s *strings.Builder) error {
+	s.WriteString("BEGIN:" + c.Name + "\n")
+	for _, p := range c.Properties {
+		s.WriteString(p.String())
+		s.WriteString("\n")
+	}
+	for _, sub := range c.Components {
+		if err := sub.WriteComponentToStrBuilder(s); err != nil {
+			return err
+		}
+	}
+	s.WriteString("END:" + c.Name + "\n")
+	return nil
+}
+
+func (c *RawComponent) String() string {
+	s := &strings.Builder{}
+	_ = c.WriteComponentToStrBuilder(s)
+	return s.String()
+}
+
+// ReadRawComponents reads the components of r, which are usually one or
+// more VCALENDAR objects.
+func ReadRawComponents(r io.Reader) ([]*RawComponent, error) {
+	lines, err := ReadContentLines(r)
+	if err != nil {
+		return nil, err
+	}
+	return RawComponents(lines)
+}
+
+// RawComponents groups content lines into components by their BEGIN and
+// END properties.
+func RawComponents(lines []*ContentLine) ([]*RawComponent, error) {
+	var top []*RawComponent
+	var stack []*RawComponent
+	for _, l := range lines {
+		switch l.Name {
+		case "BEGIN":
+			c := &RawComponent{Name: strings.ToUpper(l.Value), Line: l.Line}
+			if c.Name == "" {
+				return nil, &SyntaxError{Line: l.Line, Msg: "BEGIN without component name"}
+			}
+			if len(stack) == 0 {
+				top = append(top, c)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 {
+				return nil, &SyntaxError{Line: l.Line, Msg: fmt.Sprintf("END:%s without BEGIN", l.Value)}
+			}
+			c := stack[len(stack)-1]
+			if !strings.EqualFold(l.Value, c.Name) {
+				return nil, &SyntaxError{Line: l.Line, Msg: fmt.Sprintf("END:%s does not close BEGIN:%s of line %d", l.Value, c.Name, c.Line)}
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, &SyntaxError{Line: l.Line, Msg: fmt.Sprintf("property %s outside of a component", l.Name)}
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, l)
+		}
+	}
+	if len(stack) > 0 {
+		c := stack[len(stack)-1]
+		return nil, &SyntaxError{Line: c.Line, Msg: fmt.Sprintf("BEGIN:%s is never closed", c.Name)}
+	}
+	return top, nil
+}
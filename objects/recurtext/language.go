@@ -0,0 +1,305 @@
+package recurtext
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+)
+
+// Phrase names a phrase of a Language.  Phrases are fmt formats; the
+// verbs they take are given with each constant.
+type Phrase string
+
+const (
+	// OnDays takes the list of weekdays, "on Monday and Friday".
+	OnDays Phrase = "on-days"
+	// OnNthDays takes the list of weekdays with ordinals, "on the last
+	// Friday".
+	OnNthDays Phrase = "on-nth-days"
+	// OnWeekdays and OnWeekends stand for BYDAY=MO,TU,WE,TH,FR and
+	// BYDAY=SA,SU.
+	OnWeekdays Phrase = "on-weekdays"
+	OnWeekends Phrase = "on-weekends"
+	// EveryWeekday stands for a daily or weekly rule on Monday to Friday.
+	EveryWeekday Phrase = "every-weekday"
+	// EveryOf takes the days and the months of a monthly or yearly rule
+	// with BYMONTH, "every last Friday of January and July".
+	EveryOf Phrase = "every-of"
+	// OnMonthDays takes the list of days of the month, "on the 1st and
+	// 15th".
+	OnMonthDays Phrase = "on-month-days"
+	// DayFromEnd takes the ordinal of a day counted from the end of the
+	// month, "last day".
+	DayFromEnd Phrase = "day-from-end"
+	// OnDaysIfMonthDay takes the weekdays and the days of the month of a
+	// rule with both BYDAY and BYMONTHDAY.
+	OnDaysIfMonthDay Phrase = "on-days-if-month-day"
+	// OnYearDays takes the list of days of the year.
+	OnYearDays Phrase = "on-year-days"
+	// OnDate takes a day of the year written with DayMonth.
+	OnDate Phrase = "on-date"
+	// InMonths takes the list of months, InWeeks the list of week numbers.
+	InMonths Phrase = "in-months"
+	InWeeks  Phrase = "in-weeks"
+	// Month and LeapMonth take the number of a month of a non-Gregorian
+	// calendar system.
+	Month     Phrase = "month"
+	LeapMonth Phrase = "leap-month"
+	// OnNth takes the ordinals of BYSETPOS and the day they count,
+	// "on the last weekday"; Weekday and WeekendDay are such days.
+	OnNth      Phrase = "on-nth"
+	Weekday    Phrase = "weekday"
+	WeekendDay Phrase = "weekend-day"
+	// OnlyNth takes the ordinals of BYSETPOS for other rules.
+	OnlyNth Phrase = "only-nth"
+	// At takes the list of clock times, AtHour, AtMinute and AtSecond
+	// the lists of hours, minutes and seconds.
+	At       Phrase = "at"
+	AtHour   Phrase = "at-hour"
+	AtMinute Phrase = "at-minute"
+	AtSecond Phrase = "at-second"
+	// WeekStart takes the weekday of WKST.
+	WeekStart Phrase = "week-start"
+	// Calendar takes the calendar system of RSCALE, written with
+	// CalendarName unless the Language names it.
+	Calendar     Phrase = "calendar"
+	CalendarName Phrase = "calendar-name"
+	// SkipOmit, SkipBackward and SkipForward stand for the values of
+	// SKIP.
+	SkipOmit     Phrase = "skip-omit"
+	SkipBackward Phrase = "skip-backward"
+	SkipForward  Phrase = "skip-forward"
+	// Times takes COUNT; Once stands for COUNT=1.
+	Times Phrase = "times"
+	Once  Phrase = "once"
+	// Until takes the date of UNTIL, written with Date.
+	Until Phrase = "until"
+	// Date takes the day, the name of the month and the year; DayMonth
+	// the day and the name of the month.
+	Date     Phrase = "date"
+	DayMonth Phrase = "day-month"
+)
+
+// Language holds the words and phrases Text writes a rule with.
+type Language struct {
+	// Tag is the language tag, such as "en".
+	Tag string
+	// Every holds by frequency the phrase for an interval of one and
+	// the format for longer intervals, such as "every week" and "every
+	// %d weeks".
+	Every map[types.Frequency][2]string
+	// Weekdays are the names of the days of the week, from Sunday.
+	Weekdays [7]string
+	// Months are the names of the months, from January.
+	Months [12]string
+	// Ordinal returns the ordinal of n, counted from the end for
+	// negative n: "1st", "last", "2nd to last".
+	Ordinal func(n int) string
+	// And joins the last two items of lists.
+	And string
+	// Phrases are the phrases of the language.
+	Phrases map[Phrase]string
+	// Calendars name calendar systems by RSCALE value.
+	Calendars map[string]string
+}
+
+func (l *Language) phrase(p Phrase, a ...interface{}) string {
+	return fmt.Sprintf(l.Phrases[p], a...)
+}
+
+// list joins items as "a, b and c".
+func (l *Language) list(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + l.And + " " + items[len(items)-1]
+}
+
+var languages = map[string]*Language{}
+
+// Register makes l available to Lookup under its tag.
+func Register(l *Language) {
+	languages[strings.ToLower(l.Tag)] = l
+}
+
+// Lookup returns the language with the given tag, which is matched
+// case-insensitively; "de-AT" falls back to "de".
+func Lookup(tag string) (*Language, bool) {
+	tag = strings.ToLower(strings.Replace(tag, "_", "-", -1))
+	for {
+		if l, ok := languages[tag]; ok {
+			return l, true
+		}
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			return nil, false
+		}
+		tag = tag[:i]
+	}
+}
+
+func init() {
+	Register(English)
+	Register(German)
+}
+
+// English is the English language.
+var English = &Language{
+	Tag: "en",
+	Every: map[types.Frequency][2]string{
+		types.FreqSecondly: {"every second", "every %d seconds"},
+		types.FreqMinutely: {"every minute", "every %d minutes"},
+		types.FreqHourly:   {"every hour", "every %d hours"},
+		types.FreqDaily:    {"every day", "every %d days"},
+		types.FreqWeekly:   {"every week", "every %d weeks"},
+		types.FreqMonthly:  {"every month", "every %d months"},
+		types.FreqYearly:   {"every year", "every %d years"},
+	},
+	Weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	Months: [12]string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"},
+	Ordinal: englishOrdinal,
+	And:     "and",
+	Phrases: map[Phrase]string{
+		OnDays:           "on %s",
+		OnNthDays:        "on the %s",
+		OnWeekdays:       "on weekdays",
+		OnWeekends:       "on weekends",
+		EveryWeekday:     "every weekday",
+		EveryOf:          "every %s of %s",
+		OnMonthDays:      "on the %s",
+		DayFromEnd:       "%s day",
+		OnDaysIfMonthDay: "on %s if it falls on the %s",
+		OnYearDays:       "on the %s day of the year",
+		OnDate:           "on %s",
+		InMonths:         "in %s",
+		InWeeks:          "in week %s",
+		Month:            "month %d",
+		LeapMonth:        "leap month %d",
+		OnNth:            "on the %s %s",
+		Weekday:          "weekday",
+		WeekendDay:       "weekend day",
+		OnlyNth:          "only the %s occurrence",
+		At:               "at %s",
+		AtHour:           "at hour %s",
+		AtMinute:         "at minute %s",
+		AtSecond:         "at second %s",
+		WeekStart:        "weeks starting on %s",
+		Calendar:         "in the %s",
+		CalendarName:     "%s calendar",
+		SkipOmit:         "skipping days that do not exist",
+		SkipBackward:     "moving days that do not exist back",
+		SkipForward:      "moving days that do not exist forward",
+		Times:            "%d times",
+		Once:             "once",
+		Until:            "until %s",
+		Date:             "%[2]s %[1]d, %[3]d",
+		DayMonth:         "%[2]s %[1]d",
+	},
+	Calendars: map[string]string{
+		"GREGORIAN":     "Gregorian calendar",
+		"CHINESE":       "Chinese calendar",
+		"HEBREW":        "Hebrew calendar",
+		"ISLAMIC-CIVIL": "civil Islamic calendar",
+		"ISLAMIC-TBLA":  "tabular Islamic calendar",
+	},
+}
+
+func englishOrdinal(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < 0:
+		return englishOrdinal(-n) + " to last"
+	case n%100 >= 11 && n%100 <= 13:
+		return fmt.Sprintf("%dth", n)
+	case n%10 == 1:
+		return fmt.Sprintf("%dst", n)
+	case n%10 == 2:
+		return fmt.Sprintf("%dnd", n)
+	case n%10 == 3:
+		return fmt.Sprintf("%drd", n)
+	}
+	return fmt.Sprintf("%dth", n)
+}
+
+// German is the German language.  Ordinals are in the dative, as in "am
+// letzten Freitag".
+var German = &Language{
+	Tag: "de",
+	Every: map[types.Frequency][2]string{
+		types.FreqSecondly: {"jede Sekunde", "alle %d Sekunden"},
+		types.FreqMinutely: {"jede Minute", "alle %d Minuten"},
+		types.FreqHourly:   {"jede Stunde", "alle %d Stunden"},
+		types.FreqDaily:    {"jeden Tag", "alle %d Tage"},
+		types.FreqWeekly:   {"jede Woche", "alle %d Wochen"},
+		types.FreqMonthly:  {"jeden Monat", "alle %d Monate"},
+		types.FreqYearly:   {"jedes Jahr", "alle %d Jahre"},
+	},
+	Weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	Months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni",
+		"Juli", "August", "September", "Oktober", "November", "Dezember"},
+	Ordinal: germanOrdinal,
+	And:     "und",
+	Phrases: map[Phrase]string{
+		OnDays:           "am %s",
+		OnNthDays:        "am %s",
+		OnWeekdays:       "an Wochentagen",
+		OnWeekends:       "am Wochenende",
+		EveryWeekday:     "jeden Wochentag",
+		EveryOf:          "jeden %s im %s",
+		OnMonthDays:      "am %s",
+		DayFromEnd:       "%s Tag",
+		OnDaysIfMonthDay: "am %s, wenn er auf den %s fällt",
+		OnYearDays:       "am %s Tag des Jahres",
+		OnDate:           "am %s",
+		InMonths:         "im %s",
+		InWeeks:          "in Kalenderwoche %s",
+		Month:            "Monat %d",
+		LeapMonth:        "Schaltmonat %d",
+		OnNth:            "am %s %s",
+		Weekday:          "Wochentag",
+		WeekendDay:       "Wochenendtag",
+		OnlyNth:          "jeweils nur den %s Termin",
+		At:               "um %s Uhr",
+		AtHour:           "zur Stunde %s",
+		AtMinute:         "zur Minute %s",
+		AtSecond:         "zur Sekunde %s",
+		WeekStart:        "Wochenbeginn am %s",
+		Calendar:         "im %s",
+		CalendarName:     "Kalender %s",
+		SkipOmit:         "nicht existierende Tage entfallen",
+		SkipBackward:     "nicht existierende Tage werden vorgezogen",
+		SkipForward:      "nicht existierende Tage werden verschoben",
+		Times:            "%d-mal",
+		Once:             "einmal",
+		Until:            "bis zum %s",
+		Date:             "%[1]d. %[2]s %[3]d",
+		DayMonth:         "%[1]d. %[2]s",
+	},
+	Calendars: map[string]string{
+		"GREGORIAN":     "gregorianischen Kalender",
+		"CHINESE":       "chinesischen Kalender",
+		"HEBREW":        "hebräischen Kalender",
+		"ISLAMIC-CIVIL": "bürgerlichen islamischen Kalender",
+		"ISLAMIC-TBLA":  "tabellarischen islamischen Kalender",
+	},
+}
+
+func germanOrdinal(n int) string {
+	switch n {
+	case -1:
+		return "letzten"
+	case -2:
+		return "vorletzten"
+	case -3:
+		return "drittletzten"
+	}
+	if n < 0 {
+		return fmt.Sprintf("%d.-letzten", -n)
+	}
+	return fmt.Sprintf("%d.", n)
+}
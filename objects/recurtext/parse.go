@@ -0,0 +1,950 @@
+package recurtext
+
+import (
+	"errors"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse reads an English description of a recurrence, such as "every
+// other Tuesday until June", "the first weekday of each month" or "daily
+// at 9 and 17 for 2 weeks", as a recurrence rule.  Dates and spans are
+// taken relative to now, which stands for DTSTART:
+//
+//   - "until June 15" ends the rule at the end of the next June 15, "until
+//     June" at the end of the day before the next June 1;
+//   - "for 2 weeks" ends it a second before two weeks after now;
+//   - "10 times" sets COUNT.
+//
+// Without a frequency, the rule repeats as often as its days imply:
+// weekly on weekdays, monthly on days of the month, yearly in months.
+// "except" takes days of the week or months out of those of the rule, or
+// out of all of them, so that "every day except Sunday" is BYDAY=MO to
+// SA.  Clock times set BYHOUR and BYMINUTE, which repeat at every minute
+// in every hour: times that they cannot give alone, such as "at 9:30
+// and 17:00", are returned as Unparsed.
+//
+// The parts of s that were not understood are returned as Unparsed; the
+// rule is made of the rest.  ErrNoRule is returned when nothing in s
+// tells how often to repeat, ErrExclusion when s excludes something else
+// than days of the week or months, and the error of Validate when the
+// rest makes no valid rule, such as "the 40th".
+func Parse(s string, now time.Time) (*types.RecurRule, []*Unparsed, error) {
+	p := &parser{s: s, toks: tokenize(s), now: now}
+	for p.i < len(p.toks) {
+		if !p.part() {
+			if negations[p.peek(0)] {
+				// the rule would take in what was excluded
+				p.err = ErrExclusion
+			}
+			p.unknown()
+		}
+	}
+	if p.err != nil {
+		return nil, p.unparsed, p.err
+	}
+	r, err := p.rule()
+	return r, p.unparsed, err
+}
+
+var (
+	// ErrNoRule is returned by Parse for text without a recurrence.
+	ErrNoRule = errors.New("recurtext: no recurrence found")
+	// ErrExclusion is returned by Parse for text that excludes what a
+	// rule cannot leave out.
+	ErrExclusion = errors.New("recurtext: unsupported exclusion")
+)
+
+// Unparsed is a part of the text Parse did not understand.
+type Unparsed struct {
+	// Offset is the byte offset of Text in the text.
+	Offset int
+	Text   string
+}
+
+type token struct {
+	text string
+	off  int
+	end  int
+}
+
+// tokenize splits s into lower case words at spaces and punctuation.
+func tokenize(s string) []token {
+	var toks []token
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		sep := i == len(s) || strings.IndexByte(" \t\r\n,;", s[i]) >= 0
+		if !sep && start < 0 {
+			start = i
+		}
+		if sep && start >= 0 {
+			end := i
+			for end > start+1 && (s[end-1] == '.' || s[end-1] == '!') {
+				end--
+			}
+			toks = append(toks, token{text: strings.ToLower(s[start:end]), off: start, end: end})
+			start = -1
+		}
+	}
+	return toks
+}
+
+// filler are words that tie the parts of a description together.
+var filler = map[string]bool{
+	"the": true, "of": true, "on": true, "in": true, "and": true, "at": true,
+	"every": true, "each": true, "a": true, "an": true, "per": true, "&": true,
+	"repeat": true, "repeats": true, "repeating": true, "recurring": true,
+	"occurs": true, "happens": true,
+}
+
+// negations are words that exclude parts of a recurrence.
+var negations = map[string]bool{
+	"except": true, "excluding": true, "not": true, "never": true,
+	"without": true, "besides": true, "skip": true, "skipping": true,
+}
+
+var units = map[string]types.Frequency{
+	"second": types.FreqSecondly, "minute": types.FreqMinutely, "hour": types.FreqHourly,
+	"day": types.FreqDaily, "week": types.FreqWeekly, "month": types.FreqMonthly, "year": types.FreqYearly,
+}
+
+var adverbs = map[string]struct {
+	freq     types.Frequency
+	interval int
+}{
+	"hourly": {types.FreqHourly, 1}, "daily": {types.FreqDaily, 1},
+	"weekly": {types.FreqWeekly, 1}, "biweekly": {types.FreqWeekly, 2}, "fortnightly": {types.FreqWeekly, 2},
+	"monthly": {types.FreqMonthly, 1}, "bimonthly": {types.FreqMonthly, 2}, "quarterly": {types.FreqMonthly, 3},
+	"yearly": {types.FreqYearly, 1}, "annually": {types.FreqYearly, 1},
+}
+
+var numbers = map[string]int{
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+	"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+var ordinalWords = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "last": -1, "penultimate": -2,
+}
+
+type parser struct {
+	s        string
+	toks     []token
+	i        int
+	now      time.Time
+	unparsed []*Unparsed
+
+	freq      types.Frequency
+	interval  int
+	count     int
+	until     time.Time
+	days      []*types.WeekDayNum
+	monthDays []int
+	yearDays  []int
+	weekNos   []int
+	months    []int
+	setpos    []int
+	hours     []int
+	minutes   []int
+	// clock times, as hour*60 + minute
+	clocks []int
+
+	exceptDays   []types.WeekDay
+	exceptMonths []int
+	err          error
+}
+
+func (p *parser) peek(n int) string {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n].text
+	}
+	return ""
+}
+
+// unknown records the current token as not understood, joined to the
+// one before when that was not understood either.
+func (p *parser) unknown() {
+	t := p.toks[p.i]
+	p.i++
+	if n := len(p.unparsed); n > 0 && p.i > 1 {
+		last := p.unparsed[n-1]
+		if last.Offset+len(last.Text) == p.toks[p.i-2].end {
+			last.Text = p.s[last.Offset:t.end]
+			return
+		}
+	}
+	p.unparsed = append(p.unparsed, &Unparsed{Offset: t.off, Text: p.s[t.off:t.end]})
+}
+
+// part reads a part of the description at the current token, and reports
+// whether it understood one.
+func (p *parser) part() bool {
+	word := p.peek(0)
+	switch {
+	case word == "every" || word == "each":
+		return p.every()
+	case word == "once" || word == "twice":
+		return p.times()
+	case word == "at":
+		return p.at()
+	case word == "until" || word == "till" || word == "ending" || word == "ends":
+		return p.untilDate()
+	case word == "for":
+		return p.span()
+	case word == "week" && number(p.peek(1)) != 0:
+		return p.weeks()
+	case word == "noon" || word == "midnight":
+		return p.at()
+	case word == "except" || word == "excluding" || word == "but" && p.peek(1) == "not":
+		return p.except()
+	}
+	if a, ok := adverbs[word]; ok {
+		p.freq, p.interval = a.freq, a.interval
+		p.i++
+		return true
+	}
+	if d := dayNumber(word); d != 0 && month(p.peek(1)) != 0 {
+		// "29 February"
+		p.monthDays = append(p.monthDays, d)
+		p.i++
+		return true
+	}
+	if n := number(word); n > 0 && (p.peek(1) == "times" || p.peek(1) == "occurrences") {
+		p.count = n
+		p.i += 2
+		return true
+	}
+	if _, ok := ordinal(p.toks, p.i); ok {
+		return p.ordinals()
+	}
+	if p.weekdays(0) {
+		return true
+	}
+	if m := month(word); m != 0 {
+		for {
+			if m = month(p.peek(0)); m != 0 {
+				p.months = append(p.months, m)
+				p.i++
+				// "February 29", "December 24 and 31"
+				for d := dayNumber(p.peek(0)); d != 0 && p.peek(1) != "times" && p.peek(1) != "occurrences"; d = dayNumber(p.peek(0)) {
+					p.monthDays = append(p.monthDays, d)
+					p.i++
+					if p.peek(0) != "and" || dayNumber(p.peek(1)) == 0 || month(p.peek(2)) != 0 {
+						break
+					}
+					p.i++
+				}
+			} else if p.peek(0) != "and" || month(p.peek(1)) == 0 {
+				return true
+			} else {
+				p.i++
+			}
+		}
+	}
+	if f, ok := unit(word); ok {
+		// "of the month"
+		if p.freq == "" {
+			p.freq = f
+		}
+		p.i++
+		return true
+	}
+	if filler[word] {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// every reads "every other week", "every 3 days", "every Tuesday",
+// "every weekday" and "every first Monday".
+func (p *parser) every() bool {
+	p.i++
+	interval := 1
+	switch n := p.peek(0); {
+	case n == "other":
+		interval = 2
+		p.i++
+	case n == "second" && p.unitOrDay(1):
+		interval = 2
+		p.i++
+	case number(n) > 0 && p.unitOrDay(1):
+		interval = number(n)
+		p.i++
+	}
+	if f, ok := unit(p.peek(0)); ok {
+		p.freq, p.interval = f, interval
+		p.i++
+		return true
+	}
+	if p.weekdays(interval) {
+		return true
+	}
+	if interval > 1 {
+		p.interval = interval
+	}
+	return true
+}
+
+// unitOrDay reports whether the n-th next token is a unit or a day of
+// the week.
+func (p *parser) unitOrDay(n int) bool {
+	_, ok := unit(p.peek(n))
+	return ok || weekday(p.peek(n)) != "" || strings.HasPrefix(p.peek(n), "weekday") || strings.HasPrefix(p.peek(n), "weekend")
+}
+
+// weekdays reads lists and ranges of days of the week, "Monday and
+// Wednesday", "Monday to Friday", "weekdays", "weekends".  Read after
+// "every", they make the rule weekly with the given interval.
+func (p *parser) weekdays(interval int) bool {
+	var days []types.WeekDay
+	switch w := p.peek(0); {
+	case w == "weekday" || w == "weekdays":
+		days = weekDays[1:6]
+		p.i++
+	case w == "weekend" || w == "weekends":
+		days = []types.WeekDay{types.Saturday, types.Sunday}
+		p.i++
+	case weekday(w) != "":
+		for d := weekday(p.peek(0)); d != ""; d = weekday(p.peek(0)) {
+			p.i++
+			switch p.peek(0) {
+			case "to", "through", "thru", "-":
+				if to := weekday(p.peek(1)); to != "" {
+					for i := int(d.Weekday()); ; i = (i + 1) % 7 {
+						days = append(days, weekDays[i])
+						if weekDays[i] == to {
+							break
+						}
+					}
+					p.i += 2
+					d = ""
+				}
+			}
+			if d != "" {
+				days = append(days, d)
+			}
+			if p.peek(0) == "and" && weekday(p.peek(1)) != "" {
+				p.i++
+			}
+		}
+	default:
+		return false
+	}
+	for _, d := range days {
+		p.days = append(p.days, &types.WeekDayNum{WeekDay: d})
+	}
+	if interval > 0 && (p.freq == "" || p.freq == types.FreqDaily) {
+		p.freq, p.interval = types.FreqWeekly, interval
+	}
+	return true
+}
+
+// ordinals reads "the first and third Monday", "the last weekday", "the
+// 1st and 15th", "the last day of the year" and "the second to last
+// week of the year".
+func (p *parser) ordinals() bool {
+	var ords []int
+	for {
+		n, ok := ordinal(p.toks, p.i)
+		if !ok {
+			break
+		}
+		ords = append(ords, n)
+		p.i += ordinalLen(p.toks, p.i)
+		if p.peek(0) != "and" {
+			break
+		}
+		if _, ok := ordinal(p.toks, p.i+1); !ok {
+			break
+		}
+		p.i++
+	}
+	w := p.peek(0)
+	switch {
+	case weekday(w) != "":
+		for d := weekday(p.peek(0)); d != ""; d = weekday(p.peek(0)) {
+			for _, n := range ords {
+				op, v := types.Plus, n
+				if n < 0 {
+					op, v = types.Minus, -n
+				}
+				if op == types.Plus {
+					op = ""
+				}
+				p.days = append(p.days, &types.WeekDayNum{Operator: op, OrdWk: v, WeekDay: d})
+			}
+			p.i++
+			if p.peek(0) == "and" && weekday(p.peek(1)) != "" {
+				p.i++
+			}
+		}
+	case w == "weekday" || w == "weekend":
+		p.i++
+		if w == "weekend" && p.peek(0) == "day" {
+			p.i++
+		}
+		p.weekdaySet(w)
+		p.setpos = append(p.setpos, ords...)
+	case w == "week":
+		p.i++
+		p.weekNos = append(p.weekNos, ords...)
+		p.of(types.FreqYearly)
+	case w == "day":
+		p.i++
+		if p.of(types.FreqYearly) {
+			p.yearDays = append(p.yearDays, ords...)
+		} else {
+			p.monthDays = append(p.monthDays, ords...)
+		}
+	default:
+		p.monthDays = append(p.monthDays, ords...)
+	}
+	return true
+}
+
+func (p *parser) weekdaySet(w string) {
+	days := weekDays[1:6]
+	if w == "weekend" {
+		days = []types.WeekDay{types.Saturday, types.Sunday}
+	}
+	for _, d := range days {
+		p.days = append(p.days, &types.WeekDayNum{WeekDay: d})
+	}
+}
+
+// of reads "of the year" or "of the month" and reports whether the unit
+// was f.
+func (p *parser) of(f types.Frequency) bool {
+	n := 0
+	if p.peek(n) == "of" || p.peek(n) == "in" {
+		n++
+		if p.peek(n) == "the" || p.peek(n) == "each" || p.peek(n) == "every" || p.peek(n) == "a" {
+			n++
+		}
+		if u, ok := unit(p.peek(n)); ok && (u == types.FreqYearly || u == types.FreqMonthly) {
+			p.i += n + 1
+			if p.freq == "" {
+				p.freq = u
+			}
+			return u == f
+		}
+	}
+	return false
+}
+
+// weeks reads "week 1 and 20".
+func (p *parser) weeks() bool {
+	p.i++
+	for n := number(p.peek(0)); n != 0; n = number(p.peek(0)) {
+		p.weekNos = append(p.weekNos, n)
+		p.i++
+		if p.peek(0) == "and" && number(p.peek(1)) != 0 {
+			p.i++
+		}
+	}
+	return true
+}
+
+// times reads "once", "twice" and "once a week".
+func (p *parser) times() bool {
+	n := 1
+	if p.peek(0) == "twice" {
+		n = 2
+	}
+	if a := p.peek(1); a == "a" || a == "an" || a == "per" || a == "every" || a == "each" {
+		if f, ok := unit(p.peek(2)); ok {
+			if n == 2 {
+				// needs the days to repeat on
+				return false
+			}
+			p.freq, p.interval = f, 1
+			p.i += 3
+			return true
+		}
+	}
+	p.count = n
+	p.i++
+	return true
+}
+
+// at reads the clock times "at 9 and 17", "at 9:30am", "at 5 pm",
+// "at noon".  Times that BYHOUR and BYMINUTE cannot give without others
+// are left unparsed.
+func (p *parser) at() bool {
+	start := p.i
+	if p.peek(0) == "at" {
+		p.i++
+	}
+	clocks := p.clocks
+	for {
+		h, m, n := clock(p.toks, p.i)
+		if n == 0 {
+			break
+		}
+		clocks = appendUnique(clocks, h*60+m)
+		p.i += n
+		if p.peek(0) != "and" {
+			break
+		}
+		if _, _, n := clock(p.toks, p.i+1); n == 0 {
+			break
+		}
+		p.i++
+	}
+	var hours, minutes []int
+	for _, c := range clocks {
+		hours, minutes = appendUnique(hours, c/60), appendUnique(minutes, c%60)
+	}
+	if len(hours)*len(minutes) != len(clocks) {
+		from, to := p.toks[start].off, p.toks[p.i-1].end
+		p.unparsed = append(p.unparsed, &Unparsed{Offset: from, Text: p.s[from:to]})
+		return true
+	}
+	p.clocks, p.hours, p.minutes = clocks, hours, minutes
+	return true
+}
+
+// except reads "except Sundays", "excluding weekends", "but not in July
+// and August".
+func (p *parser) except() bool {
+	start := p.i
+	p.i++
+	if p.peek(0) == "not" {
+		p.i++
+	}
+	if w := p.peek(0); w == "on" || w == "in" || w == "during" || w == "the" {
+		p.i++
+	}
+	days := p.days
+	p.days = nil
+	if p.weekdays(0) {
+		for _, d := range p.days {
+			p.exceptDays = append(p.exceptDays, d.WeekDay)
+		}
+		p.days = days
+		return true
+	}
+	p.days = days
+	if month(p.peek(0)) == 0 {
+		p.i = start
+		return false
+	}
+	for m := month(p.peek(0)); m != 0; m = month(p.peek(0)) {
+		p.exceptMonths = append(p.exceptMonths, m)
+		p.i++
+		if p.peek(0) == "and" && month(p.peek(1)) != 0 {
+			p.i++
+		}
+	}
+	return true
+}
+
+// clock reads a clock time at toks[i] and returns its hour, minute and
+// number of tokens.
+func clock(toks []token, i int) (int, int, int) {
+	if i >= len(toks) {
+		return 0, 0, 0
+	}
+	s := toks[i].text
+	switch s {
+	case "noon":
+		return 12, 0, 1
+	case "midnight":
+		return 0, 0, 1
+	}
+	n := 1
+	suffix := ""
+	for _, x := range []string{"am", "pm", "a.m", "p.m", "h"} {
+		if strings.HasSuffix(s, x) && len(s) > len(x) {
+			s, suffix = s[:len(s)-len(x)], x
+			break
+		}
+	}
+	if suffix == "" && i+1 < len(toks) {
+		switch toks[i+1].text {
+		case "am", "pm", "a.m", "p.m", "o'clock":
+			suffix = toks[i+1].text
+			n++
+		}
+	}
+	hm := strings.SplitN(strings.Replace(s, ".", ":", 1), ":", 2)
+	h, err := strconv.Atoi(hm[0])
+	if err != nil || h < 0 || h > 23 {
+		return 0, 0, 0
+	}
+	m := 0
+	if len(hm) == 2 {
+		if m, err = strconv.Atoi(hm[1]); err != nil || len(hm[1]) != 2 || m > 59 {
+			return 0, 0, 0
+		}
+	}
+	switch suffix {
+	case "am", "a.m":
+		if h > 12 {
+			return 0, 0, 0
+		}
+		h %= 12
+	case "pm", "p.m":
+		if h > 12 {
+			return 0, 0, 0
+		}
+		h = h%12 + 12
+	}
+	return h, m, n
+}
+
+// untilDate reads "until June", "until June 15", "until 15 June 2025"
+// and "until 2025-06-15".
+func (p *parser) untilDate() bool {
+	start := p.i
+	p.i++
+	if p.peek(0) == "on" || p.peek(0) == "the" {
+		p.i++
+	}
+	loc := p.now.Location()
+	if t, err := time.ParseInLocation("2006-01-02", p.peek(0), loc); err == nil {
+		p.i++
+		p.until = t.AddDate(0, 0, 1).Add(-time.Second)
+		return true
+	}
+	day, m, year := 0, 0, 0
+	if d := dayNumber(p.peek(0)); d != 0 && month(p.peek(1)) != 0 {
+		day, m = d, month(p.peek(1))
+		p.i += 2
+	} else if m = month(p.peek(0)); m != 0 {
+		p.i++
+		if d := dayNumber(p.peek(0)); d != 0 {
+			day = d
+			p.i++
+		}
+	} else {
+		p.i = start
+		return false
+	}
+	if y, err := strconv.Atoi(p.peek(0)); err == nil && y >= 1000 && y <= 9999 {
+		year = y
+		p.i++
+	}
+	if year == 0 {
+		// the next such date that has not passed
+		year = p.now.Year()
+		d := day
+		if d == 0 {
+			d = 1
+		}
+		if time.Date(year, time.Month(m), d+1, 0, 0, 0, 0, loc).Before(p.now) || day == 0 && !p.now.Before(time.Date(year, time.Month(m), 1, 0, 0, 0, 0, loc)) {
+			year++
+		}
+	}
+	if day == 0 {
+		p.until = time.Date(year, time.Month(m), 1, 0, 0, 0, 0, loc).Add(-time.Second)
+	} else {
+		p.until = time.Date(year, time.Month(m), day+1, 0, 0, 0, 0, loc).Add(-time.Second)
+	}
+	return true
+}
+
+// span reads "for 2 weeks" and "for 10 occurrences".
+func (p *parser) span() bool {
+	n := number(p.peek(1))
+	if p.peek(1) == "a" || p.peek(1) == "an" {
+		n = 1
+	}
+	if n == 0 {
+		return false
+	}
+	w := p.peek(2)
+	if w == "times" || w == "occurrences" {
+		p.count = n
+		p.i += 3
+		return true
+	}
+	f, ok := unit(w)
+	if !ok {
+		return false
+	}
+	end := p.now
+	switch f {
+	case types.FreqYearly:
+		end = end.AddDate(n, 0, 0)
+	case types.FreqMonthly:
+		end = end.AddDate(0, n, 0)
+	case types.FreqWeekly:
+		end = end.AddDate(0, 0, 7*n)
+	case types.FreqDaily:
+		end = end.AddDate(0, 0, n)
+	case types.FreqHourly:
+		end = end.Add(time.Duration(n) * time.Hour)
+	case types.FreqMinutely:
+		end = end.Add(time.Duration(n) * time.Minute)
+	default:
+		end = end.Add(time.Duration(n) * time.Second)
+	}
+	p.until = end.Add(-time.Second)
+	p.i += 3
+	return true
+}
+
+// rule makes the rule of what was read.
+func (p *parser) rule() (*types.RecurRule, error) {
+	if err := p.exclude(); err != nil {
+		return nil, err
+	}
+	ordinalDays := false
+	for _, d := range p.days {
+		if d.OrdWk != 0 {
+			ordinalDays = true
+		}
+	}
+	freq := p.freq
+	if freq == "" {
+		switch {
+		case len(p.weekNos) > 0 || len(p.yearDays) > 0 || len(p.months) > 0:
+			freq = types.FreqYearly
+		case len(p.monthDays) > 0 || ordinalDays || len(p.setpos) > 0:
+			freq = types.FreqMonthly
+		case len(p.days) > 0:
+			freq = types.FreqWeekly
+		case len(p.hours) > 0:
+			freq = types.FreqDaily
+		default:
+			return nil, ErrNoRule
+		}
+	}
+	if freq == types.FreqWeekly && (ordinalDays || len(p.monthDays) > 0) {
+		// "every week on the first Monday" can only mean months
+		freq = types.FreqMonthly
+	}
+
+	r := &types.RecurRule{Frequency: freq}
+	if !p.until.IsZero() {
+		r.Rules = append(r.Rules, &types.Until{Time: &types.DateTime{V: p.until.UTC(), Format: types.UTCDateTimeFormat}})
+	} else if p.count > 0 {
+		r.Rules = append(r.Rules, &types.Count{V: p.count})
+	}
+	if p.interval > 1 {
+		r.Rules = append(r.Rules, &types.Interval{V: p.interval})
+	}
+	if len(p.minutes) > 0 {
+		r.Rules = append(r.Rules, &types.ByMinute{V: sorted(p.minutes)})
+	}
+	if len(p.hours) > 0 {
+		r.Rules = append(r.Rules, &types.ByHour{V: sorted(p.hours)})
+	}
+	if len(p.days) > 0 {
+		r.Rules = append(r.Rules, &types.ByDay{V: p.days})
+	}
+	if len(p.monthDays) > 0 {
+		b := &types.ByMonthDay{}
+		for _, n := range p.monthDays {
+			op, v := split(n)
+			b.V = append(b.V, &types.MonthDayNum{Operator: op, OrdMoDay: v})
+		}
+		r.Rules = append(r.Rules, b)
+	}
+	if len(p.yearDays) > 0 {
+		r.Rules = append(r.Rules, &types.ByYearDay{V: yearDayNums(p.yearDays)})
+	}
+	if len(p.weekNos) > 0 {
+		b := &types.ByWeekNo{}
+		for _, n := range p.weekNos {
+			op, v := split(n)
+			b.V = append(b.V, &types.WeekNum{Operator: op, OrdWk: v})
+		}
+		r.Rules = append(r.Rules, b)
+	}
+	if len(p.months) > 0 {
+		r.Rules = append(r.Rules, &types.ByMonth{V: p.months})
+	}
+	if len(p.setpos) > 0 {
+		r.Rules = append(r.Rules, &types.BySetpos{V: yearDayNums(p.setpos)})
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// exclude takes the excluded days of the week and months out of those
+// of the rule, or out of all of them.
+func (p *parser) exclude() error {
+	if len(p.exceptDays) > 0 {
+		days := p.days
+		if len(days) == 0 {
+			for i := 1; i <= 7; i++ {
+				days = append(days, &types.WeekDayNum{WeekDay: weekDays[i%7]})
+			}
+		}
+		p.days = nil
+		for _, d := range days {
+			if d.OrdWk != 0 {
+				// "the first Monday except in ..." has no such set
+				return ErrExclusion
+			}
+			excluded := false
+			for _, e := range p.exceptDays {
+				excluded = excluded || e == d.WeekDay
+			}
+			if !excluded {
+				p.days = append(p.days, d)
+			}
+		}
+		if len(p.days) == 0 {
+			return ErrExclusion
+		}
+	}
+	if len(p.exceptMonths) > 0 {
+		months := p.months
+		if len(months) == 0 {
+			for m := 1; m <= 12; m++ {
+				months = append(months, m)
+			}
+		}
+		p.months = nil
+		for _, m := range months {
+			excluded := false
+			for _, e := range p.exceptMonths {
+				excluded = excluded || e == m
+			}
+			if !excluded {
+				p.months = append(p.months, m)
+			}
+		}
+		if len(p.months) == 0 {
+			return ErrExclusion
+		}
+	}
+	return nil
+}
+
+func split(n int) (types.Operator, int) {
+	if n < 0 {
+		return types.Minus, -n
+	}
+	return "", n
+}
+
+func yearDayNums(v []int) []*types.YearDayNum {
+	var days []*types.YearDayNum
+	for _, n := range v {
+		op, v := split(n)
+		days = append(days, &types.YearDayNum{Operator: op, OrdYrDay: v})
+	}
+	return days
+}
+
+func appendUnique(v []int, n int) []int {
+	for _, x := range v {
+		if x == n {
+			return v
+		}
+	}
+	return append(v, n)
+}
+
+func sorted(v []int) []int {
+	sort.Ints(v)
+	return v
+}
+
+// ordinal reads an ordinal at toks[i]: "first", "1st", "last", "second
+// to last", "penultimate".
+func ordinal(toks []token, i int) (int, bool) {
+	if i >= len(toks) {
+		return 0, false
+	}
+	s := toks[i].text
+	if s == "next" && i+2 < len(toks) && toks[i+1].text == "to" && toks[i+2].text == "last" {
+		return -2, true
+	}
+	n, ok := ordinalWords[s]
+	if !ok {
+		if len(s) < 3 {
+			return 0, false
+		}
+		switch s[len(s)-2:] {
+		case "st", "nd", "rd", "th":
+		default:
+			return 0, false
+		}
+		v, err := strconv.Atoi(s[:len(s)-2])
+		if err != nil || v <= 0 || v > 366 {
+			return 0, false
+		}
+		n = v
+	}
+	if n > 0 && i+2 < len(toks) && toks[i+1].text == "to" && toks[i+2].text == "last" {
+		return -n, true
+	}
+	return n, true
+}
+
+func ordinalLen(toks []token, i int) int {
+	if i+2 < len(toks) && toks[i+1].text == "to" && toks[i+2].text == "last" {
+		return 3
+	}
+	return 1
+}
+
+// number reads "3" or "three".
+func number(s string) int {
+	if n, ok := numbers[s]; ok {
+		return n
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// dayNumber reads a day of the month, "15" or "15th".
+func dayNumber(s string) int {
+	if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= 31 {
+		return n
+	}
+	if n, ok := ordinal([]token{{text: s}}, 0); ok && n >= 1 && n <= 31 && strings.IndexAny(s, "0123456789") >= 0 {
+		return n
+	}
+	return 0
+}
+
+// unit reads "week" or "weeks".
+func unit(s string) (types.Frequency, bool) {
+	f, ok := units[strings.TrimSuffix(s, "s")]
+	return f, ok
+}
+
+// weekday reads the name of a day of the week, "Monday", "Mondays",
+// "mon" or "tues".
+func weekday(s string) types.WeekDay {
+	s = strings.TrimSuffix(s, "s")
+	if len(s) < 3 {
+		return ""
+	}
+	for i, name := range English.Weekdays {
+		name = strings.ToLower(name)
+		if s == name || strings.HasPrefix(name, s) && len(s) <= 5 {
+			return weekDays[i]
+		}
+	}
+	return ""
+}
+
+// month reads the name of a month, "June" or "jun".
+func month(s string) int {
+	if len(s) < 3 {
+		return 0
+	}
+	for i, name := range English.Months {
+		name = strings.ToLower(name)
+		if s == name || strings.HasPrefix(name, s) && len(s) <= 4 {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package recurtext
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		text, want string
+		unparsed   string
+	}{
+		{"every other Tuesday until June", "FREQ=WEEKLY;UNTIL=20240531T235959Z;INTERVAL=2;BYDAY=TU", ""},
+		{"the first weekday of each month", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1", ""},
+		{"daily at 9 and 17 for 2 weeks", "FREQ=DAILY;UNTIL=20240329T095959Z;BYMINUTE=0;BYHOUR=9,17", ""},
+		{"every 3 days, 10 times", "FREQ=DAILY;COUNT=10;INTERVAL=3", ""},
+		{"Mondays and Wednesdays at 9:30am", "FREQ=WEEKLY;BYMINUTE=30;BYHOUR=9;BYDAY=MO,WE", ""},
+		{"every weekday at noon", "FREQ=WEEKLY;BYMINUTE=0;BYHOUR=12;BYDAY=MO,TU,WE,TH,FR", ""},
+		{"Monday to Thursday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH", ""},
+		{"the last Friday of January, April, July and October", "FREQ=YEARLY;BYDAY=-1FR;BYMONTH=1,4,7,10", ""},
+		{"monthly on the first and third Monday", "FREQ=MONTHLY;BYDAY=1MO,3MO", ""},
+		{"on the 1st and 15th", "FREQ=MONTHLY;BYMONTHDAY=1,15", ""},
+		{"the second to last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-2", ""},
+		{"the 100th day of the year", "FREQ=YEARLY;BYYEARDAY=100", ""},
+		{"every year on feb 29", "FREQ=YEARLY;BYMONTHDAY=29;BYMONTH=2", ""},
+		{"every year on 29 February", "FREQ=YEARLY;BYMONTHDAY=29;BYMONTH=2", ""},
+		{"every December 24 and 31", "FREQ=YEARLY;BYMONTHDAY=24,31;BYMONTH=12", ""},
+		{"every January 3 times", "FREQ=YEARLY;COUNT=3;BYMONTH=1", ""},
+		{"every 2 years in week 20 on Monday", "FREQ=YEARLY;INTERVAL=2;BYDAY=MO;BYWEEKNO=20", ""},
+		{"quarterly until 15 June 2025", "FREQ=MONTHLY;UNTIL=20250615T235959Z;INTERVAL=3", ""},
+		{"once a week until 2024-03-01", "FREQ=WEEKLY;UNTIL=20240301T235959Z", ""},
+		{"every Sunday at 5 pm starting tomorrow", "FREQ=WEEKLY;BYMINUTE=0;BYHOUR=17;BYDAY=SU", "starting tomorrow"},
+		{"twice a week", "FREQ=WEEKLY", "twice"},
+		{"every fortnight on Friday", "FREQ=WEEKLY;BYDAY=FR", "fortnight"},
+		{"every day except sunday", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR,SA", ""},
+		{"every day except weekends", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", ""},
+		{"every weekday excluding Friday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH", ""},
+		{"except Saturdays and Sundays, daily at 8", "FREQ=DAILY;BYMINUTE=0;BYHOUR=8;BYDAY=MO,TU,WE,TH,FR", ""},
+		{"monthly on the 1st but not in July and August", "FREQ=MONTHLY;BYMONTHDAY=1;BYMONTH=1,2,3,4,5,6,9,10,11,12", ""},
+		{"daily at 9:30 and 17:00", "FREQ=DAILY", "at 9:30 and 17:00"},
+		{"daily at 9:30 and 17:30", "FREQ=DAILY;BYMINUTE=30;BYHOUR=9,17", ""},
+		{"daily at 9 and 9:30 and 10 and 10:30", "FREQ=DAILY;BYMINUTE=0,30;BYHOUR=9,10", ""},
+	}
+	for _, tt := range tests {
+		r, unparsed, err := Parse(tt.text, now)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		b := &strings.Builder{}
+		r.WriteValueToStrBuilder(b)
+		if b.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.text, b, tt.want)
+		}
+		var s []string
+		for _, u := range unparsed {
+			if tt.text[u.Offset:u.Offset+len(u.Text)] != u.Text {
+				t.Errorf("%s: bad offset %d of %q", tt.text, u.Offset, u.Text)
+			}
+			s = append(s, u.Text)
+		}
+		if g := strings.Join(s, "|"); g != tt.unparsed {
+			t.Errorf("%s: unparsed %q, want %q", tt.text, g, tt.unparsed)
+		}
+	}
+
+	if _, unparsed, err := Parse("hello world", now); err != ErrNoRule || len(unparsed) != 1 {
+		t.Errorf("Parse without rule: %v, %v", unparsed, err)
+	}
+	for _, s := range []string{
+		"every day except holidays",
+		"every day but not on the 1st",
+		"weekly, never on Fridays",
+		"the first Monday except in weeks with holidays",
+		"every Monday except Mondays",
+		"yearly in June except June",
+	} {
+		if r, _, err := Parse(s, now); err != ErrExclusion {
+			t.Errorf("%s: %v, %v", s, r, err)
+		}
+	}
+
+	// the text of a rule reads back as the rule
+	r, _, _ := Parse("every last Friday of January, April, July and October, 10 times", now)
+	if g := Text(r, nil, nil); g != "every last Friday of January, April, July and October, 10 times" {
+		t.Errorf("Text of parsed rule: %q", g)
+	}
+}
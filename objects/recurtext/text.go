@@ -0,0 +1,450 @@
+// Package recurtext writes recurrence rules as text for people, such as
+// "every last Friday of January, April, July and October, 10 times" for
+// FREQ=MONTHLY;BYDAY=-1FR;BYMONTH=1,4,7,10;COUNT=10.
+//
+// The words and phrases come from a Language; English and German are
+// registered, and Register adds others.
+package recurtext
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.3.10.  Recurrence Rule
+//
+//      Information, not contained in the rule, necessary to determine the
+//      various recurrence instance start time and dates are derived from
+//      the Start Time ("DTSTART") component attribute.  For example,
+//      "FREQ=YEARLY;BYMONTH=1" doesn't specify a specific day within the
+//      month or a time.  This information would be the same as what is
+//      specified for "DTSTART".
+
+// at most this many clock times are listed; more are written as lists of
+// hours, minutes and seconds
+const maxClocks = 6
+
+// parts are the rule parts of a rule, with those implied by DTSTART.
+type parts struct {
+	freq      types.Frequency
+	interval  int
+	count     int
+	until     types.Value
+	seconds   []int
+	minutes   []int
+	hours     []int
+	days      []*types.WeekDayNum
+	monthDays []int
+	yearDays  []int
+	weekNos   []int
+	months    []int
+	leap      []bool
+	setpos    []int
+	wkst      types.WeekDay
+	rscale    string
+	skip      types.Skip
+}
+
+func signed(o types.Operator, v int) int {
+	if o == types.Minus {
+		return -v
+	}
+	return v
+}
+
+func newParts(r *types.RecurRule) *parts {
+	p := &parts{freq: r.Frequency, interval: 1}
+	for _, rule := range r.Rules {
+		switch v := rule.(type) {
+		case *types.Interval:
+			if v.V > 0 {
+				p.interval = v.V
+			}
+		case *types.Count:
+			p.count = v.V
+		case *types.Until:
+			p.until = v.Time
+		case *types.BySecond:
+			p.seconds = v.V
+		case *types.ByMinute:
+			p.minutes = v.V
+		case *types.ByHour:
+			p.hours = v.V
+		case *types.ByDay:
+			p.days = v.V
+		case *types.ByMonthDay:
+			for _, d := range v.V {
+				p.monthDays = append(p.monthDays, signed(d.Operator, d.OrdMoDay))
+			}
+		case *types.ByYearDay:
+			for _, d := range v.V {
+				p.yearDays = append(p.yearDays, signed(d.Operator, d.OrdYrDay))
+			}
+		case *types.ByWeekNo:
+			for _, w := range v.V {
+				p.weekNos = append(p.weekNos, signed(w.Operator, w.OrdWk))
+			}
+		case *types.ByMonth:
+			p.months = v.V
+			for i := range v.V {
+				p.leap = append(p.leap, v.IsLeap(i))
+			}
+		case *types.BySetpos:
+			for _, d := range v.V {
+				p.setpos = append(p.setpos, signed(d.Operator, d.OrdYrDay))
+			}
+		case *types.Wkst:
+			p.wkst = v.V
+		case *types.RScale:
+			p.rscale = strings.ToUpper(v.V)
+		case types.Skip:
+			p.skip = v
+		}
+	}
+	return p
+}
+
+// imply fills in the day and time of the rule from start, as the
+// expansion of the rule does.  The days of rules in other calendar
+// systems are not filled in, as their months are not those of start.
+func (p *parts) imply(start time.Time, timed bool) {
+	noDays := len(p.days) == 0 && len(p.monthDays) == 0 && len(p.yearDays) == 0
+	day := &types.WeekDayNum{WeekDay: weekDays[start.Weekday()]}
+	if p.rscale == "" || p.rscale == "GREGORIAN" {
+		switch p.freq {
+		case types.FreqWeekly:
+			if len(p.days) == 0 {
+				p.days = []*types.WeekDayNum{day}
+			}
+		case types.FreqMonthly:
+			if noDays {
+				p.monthDays = []int{start.Day()}
+			}
+		case types.FreqYearly:
+			switch {
+			case noDays && len(p.weekNos) > 0:
+				p.days = []*types.WeekDayNum{day}
+			case noDays:
+				p.monthDays = []int{start.Day()}
+				if len(p.months) == 0 {
+					p.months, p.leap = []int{int(start.Month())}, nil
+				}
+			}
+		}
+	}
+	switch p.freq {
+	case types.FreqDaily, types.FreqWeekly, types.FreqMonthly, types.FreqYearly:
+		if !timed {
+			return
+		}
+		if len(p.hours) == 0 {
+			p.hours = []int{start.Hour()}
+		}
+		if len(p.minutes) == 0 {
+			p.minutes = []int{start.Minute()}
+		}
+		if len(p.seconds) == 0 && start.Second() != 0 {
+			p.seconds = []int{start.Second()}
+		}
+	}
+}
+
+var weekDays = [7]types.WeekDay{types.Sunday, types.Monday, types.Tuesday, types.Wednesday, types.Thursday, types.Friday, types.Saturday}
+
+// Text returns r as text in lang, English when lang is nil.  dtstart is
+// nil, a *types.Date or a *types.DateTime; it fills in the parts of the
+// rule it implies, such as the weekday of a weekly rule and the time of a
+// daily one, and the time zone UNTIL is written in.
+func Text(r *types.RecurRule, dtstart types.Value, lang *Language) string {
+	if lang == nil {
+		lang = English
+	}
+	p := newParts(r)
+	var start time.Time
+	switch v := dtstart.(type) {
+	case *types.DateTime:
+		start = v.Time()
+		p.imply(start, true)
+	case *types.Date:
+		start = v.Time()
+		p.imply(start, false)
+	}
+	w := &writer{l: lang, p: p}
+
+	var words, clauses []string
+	gregorian := p.rscale == "" || p.rscale == "GREGORIAN"
+	setpos := p.setpos
+	switch {
+	case p.interval == 1 && (p.freq == types.FreqDaily || p.freq == types.FreqWeekly) &&
+		isDays(p.days, weekDays[1:6]) && len(p.monthDays) == 0 && len(p.yearDays) == 0 &&
+		len(p.months) == 0 && len(p.weekNos) == 0 && len(setpos) == 0:
+		words = append(words, lang.phrase(EveryWeekday))
+	case p.freq == types.FreqYearly && gregorian && len(p.months) == 1 && p.months[0] >= 1 && p.months[0] <= 12 &&
+		len(p.monthDays) == 1 && p.monthDays[0] > 0 && len(p.days) == 0 && len(p.weekNos) == 0 && len(p.yearDays) == 0:
+		words = append(words, w.every(), lang.phrase(OnDate, lang.phrase(DayMonth, p.monthDays[0], lang.Months[p.months[0]-1])))
+	case p.interval == 1 && (p.freq == types.FreqMonthly || p.freq == types.FreqYearly) &&
+		len(p.months) > 0 && len(p.weekNos) == 0 && len(p.yearDays) == 0 && len(setpos) == 0 &&
+		(len(p.days) > 0) != (len(p.monthDays) > 0):
+		what := lang.list(w.days())
+		if len(p.monthDays) > 0 {
+			what = lang.list(w.monthDays())
+		}
+		words = append(words, lang.phrase(EveryOf, what, lang.list(w.months())))
+	default:
+		words = append(words, w.every())
+		if len(p.months) > 0 {
+			words = append(words, lang.phrase(InMonths, lang.list(w.months())))
+		}
+		if len(p.weekNos) > 0 {
+			words = append(words, lang.phrase(InWeeks, lang.list(itoa(p.weekNos))))
+		}
+		if len(p.yearDays) > 0 {
+			words = append(words, lang.phrase(OnYearDays, lang.list(w.ordinals(p.yearDays))))
+		}
+		switch {
+		case len(p.days) > 0 && len(p.monthDays) > 0:
+			words = append(words, lang.phrase(OnDaysIfMonthDay, lang.list(w.days()), lang.list(w.monthDays())))
+		case len(p.days) > 0 && len(setpos) > 0 && len(p.yearDays) == 0 && plain(p.days):
+			if noun := w.noun(); noun != "" {
+				words = append(words, lang.phrase(OnNth, lang.list(w.ordinals(setpos)), noun))
+				setpos = nil
+			} else {
+				words = append(words, lang.phrase(OnDays, lang.list(w.days())))
+			}
+		case len(p.days) > 0 && plain(p.days) && isDays(p.days, weekDays[1:6]):
+			words = append(words, lang.phrase(OnWeekdays))
+		case len(p.days) > 0 && plain(p.days) && isDays(p.days, []types.WeekDay{types.Saturday, types.Sunday}):
+			words = append(words, lang.phrase(OnWeekends))
+		case len(p.days) > 0 && plain(p.days):
+			words = append(words, lang.phrase(OnDays, lang.list(w.days())))
+		case len(p.days) > 0:
+			words = append(words, lang.phrase(OnNthDays, lang.list(w.days())))
+		case len(p.monthDays) > 0:
+			words = append(words, lang.phrase(OnMonthDays, lang.list(w.monthDays())))
+		}
+	}
+	words = append(words, w.times()...)
+
+	if len(setpos) > 0 {
+		clauses = append(clauses, lang.phrase(OnlyNth, lang.list(w.ordinals(setpos))))
+	}
+	if p.wkst != "" && p.wkst != types.Monday {
+		clauses = append(clauses, lang.phrase(WeekStart, lang.Weekdays[p.wkst.Weekday()]))
+	}
+	if p.rscale != "" {
+		name, ok := lang.Calendars[p.rscale]
+		if !ok {
+			name = lang.phrase(CalendarName, p.rscale)
+		}
+		clauses = append(clauses, lang.phrase(Calendar, name))
+	}
+	switch p.skip {
+	case types.SkipOmit:
+		clauses = append(clauses, lang.phrase(SkipOmit))
+	case types.SkipBackward:
+		clauses = append(clauses, lang.phrase(SkipBackward))
+	case types.SkipForward:
+		clauses = append(clauses, lang.phrase(SkipForward))
+	}
+	switch {
+	case p.count == 1:
+		clauses = append(clauses, lang.phrase(Once))
+	case p.count > 1:
+		clauses = append(clauses, lang.phrase(Times, p.count))
+	case p.until != nil:
+		if t, ok := untilDate(p.until, start, dtstart); ok {
+			clauses = append(clauses, lang.phrase(Until, lang.phrase(Date, t.Day(), lang.Months[t.Month()-1], t.Year())))
+		}
+	}
+
+	s := strings.Join(words, " ")
+	for _, c := range clauses {
+		s += ", " + c
+	}
+	return s
+}
+
+// untilDate returns the day of UNTIL, in the time zone of a DTSTART with
+// a time.
+func untilDate(until types.Value, start time.Time, dtstart types.Value) (time.Time, bool) {
+	switch v := until.(type) {
+	case *types.DateTime:
+		t := v.Time()
+		if _, ok := dtstart.(*types.DateTime); ok {
+			t = t.In(start.Location())
+		}
+		return t, true
+	case *types.Date:
+		return v.V, true
+	}
+	return time.Time{}, false
+}
+
+// writer writes the parts of a rule in a language.
+type writer struct {
+	l *Language
+	p *parts
+}
+
+func (w *writer) every() string {
+	every := w.l.Every[w.p.freq]
+	if w.p.interval > 1 {
+		return fmt.Sprintf(every[1], w.p.interval)
+	}
+	return every[0]
+}
+
+func (w *writer) ordinals(v []int) []string {
+	var s []string
+	for _, n := range v {
+		s = append(s, w.l.Ordinal(n))
+	}
+	return s
+}
+
+func (w *writer) days() []string {
+	var s []string
+	for _, d := range w.p.days {
+		name := w.l.Weekdays[d.WeekDay.Weekday()]
+		if n := signed(d.Operator, d.OrdWk); n != 0 {
+			name = w.l.Ordinal(n) + " " + name
+		}
+		s = append(s, name)
+	}
+	return s
+}
+
+func (w *writer) monthDays() []string {
+	var s []string
+	for _, n := range w.p.monthDays {
+		if n < 0 {
+			s = append(s, w.l.phrase(DayFromEnd, w.l.Ordinal(n)))
+		} else {
+			s = append(s, w.l.Ordinal(n))
+		}
+	}
+	return s
+}
+
+// months names the months of BYMONTH; in other calendar systems they are
+// numbered, as their names are not those of the Gregorian months.
+func (w *writer) months() []string {
+	gregorian := w.p.rscale == "" || w.p.rscale == "GREGORIAN"
+	var s []string
+	for i, m := range w.p.months {
+		switch {
+		case i < len(w.p.leap) && w.p.leap[i]:
+			s = append(s, w.l.phrase(LeapMonth, m))
+		case gregorian && m >= 1 && m <= 12:
+			s = append(s, w.l.Months[m-1])
+		default:
+			s = append(s, w.l.phrase(Month, m))
+		}
+	}
+	return s
+}
+
+// noun returns the word for the set of weekdays BYSETPOS counts in, or
+// "" when there is none.
+func (w *writer) noun() string {
+	switch {
+	case len(w.p.days) == 1:
+		return w.l.Weekdays[w.p.days[0].WeekDay.Weekday()]
+	case isDays(w.p.days, weekDays[1:6]):
+		return w.l.phrase(Weekday)
+	case isDays(w.p.days, []types.WeekDay{types.Saturday, types.Sunday}):
+		return w.l.phrase(WeekendDay)
+	}
+	return ""
+}
+
+// times writes BYHOUR, BYMINUTE and BYSECOND: as clock times when there
+// are hours and few times, otherwise as lists.
+func (w *writer) times() []string {
+	p := w.p
+	if len(p.hours) > 0 {
+		minutes, seconds := p.minutes, p.seconds
+		if len(minutes) == 0 {
+			minutes = []int{0}
+		}
+		n := len(p.hours) * len(minutes)
+		if len(seconds) > 0 {
+			n *= len(seconds)
+		}
+		if n <= maxClocks {
+			var clocks []int
+			for _, h := range p.hours {
+				for _, m := range minutes {
+					if len(seconds) == 0 {
+						clocks = append(clocks, (h*60+m)*60)
+					}
+					for _, s := range seconds {
+						clocks = append(clocks, (h*60+m)*60+s)
+					}
+				}
+			}
+			sort.Ints(clocks)
+			var s []string
+			for _, c := range clocks {
+				if len(seconds) > 0 {
+					s = append(s, fmt.Sprintf("%d:%02d:%02d", c/3600, c/60%60, c%60))
+				} else {
+					s = append(s, fmt.Sprintf("%d:%02d", c/3600, c/60%60))
+				}
+			}
+			return []string{w.l.phrase(At, w.l.list(s))}
+		}
+	}
+	var s []string
+	if len(p.hours) > 0 {
+		s = append(s, w.l.phrase(AtHour, w.l.list(itoa(p.hours))))
+	}
+	if len(p.minutes) > 0 {
+		s = append(s, w.l.phrase(AtMinute, w.l.list(itoa(p.minutes))))
+	}
+	if len(p.seconds) > 0 {
+		s = append(s, w.l.phrase(AtSecond, w.l.list(itoa(p.seconds))))
+	}
+	return s
+}
+
+func itoa(v []int) []string {
+	var s []string
+	for _, n := range v {
+		s = append(s, fmt.Sprint(n))
+	}
+	return s
+}
+
+// plain reports whether days are weekdays without ordinals.
+func plain(days []*types.WeekDayNum) bool {
+	for _, d := range days {
+		if d.OrdWk != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isDays reports whether days are exactly the weekdays of set, without
+// ordinals.
+func isDays(days []*types.WeekDayNum, set []types.WeekDay) bool {
+	if len(days) != len(set) || !plain(days) {
+		return false
+	}
+	for _, want := range set {
+		found := false
+		for _, d := range days {
+			if d.WeekDay == want {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
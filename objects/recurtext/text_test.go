@@ -0,0 +1,82 @@
+package recurtext
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"testing"
+	"time"
+)
+
+func TestText(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := &types.DateTime{V: time.Date(2024, 3, 15, 9, 30, 0, 0, newYork), Format: types.LocalDateTimeFormat}
+	day := &types.Date{V: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		rule    string
+		dtstart types.Value
+		en, de  string
+	}{
+		{"FREQ=MONTHLY;BYDAY=-1FR;BYMONTH=1,4,7,10;COUNT=10", nil,
+			"every last Friday of January, April, July and October, 10 times",
+			"jeden letzten Freitag im Januar, April, Juli und Oktober, 10-mal"},
+		{"FREQ=DAILY", nil, "every day", "jeden Tag"},
+		{"FREQ=DAILY;INTERVAL=2;COUNT=1", nil, "every 2 days, once", "alle 2 Tage, einmal"},
+		{"FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", nil, "every weekday", "jeden Wochentag"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;WKST=SU", nil,
+			"every 2 weeks on Tuesday and Thursday, weeks starting on Sunday",
+			"alle 2 Wochen am Dienstag und Donnerstag, Wochenbeginn am Sonntag"},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", nil,
+			"every month on the last weekday", "jeden Monat am letzten Wochentag"},
+		{"FREQ=MONTHLY;BYDAY=SA,SU;BYSETPOS=1,-2", nil,
+			"every month on the 1st and 2nd to last weekend day", "jeden Monat am 1. und vorletzten Wochenendtag"},
+		{"FREQ=MONTHLY;BYMONTHDAY=13,14;BYSETPOS=1", nil,
+			"every month on the 13th and 14th, only the 1st occurrence", "jeden Monat am 13. und 14., jeweils nur den 1. Termin"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", nil, "every month on the 1st and last day", "jeden Monat am 1. und letzten Tag"},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", nil,
+			"every month on Friday if it falls on the 13th", "jeden Monat am Freitag, wenn er auf den 13. fällt"},
+		{"FREQ=MONTHLY;INTERVAL=3;BYDAY=2MO,-3SU", nil,
+			"every 3 months on the 2nd Monday and 3rd to last Sunday", "alle 3 Monate am 2. Montag und drittletzten Sonntag"},
+		{"FREQ=YEARLY;BYYEARDAY=1,100,-1", nil,
+			"every year on the 1st, 100th and last day of the year", "jedes Jahr am 1., 100. und letzten Tag des Jahres"},
+		{"FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO", nil,
+			"every year in week 20 on Monday", "jedes Jahr in Kalenderwoche 20 am Montag"},
+		{"FREQ=YEARLY;INTERVAL=2;BYMONTH=1;BYDAY=SU;BYHOUR=8,9;BYMINUTE=30", nil,
+			"every 2 years in January on Sunday at 8:30 and 9:30", "alle 2 Jahre im Januar am Sonntag um 8:30 und 9:30 Uhr"},
+		{"FREQ=HOURLY;INTERVAL=3;BYMINUTE=0,30;UNTIL=20240601T000000Z", nil,
+			"every 3 hours at minute 0 and 30, until June 1, 2024", "alle 3 Stunden zur Minute 0 und 30, bis zum 1. Juni 2024"},
+		{"FREQ=DAILY;BYHOUR=9,10,11,12;BYMINUTE=0,30", nil,
+			"every day at hour 9, 10, 11 and 12 at minute 0 and 30", "jeden Tag zur Stunde 9, 10, 11 und 12 zur Minute 0 und 30"},
+		{"FREQ=MINUTELY;INTERVAL=15;BYSECOND=0", nil, "every 15 minutes at second 0", "alle 15 Minuten zur Sekunde 0"},
+		{"RSCALE=HEBREW;FREQ=YEARLY;BYMONTH=5L;BYMONTHDAY=14;SKIP=FORWARD", nil,
+			"every 14th of leap month 5, in the Hebrew calendar, moving days that do not exist forward",
+			"jeden 14. im Schaltmonat 5, im hebräischen Kalender, nicht existierende Tage werden verschoben"},
+
+		{"FREQ=WEEKLY", start, "every week on Friday at 9:30", "jede Woche am Freitag um 9:30 Uhr"},
+		{"FREQ=YEARLY", day, "every year on March 15", "jedes Jahr am 15. März"},
+		{"FREQ=MONTHLY;BYMONTH=1,7", day, "every 15th of January and July", "jeden 15. im Januar und Juli"},
+		{"FREQ=MONTHLY;UNTIL=20240601T030000Z", start,
+			"every month on the 15th at 9:30, until May 31, 2024", "jeden Monat am 15. um 9:30 Uhr, bis zum 31. Mai 2024"},
+	}
+	for _, tt := range tests {
+		r, err := types.ParseRecurRule(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g := Text(r, tt.dtstart, nil); g != tt.en {
+			t.Errorf("%s: got %q, want %q", tt.rule, g, tt.en)
+		}
+		if g := Text(r, tt.dtstart, German); g != tt.de {
+			t.Errorf("%s: got %q, want %q", tt.rule, g, tt.de)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for tag, want := range map[string]*Language{"en": English, "EN-us": English, "de_AT": German, "fr": nil} {
+		if l, ok := Lookup(tag); l != want || ok != (want != nil) {
+			t.Errorf("Lookup(%q) = %v, %v", tag, l, ok)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/changemanage"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.8.4.4.  Recurrence ID
+//
+//      If the value of the "DTSTART" property is a DATE type value, then
+//      the value MUST be the calendar date for the recurrence instance.
+//
+//      The "RANGE" parameter is used to specify the effective range of
+//      recurrence instances from the instance specified by the
+//      "RECURRENCE-ID" property value.  The value for the range parameter
+//      can only be "THISANDFUTURE" to indicate a range defined by the
+//      given recurrence instance and all subsequent instances.
+//      Subsequent instances are determined by their "RECURRENCE-ID" value
+//      and not their current scheduled start time.
+//
+//   RFC 9253 5.  Updates to RFC 5545
+//
+//      NEXT:  Indicates that the referenced calendar component is the
+//         next in a series the referenced calendar component is part of.
+
+// SplitSeries splits a recurring event at the instance whose recurrence
+// id is at, as for "this and all following events".  events are the
+// master and the overrides of the series; before are copies of them that
+// end the series with UNTIL a second before at, after the new series,
+// with UID uid, that starts with that instance.  events are not changed.
+//
+// RDATE and EXDATE values and overrides go to the series they fall in,
+// the overrides of the new series keeping their RECURRENCE-ID.  A COUNT
+// is shared between the series.  When an override with RANGE=
+// THISANDFUTURE changed the instance at at, the new series takes its
+// properties and time.  The new series links to the first series of
+// the split ones with RELATED-TO;RELTYPE=FIRST, the old one to the new
+// one with RELTYPE=NEXT (RFC 9253); SEQUENCE of the old series goes up.
+//
+// at must be an instance of the RRULE after DTSTART, or of an RDATE of a
+// series without RRULE.
+func SplitSeries(events []*components.Event, at time.Time, uid string) (before, after []*components.Event, err error) {
+	master, overrides, err := series(events)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, _ := timingOf(master)
+	start, _ := TimeOf(t.startP, t.start)
+	if !at.After(start) {
+		return nil, nil, fmt.Errorf("objects: split at the first instance of %s", t.uid)
+	}
+	if err := isInstance(t, at); err != nil {
+		return nil, nil, err
+	}
+	var rule *types.RecurRule
+	ruleBefore := 0
+	if master.RRule != nil && master.RRule.V != nil {
+		rule = master.RRule.V
+		var ok bool
+		if ruleBefore, ok, err = countBefore(t, at); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			return nil, nil, fmt.Errorf("objects: %s is no instance of the RRULE of %s", at.Format(time.RFC3339), t.uid)
+		}
+	}
+
+	// the old series
+	old := master.Clone()
+	if rule != nil {
+		r := &types.RecurRule{Frequency: rule.Frequency}
+		for _, part := range old.RRule.V.Rules {
+			switch part.(type) {
+			case *types.Count, *types.Until:
+			default:
+				r.Rules = append(r.Rules, part)
+			}
+		}
+		r.Rules = append(r.Rules, &types.Until{Time: untilBefore(t.start, at)})
+		old.RRule.V = r
+	}
+	old.RDate, _ = splitRDates(master.RDate, at)
+	old.ExDate, _ = splitExDates(master.ExDate, at)
+	old.Seq = bumpSequence(old.Seq)
+
+	// the new series, from the master or the THISANDFUTURE override that
+	// changed the instance
+	base, id := master, at
+	for _, o := range overrides {
+		ot, _ := timingOf(o)
+		oid, _ := TimeOf(ot.recurId.Parameters, ot.recurId.Value)
+		if isThisAndFuture(ot.recurId) && !oid.After(at) && (base == master || oid.After(id)) {
+			base, id = o, oid
+		}
+	}
+	next := base.Clone()
+	bt, _ := timingOf(base)
+	bstart, _ := TimeOf(bt.startP, bt.start)
+	newStart := at
+	if base != master {
+		newStart = at.Add(bstart.Sub(id))
+	}
+	next.Uid = &relationship.Uid{Value: &types.Text{V: types.EscapeText(uid)}}
+	next.RecurId = nil
+	next.Seq = nil
+	next.DtStart = master.DtStart.Clone()
+	next.DtStart.Value = valueAt(master.DtStart.Parameters, master.DtStart.Value, newStart)
+	if next.DtEnd != nil {
+		end := components.EndOf(newStart, bt.length())
+		next.DtEnd = &datetime.DateEnd{Parameters: next.DtEnd.Parameters, Value: valueAt(next.DtEnd.Parameters, next.DtEnd.Value, end)}
+	}
+	next.RRule, next.RDate, next.ExDate = nil, nil, nil
+	if rule != nil {
+		next.RRule = master.RRule.Clone()
+		for i, part := range next.RRule.V.Rules {
+			if c, ok := part.(*types.Count); ok {
+				next.RRule.V.Rules[i] = &types.Count{V: c.V - ruleBefore}
+			}
+		}
+	}
+	_, next.RDate = splitRDates(master.RDate, at)
+	if rule == nil {
+		// DTSTART is the instance at
+		next.RDate = withoutRDate(next.RDate, at)
+	}
+	_, next.ExDate = splitExDates(master.ExDate, at)
+
+	// RELTYPE=NEXT moves to the new series
+	first := t.uid
+	next.Related, old.Related = nil, nil
+	for _, r := range master.Related {
+		switch relType(r) {
+		case parameters.First.V:
+			first = types.UnescapeText(r.Value.V)
+			old.Related = append(old.Related, r.Clone())
+		case parameters.Next.V:
+			next.Related = append(next.Related, r.Clone())
+		default:
+			old.Related = append(old.Related, r.Clone())
+			next.Related = append(next.Related, r.Clone())
+		}
+	}
+	next.Related = append(next.Related, relatedTo(first, parameters.First))
+	old.Related = append(old.Related, relatedTo(uid, parameters.Next))
+
+	before = []*components.Event{old}
+	after = []*components.Event{next}
+	for _, o := range overrides {
+		ot, _ := timingOf(o)
+		oid, _ := TimeOf(ot.recurId.Parameters, ot.recurId.Value)
+		if oid.Before(at) {
+			before = append(before, o.Clone())
+			continue
+		}
+		c := o.Clone()
+		c.Uid = &relationship.Uid{Value: &types.Text{V: types.EscapeText(uid)}}
+		c.Seq = nil
+		after = append(after, c)
+	}
+	return before, after, nil
+}
+
+// ThisAndFuture returns an override of master with RECURRENCE-ID;RANGE=
+// THISANDFUTURE for the instance whose recurrence id is at, the other
+// form of "this and all following events": the changes made to it apply
+// to that instance and all after it, a change of its DTSTART moving them
+// as well.  The override starts and ends as the instance does.
+func ThisAndFuture(master *components.Event, at time.Time) (*components.Event, error) {
+	t, ok := timingOf(master)
+	if !ok || master.RecurId != nil || master.RRule == nil && len(master.RDate) == 0 {
+		return nil, fmt.Errorf("objects: ThisAndFuture of an event that is no recurring master")
+	}
+	if err := isInstance(t, at); err != nil {
+		return nil, err
+	}
+	o := override(master, t, at)
+	o.RecurId.Parameters = append(o.RecurId.Parameters, &parameters.RecurrenceIdRange{V: parameters.ThisAndFuture.V})
+	return o, nil
+}
+
+// override returns an override of master, with timing t, for the
+// instance at, starting and ending as the instance does.
+func override(master *components.Event, t *timing, at time.Time) *components.Event {
+	o := master.Clone()
+	o.RRule, o.RDate, o.ExDate = nil, nil, nil
+	o.RecurId = recurrenceId(t, at)
+	o.DtStart.Value = valueAt(t.startP, t.start, at)
+	if o.DtEnd != nil {
+		end := components.EndOf(at, t.length())
+		o.DtEnd.Value = valueAt(o.DtEnd.Parameters, o.DtEnd.Value, end)
+	}
+	return o
+}
+
+// recurrenceId returns the RECURRENCE-ID of the instance at of a series
+// with timing t, in the value type and time zone of its start.
+func recurrenceId(t *timing, at time.Time) *relationship.RecurrenceId {
+	params := properties.DeepCopy(t.startP).([]parameters.Parameter)
+	return &relationship.RecurrenceId{Parameters: params, Value: valueAt(t.startP, t.start, at)}
+}
+
+// series returns the master and the overrides of the recurring event
+// series events.
+func series(events []*components.Event) (*components.Event, []*components.Event, error) {
+	comps := make([]components.Component, len(events))
+	for i, e := range events {
+		comps[i] = e
+	}
+	m, o, err := seriesOf(comps, "event")
+	if err != nil {
+		return nil, nil, err
+	}
+	master := events[m]
+	if master.RRule == nil && len(master.RDate) == 0 {
+		t, _ := timingOf(master)
+		return nil, nil, fmt.Errorf("objects: no recurring master of %s", t.uid)
+	}
+	overrides := make([]*components.Event, len(o))
+	for i, j := range o {
+		overrides[i] = events[j]
+	}
+	return master, overrides, nil
+}
+
+// seriesOf returns the index of the master of comps, the components of
+// a series, and the indexes of its overrides.  kind names the components
+// in errors.
+func seriesOf(comps []components.Component, kind string) (int, []int, error) {
+	master := -1
+	var overrides []int
+	uid := ""
+	for i, c := range comps {
+		t, ok := timingOf(c)
+		if !ok {
+			return 0, nil, fmt.Errorf("objects: %s without a start", kind)
+		}
+		if i > 0 && t.uid != uid {
+			return 0, nil, fmt.Errorf("objects: %ss with different UIDs", kind)
+		}
+		uid = t.uid
+		if t.recurId == nil {
+			if master >= 0 {
+				return 0, nil, fmt.Errorf("objects: two masters of %s", uid)
+			}
+			master = i
+		} else {
+			overrides = append(overrides, i)
+		}
+	}
+	if master < 0 {
+		return 0, nil, fmt.Errorf("objects: no master of %s", uid)
+	}
+	return master, overrides, nil
+}
+
+// isInstance checks that the recurrence set of t has an instance at at.
+func isInstance(t *timing, at time.Time) error {
+	starts, _, err := t.instances(at, at.Add(time.Second), t.length())
+	if err != nil {
+		return err
+	}
+	if _, ok := starts[at.Unix()]; !ok {
+		return fmt.Errorf("objects: %s is no instance of %s", at.Format(time.RFC3339), t.uid)
+	}
+	return nil
+}
+
+// countBefore returns the number of instances of the RRULE of t before
+// at, and whether at is one.  DTSTART counts as an instance whether the
+// rule matches it or not, as it does for COUNT.
+func countBefore(t *timing, at time.Time) (int, bool, error) {
+	first, _ := TimeOf(t.startP, t.start)
+	dtstart := t.start
+	if dt, ok := t.start.(*types.DateTime); ok && !dt.IsUTC() {
+		dtstart = &types.DateTime{V: first, Format: dt.Format}
+	}
+	it, err := t.rrule.V.Iterator(dtstart)
+	if err != nil {
+		return 0, false, err
+	}
+	n := 0
+	if at.After(first) {
+		n = 1
+	}
+	for s, ok := it.Next(); ok && !s.After(at); s, ok = it.Next() {
+		if s.Equal(at) {
+			return n, true, nil
+		}
+		if s.After(first) {
+			n++
+		}
+	}
+	return n, false, nil
+}
+
+// valueAt returns t as a value of the type of v: a DATE, a UTC DATE-TIME,
+// or a local DATE-TIME in the time zone of v.
+func valueAt(params []parameters.Parameter, v types.Value, t time.Time) types.Value {
+	switch d := v.(type) {
+	case *types.Date:
+		return &types.Date{V: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, d.V.Location())}
+	case *types.DateTime:
+		if d.IsUTC() {
+			return &types.DateTime{V: t.UTC(), Format: types.UTCDateTimeFormat}
+		}
+		at, _ := TimeOf(params, v)
+		w := t.In(at.Location())
+		return &types.DateTime{V: time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, d.V.Location()), Format: d.Format}
+	}
+	return v
+}
+
+// untilBefore returns the UNTIL that ends a series with DTSTART start
+// before at, as RFC 5545 wants it: a DATE for a DATE DTSTART, a floating
+// time for a floating one, a UTC time otherwise.
+func untilBefore(start types.Value, at time.Time) types.Value {
+	switch d := start.(type) {
+	case *types.Date:
+		day := at.AddDate(0, 0, -1)
+		return &types.Date{V: time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, d.V.Location())}
+	case *types.DateTime:
+		if !d.IsUTC() && d.V.Location() == time.Local {
+			return &types.DateTime{V: at.Add(-time.Second), Format: types.LocalDateTimeFormat}
+		}
+	}
+	return &types.DateTime{V: at.Add(-time.Second).UTC(), Format: types.UTCDateTimeFormat}
+}
+
+// rdateStart returns the start of an RDATE value.
+func rdateStart(params []parameters.Parameter, v types.Value) time.Time {
+	switch p := v.(type) {
+	case *types.ExplicitPeriod:
+		return p.Start.Time()
+	case *types.StartPeriod:
+		return p.Start.Time()
+	}
+	t, _ := TimeOf(params, v)
+	return t
+}
+
+// splitRDates returns copies of the RDATE values before at and of those
+// from at on.
+func splitRDates(rdates []*recurrence.RDate, at time.Time) (before, after []*recurrence.RDate) {
+	for _, r := range rdates {
+		c := r.Clone()
+		b := &recurrence.RDate{Parameters: c.Parameters}
+		a := &recurrence.RDate{Parameters: r.Clone().Parameters}
+		for _, v := range c.Values {
+			if rdateStart(r.Parameters, v).Before(at) {
+				b.Values = append(b.Values, v)
+			} else {
+				a.Values = append(a.Values, v)
+			}
+		}
+		if len(b.Values) > 0 {
+			before = append(before, b)
+		}
+		if len(a.Values) > 0 {
+			after = append(after, a)
+		}
+	}
+	return before, after
+}
+
+// withoutRDate returns a copy of rdates without the value at.
+func withoutRDate(rdates []*recurrence.RDate, at time.Time) []*recurrence.RDate {
+	var list []*recurrence.RDate
+	for _, r := range rdates {
+		r = r.Clone()
+		c := &recurrence.RDate{Parameters: r.Parameters}
+		for _, v := range r.Values {
+			if !rdateStart(r.Parameters, v).Equal(at) {
+				c.Values = append(c.Values, v)
+			}
+		}
+		if len(c.Values) > 0 {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// splitExDates returns copies of the EXDATE values before at and of
+// those from at on.
+func splitExDates(exdates []*recurrence.ExDate, at time.Time) (before, after []*recurrence.ExDate) {
+	for _, x := range exdates {
+		c := x.Clone()
+		b := &recurrence.ExDate{Parameters: c.Parameters}
+		a := &recurrence.ExDate{Parameters: x.Clone().Parameters}
+		for _, v := range c.Values {
+			if t, _ := TimeOf(x.Parameters, v); t.Before(at) {
+				b.Values = append(b.Values, v)
+			} else {
+				a.Values = append(a.Values, v)
+			}
+		}
+		if len(b.Values) > 0 {
+			before = append(before, b)
+		}
+		if len(a.Values) > 0 {
+			after = append(after, a)
+		}
+	}
+	return before, after
+}
+
+// bumpSequence returns seq one higher, or SEQUENCE:1 for none.
+func bumpSequence(seq *changemanage.Sequence) *changemanage.Sequence {
+	if seq == nil || seq.Value == nil {
+		return &changemanage.Sequence{Value: &types.Integer{V: 1}}
+	}
+	seq.Value.V++
+	return seq
+}
+
+func isThisAndFuture(id *relationship.RecurrenceId) bool {
+	for _, p := range id.Parameters {
+		if r, ok := p.(*parameters.RecurrenceIdRange); ok && r.V == parameters.ThisAndFuture.V {
+			return true
+		}
+	}
+	return false
+}
+
+// relType returns the RELTYPE of r, PARENT when it has none.
+func relType(r *relationship.RelatedTo) string {
+	for _, p := range r.Parameters {
+		if t, ok := p.(*parameters.RelType); ok {
+			return strings.ToUpper(t.V)
+		}
+	}
+	return parameters.Parent.V
+}
+
+func relatedTo(uid string, t parameters.RelType) *relationship.RelatedTo {
+	return &relationship.RelatedTo{
+		Parameters: []parameters.Parameter{&parameters.RelType{V: t.V}},
+		Value:      &types.Text{V: types.EscapeText(uid)},
+	}
+}
@@ -0,0 +1,195 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitSeries(t *testing.T) {
+	in := `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:weekly
+DTSTAMP:20240101T000000Z
+DTSTART;TZID=Europe/Berlin:20240304T100000
+DURATION:PT1H
+RRULE:FREQ=WEEKLY;COUNT=6
+EXDATE;TZID=Europe/Berlin:20240311T100000,20240401T100000
+SUMMARY:Weekly
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly
+DTSTAMP:20240101T000000Z
+RECURRENCE-ID;TZID=Europe/Berlin:20240325T100000
+DTSTART;TZID=Europe/Berlin:20240326T100000
+DURATION:PT1H
+SUMMARY:Moved
+END:VEVENT
+END:VCALENDAR
+`
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*components.Event
+	for _, comp := range c.Components {
+		events = append(events, comp.(*components.Event))
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	at := time.Date(2024, 3, 18, 10, 0, 0, 0, berlin)
+
+	if _, _, err := SplitSeries(events, at.Add(time.Hour), "later"); err == nil {
+		t.Error("split at no instance: no error")
+	}
+	if _, _, err := SplitSeries(events, time.Date(2024, 3, 4, 10, 0, 0, 0, berlin), "later"); err == nil {
+		t.Error("split at DTSTART: no error")
+	}
+
+	before, after, err := SplitSeries(events, at, "later")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 1 || len(after) != 2 {
+		t.Fatalf("got %d and %d events, want 1 and 2", len(before), len(after))
+	}
+	if s, _ := c.Calendar(); !strings.Contains(s, "COUNT=6") {
+		t.Errorf("the events were changed:\n%s", s)
+	}
+	orig, _ := c.Calendar()
+	b, a, _ := SplitSeries(events, at, "later")
+	for _, e := range append(b, a...) {
+		setTZID(e, "Europe/Paris")
+	}
+	if s, _ := c.Calendar(); s != orig {
+		t.Errorf("the split series share properties with the events:\n%s", s)
+	}
+
+	c.Components = nil
+	for _, e := range append(before, after...) {
+		c.Components = append(c.Components, e)
+	}
+	s, err := c.Calendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"RRULE:FREQ=WEEKLY;UNTIL=20240318T085959Z",
+		"SEQUENCE:1",
+		"RELATED-TO;RELTYPE=NEXT:later",
+		"UID:later",
+		"DTSTART;TZID=Europe/Berlin:20240318T100000",
+		"RRULE:FREQ=WEEKLY;COUNT=4",
+		"RELATED-TO;RELTYPE=FIRST:weekly",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+
+	occ, err := c.Occurrences(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range occ {
+		got = append(got, o.Component.(*components.Event).Uid.Value.V+" "+o.Start.In(berlin).Format("0102T1504"))
+	}
+	want := "weekly 0304T1000 later 0318T1000 later 0326T1000 later 0408T1000"
+	if g := strings.Join(got, " "); g != want {
+		t.Errorf("got:  %s\nwant: %s", g, want)
+	}
+}
+
+func TestSplitSeries_UnmatchedStart(t *testing.T) {
+	// DTSTART is a Monday, the rule only has Tuesdays: DTSTART is the
+	// first of the four instances
+	_, comps := decodeComponents(t, `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:tuesdays
+DTSTAMP:20240101T000000Z
+DTSTART:20240304T090000Z
+DURATION:PT1H
+RRULE:FREQ=WEEKLY;COUNT=4;BYDAY=TU
+END:VEVENT
+END:VCALENDAR
+`)
+	events := []*components.Event{comps[0].(*components.Event)}
+	_, after, err := SplitSeries(events, time.Date(2024, 3, 12, 9, 0, 0, 0, time.UTC), "later")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := after[0].RRule.V.Rules[0]; c.(*types.Count).V != 2 {
+		t.Errorf("new series: got %+v, want COUNT=2", c)
+	}
+}
+
+// setTZID sets the TZID parameters of the DTSTART, RRULE, RDATE and
+// EXDATE properties of e to tzid.
+func setTZID(e *components.Event, tzid string) {
+	params := [][]parameters.Parameter{e.DtStart.Parameters}
+	if e.RRule != nil {
+		params = append(params, e.RRule.Parameters)
+	}
+	for _, r := range e.RDate {
+		params = append(params, r.Parameters)
+	}
+	for _, x := range e.ExDate {
+		params = append(params, x.Parameters)
+	}
+	for _, list := range params {
+		for _, p := range list {
+			if z, ok := p.(*parameters.TimeZoneId); ok {
+				z.V = tzid
+			}
+		}
+	}
+}
+
+func TestThisAndFuture(t *testing.T) {
+	in := `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VEVENT
+UID:daily
+DTSTAMP:20240101T000000Z
+DTSTART:20240301T090000Z
+DTEND:20240301T100000Z
+RRULE:FREQ=DAILY;COUNT=5
+SUMMARY:Daily
+END:VEVENT
+END:VCALENDAR
+`
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	master := c.Components[0].(*components.Event)
+	if _, err := ThisAndFuture(master, time.Date(2024, 3, 3, 10, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("no instance: no error")
+	}
+	o, err := ThisAndFuture(master, time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Components = append(c.Components, o)
+	s, _ := c.Calendar()
+	for _, want := range []string{
+		"RECURRENCE-ID;RANGE=THISANDFUTURE:20240303T090000Z",
+		"DTSTART:20240303T090000Z",
+		"DTEND:20240303T100000Z",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+	if strings.Count(s, "RRULE") != 1 {
+		t.Errorf("override with RRULE:\n%s", s)
+	}
+}
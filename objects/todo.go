@@ -0,0 +1,326 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.8.1.11.  Status
+//
+//       statvalue-todo  = "NEEDS-ACTION" ;Indicates to-do needs action.
+//                       / "COMPLETED"    ;Indicates to-do completed.
+//                       / "IN-PROCESS"   ;Indicates to-do in process of.
+//                       / "CANCELLED"    ;Indicates to-do was cancelled.
+//
+//   RFC 5545 3.8.2.1.  Date-Time Completed
+//
+//      This property defines the date and time that a to-do was
+//      actually completed.  ...  The value MUST be specified as a date
+//      with UTC time.
+//
+//   RFC 5545 3.8.1.8.  Percent Complete
+//
+//      The property value is a positive integer between 0 and
+//      100.  A value of "0" indicates the to-do has not yet been started.
+//      A value of "100" indicates that the to-do has been completed.
+
+// CompletionPolicy is how Complete completes an instance of a recurring
+// to-do.
+type CompletionPolicy int
+
+const (
+	// AdvanceSeries moves DTSTART and DUE of the master to the next open
+	// instance, so that the to-do always shows the instance to do next.
+	// A COUNT is lowered by the instances passed over; after the last
+	// instance the master itself is completed.
+	AdvanceSeries CompletionPolicy = iota
+	// OverrideInstance completes the instance with an override, keeping
+	// the completed instances in the series.
+	OverrideInstance
+)
+
+// todoTransitions are the allowed changes of the STATUS of a to-do.
+// Completed and cancelled to-dos can be reopened.
+var todoTransitions = map[string][]string{
+	"NEEDS-ACTION": {"IN-PROCESS", "COMPLETED", "CANCELLED"},
+	"IN-PROCESS":   {"NEEDS-ACTION", "COMPLETED", "CANCELLED"},
+	"COMPLETED":    {"NEEDS-ACTION", "IN-PROCESS"},
+	"CANCELLED":    {"NEEDS-ACTION"},
+}
+
+// Transition changes the STATUS of t to status, a to-do without STATUS
+// needing action.  COMPLETED sets COMPLETED to now and PERCENT-COMPLETE
+// to 100, NEEDS-ACTION sets PERCENT-COMPLETE to 0, and leaving COMPLETED
+// removes COMPLETED.  SEQUENCE goes up.  Changes that are not from
+// NEEDS-ACTION to IN-PROCESS to COMPLETED or CANCELLED, or back to an
+// open status, are errors.
+func Transition(t *components.Todo, status string, now time.Time) error {
+	from := todoStatus(t)
+	status = strings.ToUpper(status)
+	if _, ok := todoTransitions[status]; !ok {
+		return fmt.Errorf("objects: invalid STATUS %q of VTODO", status)
+	}
+	allowed := false
+	for _, s := range todoTransitions[from] {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		return fmt.Errorf("objects: VTODO cannot go from %s to %s", from, status)
+	}
+	t.Status = descriptive.NewStatus(status)
+	switch status {
+	case "COMPLETED":
+		t.Completed = &datetime.Completed{Value: &types.DateTime{V: now.UTC(), Format: types.UTCDateTimeFormat}}
+		t.Percent = &descriptive.PercentComplete{Values: types.NewInteger(100)}
+	case "NEEDS-ACTION":
+		t.Completed = nil
+		t.Percent = &descriptive.PercentComplete{Values: types.NewInteger(0)}
+	default:
+		t.Completed = nil
+		if status == "IN-PROCESS" && t.Percent != nil && t.Percent.Values != nil && t.Percent.Values.V == 100 {
+			t.Percent = nil
+		}
+	}
+	t.Seq = bumpSequence(t.Seq)
+	return nil
+}
+
+// Complete returns todos, a to-do and the overrides of its instances,
+// with the first instance that is not completed or cancelled completed
+// at now following policy.  A to-do that does not recur is completed
+// itself.  todos are not changed.
+func Complete(todos []*components.Todo, now time.Time, policy CompletionPolicy) ([]*components.Todo, error) {
+	master, overrides, err := todoSeries(todos)
+	if err != nil {
+		return nil, err
+	}
+	if master.RRule == nil && len(master.RDate) == 0 {
+		m := master.Clone()
+		if err := Transition(m, "COMPLETED", now); err != nil {
+			return nil, err
+		}
+		return replaceTodo(todos, m), nil
+	}
+	t, _ := timingOf(master)
+	done := map[int64]bool{}
+	var open *components.Todo
+	for _, o := range overrides {
+		id, _ := TimeOf(o.RecurId.Parameters, o.RecurId.Value)
+		done[id.Unix()] = isDone(o)
+	}
+	first, _ := TimeOf(t.startP, t.start)
+	at, ok, err := nextOpen(t, first.Add(-time.Second), done)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("objects: no open instance of %s", t.uid)
+	}
+	for _, o := range overrides {
+		if isOverrideOf(o.RecurId, at) {
+			open = o
+		}
+	}
+
+	if policy == OverrideInstance {
+		o := open
+		if o == nil {
+			o = todoOverride(master, t, at)
+		} else {
+			o = o.Clone()
+		}
+		if err := Transition(o, "COMPLETED", now); err != nil {
+			return nil, err
+		}
+		return replaceTodo(todos, o), nil
+	}
+
+	m := master.Clone()
+	next, ok, err := nextOpen(t, at, done)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// the last instance: the to-do is done
+		if err := Transition(m, "COMPLETED", now); err != nil {
+			return nil, err
+		}
+	} else {
+		if m.RRule != nil && m.RRule.V != nil {
+			n, _, err := countBefore(t, next)
+			if err != nil {
+				return nil, err
+			}
+			r := &types.RecurRule{Frequency: m.RRule.V.Frequency}
+			for _, part := range m.RRule.V.Rules {
+				if c, ok := part.(*types.Count); ok {
+					part = &types.Count{V: c.V - n}
+					if c.V-n < 1 {
+						r = nil
+						break
+					}
+				}
+				r.Rules = append(r.Rules, part)
+			}
+			if r == nil {
+				m.RRule = nil
+			} else {
+				m.RRule.V = r
+			}
+		}
+		_, m.RDate = splitRDates(m.RDate, next)
+		m.RDate = withoutRDate(m.RDate, next)
+		_, m.ExDate = splitExDates(m.ExDate, next)
+		moveTodo(m, t, next)
+		if m.Status != nil {
+			m.Status = descriptive.NewStatus("NEEDS-ACTION")
+		}
+		m.Completed, m.Percent = nil, nil
+		m.Seq = bumpSequence(m.Seq)
+	}
+	list := []*components.Todo{m}
+	for _, o := range overrides {
+		if o != open {
+			list = append(list, o)
+		}
+	}
+	return list, nil
+}
+
+// Overdue returns the instances of the to-dos of c that are due before
+// now and are neither completed nor cancelled, sorted by their due time,
+// which is the End of the Occurrence.  To-dos without DUE or DURATION
+// are never overdue.
+func (c *Calendar) Overdue(now time.Time) ([]*Occurrence, error) {
+	return Overdue(c.Components, now)
+}
+
+// Overdue is Calendar.Overdue for a list of components.
+func Overdue(comps []components.Component, now time.Time) ([]*Occurrence, error) {
+	var todos []components.Component
+	for _, c := range comps {
+		if t, ok := c.(*components.Todo); ok {
+			todos = append(todos, t)
+		}
+	}
+	occ, err := Occurrences(todos, time.Time{}, now)
+	if err != nil {
+		return nil, err
+	}
+	var overdue []*Occurrence
+	for _, o := range occ {
+		t := o.Component.(*components.Todo)
+		if t.Due == nil && t.Duration == nil || isDone(t) || !o.End.Before(now) {
+			continue
+		}
+		overdue = append(overdue, o)
+	}
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return overdue[i].End.Before(overdue[j].End)
+	})
+	return overdue, nil
+}
+
+// todoSeries returns the master and the overrides of todos.
+func todoSeries(todos []*components.Todo) (*components.Todo, []*components.Todo, error) {
+	comps := make([]components.Component, len(todos))
+	for i, t := range todos {
+		comps[i] = t
+	}
+	m, o, err := seriesOf(comps, "to-do")
+	if err != nil {
+		return nil, nil, err
+	}
+	overrides := make([]*components.Todo, len(o))
+	for i, j := range o {
+		overrides[i] = todos[j]
+	}
+	return todos[m], overrides, nil
+}
+
+// nextOpen returns the first instance of t after after that done does
+// not mark, looking up to two hundred years ahead.
+func nextOpen(t *timing, after time.Time, done map[int64]bool) (time.Time, bool, error) {
+	length := t.length()
+	for span := 24 * time.Hour; span < 200*365*24*time.Hour; span *= 2 {
+		starts, _, err := t.instances(after, after.Add(span), length)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		var next time.Time
+		for key, s := range starts {
+			if s.After(after) && !done[key] && (next.IsZero() || s.Before(next)) {
+				next = s
+			}
+		}
+		if !next.IsZero() {
+			return next, true, nil
+		}
+		if t.rrule == nil {
+			break
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// todoOverride returns an override of master, with timing t, for the
+// instance at.
+func todoOverride(master *components.Todo, t *timing, at time.Time) *components.Todo {
+	o := master.Clone()
+	o.RRule, o.RDate, o.ExDate = nil, nil, nil
+	o.RecurId = recurrenceId(t, at)
+	moveTodo(o, t, at)
+	return o
+}
+
+// moveTodo moves DTSTART and DUE of o, with timing t, to the instance
+// start.
+func moveTodo(o *components.Todo, t *timing, start time.Time) {
+	if o.DtStart != nil {
+		o.DtStart.Value = valueAt(t.startP, t.start, start)
+	}
+	if o.Due != nil {
+		o.Due.Value = valueAt(o.Due.Parameters, o.Due.Value, components.EndOf(start, t.length()))
+	}
+}
+
+// replaceTodo returns todos with t in place of the one with the same
+// RECURRENCE-ID, or added to them.
+func replaceTodo(todos []*components.Todo, t *components.Todo) []*components.Todo {
+	list := make([]*components.Todo, 0, len(todos)+1)
+	replaced := false
+	for _, td := range todos {
+		if sameRecurrence(td.RecurId, t.RecurId) {
+			list = append(list, t)
+			replaced = true
+		} else {
+			list = append(list, td)
+		}
+	}
+	if !replaced {
+		list = append(list, t)
+	}
+	return list
+}
+
+// todoStatus returns the STATUS of t, NEEDS-ACTION when it has none.
+func todoStatus(t *components.Todo) string {
+	if t.Status != nil && t.Status.Value != nil && t.Status.Value.V != "" {
+		return strings.ToUpper(t.Status.Value.V)
+	}
+	return "NEEDS-ACTION"
+}
+
+func isDone(t *components.Todo) bool {
+	switch todoStatus(t) {
+	case "COMPLETED", "CANCELLED":
+		return true
+	}
+	return t.Completed != nil
+}
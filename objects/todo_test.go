@@ -0,0 +1,142 @@
+package objects
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"testing"
+	"time"
+)
+
+const todoSeriesIn = `BEGIN:VCALENDAR
+PRODID:-//test//EN
+VERSION:2.0
+BEGIN:VTODO
+UID:weekly
+DTSTAMP:20240101T000000Z
+DTSTART;TZID=Europe/Berlin:20240304T090000
+DUE;TZID=Europe/Berlin:20240304T170000
+RRULE:FREQ=WEEKLY;COUNT=3
+STATUS:NEEDS-ACTION
+SUMMARY:Report
+END:VTODO
+END:VCALENDAR
+`
+
+func TestComplete_AdvanceSeries(t *testing.T) {
+	c, comps := decodeComponents(t, todoSeriesIn)
+	todos := []*components.Todo{comps[0].(*components.Todo)}
+	now := time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC)
+	todos, err := Complete(todos, now, AdvanceSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := encodeComponents(t, c, todos)
+	for _, want := range []string{
+		"DTSTART;TZID=Europe/Berlin:20240311T090000",
+		"DUE;TZID=Europe/Berlin:20240311T170000",
+		"RRULE:FREQ=WEEKLY;COUNT=2",
+		"STATUS:NEEDS-ACTION",
+		"SEQUENCE:1",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+	if todos, err = Complete(todos, now, AdvanceSeries); err != nil {
+		t.Fatal(err)
+	}
+	if todos, err = Complete(todos, now, AdvanceSeries); err != nil {
+		t.Fatal(err)
+	}
+	s = encodeComponents(t, c, todos)
+	for _, want := range []string{
+		"DTSTART;TZID=Europe/Berlin:20240318T090000",
+		"STATUS:COMPLETED",
+		"COMPLETED:20240304T150000Z",
+		"PERCENT-COMPLETE:100",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+	if _, err := Complete(todos, now, AdvanceSeries); err == nil {
+		t.Error("completing a completed to-do: no error")
+	}
+}
+
+func TestComplete_OverrideInstance(t *testing.T) {
+	c, comps := decodeComponents(t, todoSeriesIn)
+	todos := []*components.Todo{comps[0].(*components.Todo)}
+	now := time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC)
+	todos, err := Complete(todos, now, OverrideInstance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if todos, err = Complete(todos, now, OverrideInstance); err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 3 {
+		t.Fatalf("got %d to-dos, want 3", len(todos))
+	}
+	s := encodeComponents(t, c, todos)
+	for _, want := range []string{
+		"RECURRENCE-ID;TZID=Europe/Berlin:20240304T090000",
+		"RECURRENCE-ID;TZID=Europe/Berlin:20240311T090000",
+		"DUE;TZID=Europe/Berlin:20240311T170000",
+		"RRULE:FREQ=WEEKLY;COUNT=3",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+	if strings.Count(s, "STATUS:COMPLETED") != 2 {
+		t.Errorf("want 2 completed instances:\n%s", s)
+	}
+
+	c, _ = decodeComponents(t, s)
+	overdue, err := c.Overdue(time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overdue) != 0 {
+		t.Errorf("got %d overdue, want none", len(overdue))
+	}
+	overdue, err = c.Overdue(time.Date(2024, 3, 19, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overdue) != 1 || !overdue[0].RecurrenceId.Equal(time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %+v, want the third instance", overdue)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	c, comps := decodeComponents(t, todoSeriesIn)
+	todos := []*components.Todo{comps[0].(*components.Todo)}
+	td := todos[0]
+	now := time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC)
+	if err := Transition(td, "in-process", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := Transition(td, "COMPLETED", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := Transition(td, "CANCELLED", now); err == nil {
+		t.Error("COMPLETED to CANCELLED: no error")
+	}
+	if err := Transition(td, "DONE", now); err == nil {
+		t.Error("invalid STATUS: no error")
+	}
+	s := encodeComponents(t, c, todos)
+	for _, want := range []string{"STATUS:COMPLETED", "COMPLETED:20240304T150000Z", "PERCENT-COMPLETE:100", "SEQUENCE:2"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %s in\n%s", want, s)
+		}
+	}
+	if err := Transition(td, "NEEDS-ACTION", now); err != nil {
+		t.Fatal(err)
+	}
+	if td.Completed != nil || td.Percent == nil || td.Percent.Values.V != 0 {
+		t.Errorf("reopened to-do: COMPLETED %v, PERCENT-COMPLETE %v", td.Completed, td.Percent)
+	}
+}
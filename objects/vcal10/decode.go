@@ -0,0 +1,934 @@
+package vcal10
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/alarm"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/changemanage"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/descriptive"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/miscellaneous"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"io"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type component struct {
+	name  string
+	lines []*objects.ContentLine
+}
+
+// zone is the time zone given by the TZ and DAYLIGHT properties.
+type zone struct {
+	offset   time.Duration
+	daylight []daylight
+}
+
+type daylight struct {
+	offset     time.Duration
+	start, end time.Time
+}
+
+// utc converts the local wall clock of t, whatever its location, to UTC.
+func (z *zone) utc(t time.Time) time.Time {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	offset := z.offset
+	for _, d := range z.daylight {
+		if !wall.Before(d.start) && wall.Before(d.end) {
+			offset = d.offset
+		}
+	}
+	return wall.Add(-offset)
+}
+
+// props collects the properties of an event or a to-do; Todo has all
+// properties of an event but DTEND and TRANSP.
+type props struct {
+	components.Todo
+	DtEnd       *datetime.DateEnd
+	Transparent *datetime.Transparent
+}
+
+type decoder struct {
+	zone *zone
+}
+
+func lineError(l *objects.ContentLine, format string, a ...interface{}) error {
+	return &objects.SyntaxError{Line: l.Line, Msg: l.Name + ": " + fmt.Sprintf(format, a...)}
+}
+
+// Decode reads a vCalendar 1.0 object from r and converts it into an
+// iCalendar object.  Only events and to-dos are converted; anything
+// after the first VCALENDAR is ignored.
+func Decode(r io.Reader) (*objects.Calendar, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	cal, comps, err := split(lines)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{}
+	c := &objects.Calendar{Version: &property.Version2}
+	version := ""
+	for _, l := range cal {
+		switch l.Name {
+		case "VERSION":
+			version = strings.TrimSpace(l.Value)
+		case "PRODID":
+			v, err := text(l)
+			if err != nil {
+				return nil, err
+			}
+			c.ProdId = property.NewProductIdentifier(types.EscapeText(v))
+		case "TZ":
+			offset, err := parseOffset(l.Value)
+			if err != nil {
+				return nil, lineError(l, "%v", err)
+			}
+			if d.zone == nil {
+				d.zone = &zone{}
+			}
+			d.zone.offset = offset
+		case "DAYLIGHT":
+			dl, ok, err := parseDaylight(l.Value)
+			if err != nil {
+				return nil, lineError(l, "%v", err)
+			}
+			if ok {
+				if d.zone == nil {
+					d.zone = &zone{}
+				}
+				d.zone.daylight = append(d.zone.daylight, dl)
+			}
+		default:
+			if err := d.other(l, &c.Xprop, &c.IanaProp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if version != "1.0" {
+		return nil, &objects.SyntaxError{Line: lines[0].Line, Msg: "not a vCalendar 1.0 object"}
+	}
+	if c.ProdId == nil {
+		c.ProdId = property.NewProductIdentifier(ProdId)
+	}
+	for _, comp := range comps {
+		v, err := d.component(comp)
+		if err != nil {
+			return nil, err
+		}
+		c.Components = append(c.Components, v)
+	}
+	return c, nil
+}
+
+func isQuotedPrintable(line string) bool {
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Contains(strings.ToUpper(line), "QUOTED-PRINTABLE")
+}
+
+// readLines unfolds the content lines of r.  Besides lines starting with
+// white space, a QUOTED-PRINTABLE value continues on the next line when
+// it ends with a soft line break "="; the break is kept for the decoder.
+func readLines(r io.Reader) ([]*objects.ContentLine, error) {
+	var lines []*objects.ContentLine
+	var cur strings.Builder
+	start, n := 0, 0
+	soft := false
+	flush := func() error {
+		if cur.Len() == 0 {
+			return nil
+		}
+		c, err := objects.ParseContentLine(cur.String())
+		if err != nil {
+			return &objects.SyntaxError{Line: start, Msg: err.Error()}
+		}
+		c.Line = start
+		lines = append(lines, c)
+		cur.Reset()
+		return nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		n++
+		l := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case soft:
+			cur.WriteString("\r\n")
+			cur.WriteString(l)
+		case cur.Len() > 0 && len(l) > 0 && (l[0] == ' ' || l[0] == '\t'):
+			// unlike iCalendar, the white space belongs to the value
+			cur.WriteString(l)
+		default:
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(l) == "" {
+				soft = false
+				continue
+			}
+			start = n
+			cur.WriteString(l)
+		}
+		soft = strings.HasSuffix(l, "=") && isQuotedPrintable(cur.String())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, &objects.SyntaxError{Line: 1, Msg: "empty vCalendar object"}
+	}
+	return lines, nil
+}
+
+// split returns the calendar properties and the events and to-dos of the
+// first VCALENDAR in lines.  Other components are skipped.
+func split(lines []*objects.ContentLine) ([]*objects.ContentLine, []*component, error) {
+	first := lines[0]
+	if first.Name != "BEGIN" || !strings.EqualFold(first.Value, "VCALENDAR") {
+		return nil, nil, &objects.SyntaxError{Line: first.Line, Msg: "missing BEGIN:VCALENDAR"}
+	}
+	var cal []*objects.ContentLine
+	var comps []*component
+	var cur *component
+	skip := 0
+	for _, l := range lines[1:] {
+		switch l.Name {
+		case "BEGIN":
+			name := strings.ToUpper(strings.TrimSpace(l.Value))
+			switch {
+			case skip > 0:
+				skip++
+			case cur == nil && (name == "VEVENT" || name == "VTODO"):
+				cur = &component{name: name}
+			default:
+				skip = 1
+			}
+		case "END":
+			name := strings.ToUpper(strings.TrimSpace(l.Value))
+			switch {
+			case skip > 0:
+				skip--
+			case cur != nil:
+				if name != cur.name {
+					return nil, nil, &objects.SyntaxError{Line: l.Line, Msg: "END:" + name + " inside " + cur.name}
+				}
+				comps = append(comps, cur)
+				cur = nil
+			case name == "VCALENDAR":
+				return cal, comps, nil
+			default:
+				return nil, nil, &objects.SyntaxError{Line: l.Line, Msg: "unexpected END:" + name}
+			}
+		default:
+			switch {
+			case skip > 0:
+			case cur != nil:
+				cur.lines = append(cur.lines, l)
+			default:
+				cal = append(cal, l)
+			}
+		}
+	}
+	return nil, nil, &objects.SyntaxError{Line: lines[len(lines)-1].Line, Msg: "missing END:VCALENDAR"}
+}
+
+func encoding(l *objects.ContentLine) string {
+	if v, ok := l.Param("ENCODING"); ok {
+		return strings.ToUpper(v)
+	}
+	for _, p := range l.Params {
+		switch p.Name {
+		case "QUOTED-PRINTABLE", "BASE64", "8BIT", "7BIT":
+			if len(p.Values) == 0 {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
+
+// raw returns the value of l with its ENCODING undone.
+func raw(l *objects.ContentLine) ([]byte, error) {
+	switch encoding(l) {
+	case "QUOTED-PRINTABLE":
+		b, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(l.Value)))
+		if err != nil {
+			return nil, lineError(l, "invalid QUOTED-PRINTABLE value: %v", err)
+		}
+		return b, nil
+	case "BASE64", "B":
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(l.Value), ""))
+		if err != nil {
+			return nil, lineError(l, "invalid BASE64 value: %v", err)
+		}
+		return b, nil
+	}
+	return []byte(l.Value), nil
+}
+
+// text returns the value of l decoded to UTF-8 with LF line breaks.
+// Values without CHARSET that are not valid UTF-8 are taken as
+// ISO-8859-1, which is what most old devices send.
+func text(l *objects.ContentLine) (string, error) {
+	b, err := raw(l)
+	if err != nil {
+		return "", err
+	}
+	charset, _ := l.Param("CHARSET")
+	switch strings.ToUpper(charset) {
+	case "", "UTF-8", "US-ASCII", "ISO-8859-1", "LATIN1":
+		if !utf8.Valid(b) || strings.EqualFold(charset, "ISO-8859-1") || strings.EqualFold(charset, "LATIN1") {
+			r := make([]rune, len(b))
+			for i, c := range b {
+				r[i] = rune(c)
+			}
+			b = []byte(string(r))
+		}
+	default:
+		if !utf8.Valid(b) {
+			return "", lineError(l, "unsupported CHARSET %s", charset)
+		}
+	}
+	return strings.Replace(string(b), "\r\n", "\n", -1), nil
+}
+
+// splitList splits a value on semicolons that are not escaped with a
+// backslash and trims white space around the parts.
+func splitList(s string) []string {
+	var parts []string
+	cur := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ';':
+			cur.WriteByte(';')
+			i++
+		case s[i] == ';':
+			parts = append(parts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	return append(parts, strings.TrimSpace(cur.String()))
+}
+
+func unescape(s string) string {
+	return strings.Replace(s, "\\;", ";", -1)
+}
+
+func (d *decoder) textValue(l *objects.ContentLine) (*types.Text, error) {
+	v, err := text(l)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewText(types.EscapeText(unescape(v))), nil
+}
+
+func (d *decoder) textValues(l *objects.ContentLine) ([]types.Value, error) {
+	v, err := text(l)
+	if err != nil {
+		return nil, err
+	}
+	var values []types.Value
+	for _, part := range splitList(v) {
+		if part != "" {
+			values = append(values, types.NewText(types.EscapeText(part)))
+		}
+	}
+	return values, nil
+}
+
+func parseDateOrDateTime(s string, z *zone) (types.Value, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 8 {
+		return types.ParseDate(s)
+	}
+	if strings.HasSuffix(s, "Z") || z == nil {
+		return types.ParseDateTime(s, nil)
+	}
+	v, err := types.ParseDateTime(s, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	return &types.DateTime{V: z.utc(v.V), Format: types.UTCDateTimeFormat}, nil
+}
+
+func (d *decoder) timeValue(l *objects.ContentLine) (types.Value, []parameters.Parameter, error) {
+	v, err := parseDateOrDateTime(l.Value, d.zone)
+	if err != nil {
+		return nil, nil, lineError(l, "invalid date %q", l.Value)
+	}
+	if _, ok := v.(*types.Date); ok {
+		return v, []parameters.Parameter{&parameters.Date}, nil
+	}
+	return v, nil, nil
+}
+
+func (d *decoder) dateTime(l *objects.ContentLine) (*types.DateTime, error) {
+	v, _, err := d.timeValue(l)
+	if err != nil {
+		return nil, err
+	}
+	if dt, ok := v.(*types.DateTime); ok {
+		return dt, nil
+	}
+	return &types.DateTime{V: v.(*types.Date).V, Format: types.LocalDateTimeFormat}, nil
+}
+
+func (d *decoder) timeValues(l *objects.ContentLine) ([]types.Value, []parameters.Parameter, error) {
+	var values []types.Value
+	var params []parameters.Parameter
+	for _, part := range splitList(l.Value) {
+		if part == "" {
+			continue
+		}
+		v, err := parseDateOrDateTime(part, d.zone)
+		if err != nil {
+			return nil, nil, lineError(l, "invalid date %q", part)
+		}
+		if _, ok := v.(*types.Date); ok {
+			params = []parameters.Parameter{&parameters.Date}
+		}
+		values = append(values, v)
+	}
+	return values, params, nil
+}
+
+func (d *decoder) integer(l *objects.ContentLine) (*types.Integer, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(l.Value))
+	if err != nil {
+		return nil, lineError(l, "invalid integer %q", l.Value)
+	}
+	return types.NewInteger(n), nil
+}
+
+func (d *decoder) rule(l *objects.ContentLine, dtstart types.Value) (*types.RecurRule, error) {
+	r, err := ParseRule(l.Value, dtstart)
+	if err != nil {
+		return nil, lineError(l, "%v", err)
+	}
+	for _, rule := range r.Rules {
+		if u, ok := rule.(*types.Until); ok && d.zone != nil {
+			if dt, ok := u.Time.(*types.DateTime); ok && !dt.IsUTC() {
+				u.Time = &types.DateTime{V: d.zone.utc(dt.V), Format: types.UTCDateTimeFormat}
+			}
+		}
+	}
+	return r, nil
+}
+
+// otherParams keeps the parameters of l that are not about its encoding.
+func otherParams(l *objects.ContentLine) []parameters.Parameter {
+	var params []parameters.Parameter
+	for _, p := range l.Params {
+		switch p.Name {
+		case "ENCODING", "CHARSET", "QUOTED-PRINTABLE", "BASE64", "8BIT", "7BIT":
+			continue
+		}
+		if len(p.Values) == 0 {
+			// a vCalendar 1.0 TYPE without its name
+			params = append(params, &parameters.OtherParam{Name: "TYPE", V: []string{p.Name}})
+			continue
+		}
+		params = append(params, &parameters.OtherParam{Name: p.Name, V: p.Values})
+	}
+	return params
+}
+
+func (d *decoder) other(l *objects.ContentLine, xprop *[]*miscellaneous.NoStandard, iana *[]*miscellaneous.Iana) error {
+	v, err := text(l)
+	if err != nil {
+		return err
+	}
+	value := []types.Value{types.NewText(types.EscapeText(v))}
+	if strings.HasPrefix(l.Name, "X-") {
+		*xprop = append(*xprop, &miscellaneous.NoStandard{Name: l.Name, Parameters: otherParams(l), Values: value})
+		return nil
+	}
+	*iana = append(*iana, &miscellaneous.Iana{Name: l.Name, Parameters: otherParams(l), Values: value})
+	return nil
+}
+
+// splitAddress returns the address and the display name of an attendee
+// value such as "John Smith <jsmith@host.com>".
+func splitAddress(v string) (string, string) {
+	v = strings.TrimSpace(v)
+	if a, err := mail.ParseAddress(v); err == nil {
+		return a.Address, a.Name
+	}
+	if len(v) > 7 && strings.EqualFold(v[:7], "mailto:") {
+		return v[7:], ""
+	}
+	return v, ""
+}
+
+func (d *decoder) attendee(l *objects.ContentLine, p *props) error {
+	v, err := text(l)
+	if err != nil {
+		return err
+	}
+	addr, name := splitAddress(unescape(v))
+	var params []parameters.Parameter
+	if name != "" {
+		params = append(params, &parameters.CommonName{V: name})
+	}
+	role, _ := l.Param("ROLE")
+	if strings.EqualFold(role, "ORGANIZER") && p.Organizer == nil {
+		p.Organizer = &relationship.Organizer{Parameters: params, Value: types.NewCalAddress(addr)}
+		return nil
+	}
+	expect, _ := l.Param("EXPECT")
+	if strings.EqualFold(role, "OWNER") {
+		params = append(params, &parameters.Chair)
+	} else if r, ok := expectRoles[strings.ToUpper(expect)]; ok {
+		params = append(params, r)
+	}
+	status, _ := l.Param("STATUS")
+	if ps, ok := partStats[strings.ToUpper(status)]; ok {
+		params = append(params, ps)
+	}
+	if rsvp, ok := l.Param("RSVP"); ok {
+		switch strings.ToUpper(rsvp) {
+		case "YES", "TRUE":
+			params = append(params, &parameters.Rsvp{V: true})
+		case "NO", "FALSE":
+			params = append(params, &parameters.Rsvp{V: false})
+		}
+	}
+	for _, param := range l.Params {
+		if strings.HasPrefix(param.Name, "X-") {
+			params = append(params, &parameters.OtherParam{Name: param.Name, V: param.Values})
+		}
+	}
+	p.Attendee = append(p.Attendee, &relationship.Attendee{Parameters: params, Value: types.NewCalAddress(addr)})
+	return nil
+}
+
+func (d *decoder) attach(l *objects.ContentLine) (*descriptive.Attach, error) {
+	if enc := encoding(l); enc == "BASE64" || enc == "B" {
+		b, err := raw(l)
+		if err != nil {
+			return nil, err
+		}
+		return &descriptive.Attach{
+			Parameters: []parameters.Parameter{&parameters.BASE64, &parameters.Binary},
+			Value:      &types.Binary{V: b},
+		}, nil
+	}
+	v, err := text(l)
+	if err != nil {
+		return nil, err
+	}
+	v = strings.TrimSpace(v)
+	if kind, _ := l.Param("VALUE"); strings.EqualFold(kind, "CONTENT-ID") || strings.EqualFold(kind, "CID") {
+		v = "cid:" + strings.Trim(v, "<>")
+	}
+	return &descriptive.Attach{Value: types.NewUri(v)}, nil
+}
+
+func isUTC(v types.Value) bool {
+	dt, ok := v.(*types.DateTime)
+	return ok && dt.IsUTC()
+}
+
+func instant(v types.Value) time.Time {
+	switch t := v.(type) {
+	case *types.DateTime:
+		return t.Time()
+	case *types.Date:
+		return t.Time()
+	}
+	return time.Time{}
+}
+
+// trigger returns the TRIGGER of an alarm running at run.  It is relative
+// to the start, or to the due date of a to-do, when the time kinds match,
+// and an absolute UTC time otherwise.
+func trigger(run types.Value, p *props, todo bool) *alarm.Trigger {
+	var anchor types.Value
+	var params []parameters.Parameter
+	switch {
+	case todo && p.Due != nil:
+		anchor = p.Due.Value
+		params = []parameters.Parameter{&parameters.EndRelated}
+	case p.DtStart != nil:
+		anchor = p.DtStart.Value
+	}
+	if anchor != nil && isUTC(anchor) == isUTC(run) {
+		return &alarm.Trigger{
+			Parameters: params,
+			Value:      types.NewDuration(instant(run).Sub(instant(anchor))),
+		}
+	}
+	return &alarm.Trigger{
+		Parameters: []parameters.Parameter{&parameters.DateTime},
+		Value:      &types.DateTime{V: instant(run).UTC(), Format: types.UTCDateTimeFormat},
+	}
+}
+
+// alarm converts an AALARM, DALARM or MALARM property.  Their values are
+// "runTime;snoozeTime;repeatCount;" followed by the sound, the text to
+// display, or the address and text of the mail.
+func (d *decoder) alarm(l *objects.ContentLine, p *props, todo bool) (*components.Alarm, error) {
+	v, err := text(l)
+	if err != nil {
+		return nil, err
+	}
+	parts := splitList(v)
+	for len(parts) < 5 {
+		parts = append(parts, "")
+	}
+	run, err := parseDateOrDateTime(parts[0], d.zone)
+	if err != nil {
+		return nil, lineError(l, "invalid run time %q", parts[0])
+	}
+	a := &components.Alarm{Trigger: trigger(run, p, todo)}
+	if parts[1] != "" && parts[2] != "" {
+		snooze, err := types.ParseDuration(parts[1])
+		if err != nil {
+			return nil, lineError(l, "invalid snooze time %q", parts[1])
+		}
+		repeat, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, lineError(l, "invalid repeat count %q", parts[2])
+		}
+		if repeat > 0 {
+			a.Duration = snooze
+			a.Repeat = types.NewInteger(repeat)
+		}
+	}
+	summary := "Reminder"
+	if p.Summary != nil {
+		summary = p.Summary.Value.V
+	}
+	switch l.Name {
+	case "AALARM":
+		a.Action = &alarm.Audio
+		if parts[3] != "" {
+			a.Attach = []*descriptive.Attach{{Value: types.NewUri(parts[3])}}
+		}
+	case "DALARM":
+		a.Action = &alarm.Display
+		a.Description = descriptive.NewDescription(summary)
+		if parts[3] != "" {
+			a.Description = descriptive.NewDescription(types.EscapeText(parts[3]))
+		}
+	case "MALARM":
+		a.Action = &alarm.Email
+		addr, name := splitAddress(parts[3])
+		attendee := relationship.NewAttendee(addr)
+		if name != "" {
+			attendee.Parameters = []parameters.Parameter{&parameters.CommonName{V: name}}
+		}
+		a.Attendee = []*relationship.Attendee{attendee}
+		a.Summary = descriptive.NewSummary(summary)
+		a.Description = descriptive.NewDescription(summary)
+		if parts[4] != "" {
+			a.Description = descriptive.NewDescription(types.EscapeText(parts[4]))
+		}
+	}
+	return a, nil
+}
+
+func (d *decoder) component(c *component) (components.Component, error) {
+	todo := c.name == "VTODO"
+	p := &props{}
+	statuses := eventStatus
+	if todo {
+		statuses = todoStatus
+	}
+	var rrule, exrule *objects.ContentLine
+	var alarms []*objects.ContentLine
+	for _, l := range c.lines {
+		var err error
+		switch l.Name {
+		case "ATTACH":
+			var a *descriptive.Attach
+			if a, err = d.attach(l); err == nil {
+				p.Attach = append(p.Attach, a)
+			}
+		case "ATTENDEE":
+			err = d.attendee(l, p)
+		case "DCREATED":
+			var v *types.DateTime
+			if v, err = d.dateTime(l); err == nil {
+				p.Created = &changemanage.Created{Value: v}
+			}
+		case "LAST-MODIFIED":
+			var v *types.DateTime
+			if v, err = d.dateTime(l); err == nil {
+				p.LastModified = &changemanage.LastModified{Value: v}
+			}
+		case "COMPLETED":
+			var v *types.DateTime
+			if v, err = d.dateTime(l); err == nil {
+				p.Completed = &datetime.Completed{Value: v}
+			}
+		case "DTSTART", "DTEND", "DUE":
+			v, params, err := d.timeValue(l)
+			if err != nil {
+				return nil, err
+			}
+			switch l.Name {
+			case "DTSTART":
+				p.DtStart = &datetime.DateStart{Parameters: params, Value: v}
+			case "DTEND":
+				p.DtEnd = &datetime.DateEnd{Parameters: params, Value: v}
+			case "DUE":
+				p.Due = &datetime.Due{Parameters: params, Value: v}
+			}
+		case "EXDATE", "RDATE":
+			values, params, err := d.timeValues(l)
+			if err != nil {
+				return nil, err
+			}
+			if l.Name == "EXDATE" {
+				p.ExDate = append(p.ExDate, &recurrence.ExDate{Parameters: params, Values: values})
+			} else {
+				p.RDate = append(p.RDate, &recurrence.RDate{Parameters: params, Values: values})
+			}
+		case "RRULE":
+			rrule = l
+		case "EXRULE":
+			exrule = l
+		case "RNUM":
+			// the number of instances follows from the rule
+		case "AALARM", "DALARM", "MALARM":
+			alarms = append(alarms, l)
+		case "DESCRIPTION":
+			var v *types.Text
+			if v, err = d.textValue(l); err == nil {
+				p.Description = &descriptive.Description{Value: v}
+			}
+		case "SUMMARY":
+			var v *types.Text
+			if v, err = d.textValue(l); err == nil {
+				p.Summary = &descriptive.Summary{Value: v}
+			}
+		case "LOCATION":
+			var v *types.Text
+			if v, err = d.textValue(l); err == nil {
+				p.Location = &descriptive.Location{Values: v}
+			}
+		case "UID":
+			var v *types.Text
+			if v, err = d.textValue(l); err == nil {
+				p.Uid = &relationship.Uid{Value: v}
+			}
+		case "RELATED-TO":
+			var v *types.Text
+			if v, err = d.textValue(l); err == nil {
+				p.Related = append(p.Related, &relationship.RelatedTo{Value: v})
+			}
+		case "URL":
+			p.Url = relationship.NewUrl(strings.TrimSpace(l.Value))
+		case "CATEGORIES", "RESOURCES":
+			var values []types.Value
+			if values, err = d.textValues(l); err == nil && len(values) > 0 {
+				if l.Name == "CATEGORIES" {
+					p.Categories = append(p.Categories, &descriptive.Categories{Values: values})
+				} else {
+					p.Resources = append(p.Resources, &descriptive.Resources{Values: values})
+				}
+			}
+		case "CLASS":
+			switch strings.ToUpper(strings.TrimSpace(l.Value)) {
+			case "PUBLIC":
+				p.Class = &descriptive.PublicClassification
+			case "PRIVATE":
+				p.Class = &descriptive.PrivateClassification
+			case "CONFIDENTIAL":
+				p.Class = &descriptive.ConfidentialClassification
+			default:
+				p.Class = &descriptive.Classification{Value: types.NewText(strings.TrimSpace(l.Value))}
+			}
+		case "PRIORITY":
+			var v *types.Integer
+			if v, err = d.integer(l); err == nil {
+				p.Priority = &descriptive.Priority{Value: v}
+			}
+		case "SEQUENCE":
+			var v *types.Integer
+			if v, err = d.integer(l); err == nil {
+				p.Seq = &changemanage.Sequence{Value: v}
+			}
+		case "STATUS":
+			// values without an iCalendar counterpart, such as DELEGATED,
+			// are dropped
+			if s, ok := statuses[strings.ToUpper(strings.TrimSpace(l.Value))]; ok {
+				p.Status = descriptive.NewStatus(s)
+			}
+		case "TRANSP":
+			var v *types.Integer
+			if v, err = d.integer(l); err == nil {
+				p.Transparent = &datetime.TransparentOpaque
+				if v.V != 0 {
+					p.Transparent = &datetime.TransparentTransparent
+				}
+			}
+		default:
+			err = d.other(l, &p.Xprop, &p.IanaProp)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var dtstart types.Value
+	if p.DtStart != nil {
+		dtstart = p.DtStart.Value
+	}
+	if rrule != nil {
+		r, err := d.rule(rrule, dtstart)
+		if err != nil {
+			return nil, err
+		}
+		p.RRule = &recurrence.RRule{V: r}
+	}
+	if exrule != nil {
+		// EXRULE is deprecated by RFC 5545 but still understood by most
+		// implementations of RFC 2445
+		r, err := d.rule(exrule, dtstart)
+		if err != nil {
+			return nil, err
+		}
+		p.IanaProp = append(p.IanaProp, &miscellaneous.Iana{Name: "EXRULE", Values: []types.Value{r}})
+	}
+	for _, l := range alarms {
+		a, err := d.alarm(l, p, todo)
+		if err != nil {
+			return nil, err
+		}
+		p.Alarm = append(p.Alarm, a)
+	}
+	if p.Uid == nil {
+		// derive the UID from the content so that importing the same
+		// object twice yields the same UID
+		h := sha1.New()
+		for _, l := range c.lines {
+			io.WriteString(h, l.String())
+			io.WriteString(h, "\n")
+		}
+		p.Uid = relationship.NewUid(fmt.Sprintf("%x@vcal10", h.Sum(nil)[:10]))
+	}
+	switch {
+	case p.LastModified != nil:
+		p.DtStamp = &changemanage.DtStamp{Value: &types.DateTime{V: p.LastModified.Value.V, Format: p.LastModified.Value.Format}}
+	case p.Created != nil:
+		p.DtStamp = &changemanage.DtStamp{Value: &types.DateTime{V: p.Created.Value.V, Format: p.Created.Value.Format}}
+	default:
+		p.DtStamp = &changemanage.DtStamp{Value: &types.DateTime{V: time.Now().UTC(), Format: types.UTCDateTimeFormat}}
+	}
+
+	if todo {
+		t := p.Todo
+		return &t, nil
+	}
+	return &components.Event{
+		DtStamp:      p.DtStamp,
+		Uid:          p.Uid,
+		DtStart:      p.DtStart,
+		Class:        p.Class,
+		Created:      p.Created,
+		Description:  p.Description,
+		Geo:          p.Geo,
+		LastModified: p.LastModified,
+		Location:     p.Location,
+		Organizer:    p.Organizer,
+		Priority:     p.Priority,
+		Seq:          p.Seq,
+		Status:       p.Status,
+		Summary:      p.Summary,
+		Transparent:  p.Transparent,
+		Url:          p.Url,
+		RecurId:      p.RecurId,
+		RRule:        p.RRule,
+		DtEnd:        p.DtEnd,
+		Attach:       p.Attach,
+		Attendee:     p.Attendee,
+		Categories:   p.Categories,
+		Comment:      p.Comment,
+		Contact:      p.Contact,
+		ExDate:       p.ExDate,
+		RStatus:      p.RStatus,
+		Related:      p.Related,
+		Resources:    p.Resources,
+		RDate:        p.RDate,
+		Xprop:        p.Xprop,
+		IanaProp:     p.IanaProp,
+		Alarm:        p.Alarm,
+	}, nil
+}
+
+// parseOffset parses a UTC offset such as "-05", "-05:00" or "+0530".
+func parseOffset(s string) (time.Duration, error) {
+	v := strings.Replace(strings.TrimSpace(s), ":", "", 1)
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(v, "-"):
+		sign = -1
+		v = v[1:]
+	case strings.HasPrefix(v, "+"):
+		v = v[1:]
+	}
+	if !isDigits(v) || len(v) > 4 {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	h, m := atoi(v), 0
+	if len(v) > 2 {
+		h, m = atoi(v[:len(v)-2]), atoi(v[len(v)-2:])
+	}
+	if h > 14 || m > 59 {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	return sign * (time.Duration(h)*time.Hour + time.Duration(m)*time.Minute), nil
+}
+
+// parseDaylight parses "TRUE;-04;19960407T025959;19961027T010000;EST;EDT",
+// the offset during daylight saving time and its local start and end.
+// ok is false for "FALSE".
+func parseDaylight(s string) (dl daylight, ok bool, err error) {
+	parts := splitList(s)
+	if !strings.EqualFold(parts[0], "TRUE") {
+		return dl, false, nil
+	}
+	if len(parts) < 4 {
+		return dl, false, fmt.Errorf("incomplete DAYLIGHT %q", s)
+	}
+	if dl.offset, err = parseOffset(parts[1]); err != nil {
+		return dl, false, err
+	}
+	start, err := types.ParseDateTime(strings.TrimSuffix(parts[2], "Z"), time.UTC)
+	if err != nil {
+		return dl, false, fmt.Errorf("invalid DAYLIGHT start %q", parts[2])
+	}
+	end, err := types.ParseDateTime(strings.TrimSuffix(parts[3], "Z"), time.UTC)
+	if err != nil {
+		return dl, false, fmt.Errorf("invalid DAYLIGHT end %q", parts[3])
+	}
+	dl.start, dl.end = start.V, end.V
+	return dl, true, nil
+}
@@ -0,0 +1,503 @@
+package vcal10
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/miscellaneous"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"mime/quotedprintable"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type encoder struct {
+	s *strings.Builder
+}
+
+// Encode converts the events and to-dos of c into a vCalendar 1.0 object
+// with CRLF line breaks.  Other components are left out, and times with a
+// TZID are written in UTC.  Encode fails for recurrence rules that the
+// vCalendar 1.0 syntax cannot express.
+func Encode(c *objects.Calendar) (string, error) {
+	e := &encoder{s: &strings.Builder{}}
+	e.line("BEGIN", "", "VCALENDAR")
+	e.line("VERSION", "", "1.0")
+	prodId := ProdId
+	if c.ProdId != nil && c.ProdId.Value != nil {
+		prodId = types.UnescapeText(c.ProdId.Value.V)
+	}
+	e.text("PRODID", "", prodId)
+	for _, comp := range c.Components {
+		var err error
+		switch v := comp.(type) {
+		case *components.Event:
+			err = e.component("VEVENT", fromEvent(v), false)
+		case *components.Todo:
+			err = e.component("VTODO", &props{Todo: *v}, true)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := e.others(c.Xprop, c.IanaProp, nil); err != nil {
+		return "", err
+	}
+	e.line("END", "", "VCALENDAR")
+	return e.s.String(), nil
+}
+
+func fromEvent(ev *components.Event) *props {
+	p := &props{DtEnd: ev.DtEnd, Transparent: ev.Transparent}
+	p.DtStamp = ev.DtStamp
+	p.Uid = ev.Uid
+	p.DtStart = ev.DtStart
+	p.Class = ev.Class
+	p.Created = ev.Created
+	p.Description = ev.Description
+	p.Geo = ev.Geo
+	p.LastModified = ev.LastModified
+	p.Location = ev.Location
+	p.Organizer = ev.Organizer
+	p.Priority = ev.Priority
+	p.Seq = ev.Seq
+	p.Status = ev.Status
+	p.Summary = ev.Summary
+	p.Url = ev.Url
+	p.RecurId = ev.RecurId
+	p.RRule = ev.RRule
+	p.Duration = ev.Duration
+	p.Attach = ev.Attach
+	p.Attendee = ev.Attendee
+	p.Categories = ev.Categories
+	p.Comment = ev.Comment
+	p.Contact = ev.Contact
+	p.ExDate = ev.ExDate
+	p.RStatus = ev.RStatus
+	p.Related = ev.Related
+	p.Resources = ev.Resources
+	p.RDate = ev.RDate
+	p.Xprop = ev.Xprop
+	p.IanaProp = ev.IanaProp
+	p.Alarm = ev.Alarm
+	return p
+}
+
+func (e *encoder) line(name, params, value string) {
+	e.s.WriteString(name)
+	e.s.WriteString(params)
+	e.s.WriteString(":")
+	e.s.WriteString(value)
+	e.s.WriteString("\r\n")
+}
+
+// text writes a text value, switching to QUOTED-PRINTABLE for values that
+// are not plain ASCII or that span several lines.
+func (e *encoder) text(name, params, value string) {
+	plain := true
+	for i := 0; i < len(value); i++ {
+		if value[i] >= 0x7f || value[i] < 0x20 {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		e.line(name, params, value)
+		return
+	}
+	buf := &bytes.Buffer{}
+	w := quotedprintable.NewWriter(buf)
+	w.Binary = true
+	w.Write([]byte(strings.Replace(strings.Replace(value, "\r\n", "\n", -1), "\n", "\r\n", -1)))
+	w.Close()
+	e.line(name, params+";ENCODING=QUOTED-PRINTABLE;CHARSET=UTF-8", buf.String())
+}
+
+func escapeList(s string) string {
+	return strings.Replace(s, ";", "\\;", -1)
+}
+
+func valueString(v types.Value) string {
+	s := &strings.Builder{}
+	v.WriteValueToStrBuilder(s)
+	return s.String()
+}
+
+func tzid(params []parameters.Parameter) string {
+	for _, p := range params {
+		if t, ok := p.(*parameters.TimeZoneId); ok {
+			return strings.TrimPrefix(t.V, "/")
+		}
+	}
+	return ""
+}
+
+// moment returns the time of v and whether it is written in UTC.  Times
+// with a TZID are resolved and converted to UTC.
+func moment(params []parameters.Parameter, v types.Value) (time.Time, bool, error) {
+	switch t := v.(type) {
+	case *types.Date:
+		return t.Time(), false, nil
+	case *types.DateTime:
+		if t.IsUTC() {
+			return t.Time(), true, nil
+		}
+		if id := tzid(params); id != "" {
+			loc, err := time.LoadLocation(id)
+			if err != nil {
+				return time.Time{}, false, fmt.Errorf("unknown TZID %s", id)
+			}
+			w := t.V
+			return time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), w.Second(), 0, loc).UTC(), true, nil
+		}
+		return t.V, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("unexpected time value %T", v)
+}
+
+func formatMoment(t time.Time, utc bool) string {
+	if utc {
+		return t.UTC().Format(types.UTCDateTimeFormat)
+	}
+	return t.Format(types.LocalDateTimeFormat)
+}
+
+func timeString(params []parameters.Parameter, v types.Value) (string, error) {
+	if d, ok := v.(*types.Date); ok {
+		return d.V.Format("20060102"), nil
+	}
+	t, utc, err := moment(params, v)
+	if err != nil {
+		return "", err
+	}
+	return formatMoment(t, utc), nil
+}
+
+func (e *encoder) time(name string, params []parameters.Parameter, v types.Value) error {
+	s, err := timeString(params, v)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	e.line(name, "", s)
+	return nil
+}
+
+func (e *encoder) times(name string, params []parameters.Parameter, values []types.Value) error {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := v.(types.Period); ok {
+			// periods have no vCalendar 1.0 form
+			continue
+		}
+		s, err := timeString(params, v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		parts = append(parts, s)
+	}
+	if len(parts) > 0 {
+		e.line(name, "", strings.Join(parts, ";"))
+	}
+	return nil
+}
+
+func (e *encoder) list(name string, values []types.Value) {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, escapeList(types.UnescapeText(valueString(v))))
+	}
+	e.text(name, "", strings.Join(parts, ";"))
+}
+
+func (e *encoder) attendee(name string, a []parameters.Parameter, addr *types.CalAddress, organizer bool) {
+	params := &strings.Builder{}
+	cn := ""
+	role := ";ROLE=ATTENDEE"
+	if organizer {
+		role = ";ROLE=ORGANIZER"
+	}
+	for _, p := range a {
+		switch v := p.(type) {
+		case *parameters.CommonName:
+			cn = v.V
+		case *parameters.ParticipationRole:
+			if v.V == parameters.Chair.V {
+				role = ";ROLE=OWNER"
+			} else if expect, ok := roleExpects[v.V]; ok {
+				params.WriteString(";EXPECT=" + expect)
+			}
+		case *parameters.PartStat:
+			if status, ok := attendeeStatus[v.V]; ok {
+				params.WriteString(";STATUS=" + status)
+			}
+		case *parameters.Rsvp:
+			if v.V {
+				params.WriteString(";RSVP=YES")
+			} else {
+				params.WriteString(";RSVP=NO")
+			}
+		case *parameters.OtherParam:
+			if strings.HasPrefix(v.Name, "X-") {
+				params.WriteString(";")
+				v.WriteParameterToStrBuilder(params)
+			}
+		}
+	}
+	value := ""
+	if addr != nil && addr.V != nil {
+		value = addr.V.V
+		if len(value) > 7 && strings.EqualFold(value[:7], "mailto:") {
+			value = value[7:]
+		}
+	}
+	if cn != "" {
+		value = cn + " <" + value + ">"
+	}
+	e.text(name, role+params.String(), value)
+}
+
+// alarm writes a VALARM as AALARM, DALARM or MALARM.  Relative triggers
+// are resolved against start, or end for RELATED=END; alarms whose
+// trigger cannot be resolved are left out.
+func (e *encoder) alarm(a *components.Alarm, startParams []parameters.Parameter, start types.Value, endParams []parameters.Parameter, end types.Value) error {
+	if a.Action == nil || a.Action.Value == nil || a.Trigger == nil {
+		return nil
+	}
+	run := ""
+	switch v := a.Trigger.Value.(type) {
+	case *types.DateTime:
+		s, err := timeString(nil, v)
+		if err != nil {
+			return err
+		}
+		run = s
+	case *types.Duration:
+		anchor, params := start, startParams
+		for _, p := range a.Trigger.Parameters {
+			if r, ok := p.(*parameters.Related); ok && r.V == parameters.EndRelated.V {
+				anchor, params = end, endParams
+			}
+		}
+		if anchor == nil {
+			return nil
+		}
+		t, utc, err := moment(params, anchor)
+		if err != nil {
+			return err
+		}
+		run = formatMoment(t.Add(v.Duration()), utc)
+	default:
+		return nil
+	}
+	snooze, repeat := "", ""
+	if a.Duration != nil && a.Repeat != nil {
+		snooze = valueString(a.Duration)
+		repeat = strconv.Itoa(a.Repeat.V)
+	}
+	description := ""
+	if a.Description != nil && a.Description.Value != nil {
+		description = escapeList(types.UnescapeText(a.Description.Value.V))
+	}
+	head := run + ";" + snooze + ";" + repeat + ";"
+	switch a.Action.Value.V {
+	case "AUDIO":
+		sound := ""
+		if len(a.Attach) > 0 {
+			if u, ok := a.Attach[0].Value.(*types.URI); ok {
+				sound = escapeList(u.V)
+			}
+		}
+		e.text("AALARM", "", head+sound)
+	case "DISPLAY":
+		e.text("DALARM", "", head+description)
+	case "EMAIL":
+		addr := ""
+		if len(a.Attendee) > 0 && a.Attendee[0].Value != nil && a.Attendee[0].Value.V != nil {
+			addr = strings.TrimPrefix(a.Attendee[0].Value.V.V, "mailto:")
+		}
+		e.text("MALARM", "", head+escapeList(addr)+";"+description)
+	}
+	return nil
+}
+
+func (e *encoder) others(xprop []*miscellaneous.NoStandard, iana []*miscellaneous.Iana, dtstart types.Value) error {
+	for _, x := range xprop {
+		params := &strings.Builder{}
+		parameters.WriteParametersToStrBuilder(x.Parameters, params)
+		values := make([]string, 0, len(x.Values))
+		for _, v := range x.Values {
+			values = append(values, types.UnescapeText(valueString(v)))
+		}
+		e.text(x.Name, params.String(), strings.Join(values, ","))
+	}
+	for _, x := range iana {
+		if x.Name == "EXRULE" && len(x.Values) == 1 {
+			if r, ok := x.Values[0].(*types.RecurRule); ok {
+				rule, err := FormatRule(r, dtstart)
+				if err != nil {
+					return err
+				}
+				e.line("EXRULE", "", rule)
+				continue
+			}
+		}
+		params := &strings.Builder{}
+		parameters.WriteParametersToStrBuilder(x.Parameters, params)
+		values := make([]string, 0, len(x.Values))
+		for _, v := range x.Values {
+			values = append(values, types.UnescapeText(valueString(v)))
+		}
+		e.text(x.Name, params.String(), strings.Join(values, ","))
+	}
+	return nil
+}
+
+func (e *encoder) component(name string, p *props, todo bool) error {
+	e.line("BEGIN", "", name)
+	if p.Uid != nil && p.Uid.Value != nil {
+		e.text("UID", "", types.UnescapeText(p.Uid.Value.V))
+	}
+	var startParams, endParams []parameters.Parameter
+	var start, end types.Value
+	if p.DtStart != nil && p.DtStart.Value != nil {
+		startParams, start = p.DtStart.Parameters, p.DtStart.Value
+		if err := e.time("DTSTART", startParams, start); err != nil {
+			return err
+		}
+	}
+	switch {
+	case !todo && p.DtEnd != nil && p.DtEnd.Value != nil:
+		endParams, end = p.DtEnd.Parameters, p.DtEnd.Value
+	case todo && p.Due != nil && p.Due.Value != nil:
+		endParams, end = p.Due.Parameters, p.Due.Value
+	case start != nil && p.Duration != nil && p.Duration.Value != nil:
+		// vCalendar 1.0 has no DURATION
+		t, utc, err := moment(startParams, start)
+		if err != nil {
+			return err
+		}
+		if _, ok := start.(*types.Date); ok {
+			end = &types.Date{V: t.Add(p.Duration.Value.Duration())}
+		} else {
+			end, _ = parseDateOrDateTime(formatMoment(t.Add(p.Duration.Value.Duration()), utc), nil)
+		}
+	}
+	if end != nil {
+		n := "DTEND"
+		if todo {
+			n = "DUE"
+		}
+		if err := e.time(n, endParams, end); err != nil {
+			return err
+		}
+	}
+	if p.Summary != nil && p.Summary.Value != nil {
+		e.text("SUMMARY", "", types.UnescapeText(p.Summary.Value.V))
+	}
+	if p.Description != nil && p.Description.Value != nil {
+		e.text("DESCRIPTION", "", types.UnescapeText(p.Description.Value.V))
+	}
+	if p.Location != nil && p.Location.Values != nil {
+		e.text("LOCATION", "", types.UnescapeText(p.Location.Values.V))
+	}
+	for _, c := range p.Categories {
+		e.list("CATEGORIES", c.Values)
+	}
+	for _, r := range p.Resources {
+		e.list("RESOURCES", r.Values)
+	}
+	if p.Class != nil && p.Class.Value != nil {
+		e.line("CLASS", "", valueString(p.Class.Value))
+	}
+	if p.Priority != nil && p.Priority.Value != nil {
+		e.line("PRIORITY", "", strconv.Itoa(p.Priority.Value.V))
+	}
+	if p.Seq != nil && p.Seq.Value != nil {
+		e.line("SEQUENCE", "", strconv.Itoa(p.Seq.Value.V))
+	}
+	if p.Status != nil && p.Status.Value != nil {
+		statuses := eventStatusV1
+		if todo {
+			statuses = todoStatusV1
+		}
+		if s, ok := statuses[p.Status.Value.V]; ok {
+			e.line("STATUS", "", s)
+		}
+	}
+	if p.Transparent != nil && p.Transparent.Values != nil {
+		if p.Transparent.Values.V == datetime.TransparentType {
+			e.line("TRANSP", "", "1")
+		} else {
+			e.line("TRANSP", "", "0")
+		}
+	}
+	if p.Url != nil && p.Url.Value != nil {
+		e.line("URL", "", p.Url.Value.V)
+	}
+	for _, r := range p.Related {
+		if r.Value != nil {
+			e.text("RELATED-TO", "", types.UnescapeText(r.Value.V))
+		}
+	}
+	if p.Created != nil && p.Created.Value != nil {
+		if err := e.time("DCREATED", nil, p.Created.Value); err != nil {
+			return err
+		}
+	}
+	if p.LastModified != nil && p.LastModified.Value != nil {
+		if err := e.time("LAST-MODIFIED", nil, p.LastModified.Value); err != nil {
+			return err
+		}
+	}
+	if todo && p.Completed != nil && p.Completed.Value != nil {
+		if err := e.time("COMPLETED", nil, p.Completed.Value); err != nil {
+			return err
+		}
+	}
+	if p.RRule != nil && p.RRule.V != nil {
+		rule, err := FormatRule(p.RRule.V, start)
+		if err != nil {
+			return err
+		}
+		e.line("RRULE", "", rule)
+	}
+	for _, x := range p.ExDate {
+		if err := e.times("EXDATE", x.Parameters, x.Values); err != nil {
+			return err
+		}
+	}
+	for _, r := range p.RDate {
+		if err := e.times("RDATE", r.Parameters, r.Values); err != nil {
+			return err
+		}
+	}
+	if p.Organizer != nil {
+		e.attendee("ATTENDEE", p.Organizer.Parameters, p.Organizer.Value, true)
+	}
+	for _, a := range p.Attendee {
+		e.attendee("ATTENDEE", a.Parameters, a.Value, false)
+	}
+	for _, a := range p.Attach {
+		switch v := a.Value.(type) {
+		case *types.Binary:
+			e.line("ATTACH", ";ENCODING=BASE64", base64.StdEncoding.EncodeToString(v.V))
+		case *types.URI:
+			e.line("ATTACH", ";VALUE=URL", v.V)
+		}
+	}
+	for _, a := range p.Alarm {
+		if err := e.alarm(a, startParams, start, endParams, end); err != nil {
+			return err
+		}
+	}
+	if err := e.others(p.Xprop, p.IanaProp, start); err != nil {
+		return err
+	}
+	e.line("END", "", name)
+	return nil
+}
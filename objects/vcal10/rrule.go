@@ -0,0 +1,557 @@
+package vcal10
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//   vCalendar 1.0, Appendix A: Recurrence Rule Grammar
+//
+//       {}              0 or more
+//       []              0 or 1
+//
+//       start           ::= <daily> [<enddate>] |
+//                           <weekly> [<enddate>] |
+//                           <monthlybypos> [<enddate>] |
+//                           <monthlybyday> [<enddate>] |
+//                           <yearlybymonth> [<enddate>] |
+//                           <yearlybyday> [<enddate>]
+//
+//       digit           ::= <0|1|2|3|4|5|6|7|8|9>
+//       digits          ::= <digit> {<digits>}
+//       enddate         ::= ISO 8601_date_time value(e.g., 19940712T101530Z)
+//       interval        ::= <digits>
+//       duration        ::= #<digits>
+//       lastday         ::= LD
+//       plus            ::= +
+//       minus           ::= -
+//       daynumber       ::= <1-31> [<plus>|<minus>]| <lastday>
+//       daynumberlist   ::= daynumber {<daynumberlist>}
+//       month           ::= <1-12>
+//       monthlist       ::= <month> {<monthlist>}
+//       day             ::= <1-366>
+//       daylist         ::= <day> {<daylist>}
+//       occurrence      ::= <1-5><plus> | <1-5><minus>
+//       weekday         ::= <SU|MO|TU|WE|TH|FR|SA>
+//       weekdaylist     ::= <weekday> {<weekdaylist>}
+//       occurrencelist  ::= <occurrence> [<weekdaylist>] {<occurrencelist>}
+//       time            ::= <hhmm>
+//       timelist        ::= <time> {<timelist>}
+//
+//       minutely        ::= M<interval> [<duration>]
+//       daily           ::= D<interval> [<timelist>] [<duration>]
+//       weekly          ::= W<interval> [<weekdaylist>] [<duration>]
+//       monthlybypos    ::= MP<interval> [<occurrencelist>] [<duration>]
+//       monthlybyday    ::= MD<interval> [<daynumberlist>] [<duration>]
+//       yearlybymonth   ::= YM<interval> [<monthlist>] [<duration>]
+//       yearlybyday     ::= YD<interval> [<daylist>] [<duration>]
+//
+//   A duration of #0 repeats forever.  When neither a duration nor an end
+//   date is given the rule repeats twice (#2).
+//
+//   Examples:
+//
+//       D2 #10                 every other day, ten times
+//       W1 MO TH #10           weekly on Monday and Thursday, ten times
+//       MP1 1+ SU 1- FR #0     monthly on the first Sunday and the last
+//                              Friday, forever
+//       MD1 1 1- 19971231T000000
+//                              monthly on the first and the last day of
+//                              the month until the end of 1997
+//       YM1 6 7 #10            yearly in June and July, ten times
+
+var weekdayNames = map[string]types.WeekDay{
+	"SU": types.Sunday,
+	"MO": types.Monday,
+	"TU": types.Tuesday,
+	"WE": types.Wednesday,
+	"TH": types.Thursday,
+	"FR": types.Friday,
+	"SA": types.Saturday,
+}
+
+var weekdayOrder = []types.WeekDay{types.Sunday, types.Monday, types.Tuesday, types.Wednesday, types.Thursday, types.Friday, types.Saturday}
+
+// ParseRule converts a recurrence rule in the vCalendar 1.0 syntax, such
+// as "W1 MO TH #10", into an iCalendar RRULE value.  dtstart supplies the
+// weekday of occurrences given without one; it may be nil.  An end date
+// takes precedence over a duration when both are given.
+func ParseRule(s string, dtstart types.Value) (*types.RecurRule, error) {
+	tokens := strings.Fields(s)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+	r := &types.RecurRule{}
+	kind, interval, err := parseRuleHead(tokens[0])
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "M":
+		r.Frequency = types.FreqMinutely
+	case "D":
+		r.Frequency = types.FreqDaily
+	case "W":
+		r.Frequency = types.FreqWeekly
+	case "MP", "MD":
+		r.Frequency = types.FreqMonthly
+	case "YM", "YD":
+		r.Frequency = types.FreqYearly
+	}
+
+	var (
+		until     types.Value
+		count     = -1
+		days      []*types.WeekDayNum
+		ords      []int
+		ordsUsed  bool
+		monthDays []*types.MonthDayNum
+		months    []int
+		yearDays  []*types.YearDayNum
+		times     []int
+	)
+	for _, tok := range tokens[1:] {
+		// "$" marks an instance that is kept even when it falls on a
+		// holiday; holidays are not modelled, so it is dropped.
+		tok = strings.TrimSuffix(tok, "$")
+		switch {
+		case tok == "":
+		case strings.HasPrefix(tok, "#"):
+			n, err := strconv.Atoi(tok[1:])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid duration %q", tok)
+			}
+			count = n
+		case len(tok) >= 8 && isDigits(tok[:8]):
+			v, err := parseDateOrDateTime(tok, nil)
+			if err != nil {
+				return nil, fmt.Errorf("invalid end date %q", tok)
+			}
+			until = v
+		case len(tok) == 4 && isDigits(tok):
+			times = append(times, atoi(tok))
+		case weekdayNames[tok] != "":
+			wd := weekdayNames[tok]
+			switch kind {
+			case "W":
+				days = append(days, &types.WeekDayNum{WeekDay: wd})
+			case "MP":
+				if len(ords) == 0 {
+					days = append(days, &types.WeekDayNum{WeekDay: wd})
+				}
+				for _, o := range ords {
+					days = append(days, weekDayNum(o, wd))
+				}
+				ordsUsed = true
+			default:
+				return nil, fmt.Errorf("unexpected weekday %q in %s rule", tok, kind)
+			}
+		case kind == "MP":
+			n, err := parseSignedNumber(tok, 5)
+			if err != nil {
+				return nil, err
+			}
+			if ordsUsed {
+				ords, ordsUsed = nil, false
+			}
+			ords = append(ords, n)
+		case kind == "MD":
+			n := -1
+			if tok != "LD" {
+				if n, err = parseSignedNumber(tok, 31); err != nil {
+					return nil, err
+				}
+			}
+			monthDays = append(monthDays, monthDayNum(n))
+		case kind == "YM":
+			n, err := strconv.Atoi(tok)
+			if err != nil || n < 1 || n > 12 {
+				return nil, fmt.Errorf("invalid month %q", tok)
+			}
+			months = append(months, n)
+		case kind == "YD":
+			n, err := parseSignedNumber(tok, 366)
+			if err != nil {
+				return nil, err
+			}
+			yearDays = append(yearDays, yearDayNum(n))
+		default:
+			if _, _, err := parseRuleHead(tok); err == nil {
+				return nil, fmt.Errorf("nested recurrence rules are not supported")
+			}
+			return nil, fmt.Errorf("unexpected %q in %s rule", tok, kind)
+		}
+	}
+	if len(ords) > 0 && !ordsUsed {
+		wd, ok := startWeekday(dtstart)
+		if !ok {
+			return nil, fmt.Errorf("occurrence without weekday")
+		}
+		for _, o := range ords {
+			days = append(days, weekDayNum(o, wd))
+		}
+	}
+
+	if until != nil {
+		r.Rules = append(r.Rules, &types.Until{Time: until})
+	} else if count != 0 {
+		if count < 0 {
+			count = 2
+		}
+		r.Rules = append(r.Rules, &types.Count{V: count})
+	}
+	if interval != 1 {
+		r.Rules = append(r.Rules, &types.Interval{V: interval})
+	}
+	if len(times) > 0 {
+		hours, minutes := []int{}, []int{}
+		for _, t := range times {
+			if t/100 > 23 || t%100 > 59 {
+				return nil, fmt.Errorf("invalid time %04d", t)
+			}
+			hours = appendUnique(hours, t/100)
+			minutes = appendUnique(minutes, t%100)
+		}
+		if len(hours)*len(minutes) != len(times) {
+			return nil, fmt.Errorf("times cannot be expressed as hours and minutes")
+		}
+		r.Rules = append(r.Rules, &types.ByHour{V: hours}, &types.ByMinute{V: minutes})
+	}
+	if len(months) > 0 {
+		r.Rules = append(r.Rules, &types.ByMonth{V: months})
+	}
+	if len(yearDays) > 0 {
+		r.Rules = append(r.Rules, &types.ByYearDay{V: yearDays})
+	}
+	if len(monthDays) > 0 {
+		r.Rules = append(r.Rules, &types.ByMonthDay{V: monthDays})
+	}
+	if len(days) > 0 {
+		r.Rules = append(r.Rules, &types.ByDay{V: days})
+	}
+	return r, nil
+}
+
+func parseRuleHead(tok string) (string, int, error) {
+	kind := ""
+	for _, k := range []string{"MP", "MD", "YM", "YD", "D", "W", "M"} {
+		if strings.HasPrefix(tok, k) {
+			kind = k
+			break
+		}
+	}
+	if kind == "" || !isDigits(tok[len(kind):]) {
+		return "", 0, fmt.Errorf("invalid recurrence rule %q", tok)
+	}
+	interval := atoi(tok[len(kind):])
+	if interval < 1 {
+		return "", 0, fmt.Errorf("invalid interval %q", tok)
+	}
+	return kind, interval, nil
+}
+
+// parseSignedNumber parses "3", "3+" and "3-", returning -3 for the last.
+func parseSignedNumber(tok string, max int) (int, error) {
+	sign := 1
+	v := tok
+	switch {
+	case strings.HasSuffix(v, "+"):
+		v = v[:len(v)-1]
+	case strings.HasSuffix(v, "-"):
+		sign = -1
+		v = v[:len(v)-1]
+	}
+	if !isDigits(v) {
+		return 0, fmt.Errorf("invalid number %q", tok)
+	}
+	n := atoi(v)
+	if n < 1 || n > max {
+		return 0, fmt.Errorf("number %q out of range", tok)
+	}
+	return sign * n, nil
+}
+
+func weekDayNum(n int, wd types.WeekDay) *types.WeekDayNum {
+	if n < 0 {
+		return &types.WeekDayNum{Operator: types.Minus, OrdWk: -n, WeekDay: wd}
+	}
+	return &types.WeekDayNum{OrdWk: n, WeekDay: wd}
+}
+
+func monthDayNum(n int) *types.MonthDayNum {
+	if n < 0 {
+		return &types.MonthDayNum{Operator: types.Minus, OrdMoDay: -n}
+	}
+	return &types.MonthDayNum{OrdMoDay: n}
+}
+
+func yearDayNum(n int) *types.YearDayNum {
+	if n < 0 {
+		return &types.YearDayNum{Operator: types.Minus, OrdYrDay: -n}
+	}
+	return &types.YearDayNum{OrdYrDay: n}
+}
+
+func startWeekday(dtstart types.Value) (types.WeekDay, bool) {
+	switch v := dtstart.(type) {
+	case *types.DateTime:
+		return weekdayOrder[v.V.Weekday()], true
+	case *types.Date:
+		return weekdayOrder[v.V.Weekday()], true
+	}
+	return "", false
+}
+
+// FormatRule returns r in the vCalendar 1.0 syntax.  It fails for rules
+// that the older syntax cannot express, e.g. ones with BYSETPOS, BYWEEKNO
+// or an RSCALE.  dtstart supplies the time of day of rules that only
+// give hours; it may be nil.
+func FormatRule(r *types.RecurRule, dtstart types.Value) (string, error) {
+	var (
+		until           types.Value
+		count, interval = -1, 1
+		byDay           *types.ByDay
+		byMonthDay      *types.ByMonthDay
+		byMonth         *types.ByMonth
+		byYearDay       *types.ByYearDay
+		byHour          *types.ByHour
+		byMinute        *types.ByMinute
+	)
+	for _, rule := range r.Rules {
+		switch v := rule.(type) {
+		case *types.Until:
+			until = v.Time
+		case *types.Count:
+			count = v.V
+		case *types.Interval:
+			interval = v.V
+		case *types.ByDay:
+			byDay = v
+		case *types.ByMonthDay:
+			byMonthDay = v
+		case *types.ByMonth:
+			for i := range v.V {
+				if v.IsLeap(i) {
+					return "", unsupported(r, "leap months")
+				}
+			}
+			byMonth = v
+		case *types.ByYearDay:
+			byYearDay = v
+		case *types.ByHour:
+			byHour = v
+		case *types.ByMinute:
+			byMinute = v
+		case *types.Wkst:
+			// weeks always start on Monday in vCalendar 1.0; WKST only
+			// matters for weekly rules with an interval and is dropped
+		case *types.RScale:
+			if !strings.EqualFold(v.V, "GREGORIAN") {
+				return "", unsupported(r, "RSCALE")
+			}
+		case types.Skip:
+			if v != types.SkipOmit {
+				return "", unsupported(r, "SKIP")
+			}
+		default:
+			s := &strings.Builder{}
+			rule.WriteRule(s)
+			return "", unsupported(r, s.String())
+		}
+	}
+	if interval < 1 {
+		interval = 1
+	}
+
+	s := &strings.Builder{}
+	byDayOnly := byDay != nil && byMonthDay == nil && byMonth == nil && byYearDay == nil
+	plainDays := byDay != nil && !hasOrdinals(byDay)
+	switch r.Frequency {
+	case types.FreqMinutely:
+		if byDay != nil || byMonthDay != nil || byMonth != nil || byYearDay != nil || byHour != nil || byMinute != nil {
+			return "", unsupported(r, "BYxxx rule parts with FREQ=MINUTELY")
+		}
+		s.WriteString("M" + strconv.Itoa(interval))
+	case types.FreqDaily:
+		switch {
+		case byDay == nil && byMonthDay == nil && byMonth == nil && byYearDay == nil:
+			s.WriteString("D" + strconv.Itoa(interval))
+		case byDayOnly && plainDays && interval == 1:
+			s.WriteString("W1")
+			writeWeekdays(s, byDay)
+		default:
+			return "", unsupported(r, "BYxxx rule parts with FREQ=DAILY")
+		}
+	case types.FreqWeekly:
+		if byMonthDay != nil || byMonth != nil || byYearDay != nil || byDay != nil && !plainDays {
+			return "", unsupported(r, "BYxxx rule parts with FREQ=WEEKLY")
+		}
+		s.WriteString("W" + strconv.Itoa(interval))
+		if byDay != nil {
+			writeWeekdays(s, byDay)
+		}
+	case types.FreqMonthly:
+		switch {
+		case byMonth != nil || byYearDay != nil:
+			return "", unsupported(r, "BYMONTH or BYYEARDAY with FREQ=MONTHLY")
+		case byDay != nil && byMonthDay == nil:
+			s.WriteString("MP" + strconv.Itoa(interval))
+			if err := writeOccurrences(s, byDay); err != nil {
+				return "", unsupported(r, err.Error())
+			}
+		case byDay == nil:
+			s.WriteString("MD" + strconv.Itoa(interval))
+			if byMonthDay != nil {
+				for _, d := range byMonthDay.V {
+					s.WriteString(" ")
+					s.WriteString(dayNumber(d.Operator, d.OrdMoDay))
+				}
+			}
+		default:
+			return "", unsupported(r, "BYDAY and BYMONTHDAY")
+		}
+	case types.FreqYearly:
+		switch {
+		case byDay != nil || byMonthDay != nil || byMonth != nil && byYearDay != nil:
+			return "", unsupported(r, "BYxxx rule parts with FREQ=YEARLY")
+		case byYearDay != nil:
+			s.WriteString("YD" + strconv.Itoa(interval))
+			for _, d := range byYearDay.V {
+				s.WriteString(" ")
+				s.WriteString(dayNumber(d.Operator, d.OrdYrDay))
+			}
+		default:
+			s.WriteString("YM" + strconv.Itoa(interval))
+			if byMonth != nil {
+				for _, m := range byMonth.V {
+					s.WriteString(" " + strconv.Itoa(m))
+				}
+			}
+		}
+	default:
+		return "", unsupported(r, "FREQ="+string(r.Frequency))
+	}
+
+	if byHour != nil || byMinute != nil {
+		if r.Frequency == types.FreqMinutely {
+			return "", unsupported(r, "BYHOUR or BYMINUTE with FREQ=MINUTELY")
+		}
+		hours, minutes := startClock(dtstart)
+		if byHour != nil {
+			hours = byHour.V
+		}
+		if byMinute != nil {
+			minutes = byMinute.V
+		}
+		var times []int
+		for _, h := range hours {
+			for _, m := range minutes {
+				times = append(times, h*100+m)
+			}
+		}
+		sort.Ints(times)
+		for _, t := range times {
+			s.WriteString(fmt.Sprintf(" %04d", t))
+		}
+	}
+
+	switch {
+	case until != nil:
+		s.WriteString(" ")
+		until.WriteValueToStrBuilder(s)
+	case count >= 0:
+		s.WriteString(" #" + strconv.Itoa(count))
+	default:
+		s.WriteString(" #0")
+	}
+	return s.String(), nil
+}
+
+func unsupported(r *types.RecurRule, what string) error {
+	s := &strings.Builder{}
+	r.WriteValueToStrBuilder(s)
+	return fmt.Errorf("RRULE %s cannot be expressed in vCalendar 1.0: %s", s.String(), what)
+}
+
+func hasOrdinals(b *types.ByDay) bool {
+	for _, d := range b.V {
+		if d.OrdWk != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func writeWeekdays(s *strings.Builder, b *types.ByDay) {
+	for _, d := range b.V {
+		s.WriteString(" ")
+		s.WriteString(string(d.WeekDay))
+	}
+}
+
+func writeOccurrences(s *strings.Builder, b *types.ByDay) error {
+	for _, d := range b.V {
+		if d.OrdWk == 0 {
+			s.WriteString(" " + string(d.WeekDay))
+			continue
+		}
+		if d.OrdWk > 5 {
+			return fmt.Errorf("occurrence %d", d.OrdWk)
+		}
+		s.WriteString(" ")
+		s.WriteString(signedNumber(d.Operator, d.OrdWk))
+		s.WriteString(" ")
+		s.WriteString(string(d.WeekDay))
+	}
+	return nil
+}
+
+func signedNumber(o types.Operator, n int) string {
+	if o == types.Minus {
+		return strconv.Itoa(n) + "-"
+	}
+	return strconv.Itoa(n) + "+"
+}
+
+func dayNumber(o types.Operator, n int) string {
+	if o == types.Minus {
+		return strconv.Itoa(n) + "-"
+	}
+	return strconv.Itoa(n)
+}
+
+func startClock(dtstart types.Value) ([]int, []int) {
+	if v, ok := dtstart.(*types.DateTime); ok {
+		return []int{v.V.Hour()}, []int{v.V.Minute()}
+	}
+	return []int{0}, []int{0}
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func appendUnique(values []int, v int) []int {
+	for _, x := range values {
+		if x == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
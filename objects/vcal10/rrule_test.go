@@ -0,0 +1,81 @@
+package vcal10
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ruleString(r *types.RecurRule) string {
+	s := &strings.Builder{}
+	r.WriteValueToStrBuilder(s)
+	return s.String()
+}
+
+func TestParseRule(t *testing.T) {
+	dtstart := &types.DateTime{V: time.Date(1996, 7, 1, 9, 0, 0, 0, time.UTC), Format: types.UTCDateTimeFormat}
+	tests := []struct {
+		in, want string
+	}{
+		{"D2 #0", "FREQ=DAILY;INTERVAL=2"},
+		{"D1", "FREQ=DAILY;COUNT=2"},
+		{"W1 MO TH #10", "FREQ=WEEKLY;COUNT=10;BYDAY=MO,TH"},
+		{"W2 MO WE FR 19971224T000000Z", "FREQ=WEEKLY;UNTIL=19971224T000000Z;INTERVAL=2;BYDAY=MO,WE,FR"},
+		{"MP1 1+ SU 1- SU #5", "FREQ=MONTHLY;COUNT=5;BYDAY=1SU,-1SU"},
+		{"MD1 1 LD #0", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"YM1 6 7 #10", "FREQ=YEARLY;COUNT=10;BYMONTH=6,7"},
+		{"YD3 1 100 200 #10", "FREQ=YEARLY;COUNT=10;INTERVAL=3;BYYEARDAY=1,100,200"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRule(tt.in, dtstart)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if got := ruleString(r); got != tt.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tt.in, got, tt.want)
+		}
+	}
+	if _, err := ParseRule("W1 MO D1", dtstart); err == nil {
+		t.Error("nested rule: expected an error")
+	}
+	if _, err := ParseRule("X1 #2", dtstart); err == nil {
+		t.Error("unknown frequency: expected an error")
+	}
+}
+
+func TestFormatRule(t *testing.T) {
+	dtstart := &types.DateTime{V: time.Date(1996, 7, 1, 9, 0, 0, 0, time.UTC), Format: types.UTCDateTimeFormat}
+	tests := []struct {
+		in, want string
+	}{
+		{"W1 MO TH #10", "W1 MO TH #10"},
+		{"MP1 1+ SU 1- SU #5", "MP1 1+ SU 1- SU #5"},
+		{"MD1 1 LD #0", "MD1 1 1- #0"},
+		{"YM1 6 7 #10", "YM1 6 7 #10"},
+		{"D2 #0", "D2 #0"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRule(tt.in, dtstart)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		got, err := FormatRule(r, dtstart)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tt.in, got, tt.want)
+		}
+	}
+	r := &types.RecurRule{
+		Frequency: types.FreqMonthly,
+		Rules:     []types.Rule{&types.BySetpos{V: []*types.YearDayNum{{Operator: types.Minus, OrdYrDay: 1}}}},
+	}
+	if _, err := FormatRule(r, dtstart); err == nil {
+		t.Error("BYSETPOS: expected an error")
+	}
+}
@@ -0,0 +1,89 @@
+// Package vcal10 converts between vCalendar 1.0 objects, as still written
+// by older phones and PBX systems, and iCalendar objects.
+//
+// Decode maps the vCalendar properties onto their iCalendar counterparts:
+// AALARM, DALARM and MALARM become VALARM components, recurrence rules
+// in the vCalendar 1.0 syntax become RRULE values, the STATUS, ROLE,
+// EXPECT and RSVP parameters of attendees become PARTSTAT, ROLE and RSVP
+// parameters, and QUOTED-PRINTABLE and BASE64 values are decoded.  Local
+// times are converted to UTC when the object has a TZ property.
+//
+// Encode goes the other way for the events and to-dos of a calendar.
+package vcal10
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+)
+
+// ProdId is the product identifier given to converted objects that have
+// none.
+var ProdId = "-//mmsuo//vcalender vcal10//EN"
+
+// attendee STATUS of vCalendar 1.0 and the PARTSTAT it maps to
+var partStats = map[string]*parameters.PartStat{
+	"ACCEPTED":     &parameters.Accepted,
+	"NEEDS ACTION": &parameters.NeedAction,
+	"SENT":         &parameters.NeedAction,
+	"TENTATIVE":    &parameters.Tentative,
+	"CONFIRMED":    &parameters.Accepted,
+	"DECLINED":     &parameters.Declined,
+	"COMPLETED":    &parameters.Completed,
+	"DELEGATED":    &parameters.Delegated,
+}
+
+var attendeeStatus = map[string]string{
+	"NEEDS-ACTION": "NEEDS ACTION",
+	"ACCEPTED":     "ACCEPTED",
+	"DECLINED":     "DECLINED",
+	"TENTATIVE":    "TENTATIVE",
+	"DELEGATED":    "DELEGATED",
+	"COMPLETED":    "COMPLETED",
+	"IN-PROCESS":   "CONFIRMED",
+}
+
+// attendee EXPECT of vCalendar 1.0 and the ROLE it maps to
+var expectRoles = map[string]*parameters.ParticipationRole{
+	"FYI":       &parameters.None,
+	"REQUIRE":   &parameters.Required,
+	"REQUEST":   &parameters.Optional,
+	"IMMEDIATE": &parameters.Required,
+}
+
+var roleExpects = map[string]string{
+	"REQ-PARTICIPANT": "REQUIRE",
+	"OPT-PARTICIPANT": "REQUEST",
+	"NON-PARTICIPANT": "FYI",
+}
+
+// component STATUS values, by component
+var eventStatus = map[string]string{
+	"ACCEPTED":     "CONFIRMED",
+	"CONFIRMED":    "CONFIRMED",
+	"TENTATIVE":    "TENTATIVE",
+	"NEEDS ACTION": "TENTATIVE",
+	"SENT":         "TENTATIVE",
+	"DECLINED":     "CANCELLED",
+}
+
+var todoStatus = map[string]string{
+	"NEEDS ACTION": "NEEDS-ACTION",
+	"SENT":         "NEEDS-ACTION",
+	"ACCEPTED":     "IN-PROCESS",
+	"CONFIRMED":    "IN-PROCESS",
+	"TENTATIVE":    "IN-PROCESS",
+	"COMPLETED":    "COMPLETED",
+	"DECLINED":     "CANCELLED",
+}
+
+var eventStatusV1 = map[string]string{
+	"CONFIRMED": "CONFIRMED",
+	"TENTATIVE": "TENTATIVE",
+	"CANCELLED": "DECLINED",
+}
+
+var todoStatusV1 = map[string]string{
+	"NEEDS-ACTION": "NEEDS ACTION",
+	"IN-PROCESS":   "ACCEPTED",
+	"COMPLETED":    "COMPLETED",
+	"CANCELLED":    "DECLINED",
+}
@@ -0,0 +1,142 @@
+package vcal10
+
+import (
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"testing"
+)
+
+const sample = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:1.0\r\n" +
+	"PRODID:-//Example//Phone//EN\r\n" +
+	"TZ:-05:00\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:19960918T143000\r\n" +
+	"DTEND:19960920T220000\r\n" +
+	"SUMMARY:Networld+Interop Conference\r\n" +
+	"DESCRIPTION;ENCODING=QUOTED-PRINTABLE:Networld+Interop Conference=0D=0A=\r\n" +
+	"and Exhibit=0D=0AAtlanta World Congress Center\r\n" +
+	"CATEGORIES:CONFERENCE;BUSINESS\r\n" +
+	"STATUS:TENTATIVE\r\n" +
+	"RRULE:W1 MO TH #10\r\n" +
+	"ATTENDEE;ROLE=OWNER;STATUS=CONFIRMED:John Public <jpublic@host.com>\r\n" +
+	"ATTENDEE;EXPECT=REQUEST;STATUS=NEEDS ACTION;RSVP=YES:jdoe@host.com\r\n" +
+	"AALARM:19960918T140000;PT5M;2;file:///sounds/bell.wav\r\n" +
+	"DALARM:19960918T142000;;;Leave now\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"DUE:19960920T170000Z\r\n" +
+	"SUMMARY:Book the flight\r\n" +
+	"STATUS:NEEDS ACTION\r\n" +
+	"PRIORITY:1\r\n" +
+	"END:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestDecode(t *testing.T) {
+	c, err := Decode(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Components) != 2 {
+		t.Fatalf("got %d components, want 2", len(c.Components))
+	}
+	if _, ok := c.Components[0].(*components.Event); !ok {
+		t.Errorf("first component is %T", c.Components[0])
+	}
+	if _, ok := c.Components[1].(*components.Todo); !ok {
+		t.Errorf("second component is %T", c.Components[1])
+	}
+	ical, err := c.Calendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"VERSION:2.0",
+		"PRODID:-//Example//Phone//EN",
+		"DTSTART:19960918T193000Z",
+		"DTEND:19960921T030000Z",
+		"DESCRIPTION:Networld+Interop Conference\\nand Exhibit\\nAtlanta World Congress Center",
+		"CATEGORIES:CONFERENCE,BUSINESS",
+		"STATUS:TENTATIVE",
+		"RRULE:FREQ=WEEKLY;COUNT=10;BYDAY=MO,TH",
+		"ROLE=CHAIR",
+		"PARTSTAT=ACCEPTED",
+		"CN=\"John Public\"",
+		"mailto:jpublic@host.com",
+		"ROLE=OPT-PARTICIPANT",
+		"PARTSTAT=NEEDS-ACTION",
+		"RSVP=TRUE",
+		"BEGIN:VALARM",
+		"ACTION:AUDIO",
+		"TRIGGER:-PT30M",
+		"REPEAT:2",
+		"ACTION:DISPLAY",
+		"TRIGGER:-PT10M",
+		"DESCRIPTION:Leave now",
+		"BEGIN:VTODO",
+		"STATUS:NEEDS-ACTION",
+		"PRIORITY:1",
+	} {
+		if !strings.Contains(ical, want) {
+			t.Errorf("missing %q in\n%s", want, ical)
+		}
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	for _, in := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nVERSION:1.0\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nVERSION:1.0\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := Decode(strings.NewReader(in)); err == nil {
+			t.Errorf("expected an error for\n%s", in)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	c, err := Decode(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Encode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:1.0\r\n",
+		"DTSTART:19960918T193000Z\r\n",
+		"DTEND:19960921T030000Z\r\n",
+		"DESCRIPTION;ENCODING=QUOTED-PRINTABLE;CHARSET=UTF-8:Networld+Interop Conference=0D=0Aand Exhibit=0D=0A",
+		"CATEGORIES:CONFERENCE;BUSINESS\r\n",
+		"STATUS:TENTATIVE\r\n",
+		"RRULE:W1 MO TH #10\r\n",
+		"ATTENDEE;ROLE=OWNER;STATUS=ACCEPTED:John Public <jpublic@host.com>\r\n",
+		"ATTENDEE;ROLE=ATTENDEE;EXPECT=REQUEST;STATUS=NEEDS ACTION;RSVP=YES:jdoe@host.com\r\n",
+		"AALARM:19960918T190000Z;PT5M;2;file:///sounds/bell.wav\r\n",
+		"DALARM:19960918T192000Z;;;Leave now\r\n",
+		"DUE:19960920T170000Z\r\n",
+		"STATUS:NEEDS ACTION\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	again, err := Decode(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Components) != 2 {
+		t.Fatalf("round trip: got %d components, want 2", len(again.Components))
+	}
+	ical, err := again.Calendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DESCRIPTION:Networld+Interop Conference\\nand Exhibit\\nAtlanta World Congress Center"
+	if !strings.Contains(ical, want) {
+		t.Errorf("round trip: missing %q in\n%s", want, ical)
+	}
+}
@@ -0,0 +1,370 @@
+// Package xcal converts between iCalendar objects and xCal, their XML
+// form defined by RFC 6321.
+//
+// Like package jcal, it converts objects.RawComponent trees, so that
+// unknown components and properties survive the round trip.
+package xcal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+)
+
+//   RFC 6321 3.  Converting from iCalendar to xCal
+//
+//      The iCalendar stream is represented by an "icalendar" element in
+//      the namespace "urn:ietf:params:xml:ns:icalendar-2.0".  Each
+//      component is an element with the name of the component in
+//      lowercase, holding a "properties" element and a "components"
+//      element.  Each property is an element with the name of the
+//      property in lowercase, holding an optional "parameters" element
+//      and one element per value, named by the value type:
+//
+//       <dtstart>
+//         <parameters>
+//           <tzid><text>US/Eastern</text></tzid>
+//         </parameters>
+//         <date-time>2006-01-02T12:00:00</date-time>
+//       </dtstart>
+//
+//      Recurrence rules hold one element per rule part and value, GEO a
+//      "latitude" and a "longitude" element, REQUEST-STATUS "code",
+//      "description" and "data" elements, and periods "start" and "end"
+//      or "duration" elements.  Values of unknown type are written in an
+//      "unknown" element as they are.
+
+// Namespace is the XML namespace of xCal.
+const Namespace = "urn:ietf:params:xml:ns:icalendar-2.0"
+
+// value types of parameters other than text
+var paramTypes = map[string]string{
+	"ALTREP":         "uri",
+	"DIR":            "uri",
+	"DELEGATED-FROM": "cal-address",
+	"DELEGATED-TO":   "cal-address",
+	"MEMBER":         "cal-address",
+	"SENT-BY":        "cal-address",
+	"RSVP":           "boolean",
+}
+
+type encoder struct {
+	e   *xml.Encoder
+	err error
+}
+
+func (e *encoder) start(name string) {
+	if e.err == nil {
+		e.err = e.e.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (e *encoder) end(name string) {
+	if e.err == nil {
+		e.err = e.e.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (e *encoder) element(name, text string) {
+	e.start(name)
+	if e.err == nil {
+		e.err = e.e.EncodeToken(xml.CharData(text))
+	}
+	e.end(name)
+}
+
+// Encode returns the xCal form of c, indented by two spaces.
+func Encode(c *objects.RawComponent) ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteString(xml.Header)
+	e := &encoder{e: xml.NewEncoder(b)}
+	e.e.Indent("", "  ")
+	e.err = e.e.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "icalendar"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+	})
+	if err := e.component(c); err != nil {
+		return nil, err
+	}
+	e.end("icalendar")
+	if e.err == nil {
+		e.err = e.e.Flush()
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+func (e *encoder) component(c *objects.RawComponent) error {
+	name := strings.ToLower(c.Name)
+	e.start(name)
+	if len(c.Properties) > 0 {
+		e.start("properties")
+		for _, l := range c.Properties {
+			if err := e.property(l); err != nil {
+				return &objects.SyntaxError{Line: l.Line, Msg: fmt.Sprintf("%s: %v", l.Name, err)}
+			}
+		}
+		e.end("properties")
+	}
+	if len(c.Components) > 0 {
+		e.start("components")
+		for _, sub := range c.Components {
+			if err := e.component(sub); err != nil {
+				return err
+			}
+		}
+		e.end("components")
+	}
+	e.end(name)
+	return e.err
+}
+
+func (e *encoder) property(l *objects.ContentLine) error {
+	name := strings.ToLower(l.Name)
+	e.start(name)
+	var params []*objects.Param
+	for _, p := range l.Params {
+		if p.Name != "VALUE" {
+			params = append(params, p)
+		}
+	}
+	if len(params) > 0 {
+		e.start("parameters")
+		for _, p := range params {
+			pt, ok := paramTypes[p.Name]
+			if !ok {
+				pt = "text"
+			}
+			e.start(strings.ToLower(p.Name))
+			for _, v := range p.Values {
+				if pt == "boolean" {
+					v = strings.ToLower(v)
+				}
+				e.element(pt, v)
+			}
+			e.end(strings.ToLower(p.Name))
+		}
+		e.end("parameters")
+	}
+	vt := objects.ValueType(l)
+	if vt == "" {
+		vt = "UNKNOWN"
+	}
+	switch {
+	case l.Name == "GEO":
+		parts := strings.Split(l.Value, ";")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid GEO %q", l.Value)
+		}
+		e.element("latitude", parts[0])
+		e.element("longitude", parts[1])
+	case l.Name == "REQUEST-STATUS":
+		for i, s := range objects.SplitEscaped(l.Value, ';', 3) {
+			e.element([]string{"code", "description", "data"}[i], types.UnescapeText(s))
+		}
+	default:
+		items := []string{l.Value}
+		if objects.IsList(l.Name) {
+			items = objects.SplitList(l.Value)
+		}
+		for _, s := range items {
+			if err := e.value(vt, s); err != nil {
+				return err
+			}
+		}
+	}
+	e.end(name)
+	return e.err
+}
+
+func (e *encoder) value(vt, s string) error {
+	name := strings.ToLower(vt)
+	switch vt {
+	case "TEXT":
+		e.element(name, types.UnescapeText(s))
+	case "BOOLEAN":
+		e.element(name, strings.ToLower(s))
+	case "RECUR":
+		e.start(name)
+		for _, part := range strings.Split(s, ";") {
+			eq := strings.IndexByte(part, '=')
+			if eq < 0 {
+				return fmt.Errorf("invalid rule part %q", part)
+			}
+			partName := strings.ToLower(part[:eq])
+			for _, item := range strings.Split(part[eq+1:], ",") {
+				if partName == "until" {
+					item = objects.ExtendedValue("DATE-OR-DATE-TIME", item)
+				}
+				e.element(partName, item)
+			}
+		}
+		e.end(name)
+	case "PERIOD":
+		slash := strings.IndexByte(s, '/')
+		if slash < 0 {
+			return fmt.Errorf("invalid PERIOD %q", s)
+		}
+		e.start(name)
+		e.element("start", objects.ExtendedValue("DATE-TIME", s[:slash]))
+		if end := s[slash+1:]; strings.ContainsAny(end[:1], "P+-") {
+			e.element("duration", end)
+		} else {
+			e.element("end", objects.ExtendedValue("DATE-TIME", end))
+		}
+		e.end(name)
+	default:
+		e.element(name, objects.ExtendedValue(vt, s))
+	}
+	return e.err
+}
+
+type node struct {
+	XMLName xml.Name
+	Nodes   []node `xml:",any"`
+	Text    string `xml:",chardata"`
+}
+
+func (n *node) child(name string) *node {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Decode reads an xCal document holding one component, usually a
+// VCALENDAR.
+func Decode(data []byte) (*objects.RawComponent, error) {
+	var root node
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.XMLName.Local != "icalendar" || root.XMLName.Space != Namespace {
+		return nil, fmt.Errorf("xcal: root element is not icalendar of %s", Namespace)
+	}
+	if len(root.Nodes) != 1 {
+		return nil, fmt.Errorf("xcal: expected a single component, found %d", len(root.Nodes))
+	}
+	return decodeComponent(&root.Nodes[0])
+}
+
+func decodeComponent(n *node) (*objects.RawComponent, error) {
+	c := &objects.RawComponent{Name: strings.ToUpper(n.XMLName.Local)}
+	if props := n.child("properties"); props != nil {
+		for i := range props.Nodes {
+			l, err := decodeProperty(&props.Nodes[i])
+			if err != nil {
+				return nil, fmt.Errorf("xcal: %s: %v", c.Name, err)
+			}
+			c.Properties = append(c.Properties, l)
+		}
+	}
+	if comps := n.child("components"); comps != nil {
+		for i := range comps.Nodes {
+			sub, err := decodeComponent(&comps.Nodes[i])
+			if err != nil {
+				return nil, err
+			}
+			c.Components = append(c.Components, sub)
+		}
+	}
+	return c, nil
+}
+
+func decodeProperty(n *node) (*objects.ContentLine, error) {
+	l := &objects.ContentLine{Name: strings.ToUpper(n.XMLName.Local)}
+	var values []string
+	vt := ""
+	switch l.Name {
+	case "GEO":
+		lat, lon := n.child("latitude"), n.child("longitude")
+		if lat == nil || lon == nil {
+			return nil, fmt.Errorf("GEO without latitude or longitude")
+		}
+		values = append(values, lat.Text+";"+lon.Text)
+		vt = "FLOAT"
+	case "REQUEST-STATUS":
+		var parts []string
+		for _, name := range []string{"code", "description", "data"} {
+			if p := n.child(name); p != nil {
+				parts = append(parts, types.EscapeText(p.Text))
+			}
+		}
+		values = append(values, strings.Join(parts, ";"))
+		vt = "TEXT"
+	}
+	for i := range n.Nodes {
+		v := &n.Nodes[i]
+		name := v.XMLName.Local
+		if name == "parameters" {
+			for _, p := range v.Nodes {
+				param := &objects.Param{Name: strings.ToUpper(p.XMLName.Local)}
+				for _, pv := range p.Nodes {
+					s := pv.Text
+					if pv.XMLName.Local == "boolean" {
+						s = strings.ToUpper(s)
+					}
+					param.Values = append(param.Values, s)
+				}
+				l.Params = append(l.Params, param)
+			}
+			continue
+		}
+		if l.Name == "GEO" || l.Name == "REQUEST-STATUS" {
+			continue
+		}
+		vt = strings.ToUpper(name)
+		switch {
+		case vt == "TEXT":
+			values = append(values, types.EscapeText(v.Text))
+		case vt == "BOOLEAN":
+			values = append(values, strings.ToUpper(v.Text))
+		case vt == "UNKNOWN":
+			values = append(values, v.Text)
+		case vt == "RECUR":
+			var parts []string
+			for _, p := range v.Nodes {
+				partName := strings.ToUpper(p.XMLName.Local)
+				item := p.Text
+				if partName == "UNTIL" {
+					item = objects.BasicValue("DATE-OR-DATE-TIME", item)
+				}
+				// values of the same rule part follow each other
+				if len(parts) > 0 && strings.HasPrefix(parts[len(parts)-1], partName+"=") {
+					parts[len(parts)-1] += "," + item
+				} else {
+					parts = append(parts, partName+"="+item)
+				}
+			}
+			values = append(values, strings.Join(parts, ";"))
+		case vt == "PERIOD":
+			start, end, dur := v.child("start"), v.child("end"), v.child("duration")
+			if start == nil || (end == nil && dur == nil) {
+				return nil, fmt.Errorf("period without start, end or duration")
+			}
+			if end != nil {
+				values = append(values, objects.BasicValue("PERIOD", start.Text+"/"+end.Text))
+			} else {
+				values = append(values, objects.BasicValue("DATE-TIME", start.Text)+"/"+dur.Text)
+			}
+		default:
+			values = append(values, objects.BasicValue(vt, v.Text))
+		}
+	}
+	if vt == "" {
+		return nil, fmt.Errorf("%s without value", l.Name)
+	}
+	if vt != "UNKNOWN" && vt != objects.DefaultValueType(l.Name) {
+		l.Params = append(l.Params, &objects.Param{Name: "VALUE", Values: []string{vt}})
+	}
+	l.Value = strings.Join(values, ",")
+	return l, nil
+}
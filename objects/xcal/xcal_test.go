@@ -0,0 +1,80 @@
+package xcal
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const ics = `BEGIN:VCALENDAR
+CALSCALE:GREGORIAN
+PRODID:-//Example Inc.//Example Calendar//EN
+VERSION:2.0
+BEGIN:VEVENT
+DTSTAMP:20080205T191224Z
+DTSTART;TZID=US/Eastern:20060102T120000
+SUMMARY:Planning <meeting> & lunch\, room 2
+UID:4088E990AD89CB3DBB484909
+CATEGORIES:WORK,PROJECT\, X
+GEO:37.386013;-122.082932
+RRULE:FREQ=WEEKLY;UNTIL=20081231;BYDAY=MO,TH
+RDATE;VALUE=PERIOD:20060102T150000Z/PT2H
+REQUEST-STATUS:2.0;Success
+ATTENDEE;RSVP=TRUE;SENT-BY="mailto:a@example.com":mailto:b@example.com
+X-LIC-ERROR:Oops
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestEncode(t *testing.T) {
+	raw, err := objects.ReadRawComponents(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(raw[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// compare without the indentation
+	got := regexp.MustCompile(`>\s+<`).ReplaceAllString(string(data), "><")
+	for _, want := range []string{
+		`<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0">`,
+		"<tzid><text>US/Eastern</text></tzid>",
+		"<date-time>2006-01-02T12:00:00</date-time>",
+		"<text>Planning &lt;meeting&gt; &amp; lunch, room 2</text>",
+		"<text>PROJECT, X</text>",
+		"<geo><latitude>37.386013</latitude>",
+		"<until>2008-12-31</until>",
+		"<byday>MO</byday><byday>TH</byday>",
+		"<duration>PT2H</duration>",
+		"<request-status><code>2.0</code><description>Success</description></request-status>",
+		"<boolean>true</boolean>",
+		"<cal-address>mailto:a@example.com</cal-address>",
+		"<unknown>Oops</unknown>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+
+	back, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != raw[0].String() {
+		t.Errorf("round trip:\n got: %s\nwant: %s", back.String(), raw[0].String())
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	for _, in := range []string{
+		`<vcalendar/>`,
+		`<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0"><vcalendar><properties><summary/></properties></vcalendar></icalendar>`,
+		`<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0"><vcalendar>`,
+	} {
+		if _, err := Decode([]byte(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
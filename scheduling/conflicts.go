@@ -0,0 +1,173 @@
+package scheduling
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/datetime"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+//   RFC 5545 3.2.3.  Calendar User Type
+//
+//      cutypeparam        = "CUTYPE" "="
+//                          ("INDIVIDUAL"   ; An individual
+//                         / "GROUP"        ; A group of individuals
+//                         / "RESOURCE"     ; A physical resource
+//                         / "ROOM"         ; A room resource
+//                         / "UNKNOWN"      ; Otherwise not known
+//                         / x-name         ; Experimental type
+//                         / iana-token)    ; Other IANA-registered
+//                                          ; type
+//
+//   RFC 5545 3.8.1.10.  Resources
+//
+//      Purpose:  This property defines the equipment or resources
+//      anticipated for an activity specified by a calendar component.
+
+// Conflict is a pair of overlapping event instances.
+type Conflict struct {
+	A, B *objects.Occurrence
+	// Start and End are the time the instances overlap.
+	Start, End time.Time
+	// Rooms are the calendar addresses of the ROOM and RESOURCE
+	// attendees that both instances book, in lower case.
+	Rooms []string
+	// Resources are the RESOURCES values that both instances book, in
+	// lower case.
+	Resources []string
+}
+
+// DoubleBooking reports whether c books a room or resource twice.
+func (c *Conflict) DoubleBooking() bool {
+	return len(c.Rooms) > 0 || len(c.Resources) > 0
+}
+
+// booking is an instance of an event that takes time, with what it
+// books.
+type booking struct {
+	o         *objects.Occurrence
+	uid       string
+	rooms     map[string]bool
+	resources map[string]bool
+}
+
+// Conflicts returns the pairs of overlapping instances of the opaque
+// events of comps within [after, before), which may come from several
+// calendars, sorted by the start of the overlap.  Recurring events are
+// expanded within the window; transparent and cancelled events are left
+// out.  All-day events take their days from midnight to midnight, so
+// that events on consecutive days do not overlap.  The same instance of
+// an event in two calendars, by UID and start, is no conflict.
+//
+// Conflicts that book the same room or resource have Rooms or Resources
+// set; these are the double bookings of the rooms and resources.  Rooms
+// that declined an instance are not booked by it.
+func Conflicts(comps []components.Component, after, before time.Time) ([]*Conflict, error) {
+	occurrences, err := objects.Occurrences(comps, after, before)
+	if err != nil {
+		return nil, err
+	}
+	var bookings []*booking
+	seen := map[string]bool{}
+	for _, o := range occurrences {
+		e, ok := o.Component.(*components.Event)
+		if !ok || !blocks(e) {
+			continue
+		}
+		b := newBooking(o, e)
+		if b.uid != "" {
+			// the same instance, from another calendar
+			key := b.uid + "\x00" + o.Start.UTC().Format(time.RFC3339Nano)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		bookings = append(bookings, b)
+	}
+	sort.SliceStable(bookings, func(i, j int) bool { return bookings[i].o.Start.Before(bookings[j].o.Start) })
+
+	var conflicts []*Conflict
+	var active []*booking
+	for _, b := range bookings {
+		// drop the instances that end before b starts
+		kept := active[:0]
+		for _, a := range active {
+			if a.o.End.After(b.o.Start) {
+				kept = append(kept, a)
+			}
+		}
+		active = kept
+		for _, a := range active {
+			if c := conflict(a, b); c != nil {
+				conflicts = append(conflicts, c)
+			}
+		}
+		active = append(active, b)
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Start.Before(conflicts[j].Start) })
+	return conflicts, nil
+}
+
+// blocks reports whether e takes time: it is opaque and not cancelled.
+func blocks(e *components.Event) bool {
+	if e.Transparent != nil && e.Transparent.Values != nil && strings.EqualFold(e.Transparent.Values.V, datetime.TransparentType) {
+		return false
+	}
+	return e.Status == nil || e.Status.Value == nil || !strings.EqualFold(e.Status.Value.V, "CANCELLED")
+}
+
+func newBooking(o *objects.Occurrence, e *components.Event) *booking {
+	b := &booking{o: o, rooms: map[string]bool{}, resources: map[string]bool{}}
+	if e.Uid != nil && e.Uid.Value != nil {
+		b.uid = e.Uid.Value.V
+	}
+	for _, p := range e.Attendees() {
+		switch strings.ToUpper(p.CuType) {
+		case parameters.RoomCuType.V, parameters.ResourceCuType.V:
+			if !strings.EqualFold(p.PartStat, parameters.Declined.V) && p.Address != "" {
+				b.rooms[strings.ToLower(p.Address)] = true
+			}
+		}
+	}
+	for _, r := range e.Resources {
+		for _, v := range r.Values {
+			if t, ok := v.(*types.Text); ok && t.V != "" {
+				b.resources[strings.ToLower(types.UnescapeText(t.V))] = true
+			}
+		}
+	}
+	return b
+}
+
+// conflict returns the conflict of a and b, which starts no later than
+// b, or nil when they do not overlap.
+func conflict(a, b *booking) *Conflict {
+	start, end := b.o.Start, a.o.End
+	if b.o.End.Before(end) {
+		end = b.o.End
+	}
+	if !start.Before(end) && !(start.Equal(end) && a.o.Start.Before(start)) {
+		// instances of no length conflict only inside others
+		return nil
+	}
+	c := &Conflict{A: a.o, B: b.o, Start: start, End: end}
+	c.Rooms = shared(a.rooms, b.rooms)
+	c.Resources = shared(a.resources, b.resources)
+	return c
+}
+
+func shared(a, b map[string]bool) []string {
+	var list []string
+	for k := range a {
+		if b[k] {
+			list = append(list, k)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
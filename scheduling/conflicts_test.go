@@ -0,0 +1,50 @@
+package scheduling
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConflicts(t *testing.T) {
+	room := "ATTENDEE;CUTYPE=ROOM:mailto:room-a@example.com\n"
+	comps := decode(t,
+		event("e1", "20240603T100000Z", "20240603T110000Z", room)+
+			event("e2", "20240603T103000Z", "20240603T113000Z", "ATTENDEE;CUTYPE=ROOM:MAILTO:Room-A@example.com\n")+
+			event("e3", "20240603T090000Z", "20240603T100000Z", "RRULE:FREQ=DAILY;COUNT=2\nRESOURCES:Projector,Whiteboard\n")+
+			event("e4", "20240604T093000Z", "20240604T094500Z", "RESOURCES:projector\n")+
+			"BEGIN:VEVENT\nUID:e5\nDTSTAMP:20240601T000000Z\nDTSTART;VALUE=DATE:20240605\nEND:VEVENT\n"+
+			"BEGIN:VEVENT\nUID:e6\nDTSTAMP:20240601T000000Z\nDTSTART;VALUE=DATE:20240606\nDTEND;VALUE=DATE:20240607\nEND:VEVENT\n"+
+			event("e7", "20240605T120000Z", "20240605T130000Z", "")+
+			event("e8", "20240605T120000Z", "20240605T130000Z", "TRANSP:TRANSPARENT\n")+
+			event("e9", "20240605T120000Z", "20240605T130000Z", "STATUS:CANCELLED\n")+
+			event("e10", "20240603T104500Z", "20240603T110000Z", "ATTENDEE;CUTYPE=ROOM;PARTSTAT=DECLINED:mailto:room-a@example.com\n"))
+	// the same event in a second calendar
+	comps = append(comps, decode(t, event("e1", "20240603T100000Z", "20240603T110000Z", room))...)
+
+	conflicts, err := Conflicts(comps, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range conflicts {
+		got = append(got, fmt.Sprintf("%s/%s %s-%s %v %v %v", uid(c.A), uid(c.B), c.Start.UTC().Format("0102T1504"), c.End.UTC().Format("1504"), c.Rooms, c.Resources, c.DoubleBooking()))
+	}
+	want := []string{
+		"e1/e2 0603T1030-1100 [mailto:room-a@example.com] [] true",
+		"e1/e10 0603T1045-1100 [] [] false",
+		"e2/e10 0603T1045-1100 [] [] false",
+		"e3/e4 0604T0930-0945 [] [projector] true",
+		"e5/e7 0605T1200-1300 [] [] false",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func uid(o *objects.Occurrence) string {
+	return o.Component.(*components.Event).Uid.Value.V
+}
@@ -0,0 +1,226 @@
+// Package scheduling finds times for meetings in the busy time of their
+// participants, and the double bookings of events, rooms and resources.
+package scheduling
+
+import (
+	"errors"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"sort"
+	"time"
+)
+
+//   RFC 5545 3.2.9.  Free/Busy Time Type
+//
+//      The value FREE indicates that the time interval is free for
+//      scheduling.  The value BUSY indicates that the time interval is
+//      busy because one or more events have been scheduled for that
+//      interval.  The value BUSY-UNAVAILABLE indicates that the time
+//      interval is busy and that the interval can not be scheduled.  The
+//      value BUSY-TENTATIVE indicates that the time interval is busy
+//      because one or more events have been tentatively scheduled for
+//      that interval.
+
+// WorkingHours are the hours of the week in which a participant can
+// meet: from Start to End on each of Days, as clock times in Location.
+// Clock times are durations since midnight, so that 9*time.Hour is
+// 09:00 whatever the offset of the day.
+type WorkingHours struct {
+	Location   *time.Location
+	Days       []time.Weekday
+	Start, End time.Duration
+}
+
+// OfficeHours returns the working hours from 09:00 to 17:00 on Monday to
+// Friday in loc.
+func OfficeHours(loc *time.Location) *WorkingHours {
+	return &WorkingHours{
+		Location: loc,
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+	}
+}
+
+// clock returns the time of the clock time d on the day of t in loc.
+func clock(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	y, m, day := t.Date()
+	return time.Date(y, m, day, int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second), 0, loc)
+}
+
+// contains reports whether [start, end) lies within the working hours of
+// one day.
+func (h *WorkingHours) contains(start, end time.Time) bool {
+	loc := h.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := start.In(loc)
+	working := false
+	for _, d := range h.Days {
+		if d == local.Weekday() {
+			working = true
+		}
+	}
+	if !working {
+		return false
+	}
+	from, to := clock(local, h.Start, loc), clock(local, h.End, loc)
+	return !start.Before(from) && !end.After(to)
+}
+
+// Participant is a person or a resource whose busy time a meeting must
+// avoid.
+type Participant struct {
+	// Name identifies the participant in slots, such as its calendar
+	// address.
+	Name string
+	// Components are the events and VFREEBUSY components of the
+	// participant, such as the components of its calendars.  Recurring
+	// events are expanded.
+	Components []components.Component
+	// Hours are the working hours of the participant.  Nil takes the
+	// hours of the Request.
+	Hours *WorkingHours
+	// Optional participants need not be free; slots that suit more of
+	// them rank higher.
+	Optional bool
+}
+
+// Request describes the meeting to find slots for.
+type Request struct {
+	Participants []*Participant
+	// Duration is the length of the meeting.
+	Duration time.Duration
+	// After and Before are the window to search.
+	After, Before time.Time
+	// Hours are the working hours of the participants without their
+	// own.  Nil leaves them available at any time.
+	Hours *WorkingHours
+	// Buffer is the free time that participants need between the
+	// meeting and their other meetings.
+	Buffer time.Duration
+	// Step is the interval of the starts of slots, 15 minutes when zero.
+	// Starts are multiples of Step since the zero time, in UTC.
+	Step time.Duration
+	// Tentative allows slots in which required participants are only
+	// tentatively busy.  These rank below the slots in which they are
+	// free.
+	Tentative bool
+	// Limit is the maximum number of slots to return, all when zero.
+	Limit int
+}
+
+// Slot is a time that suits the required participants of a request.
+type Slot struct {
+	Start, End time.Time
+	// Free are the names of the participants that are free, optional
+	// ones included.
+	Free []string
+	// Tentative are the names of the participants that are
+	// tentatively busy.
+	Tentative []string
+	// Missing are the names of the optional participants that are busy,
+	// even tentatively, or outside their working hours.
+	Missing []string
+}
+
+// busy is the busy time of a participant, with buffers.
+type busy struct {
+	p         *Participant
+	hard      []*objects.BusyPeriod
+	tentative []*objects.BusyPeriod
+}
+
+// overlaps reports whether one of periods, sorted by start and not
+// overlapping, overlaps [start, end).
+func overlaps(periods []*objects.BusyPeriod, start, end time.Time) bool {
+	i := sort.Search(len(periods), func(i int) bool { return periods[i].End.After(start) })
+	return i < len(periods) && periods[i].Start.Before(end)
+}
+
+// FindSlots returns the slots of r in which all required participants
+// are free and within their working hours, ranked: slots without
+// tentatively busy participants first, then those that suit more
+// optional participants, then earlier ones.  Busy time is that of
+// objects.BusyPeriods: BUSY and BUSY-UNAVAILABLE time is never
+// available, BUSY-TENTATIVE time only with r.Tentative.
+func FindSlots(r *Request) ([]*Slot, error) {
+	if r.Duration <= 0 {
+		return nil, errors.New("scheduling: meeting without duration")
+	}
+	if !r.Before.After(r.After) {
+		return nil, errors.New("scheduling: empty search window")
+	}
+	step := r.Step
+	if step <= 0 {
+		step = 15 * time.Minute
+	}
+	var all []*busy
+	for _, p := range r.Participants {
+		periods, err := objects.BusyPeriods(p.Components, r.After.Add(-r.Buffer), r.Before.Add(r.Buffer))
+		if err != nil {
+			return nil, err
+		}
+		b := &busy{p: p}
+		for _, period := range periods {
+			padded := &objects.BusyPeriod{Start: period.Start.Add(-r.Buffer), End: period.End.Add(r.Buffer), Type: parameters.Busy.V}
+			if period.Type == parameters.BusyTentative.V {
+				b.tentative = append(b.tentative, padded)
+			} else {
+				b.hard = append(b.hard, padded)
+			}
+		}
+		// buffers may make periods overlap
+		b.hard = objects.MergeBusyPeriods(b.hard, r.After.Add(-r.Buffer), r.Before.Add(r.Buffer))
+		b.tentative = objects.MergeBusyPeriods(b.tentative, r.After.Add(-r.Buffer), r.Before.Add(r.Buffer))
+		all = append(all, b)
+	}
+
+	var slots []*Slot
+	start := r.After.Truncate(step)
+	if start.Before(r.After) {
+		start = start.Add(step)
+	}
+	for ; !start.Add(r.Duration).After(r.Before); start = start.Add(step) {
+		end := start.Add(r.Duration)
+		slot := &Slot{Start: start, End: end}
+		ok := true
+		for _, b := range all {
+			hours := b.p.Hours
+			if hours == nil {
+				hours = r.Hours
+			}
+			available := (hours == nil || hours.contains(start, end)) && !overlaps(b.hard, start, end)
+			tentative := available && overlaps(b.tentative, start, end)
+			switch {
+			case available && !tentative:
+				slot.Free = append(slot.Free, b.p.Name)
+			case b.p.Optional:
+				slot.Missing = append(slot.Missing, b.p.Name)
+			case tentative && r.Tentative:
+				slot.Tentative = append(slot.Tentative, b.p.Name)
+			default:
+				ok = false
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			slots = append(slots, slot)
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		a, b := slots[i], slots[j]
+		if len(a.Tentative) != len(b.Tentative) {
+			return len(a.Tentative) < len(b.Tentative)
+		}
+		return len(a.Missing) < len(b.Missing)
+	})
+	if r.Limit > 0 && len(slots) > r.Limit {
+		slots = slots[:r.Limit]
+	}
+	return slots, nil
+}
@@ -0,0 +1,92 @@
+package scheduling
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decode(t *testing.T, body string) []components.Component {
+	t.Helper()
+	cal, err := objects.Decode(strings.NewReader("BEGIN:VCALENDAR\nVERSION:2.0\nPRODID:-//test//EN\n" + body + "END:VCALENDAR\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cal.Components
+}
+
+func event(uid, start, end, extra string) string {
+	return "BEGIN:VEVENT\nUID:" + uid + "\nDTSTAMP:20240601T000000Z\nDTSTART:" + start + "\nDTEND:" + end + "\n" + extra + "END:VEVENT\n"
+}
+
+func slotsOf(slots []*Slot) string {
+	var s []string
+	for _, slot := range slots {
+		s = append(s, slot.Start.UTC().Format("15:04"))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestFindSlots(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	participants := []*Participant{
+		{Name: "alice", Components: decode(t,
+			event("standup", "20240501T090000Z", "20240501T091500Z", "RRULE:FREQ=DAILY\n")+
+				event("review", "20240603T100000Z", "20240603T120000Z", ""))},
+		{Name: "bob", Components: decode(t,
+			event("lunch", "20240603T130000Z", "20240603T140000Z", "STATUS:TENTATIVE\n")+
+				event("gym", "20240603T170000Z", "20240603T180000Z", "TRANSP:TRANSPARENT\n")+
+				"BEGIN:VFREEBUSY\nUID:fb\nDTSTAMP:20240601T000000Z\nFREEBUSY:20240603T150000Z/PT1H\nEND:VFREEBUSY\n")},
+		{Name: "carol", Hours: OfficeHours(newYork), Components: decode(t,
+			event("sync", "20240501T123000Z", "20240501T131500Z", "RRULE:FREQ=DAILY\n"))},
+		{Name: "dave", Optional: true, Components: decode(t,
+			event("call", "20240603T140000Z", "20240603T150000Z", ""))},
+	}
+	r := &Request{
+		Participants: participants,
+		Duration:     time.Hour,
+		After:        time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC),
+		Before:       time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC),
+		Hours:        OfficeHours(time.UTC),
+		Step:         30 * time.Minute,
+	}
+	slots, err := FindSlots(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := slotsOf(slots); g != "16:00 14:00" {
+		t.Errorf("slots: %q", g)
+	}
+	if len(slots) == 2 && (strings.Join(slots[1].Missing, " ") != "dave" || len(slots[0].Free) != 4) {
+		t.Errorf("participants: %+v %+v", slots[0], slots[1])
+	}
+
+	r.Tentative = true
+	slots, _ = FindSlots(r)
+	if g := slotsOf(slots); g != "16:00 14:00 13:30" {
+		t.Errorf("tentative slots: %q", g)
+	}
+	if len(slots) == 3 && strings.Join(slots[2].Tentative, " ") != "bob" {
+		t.Errorf("tentative participants: %+v", slots[2])
+	}
+
+	r.Buffer, r.Step = 15*time.Minute, 15*time.Minute
+	slots, _ = FindSlots(r)
+	if g := slotsOf(slots); g != "13:30 13:45" {
+		t.Errorf("slots with buffers: %q", g)
+	}
+
+	r.Limit, r.Buffer = 1, 0
+	if slots, _ = FindSlots(r); len(slots) != 1 {
+		t.Errorf("%d slots, want 1", len(slots))
+	}
+	r.Duration = 0
+	if _, err := FindSlots(r); err == nil {
+		t.Errorf("FindSlots without duration succeeded")
+	}
+}
@@ -0,0 +1,739 @@
+package store
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The files of a Dir besides its resources.  Their names begin with a
+// dot, so that vdir tools ignore them.
+const (
+	lockName     = ".lock"
+	manifestName = ".manifest"
+	journalName  = ".journal"
+	tempPrefix   = ".tmp-"
+)
+
+// compactAfter is the number of journal entries after which the journal
+// is folded into the manifest.
+const compactAfter = 256
+
+// ProdId is the PRODID of the resources that a Dir creates.
+const ProdId = "-//vcalender//store//EN"
+
+// record is a resource of a Dir.  Name, UID, modification time, size and
+// span come from the manifest and the journal; the calendar and the
+// recurrence set are parsed from the file when they are needed.
+type record struct {
+	name       string
+	uid        string
+	mod, size  int64
+	start, end time.Time
+	cal        *objects.Calendar
+	set        *set
+}
+
+// Dir is a Store that keeps each recurrence set in a resource of its own
+// in a directory, laid out as a vdir: an iCalendar file named after the
+// UID with the extension .ics.  Files are replaced through temporary
+// files and renames, so that a crash leaves either the old or the new
+// version.
+//
+// A manifest lists the name, UID, modification time, size and span of
+// the resources, and a journal the changes since it was written, so that
+// opening a Dir only reads the files that changed behind its back, and
+// queries only read the resources they need.  Processes that share a
+// directory take turns through a lock file and catch up on the journal
+// before each operation.  A Dir is safe for concurrent use.
+type Dir struct {
+	mu     sync.Mutex
+	path   string
+	lock   *os.File
+	byName map[string]*record
+	byUID  map[string]*record
+	index  *index
+	// header is the first line of the journal read so far; offset is
+	// where the entries not yet read begin, and entries is the number of
+	// entries read.
+	header  string
+	offset  int64
+	entries int
+}
+
+var _ Store = (*Dir)(nil)
+
+// OpenDir opens the Dir at path, creating the directory if needed.
+// Resources that were added, changed or removed by other programs are
+// indexed again.
+func OpenDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	lock, err := os.OpenFile(filepath.Join(path, lockName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dir{path: path, lock: lock}
+	d.reset()
+	err = d.do(true, func() error {
+		return d.scan()
+	})
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Close releases the lock file of d.
+func (d *Dir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lock.Close()
+}
+
+func (d *Dir) reset() {
+	d.byName = map[string]*record{}
+	d.byUID = map[string]*record{}
+	d.index = newIndex()
+	d.header, d.offset, d.entries = "", 0, 0
+}
+
+// do runs f holding the lock, after catching up on the journal.
+// Operations that change d take the lock exclusively.
+func (d *Dir) do(exclusive bool, f func() error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := lockFile(d.lock, exclusive); err != nil {
+		return err
+	}
+	defer unlockFile(d.lock)
+	if err := d.sync(exclusive); err != nil {
+		return err
+	}
+	if err := f(); err != nil {
+		return err
+	}
+	if exclusive && d.entries >= compactAfter {
+		return d.compact()
+	}
+	return nil
+}
+
+func (d *Dir) file(name string) string {
+	return filepath.Join(d.path, name)
+}
+
+// sync applies the journal entries that d has not read yet.  When the
+// journal was compacted since, d reads the manifest again.
+func (d *Dir) sync(exclusive bool) error {
+	f, err := os.Open(d.file(journalName))
+	if os.IsNotExist(err) && exclusive {
+		// a new directory
+		return d.compact()
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("store: %s: %v", journalName, err)
+	}
+	if header != d.header {
+		d.reset()
+		if err := d.readManifest(); err != nil {
+			return err
+		}
+		d.header, d.offset = header, int64(len(header))
+	} else {
+		if _, err := f.Seek(d.offset, io.SeekStart); err != nil {
+			return err
+		}
+		r.Reset(f)
+	}
+	n, err := d.apply(r, true)
+	d.offset += n
+	return err
+}
+
+// readManifest applies the entries of the manifest.
+func (d *Dir) readManifest() error {
+	f, err := os.Open(d.file(manifestName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+		return err
+	}
+	_, err = d.apply(r, false)
+	return err
+}
+
+// apply applies the entries of r and returns the number of bytes of the
+// complete lines read.  A line cut short at the end is left for later.
+func (d *Dir) apply(r *bufio.Reader, journal bool) (int64, error) {
+	var n int64
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n += int64(len(line))
+		op, rec, err := parseEntry(line)
+		if err != nil {
+			// torn by a crash; OpenDir indexes the files again
+			continue
+		}
+		if journal {
+			d.entries++
+		}
+		switch op {
+		case "put":
+			d.put(rec)
+		case "del":
+			d.forget(rec.name)
+		}
+	}
+}
+
+// put adds rec.  A record that was not parsed keeps the parsed resource
+// of the record it replaces when the file did not change.
+func (d *Dir) put(rec *record) {
+	if old, ok := d.byName[rec.name]; ok && rec.set == nil && old.mod == rec.mod && old.size == rec.size {
+		rec.cal, rec.set = old.cal, old.set
+	}
+	d.forget(rec.name)
+	if old, ok := d.byUID[rec.uid]; ok {
+		d.forget(old.name)
+	}
+	d.byName[rec.name] = rec
+	d.byUID[rec.uid] = rec
+	if rec.start.IsZero() {
+		return
+	}
+	d.index.set(rec.uid, rec.start, rec.end)
+}
+
+// forget removes the record of the file name.
+func (d *Dir) forget(name string) {
+	rec, ok := d.byName[name]
+	if !ok {
+		return
+	}
+	delete(d.byName, name)
+	if d.byUID[rec.uid] == rec {
+		delete(d.byUID, rec.uid)
+		d.index.remove(rec.uid)
+	}
+}
+
+// journal records the entry of rec.  The lock must be held exclusively.
+func (d *Dir) journal(op string, rec *record) error {
+	line := formatEntry(op, rec)
+	f, err := os.OpenFile(d.file(journalName), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	d.offset += int64(len(line))
+	d.entries++
+	return nil
+}
+
+// compact writes the records to a new manifest and starts a new journal.
+func (d *Dir) compact() error {
+	names := make([]string, 0, len(d.byName))
+	for name := range d.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("vcalender-manifest 1\n")
+	for _, name := range names {
+		b.WriteString(formatEntry("put", d.byName[name]))
+	}
+	if err := writeFile(d.path, manifestName, []byte(b.String())); err != nil {
+		return err
+	}
+	header := fmt.Sprintf("vcalender-journal %d.%d\n", time.Now().UnixNano(), os.Getpid())
+	if err := writeFile(d.path, journalName, []byte(header)); err != nil {
+		return err
+	}
+	d.header, d.offset, d.entries = header, int64(len(header)), 0
+	return nil
+}
+
+// scan indexes the resources whose files do not match their records, and
+// forgets the records of removed files.  It also removes the temporary
+// files left by crashes.
+func (d *Dir) scan() error {
+	infos, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, fi := range infos {
+		name := fi.Name()
+		if strings.HasPrefix(name, tempPrefix) {
+			os.Remove(d.file(name))
+			continue
+		}
+		if fi.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".ics" {
+			continue
+		}
+		seen[name] = true
+		if rec, ok := d.byName[name]; ok && rec.mod == fi.ModTime().UnixNano() && rec.size == fi.Size() {
+			continue
+		}
+		rec := &record{name: name}
+		if err := d.read(rec); err != nil {
+			// not a resource of this store
+			seen[name] = false
+			continue
+		}
+		d.put(rec)
+		if err := d.journal("put", rec); err != nil {
+			return err
+		}
+	}
+	for name, rec := range d.byName {
+		if !seen[name] {
+			d.forget(name)
+			if err := d.journal("del", rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// read parses the file of rec and sets its fields.  An empty UID is set
+// to the UID of the first component.
+func (d *Dir) read(rec *record) error {
+	data, err := ioutil.ReadFile(d.file(rec.name))
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(d.file(rec.name))
+	if err != nil {
+		return err
+	}
+	cal, err := objects.Decode(strings.NewReader(string(data)))
+	if cal == nil {
+		return fmt.Errorf("store: %s: %v", rec.name, err)
+	}
+	s := &set{overrides: map[int64]components.Component{}}
+	for _, c := range cal.Components {
+		uid, id, err := key(c)
+		if err != nil || rec.uid != "" && uid != rec.uid {
+			continue
+		}
+		rec.uid = uid
+		if id.IsZero() {
+			s.master = c
+		} else {
+			s.overrides[id.Unix()] = c
+		}
+	}
+	if s.empty() {
+		return fmt.Errorf("store: %s: no component to store", rec.name)
+	}
+	rec.cal, rec.set = cal, s
+	rec.mod, rec.size = fi.ModTime().UnixNano(), fi.Size()
+	rec.start, rec.end, _ = s.span()
+	return nil
+}
+
+// load returns the recurrence set of uid, parsing its file when it was
+// not parsed yet or changed since.
+func (d *Dir) load(uid string) (*set, error) {
+	rec, ok := d.byUID[uid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	fi, err := os.Stat(d.file(rec.name))
+	if os.IsNotExist(err) {
+		d.forget(rec.name)
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rec.set != nil && rec.mod == fi.ModTime().UnixNano() && rec.size == fi.Size() {
+		return rec.set, nil
+	}
+	fresh := &record{name: rec.name, uid: uid}
+	if err := d.read(fresh); err != nil {
+		d.forget(rec.name)
+		return nil, ErrNotFound
+	}
+	d.put(fresh)
+	return fresh.set, nil
+}
+
+// write writes s as the resource of uid, or removes the resource when s
+// is empty.  Components of the file that are not part of s, such as
+// VTIMEZONEs, are kept, and VTIMEZONEs are added for the other TZIDs s
+// uses.
+func (d *Dir) write(uid string, s *set) error {
+	rec, ok := d.byUID[uid]
+	if !ok {
+		rec = &record{name: fileName(uid), uid: uid}
+	}
+	if s.empty() {
+		if err := os.Remove(d.file(rec.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		d.forget(rec.name)
+		return d.journal("del", rec)
+	}
+	cal := &objects.Calendar{}
+	if rec.cal != nil {
+		*cal = *rec.cal
+	} else {
+		version := property.Version2
+		cal.ProdId, cal.Version = property.NewProductIdentifier(ProdId), &version
+	}
+	var comps []components.Component
+	for _, c := range cal.Components {
+		if u, _, err := key(c); err != nil || u != uid {
+			comps = append(comps, c)
+		}
+	}
+	cal.Components = append(comps, s.list()...)
+	if err := cal.AddTimeZones(); err != nil {
+		return err
+	}
+	text, err := cal.Calendar()
+	if err != nil {
+		return err
+	}
+	if err := writeFile(d.path, rec.name, []byte(text)); err != nil {
+		return err
+	}
+	fi, err := os.Stat(d.file(rec.name))
+	if err != nil {
+		return err
+	}
+	fresh := &record{name: rec.name, uid: uid, mod: fi.ModTime().UnixNano(), size: fi.Size(), cal: cal, set: s}
+	fresh.start, fresh.end, _ = s.span()
+	d.put(fresh)
+	return d.journal("put", fresh)
+}
+
+// Put stores a copy of c in the resource of its UID.  The TZIDs c uses
+// must name IANA time zones, unless the resource already has their
+// VTIMEZONEs.
+func (d *Dir) Put(c components.Component) error {
+	uid, id, err := key(c)
+	if err != nil {
+		return err
+	}
+	c = components.Clone(c)
+	return d.do(true, func() error {
+		s := &set{overrides: map[int64]components.Component{}}
+		if old, err := d.load(uid); err == nil {
+			s.master = old.master
+			for k, v := range old.overrides {
+				s.overrides[k] = v
+			}
+		} else if err != ErrNotFound {
+			return err
+		}
+		if id.IsZero() {
+			s.master = c
+		} else {
+			s.overrides[id.Unix()] = c
+		}
+		return d.write(uid, s)
+	})
+}
+
+// Get returns copies of the recurrence set of uid.
+func (d *Dir) Get(uid string) ([]components.Component, error) {
+	var list []components.Component
+	err := d.do(false, func() error {
+		s, err := d.load(uid)
+		if err != nil {
+			return err
+		}
+		for _, c := range s.list() {
+			list = append(list, components.Clone(c))
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Instance returns a copy of a component of uid.
+func (d *Dir) Instance(uid string, recurrenceID time.Time) (components.Component, error) {
+	var c components.Component
+	err := d.do(false, func() error {
+		s, err := d.load(uid)
+		if err != nil {
+			return err
+		}
+		c = s.master
+		if !recurrenceID.IsZero() {
+			c = s.overrides[recurrenceID.Unix()]
+		}
+		if c == nil {
+			return ErrNotFound
+		}
+		c = components.Clone(c)
+		return nil
+	})
+	return c, err
+}
+
+// Delete removes the resource of uid.
+func (d *Dir) Delete(uid string) error {
+	return d.do(true, func() error {
+		if _, err := d.load(uid); err != nil {
+			return err
+		}
+		return d.write(uid, &set{})
+	})
+}
+
+// DeleteInstance removes a component of uid, and the resource with its
+// last component.
+func (d *Dir) DeleteInstance(uid string, recurrenceID time.Time) error {
+	return d.do(true, func() error {
+		old, err := d.load(uid)
+		if err != nil {
+			return err
+		}
+		s := &set{master: old.master, overrides: map[int64]components.Component{}}
+		for k, v := range old.overrides {
+			s.overrides[k] = v
+		}
+		if recurrenceID.IsZero() {
+			if s.master == nil {
+				return ErrNotFound
+			}
+			s.master = nil
+		} else {
+			if _, ok := s.overrides[recurrenceID.Unix()]; !ok {
+				return ErrNotFound
+			}
+			delete(s.overrides, recurrenceID.Unix())
+		}
+		return d.write(uid, s)
+	})
+}
+
+// UIDs returns the UIDs of d, sorted.
+func (d *Dir) UIDs() ([]string, error) {
+	var uids []string
+	err := d.do(false, func() error {
+		for uid := range d.byUID {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		return nil
+	})
+	return uids, err
+}
+
+// Search returns copies of the components that match q, by UID.  Without
+// a time range, all resources are read.
+func (d *Dir) Search(q *Query) ([]components.Component, error) {
+	if q.timeRange() {
+		occurrences, err := d.Occurrences(q)
+		if err != nil {
+			return nil, err
+		}
+		return distinct(occurrences), nil
+	}
+	var list []components.Component
+	err := d.do(false, func() error {
+		uids := make([]string, 0, len(d.byUID))
+		for uid := range d.byUID {
+			uids = append(uids, uid)
+		}
+		sort.Strings(uids)
+		for _, uid := range uids {
+			s, err := d.load(uid)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			for _, c := range s.list() {
+				if q.Match(c) {
+					list = append(list, components.Clone(c))
+				}
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// Occurrences returns the occurrences of the components that match q in
+// its time range.  Only the resources whose span overlaps the range are
+// read.
+func (d *Dir) Occurrences(q *Query) ([]*objects.Occurrence, error) {
+	if !q.timeRange() {
+		return nil, ErrNoRange
+	}
+	var occurrences []*objects.Occurrence
+	err := d.do(false, func() error {
+		var comps []components.Component
+		for _, uid := range d.index.overlapping(q.After, q.Before) {
+			s, err := d.load(uid)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			comps = append(comps, s.list()...)
+		}
+		var err error
+		occurrences, err = objects.Occurrences(comps, q.After, q.Before)
+		if err != nil {
+			return err
+		}
+		occurrences = filter(occurrences, q)
+		return nil
+	})
+	return occurrences, err
+}
+
+// fileName returns the name of the resource of uid: the UID itself when
+// it is safe as a file name, and its SHA-1 otherwise.
+func fileName(uid string) string {
+	safe := uid != "" && uid[0] != '.' && len(uid) <= 200
+	for _, r := range uid {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@.-_+", r)) {
+			safe = false
+		}
+	}
+	if safe {
+		return uid + ".ics"
+	}
+	sum := sha1.Sum([]byte(uid))
+	return hex.EncodeToString(sum[:]) + ".ics"
+}
+
+// writeFile replaces the file name in dir with data, through a temporary
+// file that is synced and renamed.
+func writeFile(dir, name string, data []byte) error {
+	f, err := ioutil.TempFile(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	// make the rename durable; not all systems can sync directories
+	if dh, err := os.Open(dir); err == nil {
+		dh.Sync()
+		dh.Close()
+	}
+	return nil
+}
+
+// formatEntry returns the manifest or journal line of rec.
+func formatEntry(op string, rec *record) string {
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return strings.Join([]string{
+		op,
+		strconv.Quote(rec.name),
+		strconv.Quote(rec.uid),
+		strconv.FormatInt(rec.mod, 10),
+		strconv.FormatInt(rec.size, 10),
+		stamp(rec.start),
+		stamp(rec.end),
+	}, "\t") + "\n"
+}
+
+var errEntry = errors.New("store: malformed manifest or journal entry")
+
+// parseEntry parses a line written by formatEntry.
+func parseEntry(line string) (string, *record, error) {
+	fields := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+	if len(fields) != 7 || fields[0] != "put" && fields[0] != "del" {
+		return "", nil, errEntry
+	}
+	rec := &record{}
+	var err error
+	if rec.name, err = strconv.Unquote(fields[1]); err != nil {
+		return "", nil, errEntry
+	}
+	if rec.uid, err = strconv.Unquote(fields[2]); err != nil {
+		return "", nil, errEntry
+	}
+	if rec.mod, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+		return "", nil, errEntry
+	}
+	if rec.size, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return "", nil, errEntry
+	}
+	for i, t := range []*time.Time{&rec.start, &rec.end} {
+		if fields[5+i] == "-" {
+			continue
+		}
+		if *t, err = time.Parse(time.RFC3339Nano, fields[5+i]); err != nil {
+			return "", nil, errEntry
+		}
+	}
+	return fields[0], rec, nil
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDir(t *testing.T) (*Dir, string) {
+	t.Helper()
+	path, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cal, err := objects.Decode(strings.NewReader(storeCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cal.Components {
+		if err := d.Put(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d, path
+}
+
+func TestDir(t *testing.T) {
+	d, path := newTestDir(t)
+	defer os.RemoveAll(path)
+	defer d.Close()
+	m := newTestMemory(t)
+
+	names, _ := filepath.Glob(filepath.Join(path, "*.ics"))
+	if len(names) != 5 {
+		t.Errorf("%d resources, want 5", len(names))
+	}
+	data, err := ioutil.ReadFile(filepath.Join(path, "standup@example.com.ics"))
+	if err != nil || strings.Count(string(data), "BEGIN:VEVENT") != 2 {
+		t.Errorf("standup resource: %v\n%s", err, data)
+	}
+
+	// a second Dir reads the manifest and the journal without parsing
+	// the resources, and answers like the Memory
+	e, err := OpenDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	for uid, rec := range e.byUID {
+		if rec.set != nil {
+			t.Errorf("%s parsed on open", uid)
+		}
+	}
+	for _, q := range []*Query{
+		{After: day(4), Before: day(6)},
+		{After: day(1), Before: day(30), Categories: []string{"work"}, Components: []string{"VTODO"}},
+	} {
+		want, _ := m.Occurrences(q)
+		got, err := e.Occurrences(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, w := occurrencesOf(got), occurrencesOf(want); g != w {
+			t.Errorf("Occurrences: got %q, want %q", g, w)
+		}
+	}
+	if rec := e.byUID["dinner@example.com"]; rec.set != nil {
+		t.Errorf("a query outside its span parsed the dinner resource")
+	}
+	got, _ := e.Search(&Query{Components: []string{"VTODO"}})
+	if g := uidsOf(got); g != "report@example.com someday@example.com" {
+		t.Errorf("Search: %q", g)
+	}
+
+	// changes of one Dir are seen by the other
+	if err := d.DeleteInstance("standup@example.com", day(5).Add(9*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	occurrences, _ := e.Occurrences(&Query{After: day(5), Before: day(6)})
+	if g := occurrencesOf(occurrences); g != "standup@0605T09" {
+		t.Errorf("after DeleteInstance: %q", g)
+	}
+	if err := e.Delete("course@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get("course@example.com"); err != ErrNotFound {
+		t.Errorf("Get after Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "course@example.com.ics")); !os.IsNotExist(err) {
+		t.Errorf("resource not removed: %v", err)
+	}
+}
+
+func TestDir_External(t *testing.T) {
+	d, path := newTestDir(t)
+	defer os.RemoveAll(path)
+	d.Close()
+
+	// other programs add, change and remove resources
+	party := "BEGIN:VCALENDAR\nVERSION:2.0\nPRODID:-//test//EN\n" +
+		"BEGIN:VEVENT\nUID:party\nDTSTAMP:20240601T080000Z\nDTSTART:20240615T200000Z\nSUMMARY:Party\nEND:VEVENT\nEND:VCALENDAR\n"
+	if err := ioutil.WriteFile(filepath.Join(path, "x.ics"), []byte(party), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(path, "dinner@example.com.ics")); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(path, ".tmp-123"), []byte("torn"), 0644)
+	ioutil.WriteFile(filepath.Join(path, "notes.ics"), []byte("not a calendar"), 0644)
+
+	d, err := OpenDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	uids, _ := d.UIDs()
+	if g := strings.Join(uids, " "); g != "course@example.com party report@example.com someday@example.com standup@example.com" {
+		t.Errorf("UIDs: %q", g)
+	}
+	if _, err := os.Stat(filepath.Join(path, ".tmp-123")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left: %v", err)
+	}
+	occurrences, _ := d.Occurrences(&Query{After: day(15), Before: day(16), Components: []string{"VEVENT"}, Status: []string{""}})
+	if g := occurrencesOf(occurrences); g != "standup@0615T09 party@0615T20" {
+		t.Errorf("Occurrences: %q", g)
+	}
+	// Put keeps the file name of the resource
+	c, _ := d.Instance("party", time.Time{})
+	c.(*components.Event).Summary.Value.V = "Birthday party"
+	if err := d.Put(c); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(path, "x.ics"))
+	if !strings.Contains(string(data), "SUMMARY:Birthday party") {
+		t.Errorf("x.ics not updated:\n%s", data)
+	}
+}
+
+func TestDir_TimeZones(t *testing.T) {
+	d, path := newTestDir(t)
+	defer os.RemoveAll(path)
+	defer d.Close()
+
+	cal, err := objects.Decode(strings.NewReader("BEGIN:VCALENDAR\nVERSION:2.0\nPRODID:-//test//EN\n" +
+		"BEGIN:VEVENT\nUID:trip\nDTSTAMP:20240601T080000Z\nDTSTART;TZID=Europe/Berlin:20240620T090000\nSUMMARY:Trip\nEND:VEVENT\nEND:VCALENDAR\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put(cal.Components[0]); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(path, "trip.ics"))
+	if !strings.Contains(string(data), "BEGIN:VTIMEZONE\nTZID:Europe/Berlin\n") {
+		t.Errorf("no VTIMEZONE:\n%s", data)
+	}
+	// updates keep the VTIMEZONE instead of adding another
+	c, _ := d.Instance("trip", time.Time{})
+	c.(*components.Event).Summary.Value.V = "Long trip"
+	if err := d.Put(c); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(path, "trip.ics"))
+	if n := strings.Count(string(data), "BEGIN:VTIMEZONE"); n != 1 {
+		t.Errorf("%d VTIMEZONEs:\n%s", n, data)
+	}
+}
+
+func TestDir_Compact(t *testing.T) {
+	d, path := newTestDir(t)
+	defer os.RemoveAll(path)
+	defer d.Close()
+	master, _ := d.Instance("dinner@example.com", time.Time{})
+	for i := 0; i < compactAfter+10; i++ {
+		c := components.Clone(master).(*components.Event)
+		c.Uid.Value.V = fmt.Sprintf("dinner-%d", i%20)
+		if err := d.Put(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d.entries >= compactAfter {
+		t.Errorf("journal not compacted: %d entries", d.entries)
+	}
+	e, err := OpenDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	uids, _ := e.UIDs()
+	if len(uids) != 25 {
+		t.Errorf("%d UIDs after compaction, want 25", len(uids))
+	}
+}
+
+func TestFileName(t *testing.T) {
+	if n := fileName("abc@example.com"); n != "abc@example.com.ics" {
+		t.Errorf("fileName: %q", n)
+	}
+	if n := fileName("../x y"); strings.ContainsAny(n, "/ ") || !strings.HasSuffix(n, ".ics") {
+		t.Errorf("fileName of an unsafe UID: %q", n)
+	}
+}
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/recurrence"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/parameters"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"sort"
+	"time"
+)
+
+// forever is the end of the span of sets that recur without end.
+var forever = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// timed is the part of the components that place them in time.
+type timed interface {
+	Start() (time.Time, error)
+	End() (time.Time, error)
+}
+
+func recurrenceOf(c components.Component) (*recurrence.RRule, []*recurrence.RDate, *relationship.RecurrenceId) {
+	switch v := c.(type) {
+	case *components.Event:
+		return v.RRule, v.RDate, v.RecurId
+	case *components.Todo:
+		return v.RRule, v.RDate, v.RecurId
+	case *components.Journal:
+		return v.RRule, v.RDate, v.RecurId
+	}
+	return nil, nil, nil
+}
+
+func dtStart(c components.Component) types.Value {
+	switch v := c.(type) {
+	case *components.Event:
+		if v.DtStart != nil {
+			return v.DtStart.Value
+		}
+	case *components.Todo:
+		if v.DtStart != nil {
+			return v.DtStart.Value
+		}
+	case *components.Journal:
+		if v.DtStart != nil {
+			return v.DtStart.Value
+		}
+	}
+	return nil
+}
+
+// bounds returns the first start and the last end of c, not considering
+// its recurrence.  To-dos without DTSTART take place at their DUE.
+func bounds(c components.Component) (start, end time.Time, ok bool) {
+	t, ok := c.(timed)
+	if !ok {
+		return start, end, false
+	}
+	end, errEnd := t.End()
+	start, err := t.Start()
+	switch {
+	case err != nil && errEnd != nil:
+		return start, end, false
+	case err != nil:
+		start = end
+	}
+	if end.Before(start) {
+		end = start
+	}
+	return start, end, true
+}
+
+// span returns the time a recurrence set takes place in: from the first
+// start to the last end of its instances, or to forever when it recurs
+// without end.  Only rules with COUNT are expanded, and only up to their
+// count.
+func span(master components.Component, overrides []components.Component) (start, end time.Time, ok bool) {
+	include := func(s, e time.Time) {
+		if !ok || s.Before(start) {
+			start = s
+		}
+		if !ok || e.After(end) {
+			end = e
+		}
+		ok = true
+	}
+	var shiftEarlier, shiftLater time.Duration
+	for _, o := range overrides {
+		s, e, found := bounds(o)
+		if !found {
+			continue
+		}
+		include(s, e)
+		_, _, rid := recurrenceOf(o)
+		if rid == nil {
+			continue
+		}
+		for _, p := range rid.Parameters {
+			if r, isRange := p.(*parameters.RecurrenceIdRange); isRange && r.V == parameters.ThisAndFuture.V {
+				id, _ := objects.TimeOf(rid.Parameters, rid.Value)
+				if shift := s.Sub(id); shift > shiftLater {
+					shiftLater = shift
+				} else if shift < shiftEarlier {
+					shiftEarlier = shift
+				}
+			}
+		}
+	}
+	if master == nil {
+		return start, end, ok
+	}
+	first, last, found := bounds(master)
+	if !found {
+		return start, end, ok
+	}
+	include(first.Add(shiftEarlier), last.Add(shiftLater))
+	length := last.Sub(first)
+	rrule, rdates, _ := recurrenceOf(master)
+	for _, r := range rdates {
+		for _, v := range r.Values {
+			switch p := v.(type) {
+			case *types.ExplicitPeriod:
+				include(p.Start.Time(), p.End.Time())
+			case *types.StartPeriod:
+				include(p.Start.Time(), p.Start.Time().Add(p.Duration.Duration()))
+			default:
+				if s, isTime := objects.TimeOf(r.Parameters, v); isTime {
+					include(s, s.Add(length+24*time.Hour))
+				}
+			}
+		}
+	}
+	if rrule == nil || rrule.V == nil {
+		return start, end, ok
+	}
+	var until time.Time
+	count := false
+	for _, part := range rrule.V.Rules {
+		switch p := part.(type) {
+		case *types.Until:
+			until, _ = objects.TimeOf(nil, p.Time)
+			if _, isDate := p.Time.(*types.Date); isDate {
+				// UNTIL of DATE values includes the whole day
+				until = until.Add(24 * time.Hour)
+			}
+		case *types.Count:
+			count = true
+		}
+	}
+	switch {
+	case !until.IsZero():
+		include(first, until.Add(length+shiftLater))
+	case count:
+		dtstart := dtStart(master)
+		if dt, isDateTime := dtstart.(*types.DateTime); isDateTime && !dt.IsUTC() {
+			dtstart = &types.DateTime{V: first, Format: dt.Format}
+		}
+		it, err := rrule.V.Iterator(dtstart)
+		if err != nil {
+			include(first, forever)
+			break
+		}
+		for {
+			s, more := it.Next()
+			if !more {
+				break
+			}
+			include(s, s.Add(length+shiftLater))
+		}
+		if it.Err() != nil {
+			include(first, forever)
+		}
+	default:
+		include(first, forever)
+	}
+	return start, end, ok
+}
+
+// entry is the span of the recurrence set of a UID.
+type entry struct {
+	uid        string
+	start, end time.Time
+}
+
+// index finds the spans that overlap a time range.  Entries are sorted by
+// start, and maxEnd[i] is the latest end of the entries up to i, so that
+// a query stops at the first entry whose predecessors all end before the
+// range.
+type index struct {
+	entries []*entry
+	maxEnd  []time.Time
+	byUID   map[string]*entry
+}
+
+func newIndex() *index {
+	return &index{byUID: map[string]*entry{}}
+}
+
+// set replaces the span of uid.
+func (x *index) set(uid string, start, end time.Time) {
+	x.remove(uid)
+	e := &entry{uid: uid, start: start, end: end}
+	i := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].start.After(start) })
+	x.entries = append(x.entries, nil)
+	copy(x.entries[i+1:], x.entries[i:])
+	x.entries[i] = e
+	x.byUID[uid] = e
+	x.update(i)
+}
+
+// remove removes the span of uid.
+func (x *index) remove(uid string) {
+	e, ok := x.byUID[uid]
+	if !ok {
+		return
+	}
+	delete(x.byUID, uid)
+	for i, f := range x.entries {
+		if f == e {
+			x.entries = append(x.entries[:i], x.entries[i+1:]...)
+			x.update(i)
+			return
+		}
+	}
+}
+
+// update recomputes maxEnd from i.
+func (x *index) update(i int) {
+	x.maxEnd = x.maxEnd[:i]
+	for j := i; j < len(x.entries); j++ {
+		if e := x.entries[j]; j == 0 || e.end.After(x.maxEnd[j-1]) {
+			x.maxEnd = append(x.maxEnd, e.end)
+		} else {
+			x.maxEnd = append(x.maxEnd, x.maxEnd[j-1])
+		}
+	}
+}
+
+// overlapping returns the UIDs whose spans overlap [after, before],
+// bounds included, so that instances of no length at the bounds are
+// found.
+func (x *index) overlapping(after, before time.Time) []string {
+	n := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].start.After(before) })
+	var uids []string
+	for i := n - 1; i >= 0 && !x.maxEnd[i].Before(after); i-- {
+		if !x.entries[i].end.Before(after) {
+			uids = append(uids, x.entries[i].uid)
+		}
+	}
+	return uids
+}
@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package store
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoLock = errors.New("store: file locking is not supported on this system")
+
+// lockFile fails on systems without file locks, as a Dir there could not
+// be shared between processes.
+func lockFile(f *os.File, exclusive bool) error {
+	return errNoLock
+}
+
+func unlockFile(f *os.File) error {
+	return errNoLock
+}
@@ -0,0 +1,259 @@
+package store
+
+import (
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"sort"
+	"sync"
+	"time"
+)
+
+// set is the recurrence set of a UID.
+type set struct {
+	master    components.Component
+	overrides map[int64]components.Component
+}
+
+// list returns the components of s, master first and overrides by
+// RECURRENCE-ID.
+func (s *set) list() []components.Component {
+	var list []components.Component
+	if s.master != nil {
+		list = append(list, s.master)
+	}
+	ids := make([]int64, 0, len(s.overrides))
+	for id := range s.overrides {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		list = append(list, s.overrides[id])
+	}
+	return list
+}
+
+// span returns the span of s.
+func (s *set) span() (start, end time.Time, ok bool) {
+	overrides := s.list()
+	if s.master != nil {
+		overrides = overrides[1:]
+	}
+	return span(s.master, overrides)
+}
+
+// empty reports whether s has no components.
+func (s *set) empty() bool {
+	return s.master == nil && len(s.overrides) == 0
+}
+
+// Memory is a Store in memory.  It is safe for concurrent use.  Put
+// stores copies of the components and the methods return copies, so
+// that callers can change them freely.
+type Memory struct {
+	mu    sync.RWMutex
+	sets  map[string]*set
+	index *index
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{sets: map[string]*set{}, index: newIndex()}
+}
+
+var _ Store = (*Memory)(nil)
+
+// Put stores a copy of c.
+func (m *Memory) Put(c components.Component) error {
+	uid, id, err := key(c)
+	if err != nil {
+		return err
+	}
+	c = components.Clone(c)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sets[uid]
+	if !ok {
+		s = &set{overrides: map[int64]components.Component{}}
+		m.sets[uid] = s
+	}
+	if id.IsZero() {
+		s.master = c
+	} else {
+		s.overrides[id.Unix()] = c
+	}
+	m.reindex(uid, s)
+	return nil
+}
+
+// reindex updates the span of the set of uid.
+func (m *Memory) reindex(uid string, s *set) {
+	if s.empty() {
+		delete(m.sets, uid)
+		m.index.remove(uid)
+		return
+	}
+	start, end, ok := s.span()
+	if !ok {
+		// sets without time, such as to-dos without DTSTART and DUE, are
+		// found by Search only
+		m.index.remove(uid)
+		return
+	}
+	m.index.set(uid, start, end)
+}
+
+// Get returns copies of the recurrence set of uid.
+func (m *Memory) Get(uid string) ([]components.Component, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sets[uid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	list := s.list()
+	for i, c := range list {
+		list[i] = components.Clone(c)
+	}
+	return list, nil
+}
+
+// Instance returns a copy of a component of uid.
+func (m *Memory) Instance(uid string, recurrenceID time.Time) (components.Component, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.sets[uid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := s.master
+	if !recurrenceID.IsZero() {
+		c = s.overrides[recurrenceID.Unix()]
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	return components.Clone(c), nil
+}
+
+// Delete removes the recurrence set of uid.
+func (m *Memory) Delete(uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sets[uid]; !ok {
+		return ErrNotFound
+	}
+	m.reindex(uid, &set{})
+	return nil
+}
+
+// DeleteInstance removes a component of uid.
+func (m *Memory) DeleteInstance(uid string, recurrenceID time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sets[uid]
+	if !ok {
+		return ErrNotFound
+	}
+	if recurrenceID.IsZero() {
+		if s.master == nil {
+			return ErrNotFound
+		}
+		s.master = nil
+	} else {
+		if _, ok := s.overrides[recurrenceID.Unix()]; !ok {
+			return ErrNotFound
+		}
+		delete(s.overrides, recurrenceID.Unix())
+	}
+	m.reindex(uid, s)
+	return nil
+}
+
+// UIDs returns the UIDs of m, sorted.
+func (m *Memory) UIDs() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uids := make([]string, 0, len(m.sets))
+	for uid := range m.sets {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids, nil
+}
+
+// Search returns copies of the components that match q, by UID.
+func (m *Memory) Search(q *Query) ([]components.Component, error) {
+	if q.timeRange() {
+		occurrences, err := m.Occurrences(q)
+		if err != nil {
+			return nil, err
+		}
+		return distinct(occurrences), nil
+	}
+	uids, _ := m.UIDs()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []components.Component
+	for _, uid := range uids {
+		for _, c := range m.sets[uid].list() {
+			if q.Match(c) {
+				list = append(list, components.Clone(c))
+			}
+		}
+	}
+	return list, nil
+}
+
+// distinct returns the components of occurrences, each once, in the
+// order of their first occurrence.
+func distinct(occurrences []*objects.Occurrence) []components.Component {
+	seen := map[components.Component]bool{}
+	var list []components.Component
+	for _, o := range occurrences {
+		if !seen[o.Component] {
+			seen[o.Component] = true
+			list = append(list, o.Component)
+		}
+	}
+	return list
+}
+
+// Occurrences returns the occurrences of the components that match q in
+// its time range.  The components of the occurrences are copies, shared
+// by the occurrences of a component.
+func (m *Memory) Occurrences(q *Query) ([]*objects.Occurrence, error) {
+	if !q.timeRange() {
+		return nil, ErrNoRange
+	}
+	m.mu.RLock()
+	var comps []components.Component
+	for _, uid := range m.index.overlapping(q.After, q.Before) {
+		comps = append(comps, m.sets[uid].list()...)
+	}
+	occurrences, err := objects.Occurrences(comps, q.After, q.Before)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return filter(occurrences, q), nil
+}
+
+// filter returns the occurrences whose components match q, with copies
+// of the components.
+func filter(occurrences []*objects.Occurrence, q *Query) []*objects.Occurrence {
+	copies := map[components.Component]components.Component{}
+	list := occurrences[:0]
+	for _, o := range occurrences {
+		if !q.Match(o.Component) {
+			continue
+		}
+		c, ok := copies[o.Component]
+		if !ok {
+			c = components.Clone(o.Component)
+			copies[o.Component] = c
+		}
+		o.Component = c
+		list = append(list, o)
+	}
+	return list
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const storeCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20240601T080000Z
+DTSTART:20240603T090000Z
+DTEND:20240603T091500Z
+RRULE:FREQ=DAILY
+SUMMARY:Standup
+CATEGORIES:work
+END:VEVENT
+BEGIN:VEVENT
+UID:standup@example.com
+DTSTAMP:20240601T080000Z
+RECURRENCE-ID:20240605T090000Z
+DTSTART:20240605T100000Z
+DTEND:20240605T101500Z
+SUMMARY:Late standup
+CATEGORIES:work
+END:VEVENT
+BEGIN:VEVENT
+UID:course@example.com
+DTSTAMP:20240601T080000Z
+DTSTART:20240604T180000Z
+DTEND:20240604T200000Z
+RRULE:FREQ=WEEKLY;COUNT=3
+SUMMARY:Course
+CATEGORIES:school
+STATUS:TENTATIVE
+END:VEVENT
+BEGIN:VEVENT
+UID:dinner@example.com
+DTSTAMP:20240601T080000Z
+DTSTART:20240701T190000Z
+DTEND:20240701T210000Z
+SUMMARY:Dinner
+STATUS:CONFIRMED
+END:VEVENT
+BEGIN:VTODO
+UID:report@example.com
+DTSTAMP:20240601T080000Z
+DUE:20240606T170000Z
+SUMMARY:Report
+CATEGORIES:work
+END:VTODO
+BEGIN:VTODO
+UID:someday@example.com
+DTSTAMP:20240601T080000Z
+SUMMARY:Someday
+END:VTODO
+END:VCALENDAR
+`
+
+func newTestMemory(t *testing.T) *Memory {
+	t.Helper()
+	cal, err := objects.Decode(strings.NewReader(storeCalendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory()
+	for _, c := range cal.Components {
+		if err := m.Put(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func day(d int) time.Time {
+	return time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC)
+}
+
+func uidsOf(list []components.Component) string {
+	var uids []string
+	for _, c := range list {
+		uid, id, _ := key(c)
+		if !id.IsZero() {
+			uid += "/" + id.UTC().Format("0102")
+		}
+		uids = append(uids, uid)
+	}
+	return strings.Join(uids, " ")
+}
+
+func occurrencesOf(list []*objects.Occurrence) string {
+	var s []string
+	for _, o := range list {
+		uid, _, _ := key(o.Component)
+		s = append(s, fmt.Sprintf("%s@%s", strings.TrimSuffix(uid, "@example.com"), o.Start.UTC().Format("0102T15")))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestMemory_Occurrences(t *testing.T) {
+	m := newTestMemory(t)
+	tests := []struct {
+		q    Query
+		want string
+	}{
+		{Query{After: day(4), Before: day(6)}, "standup@0604T09 course@0604T18 standup@0605T10"},
+		{Query{After: day(17), Before: day(19)}, "standup@0617T09 standup@0618T09 course@0618T18"},
+		{Query{After: day(24), Before: day(26)}, "standup@0624T09 standup@0625T09"},
+		{Query{After: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), Before: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)}, "standup@0101T09"},
+		{Query{After: day(4), Before: day(7), Components: []string{"vtodo"}}, "report@0606T17"},
+		{Query{After: day(1), Before: day(12), Categories: []string{"school"}}, "course@0604T18 course@0611T18"},
+		{Query{After: day(8), Before: day(12), Status: []string{"TENTATIVE", ""}, Components: []string{"VEVENT"}}, "standup@0608T09 standup@0609T09 standup@0610T09 standup@0611T09 course@0611T18"},
+	}
+	for i, tt := range tests {
+		got, err := m.Occurrences(&tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// occurrences of the same start may come in any order
+		if g := occurrencesOf(got); !sameWords(g, tt.want) {
+			t.Errorf("#%d: got %q, want %q", i, g, tt.want)
+		}
+	}
+	got, _ := m.Occurrences(&Query{After: day(1), Before: day(12), Status: []string{"TENTATIVE"}})
+	if g := occurrencesOf(got); g != "course@0604T18 course@0611T18" {
+		t.Errorf("status filter: %q", g)
+	}
+	got, _ = m.Occurrences(&Query{After: day(1), Before: day(4), Status: []string{""}})
+	if g := occurrencesOf(got); g != "standup@0603T09" {
+		t.Errorf("empty status filter: %q", g)
+	}
+	if _, err := m.Occurrences(&Query{}); err != ErrNoRange {
+		t.Errorf("Occurrences without range: %v", err)
+	}
+}
+
+func sameWords(a, b string) bool {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa) != len(wb) {
+		return false
+	}
+	count := map[string]int{}
+	for _, w := range wa {
+		count[w]++
+	}
+	for _, w := range wb {
+		if count[w]--; count[w] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemory_Search(t *testing.T) {
+	m := newTestMemory(t)
+	got, err := m.Search(&Query{Categories: []string{"WORK"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := uidsOf(got); g != "report@example.com standup@example.com standup@example.com/0605" {
+		t.Errorf("categories: %q", g)
+	}
+	got, _ = m.Search(&Query{Components: []string{"VTODO"}})
+	if g := uidsOf(got); g != "report@example.com someday@example.com" {
+		t.Errorf("components: %q", g)
+	}
+	got, _ = m.Search(&Query{After: day(5), Before: day(6)})
+	if g := uidsOf(got); g != "standup@example.com/0605" {
+		t.Errorf("time range: %q", g)
+	}
+	got, _ = m.Search(nil)
+	if len(got) != 6 {
+		t.Errorf("Search(nil) returned %d components", len(got))
+	}
+}
+
+func TestMemory_GetDelete(t *testing.T) {
+	m := newTestMemory(t)
+	set, err := m.Get("standup@example.com")
+	if err != nil || uidsOf(set) != "standup@example.com standup@example.com/0605" {
+		t.Fatalf("Get: %q, %v", uidsOf(set), err)
+	}
+	// the store keeps its own copies
+	set[0].(*components.Event).Summary.Value.V = "Changed"
+	c, err := m.Instance("standup@example.com", time.Time{})
+	if err != nil || c.(*components.Event).Summary.Value.V != "Standup" {
+		t.Errorf("Instance returned a changed master: %v", err)
+	}
+	if _, err := m.Instance("standup@example.com", day(6).Add(9*time.Hour)); err != ErrNotFound {
+		t.Errorf("Instance of a missing override: %v", err)
+	}
+
+	if err := m.DeleteInstance("standup@example.com", day(5).Add(9*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := m.Occurrences(&Query{After: day(5), Before: day(6)})
+	if g := occurrencesOf(got); g != "standup@0605T09" {
+		t.Errorf("after DeleteInstance: %q", g)
+	}
+	if err := m.Delete("standup@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("standup@example.com"); err != ErrNotFound {
+		t.Errorf("Get after Delete: %v", err)
+	}
+	if err := m.Delete("standup@example.com"); err != ErrNotFound {
+		t.Errorf("second Delete: %v", err)
+	}
+	uids, _ := m.UIDs()
+	if strings.Join(uids, " ") != "course@example.com dinner@example.com report@example.com someday@example.com" {
+		t.Errorf("UIDs: %v", uids)
+	}
+	if err := m.Put(&components.Event{}); err != ErrNoUID {
+		t.Errorf("Put without UID: %v", err)
+	}
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	m := newTestMemory(t)
+	master, _ := m.Instance("dinner@example.com", time.Time{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			c := components.Clone(master).(*components.Event)
+			c.Uid.Value.V = fmt.Sprintf("dinner-%d@example.com", i)
+			if err := m.Put(c); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Occurrences(&Query{After: day(1), Before: day(30).AddDate(0, 0, 5)}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, _ := m.Search(&Query{After: day(30), Before: day(30).AddDate(0, 0, 2), Status: []string{"CONFIRMED"}})
+	if len(got) != 9 {
+		t.Errorf("%d dinners, want 9", len(got))
+	}
+}
@@ -0,0 +1,161 @@
+// Package store keeps calendar components indexed by UID and
+// RECURRENCE-ID, and answers queries for the components and the
+// occurrences of a time range.
+//
+// Memory keeps the components in memory.  Time-range queries look up an
+// interval index of the span of each recurrence set, so that only the
+// sets that may have instances in the range are expanded, and only
+// within the range.  Dir keeps them in a directory of iCalendar files,
+// one per UID, that several processes can share.
+package store
+
+import (
+	"errors"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/components"
+	"github.com/mmsuo/vcalender/objects/property/components/properties/relationship"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for UIDs and instances that are not
+	// stored.
+	ErrNotFound = errors.New("store: not found")
+	// ErrNoUID is returned by Put for components without UID.
+	ErrNoUID = errors.New("store: component without UID")
+	// ErrNoRange is returned by Occurrences for queries without a time
+	// range.
+	ErrNoRange = errors.New("store: query without time range")
+)
+
+// Store is a collection of events, to-dos, journal entries and free/busy
+// components.  The components of a UID form a recurrence set: the
+// master component, stored under the zero RECURRENCE-ID, and the
+// components that override its instances.
+type Store interface {
+	// Put stores c, replacing the component of its UID and
+	// RECURRENCE-ID.
+	Put(c components.Component) error
+	// Get returns the recurrence set of uid, master first.
+	Get(uid string) ([]components.Component, error)
+	// Instance returns the component of uid that overrides the instance
+	// recurrenceID, or the master component for the zero time.
+	Instance(uid string, recurrenceID time.Time) (components.Component, error)
+	// Delete removes the recurrence set of uid.
+	Delete(uid string) error
+	// DeleteInstance removes the component of uid that overrides the
+	// instance recurrenceID, or the master component for the zero
+	// time.
+	DeleteInstance(uid string, recurrenceID time.Time) error
+	// UIDs returns the UIDs of the store, sorted.
+	UIDs() ([]string, error)
+	// Search returns the components that match q.  With a time range,
+	// these are the components that have occurrences in it.
+	Search(q *Query) ([]components.Component, error)
+	// Occurrences returns the occurrences of the time range of q of the
+	// components that match q, sorted by start.
+	Occurrences(q *Query) ([]*objects.Occurrence, error)
+}
+
+// Query selects components.  Empty fields select all components.
+type Query struct {
+	// After and Before limit the query to the components that have
+	// occurrences in [After, Before).
+	After, Before time.Time
+	// Components are the names of the components to select, such as
+	// VEVENT and VTODO.
+	Components []string
+	// Categories select the components with any of these categories.
+	Categories []string
+	// Status selects the components with any of these STATUS values.
+	// The empty string selects the components without STATUS.
+	Status []string
+}
+
+func (q *Query) timeRange() bool {
+	return q != nil && !q.Before.IsZero()
+}
+
+// Match reports whether c matches the filters of q, not considering its
+// time range.
+func (q *Query) Match(c components.Component) bool {
+	if q == nil {
+		return true
+	}
+	name, categories, status := describe(c)
+	if len(q.Components) > 0 && !anyFold(q.Components, name) {
+		return false
+	}
+	if len(q.Status) > 0 && !anyFold(q.Status, status) {
+		return false
+	}
+	if len(q.Categories) > 0 {
+		for _, c := range categories {
+			if anyFold(q.Categories, c) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func anyFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe returns the name, the categories and the status of c.
+func describe(c components.Component) (name string, categories []string, status string) {
+	switch v := c.(type) {
+	case *components.Event:
+		name, categories = "VEVENT", v.CategoryList()
+		if v.Status != nil && v.Status.Value != nil {
+			status = v.Status.Value.V
+		}
+	case *components.Todo:
+		name, categories = "VTODO", v.CategoryList()
+		if v.Status != nil && v.Status.Value != nil {
+			status = v.Status.Value.V
+		}
+	case *components.Journal:
+		name, categories = "VJOURNAL", v.CategoryList()
+		if v.Status != nil && v.Status.Value != nil {
+			status = v.Status.Value.V
+		}
+	case *components.FreeBusy:
+		name = "VFREEBUSY"
+	}
+	return name, categories, status
+}
+
+// key returns the UID and the RECURRENCE-ID of c.
+func key(c components.Component) (uid string, recurrenceID time.Time, err error) {
+	var u *relationship.Uid
+	var r *relationship.RecurrenceId
+	switch v := c.(type) {
+	case *components.Event:
+		u, r = v.Uid, v.RecurId
+	case *components.Todo:
+		u, r = v.Uid, v.RecurId
+	case *components.Journal:
+		u, r = v.Uid, v.RecurId
+	case *components.FreeBusy:
+		u = v.Uid
+	default:
+		return "", time.Time{}, errors.New("store: only events, to-dos, journal entries and free/busy components can be stored")
+	}
+	if u == nil || u.Value == nil || u.Value.V == "" {
+		return "", time.Time{}, ErrNoUID
+	}
+	if r != nil {
+		recurrenceID, _ = objects.TimeOf(r.Parameters, r.Value)
+	}
+	return types.UnescapeText(u.Value.V), recurrenceID, nil
+}
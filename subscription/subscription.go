@@ -0,0 +1,353 @@
+// Package subscription fetches calendars published over HTTP, such as
+// webcal:// feeds of holidays, and keeps them up to date.
+//
+// A Subscription fetches its URL with conditional requests, refreshes it
+// as often as the feed asks with REFRESH-INTERVAL, keeps the last good
+// copy when a fetch fails and reports the events that were added,
+// changed or removed by each fetch.  Feeds with properties that do not
+// follow RFC 5545 are kept with what could be read of them.
+package subscription
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/mmsuo/vcalender/objects"
+	"github.com/mmsuo/vcalender/objects/property/types"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//   RFC 7986 5.7.  REFRESH-INTERVAL Property
+//
+//   Purpose:  This property specifies a suggested minimum interval for
+//      polling for changes of the calendar data from the original source
+//      of that data.
+//
+//   Description:  This property specifies a positive duration that gives
+//      a suggested minimum polling interval for checking for updates to
+//      the calendar data.  The value of this property SHOULD be used by
+//      calendar user agents to limit the polling interval for calendar
+//      data updates to the minimum interval specified.
+//
+//   Example:  The following is an example of this property:
+//
+//       REFRESH-INTERVAL;VALUE=DURATION:P1W
+
+// DefaultInterval is the refresh interval of feeds that do not suggest
+// one.
+const DefaultInterval = 24 * time.Hour
+
+// maxSize limits the size of feeds.
+const maxSize = 50 << 20
+
+// Error is the answer of a server that is neither a calendar nor Not
+// Modified.
+type Error struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("subscription: GET %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Changes lists the UIDs of the components that a fetch added, changed
+// or removed, each sorted.
+type Changes struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// Empty reports whether there are no changes.
+func (c *Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+// Subscription is a calendar fetched from a URL.  It is safe for
+// concurrent use.
+type Subscription struct {
+	// URL is the http, https or webcal URL of the calendar.  webcal is
+	// fetched over https.
+	URL  string
+	HTTP *http.Client
+	// Now returns the current time, for the schedule of refreshes.
+	Now func() time.Time
+	// Interval is the refresh interval of feeds that do not suggest one.
+	Interval time.Duration
+	// MinInterval, when set, bounds the intervals that feeds suggest.
+	MinInterval time.Duration
+
+	// fetching serializes the fetches, which run without mu so that the
+	// accessors do not wait for the network.
+	fetching sync.Mutex
+	mu       sync.Mutex
+	state
+	fetched time.Time
+	next    time.Time
+	err     error
+}
+
+// state is what a successful fetch leaves.
+type state struct {
+	calendar     *objects.Calendar
+	components   map[string]string
+	etag         string
+	lastModified string
+}
+
+// New returns a Subscription of rawurl using hc, or http.DefaultClient
+// when hc is nil.
+func New(rawurl string, hc *http.Client) *Subscription {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Subscription{URL: rawurl, HTTP: hc, Now: time.Now, Interval: DefaultInterval}
+}
+
+// Calendar returns the last calendar fetched successfully, or nil.
+func (s *Subscription) Calendar() *objects.Calendar {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calendar
+}
+
+// Fetched returns the time of the last successful fetch.
+func (s *Subscription) Fetched() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetched
+}
+
+// Next returns the time the calendar is due to be refreshed.
+func (s *Subscription) Next() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// Err returns the error of the last fetch, or nil.  An objects.ErrorList
+// lists the parts of the feed left out of the calendar it fetched.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Refresh fetches the calendar when it is due, and returns the changes.
+// It returns empty changes when it is not due, which includes the
+// callers that waited for a fetch running concurrently.
+func (s *Subscription) Refresh(ctx context.Context) (*Changes, error) {
+	s.fetching.Lock()
+	defer s.fetching.Unlock()
+	s.mu.Lock()
+	due := !s.Now().Before(s.next)
+	s.mu.Unlock()
+	if !due {
+		return &Changes{}, nil
+	}
+	return s.update(ctx)
+}
+
+// Fetch fetches the calendar now, and returns the changes since the last
+// successful fetch.  The first fetch adds all components.  When the
+// fetch fails, the last good copy is kept and the next refresh is
+// scheduled as usual.  When the feed could only be read in part, the
+// calendar keeps what could be read and the error is the
+// objects.ErrorList of the rest.
+func (s *Subscription) Fetch(ctx context.Context) (*Changes, error) {
+	s.fetching.Lock()
+	defer s.fetching.Unlock()
+	return s.update(ctx)
+}
+
+// update fetches the calendar and records the result.  The caller holds
+// s.fetching.
+func (s *Subscription) update(ctx context.Context) (*Changes, error) {
+	s.mu.Lock()
+	last := s.state
+	s.mu.Unlock()
+	f, changes, err := s.fetch(ctx, &last)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if f != nil {
+		s.state = *f
+		s.fetched = s.Now()
+	}
+	interval := s.Interval
+	if s.calendar != nil {
+		if d, ok := refreshInterval(s.calendar); ok {
+			interval = d
+		}
+	}
+	if interval < s.MinInterval {
+		interval = s.MinInterval
+	}
+	s.next = s.Now().Add(interval)
+	return changes, err
+}
+
+// location returns the URL to fetch.
+func (s *Subscription) location() (string, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("subscription: unsupported URL scheme %q", u.Scheme)
+	}
+	return u.String(), nil
+}
+
+// fetch fetches the calendar, conditionally when last holds a calendar,
+// and returns the new state.  The state comes with the decoding errors
+// of a feed that could be read in part.
+func (s *Subscription) fetch(ctx context.Context, last *state) (*state, *Changes, error) {
+	loc, err := s.location()
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/calendar")
+	if last.calendar != nil {
+		if last.etag != "" {
+			req.Header.Set("If-None-Match", last.etag)
+		}
+		if last.lastModified != "" {
+			req.Header.Set("If-Modified-Since", last.lastModified)
+		}
+	}
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && last.calendar != nil:
+		return last, &Changes{}, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, &Error{URL: loc, StatusCode: resp.StatusCode}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxSize {
+		return nil, nil, fmt.Errorf("subscription: %s is larger than %d bytes", loc, maxSize)
+	}
+	raw, err := objects.ReadRawComponents(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(raw) != 1 || raw[0].Name != "VCALENDAR" {
+		return nil, nil, fmt.Errorf("subscription: %s is not a single VCALENDAR", loc)
+	}
+	// public feeds often have minor errors, what could be read is kept
+	cal, err := objects.DecodeRaw(raw[0])
+	if cal == nil {
+		return nil, nil, err
+	}
+	f := &state{
+		calendar:     cal,
+		components:   components(raw[0]),
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+	return f, diff(last.components, f.components), err
+}
+
+// refreshInterval returns the REFRESH-INTERVAL of cal, or else the
+// X-PUBLISHED-TTL that some publishers write instead.
+func refreshInterval(cal *objects.Calendar) (time.Duration, bool) {
+	for _, name := range []string{"REFRESH-INTERVAL", "X-PUBLISHED-TTL"} {
+		for _, p := range cal.IanaProp {
+			if v, ok := durationOf(p.Name, name, p.Values); ok {
+				return v, true
+			}
+		}
+		for _, p := range cal.Xprop {
+			if v, ok := durationOf(p.Name, name, p.Values); ok {
+				return v, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func durationOf(got, want string, values []types.Value) (time.Duration, bool) {
+	if !strings.EqualFold(got, want) || len(values) == 0 {
+		return 0, false
+	}
+	s := &strings.Builder{}
+	if err := values[0].WriteValueToStrBuilder(s); err != nil {
+		return 0, false
+	}
+	d, err := types.ParseDuration(s.String())
+	if err != nil || d.Duration() <= 0 {
+		return 0, false
+	}
+	return d.Duration(), true
+}
+
+// components returns the components of cal other than VTIMEZONE by UID,
+// written without their DTSTAMP, which feeds generated on request
+// change on every fetch.
+func components(cal *objects.RawComponent) map[string]string {
+	comps := map[string]string{}
+	for _, c := range cal.Components {
+		if c.Name == "VTIMEZONE" {
+			continue
+		}
+		uid := ""
+		if l := c.Property("UID"); l != nil {
+			uid = l.Value
+		}
+		cc := *c
+		cc.Properties = nil
+		for _, l := range c.Properties {
+			if l.Name != "DTSTAMP" {
+				cc.Properties = append(cc.Properties, l)
+			}
+		}
+		comps[uid] += cc.String()
+	}
+	return comps
+}
+
+// diff compares the components of two fetches.
+func diff(before, after map[string]string) *Changes {
+	changes := &Changes{}
+	for uid, c := range after {
+		if old, ok := before[uid]; !ok {
+			changes.Added = append(changes.Added, uid)
+		} else if old != c {
+			changes.Changed = append(changes.Changed, uid)
+		}
+	}
+	for uid := range before {
+		if _, ok := after[uid]; !ok {
+			changes.Removed = append(changes.Removed, uid)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Removed)
+	return changes
+}
@@ -0,0 +1,215 @@
+package subscription
+
+import (
+	"context"
+	"github.com/mmsuo/vcalender/objects"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const feed = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//EN
+REFRESH-INTERVAL;VALUE=DURATION:PT12H
+BEGIN:VEVENT
+UID:new-year@example.com
+DTSTAMP:%s
+DTSTART;VALUE=DATE:20250101
+SUMMARY:New Year
+END:VEVENT
+BEGIN:VEVENT
+UID:%s
+DTSTAMP:%s
+DTSTART;VALUE=DATE:20250501
+SUMMARY:%s
+END:VEVENT
+END:VCALENDAR
+`
+
+// publisher serves a feed with an ETag.  set fills in the placeholders
+// of feed.
+type publisher struct {
+	mu       sync.Mutex
+	body     string
+	etag     string
+	status   int
+	requests int
+	notMod   int
+}
+
+func (p *publisher) set(stamp, uid, summary string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	parts := []string{stamp, uid, stamp, summary}
+	b := &strings.Builder{}
+	for i, s := range strings.Split(feed, "%s") {
+		b.WriteString(s)
+		if i < len(parts) {
+			b.WriteString(parts[i])
+		}
+	}
+	p.body = b.String()
+	p.etag = `"` + uid + summary + `"`
+}
+
+func (p *publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	if p.status != 0 {
+		w.WriteHeader(p.status)
+		return
+	}
+	if r.Header.Get("If-None-Match") == p.etag {
+		p.notMod++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", p.etag)
+	w.Header().Set("Content-Type", "text/calendar")
+	w.Write([]byte(p.body))
+}
+
+func TestSubscription(t *testing.T) {
+	ctx := context.Background()
+	p := &publisher{}
+	p.set("20240101T000000Z", "labour-day@example.com", "Labour Day")
+	server := httptest.NewTLSServer(p)
+	defer server.Close()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := New(strings.Replace(server.URL, "https://", "webcal://", 1), server.Client())
+	s.Now = func() time.Time { return now }
+
+	changes, err := s.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(changes.Added, " ") != "labour-day@example.com new-year@example.com" || len(changes.Changed)+len(changes.Removed) != 0 {
+		t.Errorf("first fetch: %+v", changes)
+	}
+	if cal := s.Calendar(); cal == nil || len(cal.Components) != 2 {
+		t.Fatalf("Calendar: %+v", cal)
+	}
+	if want := now.Add(12 * time.Hour); !s.Next().Equal(want) {
+		t.Errorf("Next: %v, want %v", s.Next(), want)
+	}
+
+	// not due yet
+	now = now.Add(time.Hour)
+	if changes, err := s.Refresh(ctx); err != nil || !changes.Empty() || p.requests != 1 {
+		t.Errorf("Refresh before it is due: %+v %v, %d requests", changes, err, p.requests)
+	}
+
+	// due, not modified
+	now = now.Add(12 * time.Hour)
+	if changes, err := s.Refresh(ctx); err != nil || !changes.Empty() || p.notMod != 1 || !s.Fetched().Equal(now) {
+		t.Errorf("Refresh of an unmodified feed: %+v %v", changes, err)
+	}
+
+	// a new time stamp alone is no change
+	p.set("20240602T000000Z", "labour-day@example.com", "Labour Day")
+	p.etag = `"other"`
+	if changes, err := s.Fetch(ctx); err != nil || !changes.Empty() {
+		t.Errorf("Fetch with new time stamps: %+v %v", changes, err)
+	}
+
+	p.set("20240603T000000Z", "labour-day@example.com", "May Day")
+	if changes, err := s.Fetch(ctx); err != nil || strings.Join(changes.Changed, " ") != "labour-day@example.com" || len(changes.Added)+len(changes.Removed) != 0 {
+		t.Errorf("Fetch of a changed event: %+v %v", changes, err)
+	}
+	p.set("20240604T000000Z", "whit-monday@example.com", "Whit Monday")
+	changes, err = s.Fetch(ctx)
+	if err != nil || strings.Join(changes.Added, " ") != "whit-monday@example.com" || strings.Join(changes.Removed, " ") != "labour-day@example.com" {
+		t.Errorf("Fetch of a replaced event: %+v %v", changes, err)
+	}
+
+	// failures keep the last good copy
+	good := s.Calendar()
+	p.status = http.StatusInternalServerError
+	if _, err := s.Fetch(ctx); err == nil || s.Err() == nil {
+		t.Errorf("Fetch of a failing feed succeeded")
+	}
+	if e, ok := s.Err().(*Error); !ok || e.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Err: %v", s.Err())
+	}
+	p.status = 0
+	p.body = "BEGIN:VCALENDAR\nBEGIN:VEVENT\n"
+	p.etag = `"broken"`
+	if _, err := s.Fetch(ctx); err == nil {
+		t.Errorf("Fetch of a broken feed succeeded")
+	}
+	if s.Calendar() != good {
+		t.Errorf("the last good copy was dropped")
+	}
+	// a feed with an invalid property keeps what could be read
+	p.set("20240605T000000Z", "whit-monday@example.com", "Whit Monday")
+	p.body = strings.Replace(p.body, "DTSTART;VALUE=DATE:20250101", "DTSTART;VALUE=DATE:2025-01-01", 1)
+	p.etag = `"partial"`
+	changes, err = s.Fetch(ctx)
+	if _, ok := err.(objects.ErrorList); !ok || s.Err() == nil {
+		t.Errorf("Fetch of a partly invalid feed: %v", err)
+	}
+	if cal := s.Calendar(); cal == good || cal == nil || len(cal.Components) != 2 || changes == nil {
+		t.Errorf("the partly invalid feed was not kept: %+v", cal)
+	}
+	p.set("20240605T000000Z", "whit-monday@example.com", "Whit Monday")
+	p.etag = `"fixed"`
+	if changes, err := s.Fetch(ctx); err != nil || strings.Join(changes.Changed, " ") != "new-year@example.com" || s.Err() != nil {
+		t.Errorf("Fetch after the failures: %+v %v", changes, err)
+	}
+}
+
+func TestSubscription_Concurrent(t *testing.T) {
+	p := &publisher{}
+	p.set("20240101T000000Z", "labour-day@example.com", "Labour Day")
+	release := make(chan struct{})
+	arrived := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		p.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	s := New(server.URL, server.Client())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Refresh(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	<-arrived
+
+	// the accessors do not wait for the request
+	done := make(chan struct{})
+	go func() {
+		s.Calendar()
+		s.Err()
+		s.Next()
+		s.Fetched()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("the accessors waited for the fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	if p.requests != 1 {
+		t.Errorf("%d requests for concurrent refreshes, want 1", p.requests)
+	}
+	if s.Calendar() == nil {
+		t.Errorf("no calendar after the refreshes")
+	}
+}